@@ -23,29 +23,47 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net"
+	"net/http"
+	"time"
 
 	"github.com/rightscale/rsc/cm15"
 	"github.com/rightscale/rsc/cm16"
 	"github.com/rightscale/rsc/rsapi"
+
+	"github.com/rightscale/right_st/rightscript"
 )
 
 type Account struct {
-	Host         string
-	Id           int
-	RefreshToken string `mapstructure:"refresh_token" yaml:"refresh_token"`
-	client15     *cm15.API
-	client16     *cm16.API
+	Host         string `json:"host"`
+	Id           int    `json:"id"`
+	RefreshToken string `mapstructure:"refresh_token" yaml:"refresh_token" json:"refresh_token"`
+	// AccessToken is an OAuth2 access token obtained elsewhere which is used as is instead of exchanging the refresh
+	// token. It cannot be refreshed so AccessTokenExpiresAt, an RFC 3339 timestamp, may say when it stops working.
+	AccessToken          string `mapstructure:"access_token" yaml:"access_token,omitempty" json:"access_token,omitempty"`
+	AccessTokenExpiresAt string `mapstructure:"access_token_expires_at" yaml:"access_token_expires_at,omitempty" json:"access_token_expires_at,omitempty"`
+	client15             *cm15.API
+	client16             *cm16.API
 }
 
+// Version of the RightScale API used by default. The cm15 client always uses this version for its own typed requests;
+// the --api-version flag overrides it for the requests right_st builds itself with BuildHTTPRequest.
+const defaultAPIVersion = rightscript.DefaultAPIVersion
+
 func (account *Account) Client15() (*cm15.API, error) {
 	if account.client15 == nil {
 		if err := account.validate(); err != nil {
 			return nil, err
 		}
-		auth := rsapi.NewOAuthAuthenticator(account.RefreshToken, account.Id)
+		auth, err := account.authenticator()
+		if err != nil {
+			return nil, err
+		}
 		account.client15 = cm15.New(account.Host, auth)
+		usePooledClient(account.client15.API)
 	}
 	return account.client15, nil
 }
@@ -55,14 +73,95 @@ func (account *Account) Client16() (*cm16.API, error) {
 		if err := account.validate(); err != nil {
 			return nil, err
 		}
-		auth := rsapi.NewOAuthAuthenticator(account.RefreshToken, account.Id)
+		auth, err := account.authenticator()
+		if err != nil {
+			return nil, err
+		}
 		account.client16 = cm16.New(account.Host, auth)
+		usePooledClient(account.client16.API)
 	}
 	return account.client16, nil
 }
 
+// authenticator signs requests with the access token when there is one, otherwise with access tokens from exchanging
+// the refresh token.
+func (account *Account) authenticator() (rsapi.Authenticator, error) {
+	if account.AccessToken == "" {
+		if BaseURL != nil {
+			return tokenAuthenticator{&baseURLAuthenticator{refreshToken: account.RefreshToken, accountId: account.Id}}, nil
+		}
+		return tokenAuthenticator{rsapi.NewOAuthAuthenticator(account.RefreshToken, account.Id)}, nil
+	}
+	expired, err := account.AccessTokenExpired(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if expired {
+		return nil, &tokenError{fmt.Errorf("access token expired at %s", account.AccessTokenExpiresAt)}
+	}
+	return tokenAuthenticator{rsapi.NewTokenAuthenticator(account.AccessToken, account.Id)}, nil
+}
+
+// AccessTokenExpired reports whether the access token has expired by now according to AccessTokenExpiresAt. An access
+// token without an expiry is never considered expired here, the API will reject it once it has.
+func (account *Account) AccessTokenExpired(now time.Time) (bool, error) {
+	if account.AccessTokenExpiresAt == "" {
+		return false, nil
+	}
+	expiresAt, err := time.Parse(time.RFC3339, account.AccessTokenExpiresAt)
+	if err != nil {
+		return false, fmt.Errorf("Invalid access token expiry '%s': must be an RFC 3339 timestamp (e.g. 2006-01-02T15:04:05Z)",
+			account.AccessTokenExpiresAt)
+	}
+	return !now.Before(expiresAt), nil
+}
+
+// TokenFingerprint returns a short digest of the token used to authenticate, the access token if there is one or else
+// the refresh token, which can be used to tell tokens apart without revealing the token itself.
+func (account *Account) TokenFingerprint() string {
+	token := account.RefreshToken
+	if account.AccessToken != "" {
+		token = account.AccessToken
+	}
+	digest := sha256.Sum256([]byte(token))
+	return "sha256:" + hex.EncodeToString(digest[:])[:16]
+}
+
+// tokenAuthenticator wraps the OAuth authenticator so that an expired or revoked refresh token fails with an error
+// explaining how to fix it rather than the raw error from the OAuth token exchange.
+type tokenAuthenticator struct {
+	rsapi.Authenticator
+}
+
+func (auth tokenAuthenticator) Sign(r *http.Request) error {
+	err := auth.Authenticator.Sign(r)
+	if err != nil {
+		switch rightscript.ResponseStatusCode(err) {
+		case http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden:
+			return &tokenError{err}
+		}
+	}
+	return err
+}
+
+// tokenError is returned when the API rejects the refresh or access token of the configured account.
+type tokenError struct {
+	err error
+}
+
+func (e *tokenError) Error() string {
+	return Config.TokenHelp() + " (" + e.err.Error() + ")"
+}
+
 func (account *Account) validate() error {
-	if _, err := net.LookupIP(account.Host); err != nil {
+	host := account.Host
+	if BaseURL != nil {
+		host = BaseURL.Host
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if _, err := net.LookupIP(host); err != nil {
 		return fmt.Errorf("Invalid host name for account (host: %s, id: %d): %s", account.Host, account.Id, err)
 	}
 	return nil
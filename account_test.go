@@ -23,6 +23,8 @@
 package main_test
 
 import (
+	"time"
+
 	. "github.com/rightscale/right_st"
 
 	. "github.com/onsi/ginkgo"
@@ -56,6 +58,34 @@ var _ = Describe("Account", func() {
 		Expect(secondClient).To(BeIdenticalTo(firstClient))
 	})
 
+	Context("With an access token", func() {
+		now := time.Date(2016, 7, 4, 12, 0, 0, 0, time.UTC)
+
+		It("Never expires without an expiry", func() {
+			accessTokenAccount := Account{Id: 54321, Host: "localhost", AccessToken: "abc"}
+			Expect(accessTokenAccount.AccessTokenExpired(now)).To(BeFalse())
+		})
+
+		It("Expires at the expiry", func() {
+			accessTokenAccount := Account{Id: 54321, Host: "localhost", AccessToken: "abc", AccessTokenExpiresAt: "2016-07-04T13:00:00Z"}
+			Expect(accessTokenAccount.AccessTokenExpired(now)).To(BeFalse())
+			Expect(accessTokenAccount.AccessTokenExpired(now.Add(time.Hour))).To(BeTrue())
+		})
+
+		It("Returns an error for an invalid expiry", func() {
+			accessTokenAccount := Account{Id: 54321, Host: "localhost", AccessToken: "abc", AccessTokenExpiresAt: "soon"}
+			_, err := accessTokenAccount.AccessTokenExpired(now)
+			Expect(err).To(MatchError(HavePrefix("Invalid access token expiry 'soon'")))
+		})
+
+		It("Fails to get a client once expired", func() {
+			accessTokenAccount := Account{Id: 54321, Host: "localhost", AccessToken: "abc", AccessTokenExpiresAt: "2016-07-04T13:00:00Z"}
+			client, err := accessTokenAccount.Client15()
+			Expect(err).To(MatchError(ContainSubstring("access token expired at 2016-07-04T13:00:00Z")))
+			Expect(client).To(BeNil())
+		})
+	})
+
 	Context("With an invalid host", func() {
 		var invalidHostAccount = Account{
 			Id:           54321,
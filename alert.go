@@ -7,6 +7,8 @@ import (
 
 	"github.com/rightscale/rsc/cm15"
 	"github.com/rightscale/rsc/rsapi"
+
+	"github.com/rightscale/right_st/rightscript"
 )
 
 type Alert struct {
@@ -112,7 +114,7 @@ func validateAlert(alert *Alert) error {
 func downloadAlerts(st *cm15.ServerTemplate) ([]*Alert, error) {
 	client, _ := Config.Account.Client15()
 
-	alertsLocator := client.AlertSpecLocator(getLink(st.Links, "alert_specs"))
+	alertsLocator := client.AlertSpecLocator(rightscript.Link(st.Links, "alert_specs"))
 	alertSpecs, err := alertsLocator.Index(rsapi.APIParams{})
 	if err != nil {
 		return nil, fmt.Errorf("Could not find Alerts with href %s: %s", alertsLocator.Href, err.Error())
@@ -121,7 +123,7 @@ func downloadAlerts(st *cm15.ServerTemplate) ([]*Alert, error) {
 	for i, alertSpec := range alertSpecs {
 		alerts[i] = &Alert{
 			Name:        alertSpec.Name,
-			Description: removeCarriageReturns(alertSpec.Description),
+			Description: rightscript.RemoveCarriageReturns(alertSpec.Description),
 			Clause:      printAlertClause(*alertSpec),
 		}
 	}
@@ -149,9 +151,9 @@ func uploadAlerts(stDef *ServerTemplate) error {
 		existingAlert, ok := alertLookup[alert.Name]
 		if ok { // update
 			if alert.Clause != printAlertClause(*existingAlert) || alert.Description != existingAlert.Description {
-				alertsUpdateLocator := client.AlertSpecLocator(getLink(existingAlert.Links, "self"))
+				alertsUpdateLocator := client.AlertSpecLocator(rightscript.Link(existingAlert.Links, "self"))
 
-				fmt.Printf("  Updating Alert %s\n", alert.Name)
+				fmt.Fprintf(Stdout, "  Updating Alert %s\n", alert.Name)
 				params := cm15.AlertSpecParam2{
 					Condition:      parsedAlert.Condition,
 					Description:    alert.Description,
@@ -170,7 +172,7 @@ func uploadAlerts(stDef *ServerTemplate) error {
 				}
 			}
 		} else { // new alert
-			fmt.Printf("  Adding Alert %s\n", alert.Name)
+			fmt.Fprintf(Stdout, "  Adding Alert %s\n", alert.Name)
 			params := cm15.AlertSpecParam{
 				Condition:      parsedAlert.Condition,
 				Description:    alert.Description,
@@ -191,7 +193,7 @@ func uploadAlerts(stDef *ServerTemplate) error {
 	}
 	for _, alert := range existingAlerts {
 		if !seenAlert[alert.Name] {
-			fmt.Printf("  Removing alert %s\n", alert.Name)
+			fmt.Fprintf(Stdout, "  Removing alert %s\n", alert.Name)
 			err := alert.Locator(client).Destroy()
 			if err != nil {
 				return fmt.Errorf("Could not destroy Alert %s: %s", alert.Name, err.Error())
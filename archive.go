@@ -0,0 +1,110 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IsArchive returns whether a path given to rightscript upload is a tar (optionally gzipped) or zip archive of scripts
+// and their attachments rather than a script or directory.
+func IsArchive(file string) bool {
+	lower := strings.ToLower(file)
+	for _, ext := range []string{".tar", ".tar.gz", ".tgz", ".zip"} {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtractArchive streams the entries of a tar, gzipped tar, or zip archive into dir keeping the directory structure of
+// the archive, so attachments referenced by scripts in the archive are found relative to them just like on disk.
+// Entries which would end up outside of dir are rejected.
+func ExtractArchive(archive, dir string) error {
+	if strings.HasSuffix(strings.ToLower(archive), ".zip") {
+		return extractZip(archive, dir)
+	}
+
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var reader io.Reader = f
+	if lower := strings.ToLower(archive); strings.HasSuffix(lower, ".gz") || strings.HasSuffix(lower, ".tgz") {
+		gzipReader, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("%s: %s", archive, err.Error())
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s: %s", archive, err.Error())
+		}
+		if err := extractEntry(archive, dir, header.Name, header.FileInfo(), tarReader); err != nil {
+			return err
+		}
+	}
+}
+
+func extractZip(archive, dir string) error {
+	zipReader, err := zip.OpenReader(archive)
+	if err != nil {
+		return fmt.Errorf("%s: %s", archive, err.Error())
+	}
+	defer zipReader.Close()
+
+	for _, file := range zipReader.File {
+		contents, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("%s: %s: %s", archive, file.Name, err.Error())
+		}
+		err = extractEntry(archive, dir, file.Name, file.FileInfo(), contents)
+		contents.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractEntry writes a single archive entry below dir, only directories and regular files are extracted.
+func extractEntry(archive, dir, name string, info os.FileInfo, contents io.Reader) error {
+	target := filepath.Join(dir, filepath.FromSlash(name))
+	if target != dir && !strings.HasPrefix(target, dir+string(os.PathSeparator)) {
+		return fmt.Errorf("%s: entry %s is outside of the archive", archive, name)
+	}
+	if info.IsDir() {
+		return os.MkdirAll(target, 0755)
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm()|0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, contents)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %s: %s", archive, name, err.Error())
+	}
+	// keep the modification time from the archive so --since works the same as for files on disk
+	return os.Chtimes(target, info.ModTime(), info.ModTime())
+}
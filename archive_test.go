@@ -0,0 +1,135 @@
+package main_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/rightscale/right_st"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Archive", func() {
+	var (
+		tempDir string
+		destDir string
+		entries map[string]string
+		modTime time.Time
+	)
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = ioutil.TempDir("", "archive")
+		if err != nil {
+			panic(err)
+		}
+		destDir = filepath.Join(tempDir, "dest")
+		if err := os.Mkdir(destDir, 0755); err != nil {
+			panic(err)
+		}
+		entries = map[string]string{
+			"scripts/script.sh":                  "#!/bin/bash\necho hi\n",
+			"scripts/attachments/attachment.txt": "attached\n",
+		}
+		modTime = time.Date(2016, 7, 4, 12, 0, 0, 0, time.UTC)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tempDir)
+	})
+
+	writeTar := func(name string, compress bool) string {
+		file := filepath.Join(tempDir, name)
+		f, err := os.Create(file)
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+		var tarWriter *tar.Writer
+		if compress {
+			gzipWriter := gzip.NewWriter(f)
+			defer gzipWriter.Close()
+			tarWriter = tar.NewWriter(gzipWriter)
+		} else {
+			tarWriter = tar.NewWriter(f)
+		}
+		defer tarWriter.Close()
+		for name, content := range entries {
+			header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), ModTime: modTime}
+			if err := tarWriter.WriteHeader(header); err != nil {
+				panic(err)
+			}
+			if _, err := tarWriter.Write([]byte(content)); err != nil {
+				panic(err)
+			}
+		}
+		return file
+	}
+
+	expectExtracted := func() {
+		for name, content := range entries {
+			file := filepath.Join(destDir, filepath.FromSlash(name))
+			Expect(ioutil.ReadFile(file)).To(BeEquivalentTo(content))
+			info, err := os.Stat(file)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.ModTime().Equal(modTime)).To(BeTrue())
+		}
+	}
+
+	It("Detects archives by extension", func() {
+		Expect(IsArchive("bundle.tar.gz")).To(BeTrue())
+		Expect(IsArchive("bundle.TGZ")).To(BeTrue())
+		Expect(IsArchive("bundle.tar")).To(BeTrue())
+		Expect(IsArchive("bundle.zip")).To(BeTrue())
+		Expect(IsArchive("script.sh")).To(BeFalse())
+	})
+
+	It("Extracts a gzipped tar file", func() {
+		Expect(ExtractArchive(writeTar("bundle.tar.gz", true), destDir)).To(Succeed())
+		expectExtracted()
+	})
+
+	It("Extracts a tar file", func() {
+		Expect(ExtractArchive(writeTar("bundle.tar", false), destDir)).To(Succeed())
+		expectExtracted()
+	})
+
+	It("Extracts a zip file", func() {
+		file := filepath.Join(tempDir, "bundle.zip")
+		f, err := os.Create(file)
+		if err != nil {
+			panic(err)
+		}
+		zipWriter := zip.NewWriter(f)
+		for name, content := range entries {
+			header := &zip.FileHeader{Name: name, Method: zip.Deflate}
+			header.SetModTime(modTime)
+			header.SetMode(0644)
+			w, err := zipWriter.CreateHeader(header)
+			if err != nil {
+				panic(err)
+			}
+			if _, err := w.Write([]byte(content)); err != nil {
+				panic(err)
+			}
+		}
+		zipWriter.Close()
+		f.Close()
+
+		Expect(ExtractArchive(file, destDir)).To(Succeed())
+		expectExtracted()
+	})
+
+	It("Rejects entries outside of the archive", func() {
+		entries = map[string]string{"../escaped.sh": "echo escaped\n"}
+		file := writeTar("bundle.tar", false)
+		Expect(ExtractArchive(file, destDir)).To(MatchError(file + ": entry ../escaped.sh is outside of the archive"))
+		Expect(filepath.Join(tempDir, "escaped.sh")).NotTo(BeAnExistingFile())
+	})
+})
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rightscale/rsc/httpclient"
+)
+
+// BaseURL set by --base-url replaces the scheme and host of every API request and prefixes its path, for on-premises
+// and proxied installations which are not served from https://<host>/api.
+var BaseURL *url.URL
+
+// ParseBaseURL parses the value of --base-url which must be an absolute http or https URL.
+func ParseBaseURL(value string) (*url.URL, error) {
+	base, err := url.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid base URL '%s': %s", value, err.Error())
+	}
+	if (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("Invalid base URL '%s': must be an http or https URL such as https://rightscale.example.com/prefix", value)
+	}
+	return base, nil
+}
+
+// ApplyBaseURL returns the URL of an API request built for u sent to base instead.
+func ApplyBaseURL(base, u *url.URL) *url.URL {
+	rewritten := *u
+	rewritten.Scheme = base.Scheme
+	rewritten.Host = base.Host
+	rewritten.Path = strings.TrimRight(base.Path, "/") + u.Path
+	return &rewritten
+}
+
+// baseURLClient sends requests to BaseURL instead of where they were built for.
+type baseURLClient struct {
+	next httpclient.HTTPClient
+}
+
+func (c *baseURLClient) Do(req *http.Request) (*http.Response, error) {
+	return c.next.Do(rewriteRequest(req))
+}
+
+func (c *baseURLClient) DoHidden(req *http.Request) (*http.Response, error) {
+	return c.next.DoHidden(rewriteRequest(req))
+}
+
+func rewriteRequest(req *http.Request) *http.Request {
+	req.URL = ApplyBaseURL(BaseURL, req.URL)
+	req.Host = BaseURL.Host
+	return req
+}
+
+// baseURLAuthenticator exchanges the refresh token for access tokens through BaseURL, the rsc OAuth authenticator
+// always sends the exchange to https://<host>/api/oauth2.
+type baseURLAuthenticator struct {
+	refreshToken string
+	accountId    int
+	lock         sync.Mutex // for accessToken and expiresAt
+	accessToken  string
+	expiresAt    time.Time
+}
+
+func (auth *baseURLAuthenticator) Sign(r *http.Request) error {
+	auth.lock.Lock()
+	defer auth.lock.Unlock()
+	if auth.accessToken == "" || time.Now().After(auth.expiresAt) {
+		if err := auth.refresh(); err != nil {
+			return err
+		}
+	}
+	r.Header.Set("Authorization", "Bearer "+auth.accessToken)
+	r.Header.Set("X-Account", strconv.Itoa(auth.accountId))
+	return nil
+}
+
+func (auth *baseURLAuthenticator) CanAuthenticate(host string) error {
+	return nil
+}
+
+func (auth *baseURLAuthenticator) refresh() error {
+	oauthURL := ApplyBaseURL(BaseURL, &url.URL{Path: "/api/oauth2"})
+	form := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {auth.refreshToken}}
+	req, err := http.NewRequest("POST", oauthURL.String(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-API-Version", defaultAPIVersion)
+	resp, err := (&http.Client{Transport: NewTransport(MaxIdleConnsPerHost, KeepAlive)}).Do(req.WithContext(RequestContext))
+	if err != nil {
+		return fmt.Errorf("Authentication failed: %s", err.Error())
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Authentication failed: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return fmt.Errorf("Authentication failed: %s", err.Error())
+	}
+	// refresh a little early so a request signed just before the access token expires does not fail
+	auth.accessToken = token.AccessToken
+	auth.expiresAt = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return nil
+}
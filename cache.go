@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Name of the file in the cache directory holding resolved HREFs
+const hrefCacheFile = "hrefs.json"
+
+// HrefCache is a local cache of resource name to HREF resolutions so that repeated commands do not have to query the
+// API every time. Entries older than TTL are ignored.
+type HrefCache struct {
+	Path    string                     `json:"-"`
+	TTL     time.Duration              `json:"-"`
+	Entries map[string]*HrefCacheEntry `json:"entries"`
+}
+
+type HrefCacheEntry struct {
+	Href     string    `json:"href"`
+	Revision int       `json:"revision"`
+	CachedAt time.Time `json:"cached_at"`
+}
+
+// LoadHrefCache reads the HREF cache from a cache directory. A missing or unreadable cache file results in an empty
+// cache rather than an error since the cache can always be rebuilt from the API.
+func LoadHrefCache(cacheDir string, ttl time.Duration) *HrefCache {
+	cache := &HrefCache{
+		Path:    filepath.Join(cacheDir, hrefCacheFile),
+		TTL:     ttl,
+		Entries: make(map[string]*HrefCacheEntry),
+	}
+	data, err := ioutil.ReadFile(cache.Path)
+	if err != nil {
+		return cache
+	}
+	if err := json.Unmarshal(data, cache); err != nil || cache.Entries == nil {
+		cache.Entries = make(map[string]*HrefCacheEntry)
+	}
+	return cache
+}
+
+// HrefCacheKey builds the key for a resource name. Keys include the account so that the same name in different
+// accounts does not collide.
+func HrefCacheKey(account *Account, resourceType, name string, revision int) string {
+	return fmt.Sprintf("%s/%d/%s/%d/%s", account.Host, account.Id, resourceType, revision, name)
+}
+
+// Get returns the cache entry for a key if there is one that has not expired yet.
+func (cache *HrefCache) Get(key string, now time.Time) (*HrefCacheEntry, bool) {
+	entry, ok := cache.Entries[key]
+	if !ok || now.Sub(entry.CachedAt) > cache.TTL {
+		return nil, false
+	}
+	return entry, true
+}
+
+// Put adds or replaces the cache entry for a key.
+func (cache *HrefCache) Put(key, href string, revision int, now time.Time) {
+	cache.Entries[key] = &HrefCacheEntry{Href: href, Revision: revision, CachedAt: now}
+}
+
+// Save writes the cache back to its cache directory, dropping any expired entries.
+func (cache *HrefCache) Save(now time.Time) error {
+	for key, entry := range cache.Entries {
+		if now.Sub(entry.CachedAt) > cache.TTL {
+			delete(cache.Entries, key)
+		}
+	}
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(cache.Path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(cache.Path, data, 0600)
+}
+
+// ClearHrefCache removes the HREF cache from a cache directory.
+func ClearHrefCache(cacheDir string) error {
+	err := os.Remove(filepath.Join(cacheDir, hrefCacheFile))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package main_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/rightscale/right_st"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HREF cache", func() {
+	var (
+		cacheDir string
+		account  *Account
+		now      time.Time
+	)
+
+	BeforeEach(func() {
+		var err error
+		cacheDir, err = ioutil.TempDir("", "cache")
+		if err != nil {
+			panic(err)
+		}
+		account = &Account{Id: 12345, Host: "us-3.rightscale.com"}
+		now = time.Date(2016, 7, 4, 12, 0, 0, 0, time.UTC)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(cacheDir)
+	})
+
+	It("Starts out empty without a cache file", func() {
+		cache := LoadHrefCache(cacheDir, time.Minute)
+		_, ok := cache.Get(HrefCacheKey(account, "right_scripts", "Foo", 0), now)
+		Expect(ok).To(BeFalse())
+	})
+
+	It("Round trips entries through the cache file", func() {
+		key := HrefCacheKey(account, "right_scripts", "Foo", 0)
+		cache := LoadHrefCache(cacheDir, time.Minute)
+		cache.Put(key, "/api/right_scripts/1234", 0, now)
+		Expect(cache.Save(now)).To(Succeed())
+
+		cache = LoadHrefCache(cacheDir, time.Minute)
+		entry, ok := cache.Get(key, now.Add(30*time.Second))
+		Expect(ok).To(BeTrue())
+		Expect(entry.Href).To(Equal("/api/right_scripts/1234"))
+	})
+
+	It("Ignores expired entries", func() {
+		key := HrefCacheKey(account, "right_scripts", "Foo", 0)
+		cache := LoadHrefCache(cacheDir, time.Minute)
+		cache.Put(key, "/api/right_scripts/1234", 0, now)
+		_, ok := cache.Get(key, now.Add(2*time.Minute))
+		Expect(ok).To(BeFalse())
+	})
+
+	It("Keys entries by account", func() {
+		other := &Account{Id: 67890, Host: "us-3.rightscale.com"}
+		Expect(HrefCacheKey(account, "right_scripts", "Foo", 0)).NotTo(Equal(HrefCacheKey(other, "right_scripts", "Foo", 0)))
+	})
+
+	It("Clears the cache file", func() {
+		cache := LoadHrefCache(cacheDir, time.Minute)
+		cache.Put(HrefCacheKey(account, "right_scripts", "Foo", 0), "/api/right_scripts/1234", 0, now)
+		Expect(cache.Save(now)).To(Succeed())
+		Expect(ClearHrefCache(cacheDir)).To(Succeed())
+		_, err := os.Stat(filepath.Join(cacheDir, "hrefs.json"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+		Expect(ClearHrefCache(cacheDir)).To(Succeed())
+	})
+})
@@ -0,0 +1,11 @@
+package main
+
+import "crypto/x509"
+
+func systemCertPool() *x509.CertPool {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		return x509.NewCertPool()
+	}
+	return pool
+}
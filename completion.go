@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/rightscale/rsc/rsapi"
+
+	"github.com/rightscale/right_st/rightscript"
+)
+
+// Arguments which name a RightScript or ServerTemplate are completed with the names of the ones in the account
+var nameArgs = map[string]bool{"name|href|id": true, "target": true}
+
+// The completion scripts run this hidden command with the words on the command line to get the candidates
+const completeCommand = "__complete"
+
+var completionScripts = map[string]string{
+	"bash": `# bash completion for {{name}}, load with: source <({{name}} completion bash)
+_{{func}}() {
+    local IFS=$'\n'
+    COMPREPLY=($({{name}} ` + completeCommand + ` -- "${COMP_WORDS[@]:1:$COMP_CWORD}" 2>/dev/null))
+}
+complete -o default -F _{{func}} {{name}}
+`,
+	"zsh": `#compdef {{name}}
+# zsh completion for {{name}}, load with: source <({{name}} completion zsh)
+_{{func}}() {
+    local -a candidates
+    candidates=("${(@f)$({{name}} ` + completeCommand + ` -- "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    if [[ -n "${candidates[1]}" ]]; then
+        compadd -- "${candidates[@]}"
+    else
+        _files
+    fi
+}
+compdef _{{func}} {{name}}
+`,
+	"fish": `# fish completion for {{name}}, load with: {{name}} completion fish | source
+function __{{func}}_complete
+    set -l tokens (commandline -opc)
+    set -e tokens[1]
+    set -l current (commandline -ct)
+    {{name}} ` + completeCommand + ` -- $tokens "$current" 2>/dev/null
+end
+complete -c {{name}} -a '(__{{func}}_complete)'
+`,
+}
+
+// CompletionScript returns the script which makes shell, bash, zsh, or fish, complete the commands and flags of the
+// executable name.
+func CompletionScript(shell, name string) (string, error) {
+	script, ok := completionScripts[shell]
+	if !ok {
+		return "", fmt.Errorf("Unknown shell %s, must be bash, zsh, or fish", shell)
+	}
+	function := strings.NewReplacer("-", "_", ".", "_").Replace(name)
+	return strings.NewReplacer("{{name}}", name, "{{func}}", function).Replace(script), nil
+}
+
+// Complete returns the candidates for the last of words, the words on the command line after the executable name:
+// the commands, flags, or, for arguments naming a RightScript or ServerTemplate, the names returned by names for the
+// resource type ("right_scripts" or "server_templates").
+func Complete(model *kingpin.ApplicationModel, words []string, names func(resourceType, prefix string) []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	flags := append([]*kingpin.FlagModel{}, model.Flags...)
+	commands := model.Commands
+	var command *kingpin.CmdModel
+	argIndex := 0
+
+	completed, current := words[:len(words)-1], words[len(words)-1]
+	for index := 0; index < len(completed); index++ {
+		word := completed[index]
+		switch {
+		case word == "--":
+		case strings.HasPrefix(word, "-") && len(word) > 1:
+			// the value of a flag is the next word unless it is given with =
+			if flag := findFlag(flags, word); flag != nil && !flag.IsBoolFlag() && !strings.Contains(word, "=") {
+				index++
+			}
+		default:
+			if subcommand := findCommand(commands, word); subcommand != nil {
+				command = subcommand
+				flags = append(flags, command.Flags...)
+				commands = command.Commands
+				argIndex = 0
+			} else {
+				argIndex++
+			}
+		}
+	}
+
+	candidates := []string{}
+	if len(completed) > 0 {
+		if flag := findFlag(flags, completed[len(completed)-1]); flag != nil && !flag.IsBoolFlag() &&
+			!strings.Contains(completed[len(completed)-1], "=") {
+			// there is nothing to suggest for a flag value, so leave it to the shell
+			return candidates
+		}
+	}
+	switch {
+	case strings.HasPrefix(current, "-"):
+		for _, flag := range flags {
+			if !flag.Hidden && strings.HasPrefix("--"+flag.Name, current) {
+				candidates = append(candidates, "--"+flag.Name)
+			}
+		}
+	case len(commands) > 0:
+		for _, subcommand := range commands {
+			if !subcommand.Hidden && strings.HasPrefix(subcommand.Name, current) {
+				candidates = append(candidates, subcommand.Name)
+			}
+		}
+	case command != nil && argIndex < len(command.Args) && nameArgs[command.Args[argIndex].Name]:
+		resourceType := "right_scripts"
+		if strings.HasPrefix(command.FullCommand, "st ") {
+			resourceType = "server_templates"
+		}
+		candidates = append(candidates, names(resourceType, current)...)
+	}
+	sort.Strings(candidates)
+	return candidates
+}
+
+func findFlag(flags []*kingpin.FlagModel, word string) *kingpin.FlagModel {
+	name := strings.SplitN(strings.TrimLeft(word, "-"), "=", 2)[0]
+	for _, flag := range flags {
+		if strings.HasPrefix(word, "--") && flag.Name == name || !strings.HasPrefix(word, "--") && string(flag.Short) == name {
+			return flag
+		}
+	}
+	return nil
+}
+
+func findCommand(commands []*kingpin.CmdModel, word string) *kingpin.CmdModel {
+	for _, command := range commands {
+		if command.Name == word {
+			return command
+		}
+	}
+	return nil
+}
+
+// completeNames returns the names of the HEAD RightScripts or ServerTemplates starting with prefix. Completion must
+// never print errors into the command line, so any problem just means there are no names.
+func completeNames(resourceType, prefix string) []string {
+	names := []string{}
+	if Config.Account == nil {
+		return names
+	}
+	client, err := Config.Account.Client15()
+	if err != nil {
+		return names
+	}
+	params := rsapi.APIParams{}
+	if prefix != "" {
+		filter, err := rightscript.NameFilter(prefix)
+		if err != nil {
+			return names
+		}
+		params["filter"] = []string{filter}
+	}
+	add := func(name string, revision int) {
+		if revision == 0 && strings.HasPrefix(strings.ToLower(name), strings.ToLower(prefix)) {
+			names = append(names, name)
+		}
+	}
+	switch resourceType {
+	case "server_templates":
+		sts, err := client.ServerTemplateLocator("/api/server_templates").Index(params)
+		if err != nil {
+			return names
+		}
+		for _, st := range sts {
+			add(st.Name, st.Revision)
+		}
+	default:
+		rightscripts, err := client.RightScriptLocator("/api/right_scripts").Index(params)
+		if err != nil {
+			return names
+		}
+		for _, rs := range rightscripts {
+			add(rs.Name, rs.Revision)
+		}
+	}
+	return names
+}
@@ -0,0 +1,76 @@
+package main_test
+
+import (
+	"strings"
+
+	"github.com/alecthomas/kingpin"
+
+	. "github.com/rightscale/right_st"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Completion", func() {
+	var (
+		model    *kingpin.ApplicationModel
+		prefixes []string
+	)
+
+	names := func(resourceType, prefix string) []string {
+		prefixes = append(prefixes, resourceType+":"+prefix)
+		return []string{prefix + "Script"}
+	}
+
+	BeforeEach(func() {
+		prefixes = nil
+		app := kingpin.New("right_st", "")
+		app.Flag("account", "").Short('a').String()
+		app.Flag("debug", "").Bool()
+		rightScript := app.Command("rightscript", "")
+		show := rightScript.Command("show", "")
+		show.Arg("name|href|id", "").String()
+		show.Flag("format", "").String()
+		upload := rightScript.Command("upload", "")
+		upload.Arg("path", "").Strings()
+		upload.Flag("force", "").Bool()
+		rightScript.Command("secret", "").Hidden()
+		st := app.Command("st", "")
+		st.Command("show", "").Arg("name|href|id", "").String()
+		model = app.Model()
+	})
+
+	It("Completes commands", func() {
+		Expect(Complete(model, []string{""}, names)).To(Equal([]string{"rightscript", "st"}))
+		Expect(Complete(model, []string{"rightscript", ""}, names)).To(Equal([]string{"show", "upload"}))
+		Expect(Complete(model, []string{"--debug", "rightscript", "up"}, names)).To(Equal([]string{"upload"}))
+	})
+
+	It("Completes global and command flags", func() {
+		Expect(Complete(model, []string{"rightscript", "upload", "--"}, names)).To(Equal([]string{"--account", "--debug", "--force", "--help"}))
+		Expect(Complete(model, []string{"rightscript", "show", "--f"}, names)).To(Equal([]string{"--format"}))
+	})
+
+	It("Completes names with the API", func() {
+		Expect(Complete(model, []string{"rightscript", "show", "My"}, names)).To(Equal([]string{"MyScript"}))
+		Expect(Complete(model, []string{"-a", "acct", "st", "show", ""}, names)).To(Equal([]string{"Script"}))
+		Expect(prefixes).To(Equal([]string{"right_scripts:My", "server_templates:"}))
+	})
+
+	It("Leaves flag values and other arguments to the shell", func() {
+		Expect(Complete(model, []string{"rightscript", "show", "--format", ""}, names)).To(BeEmpty())
+		Expect(Complete(model, []string{"rightscript", "upload", "scr"}, names)).To(BeEmpty())
+		Expect(Complete(model, []string{"rightscript", "show", "--format", "text", "My"}, names)).To(Equal([]string{"MyScript"}))
+		Expect(prefixes).To(Equal([]string{"right_scripts:My"}))
+	})
+
+	It("Prints a script for each shell", func() {
+		for _, shell := range []string{"bash", "zsh", "fish"} {
+			script, err := CompletionScript(shell, "right_st")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(strings.Contains(script, "right_st __complete --")).To(BeTrue())
+		}
+		_, err := CompletionScript("tcsh", "right_st")
+		Expect(err).To(HaveOccurred())
+	})
+})
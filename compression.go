@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/rightscale/rsc/httpclient"
+)
+
+// Compression settings for API requests: responses are asked for gzip compressed unless --no-compression is given for
+// endpoints which mishandle it, and with --compress-uploads request bodies of at least MinCompressSize bytes, such as
+// the source of large RightScripts, are sent gzip compressed too.
+var (
+	Compression     = true
+	CompressUploads = false
+	MinCompressSize = 64 * 1024
+)
+
+// gzipClient asks for gzip compressed responses and decompresses them before rsc or getSource read them.
+type gzipClient struct {
+	next httpclient.HTTPClient
+}
+
+func (c *gzipClient) Do(req *http.Request) (*http.Response, error) {
+	return doGzip(req, c.next.Do)
+}
+
+func (c *gzipClient) DoHidden(req *http.Request) (*http.Response, error) {
+	return doGzip(req, c.next.DoHidden)
+}
+
+func doGzip(req *http.Request, do func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	// asking for gzip explicitly turns off the transparent decompression of the transport, which only some of the
+	// clients have, so responses are always decompressed here
+	req.Header.Set("Accept-Encoding", "gzip")
+	if CompressUploads {
+		if err := CompressRequest(req, MinCompressSize); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := do(req)
+	if err != nil {
+		return resp, err
+	}
+	if err := DecompressResponse(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+// CompressRequest gzip compresses the body of req when it is at least minSize bytes and not compressed already.
+// Multipart attachment uploads are left alone since attachments are often compressed archives anyway.
+func CompressRequest(req *http.Request, minSize int) error {
+	if req.Body == nil || req.Header.Get("Content-Encoding") != "" ||
+		strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/") {
+		return nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return err
+	}
+	if len(body) < minSize {
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		return nil
+	}
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	if _, err := writer.Write(body); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	req.Body = ioutil.NopCloser(&compressed)
+	req.ContentLength = int64(compressed.Len())
+	req.Header.Set("Content-Encoding", "gzip")
+	return nil
+}
+
+// DecompressResponse replaces the body of a gzip encoded response with its decompressed content.
+func DecompressResponse(resp *http.Response) error {
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		return nil
+	}
+	reader, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return err
+	}
+	resp.Body = &gzipBody{reader, resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// gzipBody reads the decompressed content of a response body and closes the response body itself when closed.
+type gzipBody struct {
+	io.Reader
+	body io.Closer
+}
+
+func (b *gzipBody) Close() error {
+	return b.body.Close()
+}
@@ -0,0 +1,56 @@
+package main_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	. "github.com/rightscale/right_st"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Compression", func() {
+	It("Compresses large request bodies", func() {
+		source := strings.Repeat("echo hello\n", 100)
+		req, err := http.NewRequest("PUT", "https://us-3.rightscale.com/api/right_scripts/1", strings.NewReader(source))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(CompressRequest(req, 1024)).To(Succeed())
+		Expect(req.Header.Get("Content-Encoding")).To(Equal("gzip"))
+		reader, err := gzip.NewReader(req.Body)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ioutil.ReadAll(reader)).To(Equal([]byte(source)))
+	})
+
+	It("Leaves small request bodies and attachment uploads alone", func() {
+		req, err := http.NewRequest("PUT", "https://us-3.rightscale.com/api/right_scripts/1", strings.NewReader("echo hello\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(CompressRequest(req, 1024)).To(Succeed())
+		Expect(req.Header.Get("Content-Encoding")).To(BeEmpty())
+		Expect(ioutil.ReadAll(req.Body)).To(Equal([]byte("echo hello\n")))
+
+		req, err = http.NewRequest("POST", "https://us-3.rightscale.com/api/right_scripts/1/attachments",
+			strings.NewReader(strings.Repeat("x", 2048)))
+		Expect(err).NotTo(HaveOccurred())
+		req.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+		Expect(CompressRequest(req, 1024)).To(Succeed())
+		Expect(req.Header.Get("Content-Encoding")).To(BeEmpty())
+	})
+
+	It("Decompresses gzip encoded responses", func() {
+		var compressed bytes.Buffer
+		writer := gzip.NewWriter(&compressed)
+		writer.Write([]byte("#!/bin/bash\n"))
+		writer.Close()
+		resp := &http.Response{Header: http.Header{"Content-Encoding": {"gzip"}}, Body: ioutil.NopCloser(&compressed),
+			ContentLength: int64(compressed.Len())}
+		Expect(DecompressResponse(resp)).To(Succeed())
+		Expect(resp.Header.Get("Content-Encoding")).To(BeEmpty())
+		Expect(resp.ContentLength).To(Equal(int64(-1)))
+		Expect(ioutil.ReadAll(resp.Body)).To(Equal([]byte("#!/bin/bash\n")))
+		Expect(resp.Body.Close()).To(Succeed())
+	})
+})
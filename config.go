@@ -23,8 +23,11 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -35,8 +38,11 @@ import (
 
 type ConfigViper struct {
 	*viper.Viper
-	Account  *Account
-	Accounts map[string]*Account
+	Account     *Account
+	AccountName string // Name of the selected account, empty if it came from environment variables
+	Accounts    map[string]*Account
+	rawAccounts map[string]Account // Accounts before environment variables in their values were expanded
+	configFiles []string           // Config files read by ReadConfig in the order they were merged
 }
 
 var Config ConfigViper
@@ -51,49 +57,225 @@ func init() {
 }
 
 func ReadConfig(configFile, account string) error {
-	Config.SetConfigFile(configFile)
-	err := Config.ReadInConfig()
-	if err != nil {
-		if _, ok := err.(*os.PathError); !(ok &&
-			Config.IsSet("login.account.id") &&
-			Config.IsSet("login.account.host") &&
-			Config.IsSet("login.account.refresh_token")) {
-			return err
+	Config.configFiles = SplitConfigFiles(configFile)
+	configFile = strings.Join(Config.configFiles, ", ")
+	for index, file := range Config.configFiles {
+		Config.SetConfigFile(file)
+		Config.SetConfigType(configFileType(file))
+		var err error
+		if index == 0 {
+			err = Config.ReadInConfig()
+		} else {
+			// later files override the settings of earlier ones key by key, so an account can get its host from a
+			// shared file and its token from a personal one
+			err = Config.MergeInConfig()
+		}
+		if err != nil {
+			if _, ok := err.(*os.PathError); !(ok && environmentAccount()) {
+				return err
+			}
 		}
 	}
 
-	err = Config.UnmarshalKey("login.accounts", &Config.Accounts)
+	err := Config.UnmarshalKey("login.accounts", &Config.Accounts)
 	if err != nil {
 		return fmt.Errorf("%s: %s", configFile, err)
 	}
+	// keep the values as written in the config file so SetAccount does not write out expanded secrets
+	Config.rawAccounts = make(map[string]Account, len(Config.Accounts))
+	for name, a := range Config.Accounts {
+		Config.rawAccounts[name] = *a
+	}
 
-	if Config.IsSet("login.account.id") &&
-		Config.IsSet("login.account.host") &&
-		Config.IsSet("login.account.refresh_token") {
+	Config.AccountName = ""
+	if environmentAccount() {
 		Config.Account = &Account{
-			Id:           Config.GetInt("login.account.id"),
-			Host:         Config.GetString("login.account.host"),
-			RefreshToken: Config.GetString("login.account.refresh_token"),
+			Id:                   Config.GetInt("login.account.id"),
+			Host:                 Config.GetString("login.account.host"),
+			RefreshToken:         Config.GetString("login.account.refresh_token"),
+			AccessToken:          Config.GetString("login.account.access_token"),
+			AccessTokenExpiresAt: Config.GetString("login.account.access_token_expires_at"),
 		}
 	} else {
 		var ok bool
 		if account == "" {
-			defaultAccount := Config.GetString("login.default_account")
+			defaultAccount, err := ExpandConfigValue(Config.GetString("login.default_account"))
+			if err != nil {
+				return fmt.Errorf("%s: default_account: %s", configFile, err)
+			}
 			Config.Account, ok = Config.Accounts[defaultAccount]
 			if !ok {
 				return fmt.Errorf("%s: could not find default account: %s", configFile, defaultAccount)
 			}
+			Config.AccountName = defaultAccount
 		} else {
 			Config.Account, ok = Config.Accounts[account]
 			if !ok {
 				return fmt.Errorf("%s: could not find account: %s", configFile, account)
 			}
+			Config.AccountName = account
+		}
+		// only the selected account is expanded so the variables of the other accounts do not need to be set
+		if err := expandAccount(Config.Account); err != nil {
+			return fmt.Errorf("%s: account %s: %s", configFile, Config.AccountName, err)
 		}
 	}
 
 	return nil
 }
 
+// expandAccount expands the environment variables referenced in the host and tokens of an account from a config file.
+func expandAccount(a *Account) (err error) {
+	if a.Host, err = ExpandConfigValue(a.Host); err == nil {
+		if a.RefreshToken, err = ExpandConfigValue(a.RefreshToken); err == nil {
+			a.AccessToken, err = ExpandConfigValue(a.AccessToken)
+		}
+	}
+	return err
+}
+
+// environmentAccount reports whether an account is given entirely by environment variables, which needs either a
+// refresh token or an access token.
+func environmentAccount() bool {
+	return Config.IsSet("login.account.id") &&
+		Config.IsSet("login.account.host") &&
+		(Config.IsSet("login.account.refresh_token") || Config.IsSet("login.account.access_token"))
+}
+
+// ExpandConfigValue replaces ${VAR} and $VAR references in a config value with the values of environment variables so
+// secrets such as refresh tokens can be kept out of a config file committed to version control. It is an error to
+// reference a variable which is not set.
+func ExpandConfigValue(value string) (string, error) {
+	missing := []string{}
+	expanded := os.Expand(value, func(name string) string {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return v
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+// FindConfigFile resolves a relative config file path which does not exist in dir by looking for it in each parent
+// directory in turn, the way git finds .git, so a per-project config file is found from anywhere in the project. The
+// path is returned unchanged when it is absolute, exists as given, or is not found in any parent directory.
+func FindConfigFile(configFile, dir string) string {
+	if files := SplitConfigFiles(configFile); len(files) > 1 {
+		for index, file := range files {
+			files[index] = FindConfigFile(file, dir)
+		}
+		return strings.Join(files, string(filepath.ListSeparator))
+	}
+	if filepath.IsAbs(configFile) {
+		return configFile
+	}
+	if _, err := os.Stat(filepath.Join(dir, configFile)); err == nil {
+		return configFile
+	}
+	for {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return configFile
+		}
+		dir = parent
+		candidate := filepath.Join(dir, configFile)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+}
+
+// SplitConfigFiles splits a list of config files separated like PATH, with : (or ; on Windows), into the files to
+// merge in order.
+func SplitConfigFiles(configFile string) []string {
+	files := []string{}
+	for _, file := range filepath.SplitList(configFile) {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		files = append(files, configFile)
+	}
+	return files
+}
+
+// DefaultConfigFiles is the RIGHT_ST_CONFIG environment variable, which may list several config files to merge, or
+// else the default config file.
+func DefaultConfigFiles() string {
+	if configFile := os.Getenv("RIGHT_ST_CONFIG"); configFile != "" {
+		return configFile
+	}
+	return DefaultConfigFile()
+}
+
+// configFileNames lists the config files which were read.
+func (config *ConfigViper) configFileNames() string {
+	if len(config.configFiles) == 0 {
+		return config.ConfigFileUsed()
+	}
+	return strings.Join(config.configFiles, ", ")
+}
+
+// configFileType determines whether a config file is JSON or YAML. The extension is used when it is a known one,
+// otherwise the content is checked since a JSON config file is always an object starting with "{".
+func configFileType(configFile string) string {
+	switch strings.ToLower(filepath.Ext(configFile)) {
+	case ".json":
+		return "json"
+	case ".yml", ".yaml":
+		return "yaml"
+	}
+	content, err := ioutil.ReadFile(configFile)
+	if err == nil && bytes.HasPrefix(bytes.TrimSpace(content), []byte("{")) {
+		return "json"
+	}
+	return "yaml"
+}
+
+// marshalConfig renders settings in the same format as the config file they were read from.
+func (config *ConfigViper) marshalConfig(settings map[string]interface{}) ([]byte, error) {
+	if configFileType(config.ConfigFileUsed()) == "json" {
+		data, err := json.MarshalIndent(settings, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	}
+	return yaml.Marshal(settings)
+}
+
+// stringKeys converts the map[interface{}]interface{} values produced by YAML into map[string]interface{} so settings
+// read from YAML or JSON can be handled (and marshalled to either format) the same way.
+func stringKeys(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprintf("%v", key)] = stringKeys(item)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[key] = stringKeys(item)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, item := range v {
+			l[i] = stringKeys(item)
+		}
+		return l
+	default:
+		return value
+	}
+}
+
 func (config *ConfigViper) GetAccount(id int, host string) (*Account, error) {
 	for _, account := range config.Accounts {
 		if account.Id == id && account.Host == host {
@@ -123,18 +305,19 @@ func (config *ConfigViper) SetAccount(name string, setDefault bool, input io.Rea
 		setDefault = true
 	}
 
-	// get the settings and specifically the login settings into a map we can manipulate and marshal to YAML unhindered
-	// by the meddling of the Viper
-	settings := config.AllSettings()
-	loginSettings := settings["login"].(map[interface{}]interface{})
+	// get the settings and specifically the login settings into a map we can manipulate and marshal to YAML or JSON
+	// unhindered by the meddling of the Viper
+	settings := stringKeys(config.AllSettings()).(map[string]interface{})
+	loginSettings := settings["login"].(map[string]interface{})
 
 	// set the default account if we want or need to
 	if setDefault {
 		loginSettings["default_account"] = name
 	}
 
-	// get the previous value for the named account if it exists and construct a new account to populate
-	oldAccount, ok := config.Accounts[name]
+	// get the previous value for the named account if it exists and construct a new account to populate, values
+	// referencing environment variables are shown and kept as they are written in the config file
+	oldAccount, ok := config.rawAccounts[name]
 	newAccount := &Account{}
 
 	// prompt for the account ID and use the old value if nothing is entered
@@ -171,11 +354,11 @@ func (config *ConfigViper) SetAccount(name string, setDefault bool, input io.Rea
 	}
 
 	// add the new account to the map of accounts overwriting any old value
-	accounts := loginSettings["accounts"].(map[interface{}]interface{})
+	accounts := loginSettings["accounts"].(map[string]interface{})
 	accounts[name] = newAccount
 
-	// render the settings map as YAML
-	yml, err := yaml.Marshal(settings)
+	// render the settings map in the format of the config file
+	data, err := config.marshalConfig(settings)
 	if err != nil {
 		return err
 	}
@@ -199,8 +382,8 @@ func (config *ConfigViper) SetAccount(name string, setDefault bool, input io.Rea
 	}
 	defer configFile.Close()
 
-	// write the YAML into the config file
-	if _, err := configFile.Write(yml); err != nil {
+	// write the YAML or JSON into the config file
+	if _, err := configFile.Write(data); err != nil {
 		return err
 	}
 
@@ -213,11 +396,52 @@ func (config *ConfigViper) ShowConfiguration(output io.Writer) error {
 		return err
 	}
 
-	yml, err := yaml.Marshal(config.AllSettings())
+	data, err := config.marshalConfig(stringKeys(config.AllSettings()).(map[string]interface{}))
 	if err != nil {
 		return err
 	}
-	output.Write(yml)
+	output.Write(data)
+
+	return nil
+}
+
+// TokenHelp explains how to fix the refresh or access token for the selected account when the API rejects it.
+func (config *ConfigViper) TokenHelp() string {
+	if config.Account != nil && config.Account.AccessToken != "" {
+		return "Your RightScale access token has expired or is invalid; right_st cannot refresh an access token, obtain a new one " +
+			"and update it along with its expiry"
+	}
+	if config.AccountName == "" {
+		return "Your RightScale token has expired or is invalid; update RIGHT_ST_LOGIN_ACCOUNT_REFRESH_TOKEN"
+	}
+	return fmt.Sprintf("Your RightScale token has expired or is invalid for account %s; update %s (or run: right_st config account %s)",
+		config.AccountName, config.ConfigFileUsed(), config.AccountName)
+}
+
+// ShowAccount prints out the account selected by ReadConfig after merging flags, environment variables, and the config
+// file so it is clear which RightScale account and API endpoint host will be used. Only a fingerprint of the refresh
+// token is printed.
+func (config *ConfigViper) ShowAccount(output io.Writer) error {
+	if config.Account == nil {
+		return fmt.Errorf("No account has been selected")
+	}
+
+	name := config.AccountName
+	if name == "" {
+		name = "(from environment variables)"
+	}
+	fmt.Fprintf(output, "Config file: %s\n", config.configFileNames())
+	fmt.Fprintf(output, "Account name: %s\n", name)
+	fmt.Fprintf(output, "Account ID: %d\n", config.Account.Id)
+	fmt.Fprintf(output, "API endpoint host: %s\n", config.Account.Host)
+	if config.Account.AccessToken != "" {
+		fmt.Fprintf(output, "Access token: %s\n", config.Account.TokenFingerprint())
+		if config.Account.AccessTokenExpiresAt != "" {
+			fmt.Fprintf(output, "Access token expires at: %s\n", config.Account.AccessTokenExpiresAt)
+		}
+	} else {
+		fmt.Fprintf(output, "Refresh token: %s\n", config.Account.TokenFingerprint())
+	}
 
 	return nil
 }
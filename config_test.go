@@ -43,6 +43,53 @@ var _ = Describe("Config", func() {
 		Expect(filepath.IsAbs(configFile)).To(BeTrue())
 	})
 
+	Describe("Find config file", func() {
+		var tempDir, subDir string
+
+		BeforeEach(func() {
+			var err error
+			tempDir, err = ioutil.TempDir("", "config")
+			if err != nil {
+				panic(err)
+			}
+			subDir = filepath.Join(tempDir, "a", "b")
+			if err := os.MkdirAll(subDir, 0755); err != nil {
+				panic(err)
+			}
+			if err := ioutil.WriteFile(filepath.Join(tempDir, "project.yml"), []byte{}, 0644); err != nil {
+				panic(err)
+			}
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(tempDir)
+		})
+
+		It("Finds a relative config file in a parent directory", func() {
+			Expect(FindConfigFile("project.yml", subDir)).To(Equal(filepath.Join(tempDir, "project.yml")))
+		})
+
+		It("Prefers a config file in the directory itself", func() {
+			if err := ioutil.WriteFile(filepath.Join(subDir, "project.yml"), []byte{}, 0644); err != nil {
+				panic(err)
+			}
+			Expect(FindConfigFile("project.yml", subDir)).To(Equal("project.yml"))
+		})
+
+		It("Leaves absolute and missing config files alone", func() {
+			missing := filepath.Join(tempDir, "missing.yml")
+			Expect(FindConfigFile(missing, subDir)).To(Equal(missing))
+			Expect(FindConfigFile("missing.yml", subDir)).To(Equal("missing.yml"))
+		})
+
+		It("Finds each of several config files", func() {
+			files := "project.yml" + string(filepath.ListSeparator) + "missing.yml"
+			Expect(FindConfigFile(files, subDir)).To(Equal(filepath.Join(tempDir, "project.yml") +
+				string(filepath.ListSeparator) + "missing.yml"))
+			Expect(SplitConfigFiles(files)).To(Equal([]string{"project.yml", "missing.yml"}))
+		})
+	})
+
 	Describe("Read config", func() {
 		var (
 			tempDir string
@@ -202,6 +249,80 @@ login:
 			})
 		})
 
+		Context("With a config file referencing environment variables", func() {
+			var configFile string
+
+			BeforeEach(func() {
+				configFile = filepath.Join(tempDir, ".right_st.yml")
+				err := ioutil.WriteFile(configFile, []byte(`---
+login:
+  default_account: production
+  accounts:
+    production:
+      host: ${RIGHT_ST_TEST_HOST}
+      id: 12345
+      refresh_token: $RIGHT_ST_TEST_TOKEN
+    staging:
+      host: us-4.rightscale.com
+      id: 67890
+      refresh_token: $RIGHT_ST_TEST_STAGING_TOKEN
+`), 0600)
+				if err != nil {
+					panic(err)
+				}
+				if err := os.Setenv("RIGHT_ST_TEST_HOST", "us-3.rightscale.com"); err != nil {
+					panic(err)
+				}
+				if err := os.Setenv("RIGHT_ST_TEST_TOKEN", "abcdef1234567890abcdef1234567890abcdef12"); err != nil {
+					panic(err)
+				}
+			})
+
+			AfterEach(func() {
+				if err := os.Unsetenv("RIGHT_ST_TEST_HOST"); err != nil {
+					panic(err)
+				}
+				if err := os.Unsetenv("RIGHT_ST_TEST_TOKEN"); err != nil {
+					panic(err)
+				}
+			})
+
+			It("Expands the environment variables", func() {
+				Expect(ReadConfig(configFile, "")).To(Succeed())
+				Expect(Config.Account).To(Equal(&Account{
+					Id:           12345,
+					Host:         "us-3.rightscale.com",
+					RefreshToken: "abcdef1234567890abcdef1234567890abcdef12",
+				}))
+			})
+
+			It("Returns an error when a variable is not set", func() {
+				if err := os.Unsetenv("RIGHT_ST_TEST_TOKEN"); err != nil {
+					panic(err)
+				}
+				Expect(ReadConfig(configFile, "")).To(MatchError(configFile +
+					": account production: environment variable RIGHT_ST_TEST_TOKEN is not set"))
+			})
+
+			It("Only expands the selected account", func() {
+				Expect(ReadConfig(configFile, "production")).To(Succeed())
+				Expect(Config.Accounts["staging"].RefreshToken).To(Equal("$RIGHT_ST_TEST_STAGING_TOKEN"))
+				Expect(ReadConfig(configFile, "staging")).To(MatchError(configFile +
+					": account staging: environment variable RIGHT_ST_TEST_STAGING_TOKEN is not set"))
+			})
+
+			It("Keeps the references when setting the account", func() {
+				Expect(ReadConfig(configFile, "")).To(Succeed())
+				Expect(Config.SetAccount("production", false, new(bytes.Buffer), buffer)).To(Succeed())
+				Expect(buffer.Contents()).To(BeEquivalentTo("Account ID (12345): " +
+					"API endpoint host (${RIGHT_ST_TEST_HOST}): " +
+					"Refresh token ($RIGHT_ST_TEST_TOKEN): "))
+				config, err := ioutil.ReadFile(configFile)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(config)).To(ContainSubstring("refresh_token: $RIGHT_ST_TEST_TOKEN\n"))
+			})
+		})
+
 		Context("With a valid config file", func() {
 			var configFile string
 
@@ -272,6 +393,28 @@ login:
 				Expect(err).To(MatchError(configFile + ": could not find account: development"))
 			})
 
+			It("Merges later config files over earlier ones", func() {
+				personalFile := filepath.Join(tempDir, "personal.yml")
+				Expect(ioutil.WriteFile(personalFile, []byte(`---
+login:
+  default_account: staging
+  accounts:
+    staging:
+      refresh_token: 0123456789abcdef0123456789abcdef01234567
+`), 0600)).To(Succeed())
+				Expect(ReadConfig(configFile+string(filepath.ListSeparator)+personalFile, "")).To(Succeed())
+				Expect(Config.Accounts["production"]).To(Equal(&Account{
+					Id:           12345,
+					Host:         "us-3.rightscale.com",
+					RefreshToken: "abcdef1234567890abcdef1234567890abcdef12",
+				}))
+				Expect(Config.Account).To(Equal(&Account{
+					Id:           67890,
+					Host:         "us-4.rightscale.com",
+					RefreshToken: "0123456789abcdef0123456789abcdef01234567",
+				}))
+			})
+
 			Describe("Get account", func() {
 				It("Gets an account with a specified account and host", func() {
 					Expect(ReadConfig(configFile, "")).To(Succeed())
@@ -417,6 +560,96 @@ update:
 `))
 				})
 			})
+
+			Describe("Token help", func() {
+				It("Names the account and config file", func() {
+					Expect(ReadConfig(configFile, "staging")).To(Succeed())
+					Expect(Config.TokenHelp()).To(Equal("Your RightScale token has expired or is invalid for account staging; update " +
+						configFile + " (or run: right_st config account staging)"))
+				})
+			})
+
+			Describe("Show account", func() {
+				It("Prints the default account", func() {
+					Expect(ReadConfig(configFile, "")).To(Succeed())
+					Expect(Config.ShowAccount(buffer)).To(Succeed())
+					Expect(buffer.Contents()).To(BeEquivalentTo("Config file: " + configFile + "\n" +
+						"Account name: production\n" +
+						"Account ID: 12345\n" +
+						"API endpoint host: us-3.rightscale.com\n" +
+						"Refresh token: sha256:73773a9499e845b6\n"))
+				})
+
+				It("Prints a specified account", func() {
+					Expect(ReadConfig(configFile, "staging")).To(Succeed())
+					Expect(Config.ShowAccount(buffer)).To(Succeed())
+					Expect(buffer.Contents()).To(BeEquivalentTo("Config file: " + configFile + "\n" +
+						"Account name: staging\n" +
+						"Account ID: 67890\n" +
+						"API endpoint host: us-4.rightscale.com\n" +
+						"Refresh token: sha256:7c93db1645bde69f\n"))
+				})
+			})
+		})
+
+		Context("With a valid JSON config file", func() {
+			var configFile string
+
+			BeforeEach(func() {
+				configFile = filepath.Join(tempDir, "right_st.json")
+				err := ioutil.WriteFile(configFile, []byte(`{
+  "login": {
+    "default_account": "production",
+    "accounts": {
+      "production": {
+        "host": "us-3.rightscale.com",
+        "id": 12345,
+        "refresh_token": "abcdef1234567890abcdef1234567890abcdef12"
+      }
+    }
+  }
+}
+`), 0600)
+				if err != nil {
+					panic(err)
+				}
+			})
+
+			It("Loads the accounts from the config file and sets the default account", func() {
+				Expect(ReadConfig(configFile, "")).To(Succeed())
+				Expect(Config.Account).To(Equal(&Account{
+					Id:           12345,
+					Host:         "us-3.rightscale.com",
+					RefreshToken: "abcdef1234567890abcdef1234567890abcdef12",
+				}))
+			})
+
+			It("Detects JSON by content when the extension is unknown", func() {
+				contentConfigFile := filepath.Join(tempDir, "right_st.conf")
+				Expect(os.Rename(configFile, contentConfigFile)).To(Succeed())
+				Expect(ReadConfig(contentConfigFile, "production")).To(Succeed())
+				Expect(Config.Account.Id).To(Equal(12345))
+			})
+
+			Describe("Set account", func() {
+				It("Updates the config file as JSON", func() {
+					Expect(ReadConfig(configFile, "")).To(Succeed())
+					input := new(bytes.Buffer)
+					fmt.Fprintln(input, 54321)
+					fmt.Fprintln(input, "us-4.rightscale.com")
+					fmt.Fprintln(input, "21fedcba0987654321fedcba0987654321fedcba")
+					Expect(Config.SetAccount("testing", false, input, buffer)).To(Succeed())
+					Expect(ReadConfig(configFile, "testing")).To(Succeed())
+					Expect(Config.Account).To(Equal(&Account{
+						Id:           54321,
+						Host:         "us-4.rightscale.com",
+						RefreshToken: "21fedcba0987654321fedcba0987654321fedcba",
+					}))
+					config, err := ioutil.ReadFile(configFile)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(bytes.TrimSpace(config)).To(HavePrefix("{"))
+				})
+			})
 		})
 	})
 })
@@ -15,3 +15,12 @@ func DefaultConfigFile() string {
 
 	return filepath.Join(currentUser.HomeDir, ".right_st.yml")
 }
+
+func DefaultCacheDir() string {
+	currentUser, err := user.Current()
+	if err != nil {
+		panic(err)
+	}
+
+	return filepath.Join(currentUser.HomeDir, ".right_st_cache")
+}
@@ -14,3 +14,12 @@ func DefaultConfigFile() string {
 
 	return filepath.Join(roamingPath, "RightST", ".right_st.yml")
 }
+
+func DefaultCacheDir() string {
+	roamingPath, err := win32.SHGetKnownFolderPath(&win32.FOLDERID_RoamingAppData, 0, 0)
+	if err != nil {
+		panic(err)
+	}
+
+	return filepath.Join(roamingPath, "RightST", "cache")
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rightscale/rsc/httpclient"
+)
+
+// RequestContext is the context every API request and attachment download is made with. main sets it to the context
+// of the command, which is cancelled by SIGINT or SIGTERM and once --timeout has passed.
+var RequestContext = context.Background()
+
+// requestTimeout is the timeout RequestContext was created with, 0 when there is none.
+var requestTimeout time.Duration
+
+// DefaultCommandTimeouts are the timeouts of commands which only make a few requests and should never take long, so
+// one which hangs fails quickly. They apply when neither --timeout nor the timeouts section of the config file gives
+// one for the command; every other command may take as long as it needs.
+var DefaultCommandTimeouts = map[string]time.Duration{
+	"st show":                     2 * time.Minute,
+	"rightscript list":            2 * time.Minute,
+	"rightscript search":          2 * time.Minute,
+	"rightscript show":            2 * time.Minute,
+	"rightscript history":         2 * time.Minute,
+	"rightscript attachment list": 2 * time.Minute,
+	"update list":                 2 * time.Minute,
+	// completion blocks the shell until it returns
+	completeCommand: 5 * time.Second,
+}
+
+// CommandTimeout returns the timeout of command: flag when --timeout was given, or else its duration in configured, the
+// timeouts section of the config file which maps command names like "rightscript upload" to durations, or else its
+// entry in DefaultCommandTimeouts. 0 means there is no timeout.
+func CommandTimeout(command string, flag time.Duration, flagSet bool, configured map[string]string) (time.Duration, error) {
+	if flagSet {
+		return flag, nil
+	}
+	if value, ok := configured[command]; ok {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("Invalid timeout for %s in config file: %s", command, err.Error())
+		}
+		return timeout, nil
+	}
+	return DefaultCommandTimeouts[command], nil
+}
+
+// A command which does not stop within this long after being cancelled by a signal is exited anyway, e.g. when it
+// is busy with local files and never looks at the context
+const interruptGracePeriod = 2 * time.Second
+
+var interrupts struct {
+	sync.Mutex
+	graceful chan os.Signal
+}
+
+// commandContext returns the context to run a command with: it is cancelled by SIGINT or SIGTERM, unless the command
+// asked to finish first with finishOnInterrupt, and when timeout is not zero once it has passed.
+func commandContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, timeout)
+		cancelSignal := cancel
+		cancel = func() {
+			cancelTimeout()
+			cancelSignal()
+		}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for sig := range signals {
+			interrupts.Lock()
+			graceful := interrupts.graceful
+			interrupts.graceful = nil
+			interrupts.Unlock()
+			if graceful != nil {
+				graceful <- sig
+				continue
+			}
+			if ctx.Err() != nil {
+				fatalError(exitInterrupted, "Received %s again, exiting", sig)
+			}
+			fmt.Fprintf(Stderr, "Received %s, stopping\n", sig)
+			cancel()
+			time.AfterFunc(interruptGracePeriod, func() { fatalError(exitInterrupted, "Interrupted") })
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
+
+// finishOnInterrupt delivers the next SIGINT or SIGTERM on the returned channel instead of cancelling the command, so
+// it can finish what it is in the middle of and stop cleanly. A signal after that cancels the command as usual.
+func finishOnInterrupt() <-chan os.Signal {
+	graceful := make(chan os.Signal, 1)
+	interrupts.Lock()
+	interrupts.graceful = graceful
+	interrupts.Unlock()
+	return graceful
+}
+
+// sleep waits for d unless ctx is done first, in which case its error is returned.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// contextClient makes the requests of an API client with RequestContext so they are abandoned when the command is
+// cancelled.
+type contextClient struct {
+	next httpclient.HTTPClient
+}
+
+func (c *contextClient) Do(req *http.Request) (*http.Response, error) {
+	return c.next.Do(req.WithContext(RequestContext))
+}
+
+func (c *contextClient) DoHidden(req *http.Request) (*http.Response, error) {
+	return c.next.DoHidden(req.WithContext(RequestContext))
+}
+
+// httpGet is http.Get with RequestContext, for downloads which are not API requests.
+func httpGet(url string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(req.WithContext(RequestContext))
+}
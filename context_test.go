@@ -0,0 +1,33 @@
+package main_test
+
+import (
+	"time"
+
+	. "github.com/rightscale/right_st"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Command timeout", func() {
+	configured := map[string]string{"rightscript upload": "2h", "rightscript list": "nope"}
+
+	It("Uses --timeout when it is given, even 0", func() {
+		Expect(CommandTimeout("rightscript upload", 10*time.Minute, true, configured)).To(Equal(10 * time.Minute))
+		Expect(CommandTimeout("rightscript show", 0, true, configured)).To(Equal(time.Duration(0)))
+	})
+
+	It("Uses the timeout of the command from the config file", func() {
+		Expect(CommandTimeout("rightscript upload", 0, false, configured)).To(Equal(2 * time.Hour))
+	})
+
+	It("Falls back to the default timeout of the command", func() {
+		Expect(CommandTimeout("rightscript show", 0, false, configured)).To(Equal(DefaultCommandTimeouts["rightscript show"]))
+		Expect(CommandTimeout("rightscript download", 0, false, configured)).To(Equal(time.Duration(0)))
+	})
+
+	It("Rejects an invalid duration in the config file", func() {
+		_, err := CommandTimeout("rightscript list", 0, false, configured)
+		Expect(err).To(MatchError(ContainSubstring("Invalid timeout for rightscript list in config file")))
+	})
+})
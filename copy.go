@@ -0,0 +1,254 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/rightscale/rsc/cm15"
+	"github.com/rightscale/rsc/rsapi"
+
+	"github.com/rightscale/right_st/rightscript"
+)
+
+// AttachmentSteps are the calls made to copy attachments from one RightScript to another, passed in so the order they
+// are made in can be tested without the API.
+type AttachmentSteps struct {
+	Download func(a *cm15.RightScriptAttachment, file string) error // downloads the content of a to file
+	Upload   func(name, file string) (string, error)                // uploads file as the attachment name, returning its HREF
+	Delete   func(href string) error                                // deletes the attachment at href
+}
+
+// apiAttachmentSteps returns the steps which copy attachments to the attachments at loc with the API.
+func apiAttachmentSteps(client *cm15.API, loc *cm15.RightScriptAttachmentLocator) AttachmentSteps {
+	return AttachmentSteps{
+		Download: downloadAttachment,
+		Upload: func(name, file string) (string, error) {
+			f, err := os.Open(file)
+			if err != nil {
+				return "", err
+			}
+			defer f.Close()
+			upload := rsapi.FileUpload{Name: "right_script_attachment[content]", Reader: f, Filename: name}
+			return rightScriptClient(client).UploadAttachment(loc, &upload, name)
+		},
+		Delete: func(href string) error {
+			return client.RightScriptAttachmentLocator(href).Destroy()
+		},
+	}
+}
+
+// downloadAttachment downloads the content of the attachment a to file and checks it has the md5 digest of a.
+func downloadAttachment(a *cm15.RightScriptAttachment, file string) error {
+	resp, err := httpGet(a.DownloadUrl)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return rightscript.NewResponseError(resp, body)
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	hash := md5.New()
+	_, err = io.Copy(io.MultiWriter(f, hash), resp.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if digest := hex.EncodeToString(hash.Sum(nil)); a.Digest != "" && digest != a.Digest {
+		return fmt.Errorf("downloaded content has md5 %s instead of %s", digest, a.Digest)
+	}
+	return nil
+}
+
+// stage downloads attachments into a new temporary directory before anything is changed, so a failed download leaves
+// the RightScripts alone. The files are returned in the same order as the attachments along with the directory, which
+// has to be removed with removeTempDir.
+func (steps AttachmentSteps) stage(attachments []*cm15.RightScriptAttachment) ([]string, string, error) {
+	dir, err := rightscript.MakeTempDir("right_st_copy")
+	if err != nil {
+		return nil, "", err
+	}
+	files := make([]string, len(attachments))
+	for i, a := range attachments {
+		fmt.Fprintf(Stdout, "  Downloading attachment '%s' with md5 %s\n", a.Filename, a.Digest)
+		// numbered files so attachment names never clash or escape the directory
+		files[i] = filepath.Join(dir, strconv.Itoa(i))
+		if err := steps.Download(a, files[i]); err != nil {
+			rightscript.RemoveTempDir(dir)
+			return nil, "", fmt.Errorf("Could not download attachment '%s': %s", a.Filename, err.Error())
+		}
+	}
+	return files, dir, nil
+}
+
+// upload uploads the staged files as the attachments and returns the HREFs of the attachments uploaded, which are the
+// ones before the failed one when an upload fails.
+func (steps AttachmentSteps) upload(attachments []*cm15.RightScriptAttachment, files []string) ([]string, error) {
+	hrefs := []string{}
+	for i, a := range attachments {
+		href, err := steps.uploadOne(a, files[i])
+		if err != nil {
+			return hrefs, err
+		}
+		hrefs = append(hrefs, href)
+	}
+	return hrefs, nil
+}
+
+// uploadOne uploads the staged file as the attachment a and returns its HREF.
+func (steps AttachmentSteps) uploadOne(a *cm15.RightScriptAttachment, file string) (string, error) {
+	fmt.Fprintf(Stdout, "  Copying attachment '%s' with md5 %s\n", a.Filename, a.Digest)
+	href, err := steps.Upload(a.Filename, file)
+	if err != nil {
+		return "", fmt.Errorf("Could not upload attachment '%s': %s", a.Filename, err.Error())
+	}
+	return href, nil
+}
+
+// deleteAttachment deletes the attachment name at href.
+func (steps AttachmentSteps) deleteAttachment(name, href string) error {
+	if href == "" {
+		return fmt.Errorf("Could not delete attachment '%s': its HREF is not known", name)
+	}
+	fmt.Fprintf(Stdout, "  Deleting attachment '%s' with HREF %s\n", name, href)
+	if err := steps.Delete(href); err != nil {
+		return fmt.Errorf("Could not delete attachment '%s': %s", name, err.Error())
+	}
+	return nil
+}
+
+// CloneAttachments copies attachments onto a clone which was just created. All of them are downloaded before any is
+// uploaded, and when copying fails deleteClone is called so no half made clone is left in the account.
+func CloneAttachments(attachments []*cm15.RightScriptAttachment, steps AttachmentSteps, deleteClone func() error) error {
+	files, dir, err := steps.stage(attachments)
+	if err == nil {
+		defer rightscript.RemoveTempDir(dir)
+		_, err = steps.upload(attachments, files)
+	}
+	if err != nil {
+		if deleteErr := deleteClone(); deleteErr != nil {
+			return fmt.Errorf("%s, and the clone could not be deleted: %s", err.Error(), deleteErr.Error())
+		}
+	}
+	return err
+}
+
+// AttachmentMove is how the attachments of the target of a move change: Add are source attachments with names the
+// target has no attachment for, Replace are source attachments replacing the target attachments in Replaced which have
+// the same names but other content, and Remove are target attachments the source has no attachment for.
+type AttachmentMove struct {
+	Add      []*cm15.RightScriptAttachment
+	Replace  []*cm15.RightScriptAttachment
+	Replaced []*cm15.RightScriptAttachment
+	Remove   []*cm15.RightScriptAttachment
+}
+
+// PlanAttachmentMove works out how the target attachments change when the source attachments are moved onto them.
+// Attachments with the same name and md5 digest are kept as they are.
+func PlanAttachmentMove(source, target []*cm15.RightScriptAttachment) AttachmentMove {
+	existing := make(map[string]*cm15.RightScriptAttachment, len(target))
+	for _, a := range target {
+		existing[a.Filename] = a
+	}
+	var move AttachmentMove
+	moved := make(map[string]bool, len(source))
+	for _, a := range source {
+		moved[a.Filename] = true
+		if e, ok := existing[a.Filename]; ok {
+			if e.Digest != a.Digest {
+				move.Replace = append(move.Replace, a)
+				move.Replaced = append(move.Replaced, e)
+			}
+			continue
+		}
+		move.Add = append(move.Add, a)
+	}
+	for _, a := range target {
+		if !moved[a.Filename] {
+			move.Remove = append(move.Remove, a)
+		}
+	}
+	return move
+}
+
+// MoveAttachments changes the attachments of the target of a move as planned and calls updateTarget to update the rest
+// of it. Every attachment which is copied or replaced is downloaded first so a failed download changes nothing. When
+// adding or replacing an attachment or updating the target fails, the attachments changed so far are put back the way
+// they were. Attachments the source does not have are only removed once everything else has succeeded.
+func MoveAttachments(move AttachmentMove, steps AttachmentSteps, updateTarget func() error) error {
+	staged := append(append(append([]*cm15.RightScriptAttachment{}, move.Add...), move.Replace...), move.Replaced...)
+	files, dir, err := steps.stage(staged)
+	if err != nil {
+		return err
+	}
+	defer rightscript.RemoveTempDir(dir)
+	addFiles := files[:len(move.Add)]
+	replaceFiles := files[len(move.Add) : len(move.Add)+len(move.Replace)]
+	replacedFiles := files[len(move.Add)+len(move.Replace):]
+
+	var undo []func() error
+	rollBack := func(err error) error {
+		for i := len(undo) - 1; i >= 0; i-- {
+			if undoErr := undo[i](); undoErr != nil {
+				fmt.Fprintf(Stderr, "WARNING: %s\n", undoErr.Error())
+			}
+		}
+		return err
+	}
+
+	for i, a := range move.Add {
+		a := a
+		href, err := steps.uploadOne(a, addFiles[i])
+		if err != nil {
+			return rollBack(err)
+		}
+		undo = append(undo, func() error { return steps.deleteAttachment(a.Filename, href) })
+	}
+	for i, a := range move.Replace {
+		a, old, oldFile := a, move.Replaced[i], replacedFiles[i]
+		if err := steps.deleteAttachment(old.Filename, rightscript.Link(old.Links, "self")); err != nil {
+			return rollBack(err)
+		}
+		restore := func() error {
+			fmt.Fprintf(Stdout, "  Restoring attachment '%s' with md5 %s\n", old.Filename, old.Digest)
+			if _, err := steps.Upload(old.Filename, oldFile); err != nil {
+				return fmt.Errorf("Could not restore attachment '%s': %s", old.Filename, err.Error())
+			}
+			return nil
+		}
+		href, err := steps.uploadOne(a, replaceFiles[i])
+		if err != nil {
+			undo = append(undo, restore)
+			return rollBack(err)
+		}
+		undo = append(undo, func() error {
+			if err := steps.deleteAttachment(a.Filename, href); err != nil {
+				return err
+			}
+			return restore()
+		})
+	}
+	if err := updateTarget(); err != nil {
+		return rollBack(err)
+	}
+
+	for _, a := range move.Remove {
+		if err := steps.deleteAttachment(a.Filename, rightscript.Link(a.Links, "self")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package main_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+
+	"github.com/rightscale/rsc/cm15"
+
+	. "github.com/rightscale/right_st"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// fakeAttachmentSteps records the attachment steps made, in order, and fails the next of the ones named in fail
+type fakeAttachmentSteps struct {
+	calls []string
+	fail  map[string]bool
+}
+
+func (f *fakeAttachmentSteps) steps() AttachmentSteps {
+	return AttachmentSteps{
+		Download: func(a *cm15.RightScriptAttachment, file string) error {
+			f.calls = append(f.calls, "download "+a.Filename)
+			if f.fail["download "+a.Filename] {
+				delete(f.fail, "download "+a.Filename)
+				return errors.New("invalid response 404 Not Found: gone")
+			}
+			return ioutil.WriteFile(file, []byte(a.Filename), 0644)
+		},
+		Upload: func(name, file string) (string, error) {
+			f.calls = append(f.calls, "upload "+name)
+			if f.fail["upload "+name] {
+				delete(f.fail, "upload "+name)
+				return "", errors.New("invalid response 500 Internal Server Error: oops")
+			}
+			if content, err := ioutil.ReadFile(file); err != nil || string(content) != name {
+				return "", errors.New("staged file does not hold " + name)
+			}
+			return "/new/" + name, nil
+		},
+		Delete: func(href string) error {
+			f.calls = append(f.calls, "delete "+href)
+			if f.fail["delete "+href] {
+				delete(f.fail, "delete "+href)
+				return errors.New("invalid response 403 Forbidden: nope")
+			}
+			return nil
+		},
+	}
+}
+
+var _ = Describe("Copying attachments", func() {
+	var (
+		fake             *fakeAttachmentSteps
+		stdout, stderr   io.Writer
+		output, warnings bytes.Buffer
+		deleted          bool
+		deleteFunc       func() error
+	)
+	attachments := []*cm15.RightScriptAttachment{{Filename: "a.txt"}, {Filename: "b.txt"}}
+
+	BeforeEach(func() {
+		fake = &fakeAttachmentSteps{fail: map[string]bool{}}
+		output.Reset()
+		warnings.Reset()
+		stdout, Stdout = Stdout, &output
+		stderr, Stderr = Stderr, &warnings
+		deleted = false
+		deleteFunc = func() error {
+			deleted = true
+			return nil
+		}
+	})
+
+	AfterEach(func() {
+		Stdout = stdout
+		Stderr = stderr
+	})
+
+	Context("When cloning", func() {
+		It("Downloads every attachment before uploading any", func() {
+			Expect(CloneAttachments(attachments, fake.steps(), deleteFunc)).To(Succeed())
+			Expect(fake.calls).To(Equal([]string{"download a.txt", "download b.txt", "upload a.txt", "upload b.txt"}))
+			Expect(deleted).To(BeFalse())
+		})
+
+		It("Deletes the clone when a download fails", func() {
+			fake.fail["download b.txt"] = true
+			err := CloneAttachments(attachments, fake.steps(), deleteFunc)
+			Expect(err).To(MatchError("Could not download attachment 'b.txt': invalid response 404 Not Found: gone"))
+			Expect(fake.calls).To(Equal([]string{"download a.txt", "download b.txt"}))
+			Expect(deleted).To(BeTrue())
+		})
+
+		It("Deletes the clone when an upload fails", func() {
+			fake.fail["upload b.txt"] = true
+			err := CloneAttachments(attachments, fake.steps(), deleteFunc)
+			Expect(err).To(MatchError(
+				"Could not upload attachment 'b.txt': invalid response 500 Internal Server Error: oops"))
+			Expect(deleted).To(BeTrue())
+		})
+
+		It("Reports when the clone cannot be deleted either", func() {
+			fake.fail["upload a.txt"] = true
+			err := CloneAttachments(attachments, fake.steps(), func() error { return errors.New("forbidden") })
+			Expect(err).To(MatchError(
+				"Could not upload attachment 'a.txt': invalid response 500 Internal Server Error: oops, and the clone " +
+					"could not be deleted: forbidden"))
+		})
+	})
+
+	Context("When moving", func() {
+		attachment := func(name, digest string) *cm15.RightScriptAttachment {
+			return &cm15.RightScriptAttachment{Filename: name, Digest: digest,
+				Links: []map[string]string{{"rel": "self", "href": "/old/" + name}}}
+		}
+		source := []*cm15.RightScriptAttachment{attachment("same.txt", "1"), attachment("new.txt", "2"),
+			attachment("changed.txt", "3")}
+		target := []*cm15.RightScriptAttachment{attachment("same.txt", "1"), attachment("changed.txt", "4"),
+			attachment("gone.txt", "5")}
+		move := PlanAttachmentMove(source, target)
+
+		var updated bool
+		updateTarget := func() error {
+			fake.calls = append(fake.calls, "update")
+			if fake.fail["update"] {
+				delete(fake.fail, "update")
+				return errors.New("invalid response 422 Unprocessable Entity: bad")
+			}
+			updated = true
+			return nil
+		}
+
+		BeforeEach(func() {
+			updated = false
+		})
+
+		It("Plans which attachments are added, replaced, and removed", func() {
+			Expect(move.Add).To(Equal([]*cm15.RightScriptAttachment{source[1]}))
+			Expect(move.Replace).To(Equal([]*cm15.RightScriptAttachment{source[2]}))
+			Expect(move.Replaced).To(Equal([]*cm15.RightScriptAttachment{target[1]}))
+			Expect(move.Remove).To(Equal([]*cm15.RightScriptAttachment{target[2]}))
+		})
+
+		It("Downloads everything first and removes attachments last", func() {
+			Expect(MoveAttachments(move, fake.steps(), updateTarget)).To(Succeed())
+			Expect(fake.calls).To(Equal([]string{"download new.txt", "download changed.txt", "download changed.txt",
+				"upload new.txt", "delete /old/changed.txt", "upload changed.txt", "update", "delete /old/gone.txt"}))
+			Expect(updated).To(BeTrue())
+		})
+
+		It("Changes nothing when a download fails", func() {
+			fake.fail["download changed.txt"] = true
+			Expect(MoveAttachments(move, fake.steps(), updateTarget)).To(MatchError(
+				"Could not download attachment 'changed.txt': invalid response 404 Not Found: gone"))
+			Expect(fake.calls).To(Equal([]string{"download new.txt", "download changed.txt"}))
+		})
+
+		It("Changes nothing when adding the first attachment fails", func() {
+			fake.fail["upload new.txt"] = true
+			Expect(MoveAttachments(move, fake.steps(), updateTarget)).To(MatchError(
+				"Could not upload attachment 'new.txt': invalid response 500 Internal Server Error: oops"))
+			Expect(fake.calls[3:]).To(Equal([]string{"upload new.txt"}))
+		})
+
+		It("Restores a replaced attachment and deletes added ones when replacing fails", func() {
+			fake.fail["upload changed.txt"] = true
+			Expect(MoveAttachments(move, fake.steps(), updateTarget)).To(MatchError(
+				"Could not upload attachment 'changed.txt': invalid response 500 Internal Server Error: oops"))
+			Expect(fake.calls[3:]).To(Equal([]string{"upload new.txt", "delete /old/changed.txt", "upload changed.txt",
+				"upload changed.txt", "delete /new/new.txt"}))
+			Expect(updated).To(BeFalse())
+		})
+
+		It("Puts the attachments back without removing any when updating the target fails", func() {
+			fake.fail["update"] = true
+			Expect(MoveAttachments(move, fake.steps(), updateTarget)).To(MatchError(
+				"invalid response 422 Unprocessable Entity: bad"))
+			Expect(fake.calls[3:]).To(Equal([]string{"upload new.txt", "delete /old/changed.txt", "upload changed.txt",
+				"update", "delete /new/changed.txt", "upload changed.txt", "delete /new/new.txt"}))
+		})
+
+		It("Prints each step and warns about attachments which cannot be put back", func() {
+			fake.fail["update"] = true
+			fake.fail["delete /new/new.txt"] = true
+			Expect(MoveAttachments(move, fake.steps(), updateTarget)).To(MatchError(
+				"invalid response 422 Unprocessable Entity: bad"))
+			Expect(output.String()).To(Equal("  Downloading attachment 'new.txt' with md5 2\n" +
+				"  Downloading attachment 'changed.txt' with md5 3\n" +
+				"  Downloading attachment 'changed.txt' with md5 4\n" +
+				"  Copying attachment 'new.txt' with md5 2\n" +
+				"  Deleting attachment 'changed.txt' with HREF /old/changed.txt\n" +
+				"  Copying attachment 'changed.txt' with md5 3\n" +
+				"  Deleting attachment 'changed.txt' with HREF /new/changed.txt\n" +
+				"  Restoring attachment 'changed.txt' with md5 4\n" +
+				"  Deleting attachment 'new.txt' with HREF /new/new.txt\n"))
+			Expect(warnings.String()).To(Equal(
+				"WARNING: Could not delete attachment 'new.txt': invalid response 403 Forbidden: nope\n"))
+		})
+	})
+})
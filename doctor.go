@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/rightscale/right_st/rightscript"
+)
+
+// Clocks further apart than this from the API endpoint host make OAuth tokens look expired or not yet valid
+const maxClockSkew = 5 * time.Minute
+
+// DoctorCheck is the outcome of one of the checks made by the doctor command. A check which is skipped because an
+// earlier one it depends on failed has Skipped set.
+type DoctorCheck struct {
+	Name    string
+	Err     error
+	Hint    string // how to fix a failure
+	Skipped bool
+}
+
+// PrintDoctorChecks writes a checklist of the checks followed by the first failure as the most likely problem, and
+// reports whether every check passed.
+func PrintDoctorChecks(w io.Writer, checks []DoctorCheck) bool {
+	var first *DoctorCheck
+	for index, check := range checks {
+		switch {
+		case check.Skipped:
+			fmt.Fprintf(w, "[SKIP] %s\n", check.Name)
+		case check.Err == nil:
+			fmt.Fprintf(w, "[PASS] %s\n", check.Name)
+		default:
+			fmt.Fprintf(w, "[FAIL] %s: %s\n", check.Name, check.Err.Error())
+			if check.Hint != "" {
+				fmt.Fprintf(w, "       %s\n", check.Hint)
+			}
+			if first == nil {
+				first = &checks[index]
+			}
+		}
+	}
+	if first == nil {
+		fmt.Fprintln(w, "Everything looks fine")
+		return true
+	}
+	fmt.Fprintf(w, "Most likely problem: %s\n", first.Name)
+	if first.Hint != "" {
+		fmt.Fprintf(w, "  %s\n", first.Hint)
+	}
+	return false
+}
+
+// ClockSkew returns how far the local clock at now is ahead of the server according to the Date header of one of its
+// responses.
+func ClockSkew(date string, now time.Time) (time.Duration, error) {
+	serverTime, err := http.ParseTime(date)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid Date header '%s'", date)
+	}
+	return now.Sub(serverTime), nil
+}
+
+// doctor checks everything needed to use the API step by step, from the config file read by ReadConfig with the error
+// configErr to a request with the token, and prints which steps fail and how to fix them.
+func doctor(configFile string, configErr error) {
+	checks := []DoctorCheck{}
+	failed := false
+	check := func(name, hint string, f func() error) {
+		if failed {
+			checks = append(checks, DoctorCheck{Name: name, Skipped: true})
+			return
+		}
+		err := f()
+		failed = err != nil
+		checks = append(checks, DoctorCheck{Name: name, Err: err, Hint: hint})
+	}
+
+	fromEnvironment := environmentAccount()
+	check("Config file exists", fmt.Sprintf("Run '%s config account <name>' to create %s, pass --config, or set the "+
+		"RIGHT_ST_LOGIN_ACCOUNT_ID, RIGHT_ST_LOGIN_ACCOUNT_HOST, and RIGHT_ST_LOGIN_ACCOUNT_REFRESH_TOKEN environment variables",
+		app.Name, configFile), func() error {
+		if fromEnvironment {
+			return nil
+		}
+		for _, file := range SplitConfigFiles(configFile) {
+			if _, err := os.Stat(file); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	check("Config file is valid", fmt.Sprintf("Fix the error in %s, or select an account which exists with --account", configFile),
+		func() error { return configErr })
+	check("Account has an ID, host, and token", fmt.Sprintf("Run '%s config account %s' to set them", app.Name, Config.AccountName),
+		func() error {
+			a := Config.Account
+			switch {
+			case a == nil:
+				return fmt.Errorf("No account has been selected")
+			case a.Id == 0:
+				return fmt.Errorf("The account ID is missing")
+			case a.Host == "":
+				return fmt.Errorf("The API endpoint host is missing")
+			case a.RefreshToken == "" && a.AccessToken == "":
+				return fmt.Errorf("Neither a refresh token nor an access token is set")
+			}
+			return nil
+		})
+	check("API endpoint host resolves", "Check the host (e.g. us-3.rightscale.com) and your DNS settings",
+		func() error { return Config.Account.validate() })
+	check("API endpoint host is reachable", "Check your network connection, firewall, and proxy settings", func() error {
+		address := Config.Account.Host
+		if BaseURL != nil {
+			address = BaseURL.Host
+		}
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			port := "443"
+			if BaseURL != nil && BaseURL.Scheme == "http" {
+				port = "80"
+			}
+			address = net.JoinHostPort(address, port)
+		}
+		conn, err := net.DialTimeout("tcp", address, 10*time.Second)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	})
+
+	// the response to a request with the token tells whether the token is accepted and, even when it is not, the time
+	// on the server, and a clock which is off is the more likely reason for a rejected token
+	var date string
+	var tokenErr error
+	if !failed {
+		date, tokenErr = sessionRequest()
+	}
+	check("Clock is in sync with the API endpoint host", "Synchronize your clock, e.g. with NTP", func() error {
+		if date == "" {
+			return nil
+		}
+		skew, err := ClockSkew(date, time.Now())
+		if err != nil {
+			return err
+		}
+		if skew > maxClockSkew || skew < -maxClockSkew {
+			return fmt.Errorf("The local clock is %s off", skew)
+		}
+		return nil
+	})
+	check("Token is accepted", Config.TokenHelp(), func() error { return tokenErr })
+
+	if !PrintDoctorChecks(Stdout, checks) {
+		exit(exitGeneral)
+	}
+}
+
+// sessionRequest makes a trivial API request with the token of the selected account, returning the Date header of the
+// response if there was one.
+func sessionRequest() (date string, err error) {
+	if expired, err := Config.Account.AccessTokenExpired(time.Now()); err != nil || expired {
+		if err == nil {
+			err = fmt.Errorf("The access token expired at %s", Config.Account.AccessTokenExpiresAt)
+		}
+		return "", err
+	}
+	client, err := Config.Account.Client15()
+	if err != nil {
+		return "", err
+	}
+	req, err := client.BuildHTTPRequest("GET", "/api/sessions", defaultAPIVersion, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.PerformRequest(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	date = resp.Header.Get("Date")
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return date, rightscript.NewResponseError(resp, body)
+	}
+	return date, nil
+}
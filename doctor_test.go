@@ -0,0 +1,46 @@
+package main_test
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	. "github.com/rightscale/right_st"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Doctor", func() {
+	It("Prints a checklist with the first failure as the most likely problem", func() {
+		buffer := new(bytes.Buffer)
+		passed := PrintDoctorChecks(buffer, []DoctorCheck{
+			{Name: "Config file exists"},
+			{Name: "API endpoint host resolves", Err: errors.New("no such host"), Hint: "Check the host"},
+			{Name: "Token is accepted", Skipped: true},
+		})
+		Expect(passed).To(BeFalse())
+		Expect(buffer.String()).To(Equal("[PASS] Config file exists\n" +
+			"[FAIL] API endpoint host resolves: no such host\n" +
+			"       Check the host\n" +
+			"[SKIP] Token is accepted\n" +
+			"Most likely problem: API endpoint host resolves\n" +
+			"  Check the host\n"))
+	})
+
+	It("Reports when every check passed", func() {
+		buffer := new(bytes.Buffer)
+		Expect(PrintDoctorChecks(buffer, []DoctorCheck{{Name: "Config file exists"}})).To(BeTrue())
+		Expect(buffer.String()).To(HaveSuffix("Everything looks fine\n"))
+	})
+
+	It("Measures the clock skew from a Date header", func() {
+		now := time.Date(2016, 5, 10, 12, 10, 0, 0, time.UTC)
+		skew, err := ClockSkew("Tue, 10 May 2016 12:00:00 GMT", now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(skew).To(Equal(10 * time.Minute))
+
+		_, err = ClockSkew("yesterday", now)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/go-yaml/yaml"
+	"github.com/rightscale/rsc/cm15"
+	"github.com/rightscale/rsc/rsapi"
+
+	"github.com/rightscale/right_st/rightscript"
+)
+
+// MetadataExport is the document written by rightscript export and read by rightscript import.
+type MetadataExport struct {
+	RightScripts []ExportedRightScript `yaml:"RightScripts"`
+}
+
+// ExportedRightScript is the metadata of a single RightScript in a MetadataExport. Attachments maps the name of each
+// attachment to its md5 digest.
+type ExportedRightScript struct {
+	Href        string               `yaml:"Href,omitempty"`
+	Name        string               `yaml:"RightScript Name"`
+	Description string               `yaml:"Description,omitempty"`
+	Packages    string               `yaml:"Packages,omitempty"`
+	Inputs      rightscript.InputMap `yaml:"Inputs"`
+	Attachments map[string]string    `yaml:"Attachments,omitempty"`
+}
+
+type exportedByName []ExportedRightScript
+
+func (scripts exportedByName) Len() int           { return len(scripts) }
+func (scripts exportedByName) Less(i, j int) bool { return scripts[i].Name < scripts[j].Name }
+func (scripts exportedByName) Swap(i, j int)      { scripts[i], scripts[j] = scripts[j], scripts[i] }
+
+// WriteMetadataExport writes the export sorted by name as YAML or, when format is "json", as JSON.
+func WriteMetadataExport(w io.Writer, export MetadataExport, format string) error {
+	sort.Sort(exportedByName(export.RightScripts))
+	data, err := yaml.Marshal(export)
+	if err != nil {
+		return err
+	}
+	if format == "json" {
+		// the inputs only know how to marshal themselves to YAML, so go through YAML to get the same document
+		var document interface{}
+		if err := yaml.Unmarshal(data, &document); err != nil {
+			return err
+		}
+		if data, err = json.MarshalIndent(jsonValue(document), "", "  "); err != nil {
+			return err
+		}
+		data = append(data, '\n')
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// jsonValue converts the maps of a value unmarshaled from YAML to maps with string keys for encoding/json.
+func jsonValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			converted[fmt.Sprintf("%v", k)] = jsonValue(v)
+		}
+		return converted
+	case []interface{}:
+		for i, v := range value {
+			value[i] = jsonValue(v)
+		}
+	}
+	return value
+}
+
+// ReadMetadataExport reads an export written by WriteMetadataExport in either format, since JSON is also YAML.
+func ReadMetadataExport(r io.Reader) (*MetadataExport, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var export MetadataExport
+	if err := yaml.Unmarshal(data, &export); err != nil {
+		return nil, err
+	}
+	for _, script := range export.RightScripts {
+		if script.Name == "" {
+			return nil, fmt.Errorf("Every RightScript must have a RightScript Name")
+		}
+	}
+	return &export, nil
+}
+
+// rightScriptExport writes the metadata of every HEAD RightScript with a name matching filter to output, or stdout
+// when output is empty.
+func rightScriptExport(filter, output, format string) {
+	client, err := Config.Account.Client15()
+	if err != nil {
+		fatalError(exitConfig, "Could not export RightScripts: %s", err.Error())
+	}
+	params := rsapi.APIParams{"view": "inputs_2_0"}
+	if filter != "" {
+		nameFilter, err := rightscript.NameFilter(filter)
+		if err != nil {
+			fatalError(exitGeneral, "Could not export RightScripts: %s", err.Error())
+		}
+		params["filter"] = []string{nameFilter}
+	}
+	rightscripts, err := client.RightScriptLocator("/api/right_scripts").Index(params)
+	if err != nil {
+		fatalError(ErrorExitCode(err), "Could not export RightScripts: %s", err.Error())
+	}
+
+	export := MetadataExport{RightScripts: []ExportedRightScript{}}
+	for _, rs := range rightscripts {
+		if rs.Revision != 0 || !nameContains(rs.Name, filter) {
+			continue
+		}
+		href := rightscript.Link(rs.Links, "self")
+		attachments, err := client.RightScriptAttachmentLocator(href + "/attachments").Index(rsapi.APIParams{})
+		if err != nil {
+			fatalError(ErrorExitCode(err), "Could not find attachments for RightScript with href %s: %s", href, err.Error())
+		}
+		inputs := rightscript.InputMap{}
+		for _, input := range rs.Inputs {
+			inputs = append(inputs, rightscript.JsonMapToInput(input))
+		}
+		exported := ExportedRightScript{
+			Href:        href,
+			Name:        rs.Name,
+			Description: rightscript.RemoveCarriageReturns(rs.Description),
+			Packages:    rs.Packages,
+			Inputs:      inputs,
+		}
+		if len(attachments) > 0 {
+			exported.Attachments = make(map[string]string, len(attachments))
+			for _, a := range attachments {
+				exported.Attachments[a.Filename] = a.Digest
+			}
+		}
+		export.RightScripts = append(export.RightScripts, exported)
+	}
+
+	write := func(w io.Writer) error { return WriteMetadataExport(w, export, format) }
+	if output == "" {
+		err = write(Stdout)
+	} else {
+		err = rightscript.WriteFileAtomic(output, 0644, write)
+	}
+	if err != nil {
+		fatalError(exitGeneral, "Could not write export: %s", err.Error())
+	}
+	if output != "" {
+		fmt.Fprintf(Stdout, "Exported the metadata of %d RightScripts to %s\n", len(export.RightScripts), output)
+	}
+}
+
+// rightScriptImport applies the metadata in an export to the HEAD of the RightScripts it names: the description and
+// packages, and the inputs in the metadata comment of the source. The rest of the source and the attachments are left
+// alone, attachments which differ from the export are only reported.
+func rightScriptImport(file string, dryRun bool) {
+	f, err := os.Open(file)
+	if err != nil {
+		fatalError(exitGeneral, "Could not read export: %s", err.Error())
+	}
+	export, err := ReadMetadataExport(f)
+	f.Close()
+	if err != nil {
+		fatalError(exitValidation, "%s: %s", file, err.Error())
+	}
+	client, err := Config.Account.Client15()
+	if err != nil {
+		fatalError(exitConfig, "Could not import RightScripts: %s", err.Error())
+	}
+
+	for _, script := range export.RightScripts {
+		id, err := rightScriptClient(client).IdByName(script.Name)
+		if err != nil {
+			fatalError(ErrorExitCode(err), "%s", err.Error())
+		}
+		if id == "" {
+			fatalError(exitNotFound, "Could not find RightScript named '%s'", script.Name)
+		}
+		href := fmt.Sprintf("/api/right_scripts/%s", id)
+		if err := importMetadata(client, href, script, dryRun); err != nil {
+			fatalError(ErrorExitCode(err), "Could not import metadata for RightScript '%s': %s", script.Name, err.Error())
+		}
+	}
+}
+
+func importMetadata(client *cm15.API, href string, script ExportedRightScript, dryRun bool) error {
+	loc := client.RightScriptLocator(href)
+	existing, err := loc.Show(rsapi.APIParams{})
+	if err != nil {
+		return err
+	}
+	source, err := rightScriptClient(client).Source(loc)
+	if err != nil {
+		return err
+	}
+	metadata, err := rightscript.ParseRightScriptMetadata(bytes.NewReader(source))
+	if err != nil {
+		return fmt.Errorf("metadata in the source is malformed: %s", err.Error())
+	}
+	if metadata == nil {
+		metadata = &rightscript.RightScriptMetadata{Name: existing.Name, Attachments: []string{}}
+	}
+	metadata.Description = script.Description
+	metadata.Packages = script.Packages
+	metadata.Inputs = script.Inputs
+	if metadata.Inputs == nil {
+		metadata.Inputs = rightscript.InputMap{}
+	}
+	newSource, err := rightscript.ScaffoldBuffer(source, *metadata, "", "", false)
+	if err != nil {
+		return err
+	}
+
+	attachments, err := client.RightScriptAttachmentLocator(href + "/attachments").Index(rsapi.APIParams{})
+	if err != nil {
+		return err
+	}
+	for _, a := range attachments {
+		if digest, ok := script.Attachments[a.Filename]; ok && digest != a.Digest {
+			fmt.Fprintf(Stdout, "  WARNING: Attachment '%s' of '%s' has md5 %s but %s in the export, it is not changed\n",
+				a.Filename, existing.Name, a.Digest, digest)
+		}
+	}
+
+	sourceChanged := !bytes.Equal(newSource, source)
+	if !sourceChanged && existing.Description == script.Description && existing.Packages == script.Packages {
+		fmt.Fprintf(Stdout, "Metadata of '%s' with HREF %s unchanged\n", existing.Name, href)
+		return nil
+	}
+	if dryRun {
+		fmt.Fprintf(Stdout, "Would update metadata of '%s' with HREF %s\n", existing.Name, href)
+		return nil
+	}
+	fmt.Fprintf(Stdout, "Updating metadata of '%s' with HREF %s\n", existing.Name, href)
+	params := cm15.RightScriptParam3{Name: existing.Name, Description: script.Description, Packages: script.Packages}
+	if sourceChanged {
+		params.Source = string(newSource)
+	}
+	return loc.Update(&params)
+}
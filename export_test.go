@@ -0,0 +1,57 @@
+package main_test
+
+import (
+	"bytes"
+
+	. "github.com/rightscale/right_st"
+	"github.com/rightscale/right_st/rightscript"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Metadata export", func() {
+	export := MetadataExport{RightScripts: []ExportedRightScript{
+		{
+			Href: "/api/right_scripts/2",
+			Name: "Second Script",
+			Inputs: rightscript.InputMap{
+				{Name: "APP_NAME", Category: "Application", InputType: rightscript.Single, Default: &rightscript.InputValue{Type: "text", Value: "app"}},
+			},
+			Attachments: map[string]string{"app.tgz": "0123456789abcdef0123456789abcdef"},
+		},
+		{Href: "/api/right_scripts/1", Name: "First Script", Description: "Runs first", Inputs: rightscript.InputMap{}},
+	}}
+
+	It("Writes YAML sorted by name which reads back the same", func() {
+		buffer := new(bytes.Buffer)
+		Expect(WriteMetadataExport(buffer, export, "yaml")).To(Succeed())
+		Expect(buffer.String()).To(HavePrefix("RightScripts:\n- Href: /api/right_scripts/1\n  RightScript Name: First Script\n"))
+
+		read, err := ReadMetadataExport(buffer)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(read.RightScripts).To(HaveLen(2))
+		Expect(read.RightScripts[1].Name).To(Equal("Second Script"))
+		Expect(read.RightScripts[1].Inputs).To(HaveLen(1))
+		Expect(read.RightScripts[1].Inputs[0].Name).To(Equal("APP_NAME"))
+		Expect(read.RightScripts[1].Inputs[0].Default).To(Equal(&rightscript.InputValue{Type: "text", Value: "app"}))
+		Expect(read.RightScripts[1].Attachments).To(Equal(map[string]string{"app.tgz": "0123456789abcdef0123456789abcdef"}))
+	})
+
+	It("Writes JSON which reads back the same", func() {
+		buffer := new(bytes.Buffer)
+		Expect(WriteMetadataExport(buffer, export, "json")).To(Succeed())
+		Expect(buffer.String()).To(HavePrefix("{\n"))
+
+		read, err := ReadMetadataExport(buffer)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(read.RightScripts).To(HaveLen(2))
+		Expect(read.RightScripts[0].Description).To(Equal("Runs first"))
+		Expect(read.RightScripts[1].Inputs[0].Category).To(Equal("Application"))
+	})
+
+	It("Requires a name for every RightScript", func() {
+		_, err := ReadMetadataExport(bytes.NewBufferString("RightScripts:\n- Description: No name\n  Inputs: {}\n"))
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/rightscale/rsc/cm15"
+	"github.com/rightscale/rsc/rsapi"
+
+	"github.com/rightscale/right_st/rightscript"
+)
+
+// RightScriptHistoryItem is a change to a RightScript lineage: the commit of a revision, or the last modification of
+// HEAD when Revision is 0. The API does not say who made a change, so there is no updater.
+type RightScriptHistoryItem struct {
+	Time     time.Time `json:"time"`
+	Revision int       `json:"revision"`
+	Href     string    `json:"href"`
+	Name     string    `json:"name"`
+}
+
+type historyByTime []RightScriptHistoryItem
+
+func (items historyByTime) Len() int { return len(items) }
+func (items historyByTime) Less(i, j int) bool {
+	if !items[i].Time.Equal(items[j].Time) {
+		return items[i].Time.Before(items[j].Time)
+	}
+	// HEAD is modified after the revisions are committed from it
+	if items[i].Revision == 0 || items[j].Revision == 0 {
+		return items[j].Revision == 0 && items[i].Revision != 0
+	}
+	return items[i].Revision < items[j].Revision
+}
+func (items historyByTime) Swap(i, j int) { items[i], items[j] = items[j], items[i] }
+
+// RightScriptHistory returns the changes to the RightScripts of a lineage in chronological order.
+func RightScriptHistory(rightscripts []*cm15.RightScript) []RightScriptHistoryItem {
+	items := []RightScriptHistoryItem{}
+	for _, rs := range rightscripts {
+		item := RightScriptHistoryItem{Revision: rs.Revision, Href: rightscript.Link(rs.Links, "self"), Name: rs.Name}
+		switch {
+		case rs.Revision == 0 && rs.UpdatedAt != nil:
+			item.Time = rs.UpdatedAt.Time
+		case rs.CreatedAt != nil:
+			item.Time = rs.CreatedAt.Time
+		}
+		items = append(items, item)
+	}
+	sort.Sort(historyByTime(items))
+	return items
+}
+
+// PrintRightScriptHistory writes one line per change with the time, the revision, and the name.
+func PrintRightScriptHistory(w io.Writer, items []RightScriptHistoryItem) error {
+	for _, item := range items {
+		when := "unknown time"
+		if !item.Time.IsZero() {
+			when = item.Time.UTC().Format(time.RFC3339)
+		}
+		change := fmt.Sprintf("committed revision %d", item.Revision)
+		if item.Revision == 0 {
+			change = "last modified HEAD"
+		}
+		if _, err := fmt.Fprintf(w, "%-20s  %-24s  %s  %s\n", when, change, item.Href, item.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rightScriptHistory prints the history of the lineage of the RightScript at href.
+func rightScriptHistory(href, format string) {
+	client, err := Config.Account.Client15()
+	if err != nil {
+		fatalError(exitConfig, "Could not find rightscript with href %s: %s", href, err.Error())
+	}
+	rs, err := client.RightScriptLocator(href).Show(rsapi.APIParams{})
+	if err != nil {
+		fatalError(ErrorExitCode(err), "Could not find rightscript with href %s: %s", href, err.Error())
+	}
+	lineage, err := client.RightScriptLocator("/api/right_scripts").Index(rsapi.APIParams{
+		"filter": []string{"lineage==" + rs.Lineage},
+	})
+	if err != nil {
+		fatalError(ErrorExitCode(err), "Could not list revisions of RightScript with href %s: %s", href, err.Error())
+	}
+	// the lineage filter is matched by the API, so make sure only the lineage is shown
+	revisions := []*cm15.RightScript{}
+	for _, rs := range lineage {
+		if rs.Lineage == rs.Lineage {
+			revisions = append(revisions, rs)
+		}
+	}
+
+	items := RightScriptHistory(revisions)
+	if format == "json" {
+		var data []byte
+		if data, err = json.MarshalIndent(items, "", "  "); err == nil {
+			fmt.Fprintf(Stdout, "%s\n", data)
+		}
+	} else {
+		err = PrintRightScriptHistory(Stdout, items)
+	}
+	if err != nil {
+		fatalError(exitGeneral, "%s", err.Error())
+	}
+}
@@ -0,0 +1,51 @@
+package main_test
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/rightscale/rsc/cm15"
+
+	. "github.com/rightscale/right_st"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RightScript history", func() {
+	at := func(value string) *cm15.RubyTime {
+		t, err := time.Parse(time.RFC3339, value)
+		Expect(err).NotTo(HaveOccurred())
+		return &cm15.RubyTime{Time: t}
+	}
+	self := func(href string) []map[string]string {
+		return []map[string]string{{"rel": "self", "href": href}}
+	}
+
+	It("Orders the commits and the last modification of HEAD by time", func() {
+		items := RightScriptHistory([]*cm15.RightScript{
+			{Name: "Script", Revision: 0, Links: self("/api/right_scripts/3"),
+				CreatedAt: at("2016-01-01T00:00:00Z"), UpdatedAt: at("2016-03-01T00:00:00Z")},
+			{Name: "Script v2", Revision: 2, Links: self("/api/right_scripts/2"), CreatedAt: at("2016-02-01T00:00:00Z")},
+			{Name: "Script", Revision: 1, Links: self("/api/right_scripts/1"), CreatedAt: at("2016-01-15T00:00:00Z")},
+		})
+		Expect(items).To(HaveLen(3))
+		Expect([]int{items[0].Revision, items[1].Revision, items[2].Revision}).To(Equal([]int{1, 2, 0}))
+
+		buffer := new(bytes.Buffer)
+		Expect(PrintRightScriptHistory(buffer, items)).To(Succeed())
+		Expect(buffer.String()).To(Equal(
+			"2016-01-15T00:00:00Z  committed revision 1      /api/right_scripts/1  Script\n" +
+				"2016-02-01T00:00:00Z  committed revision 2      /api/right_scripts/2  Script v2\n" +
+				"2016-03-01T00:00:00Z  last modified HEAD        /api/right_scripts/3  Script\n"))
+	})
+
+	It("Puts HEAD after a revision committed at the same time", func() {
+		items := RightScriptHistory([]*cm15.RightScript{
+			{Revision: 0, UpdatedAt: at("2016-01-01T00:00:00Z")},
+			{Revision: 1, CreatedAt: at("2016-01-01T00:00:00Z")},
+		})
+		Expect(items[0].Revision).To(Equal(1))
+		Expect(items[1].Revision).To(Equal(0))
+	})
+})
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/rightscale/right_st/rightscript"
+)
+
+func rightScriptLint(files []string, strict bool) {
+	issuesFound := false
+	for _, file := range files {
+		if rightscript.IsDirectory(file) {
+			continue
+		}
+		issues, err := rightscript.LintRightScript(file)
+		if err != nil {
+			fatalError(exitValidation, "%s: %s", file, err.Error())
+		}
+		for _, issue := range issues {
+			issuesFound = true
+			fmt.Fprintf(Stdout, "%s:%d: %s\n", file, issue.Line, issue.Message)
+		}
+	}
+	if issuesFound && strict {
+		exit(exitValidation)
+	}
+}
@@ -3,33 +3,54 @@ package main
 //	"github.com/tonnerre/golang-pretty"
 
 import (
-	"crypto/md5"
-	"encoding/hex"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path/filepath"
-	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/alecthomas/kingpin"
 	"github.com/mattn/go-colorable"
-	"github.com/rightscale/rsc/cm15"
 	"github.com/rightscale/rsc/httpclient"
 	"github.com/rightscale/rsc/log"
 	"github.com/rightscale/rsc/rsapi"
 	"gopkg.in/inconshreveable/log15.v2"
+
+	"github.com/rightscale/right_st/rightscript"
 )
 
 var (
-	app        = kingpin.New("right_st", "A command-line application for managing RightScripts")
-	debug      = app.Flag("debug", "Debug mode").Short('d').Bool()
-	configFile = app.Flag("config", "Set the config file path.").Short('c').Default(DefaultConfigFile()).String()
-	account    = app.Flag("account", "RightScale account name to use").Short('a').String()
+	app                 = kingpin.New("right_st", "A command-line application for managing RightScripts")
+	debug               = app.Flag("debug", "Debug mode").Short('d').Bool()
+	configFile          = app.Flag("config", "Set the config file path, or several separated by "+string(filepath.ListSeparator)+" to merge with later ones overriding earlier ones (default from RIGHT_ST_CONFIG)").Short('c').Default(DefaultConfigFiles()).String()
+	account             = app.Flag("account", "RightScale account name to use").Short('a').String()
+	configPrint         = app.Flag("config-print", "Print the account configuration that would be used and exit").Bool()
+	noCache             = app.Flag("no-cache", "Do not use the local cache of resolved RightScript names").Bool()
+	cacheTTL            = app.Flag("cache-ttl", "How long resolved RightScript names are cached for").Default("5m").Duration()
+	apiVersion          = app.Flag("api-version", "RightScale API version sent with the requests right_st builds itself").Default(defaultAPIVersion).String()
+	caCert              = app.Flag("ca-cert", "PEM file with certificate authorities to trust in addition to the system roots").ExistingFile()
+	insecureSkipVerify  = app.Flag("insecure-skip-verify", "Do not verify the TLS certificate of the API endpoint host (INSECURE, for testing only)").Bool()
+	baseURL             = app.Flag("base-url", "Send API requests to this URL instead of https://<host>, e.g. for on-premises installations with a path prefix").String()
+	maxIdleConnsPerHost = app.Flag("max-idle-conns-per-host", "Number of idle connections to keep open to the API endpoint host for reuse").Default("16").Action(setTransportFlag("--max-idle-conns-per-host")).Int()
+	keepAlive           = app.Flag("keep-alive", "Keep-alive period of connections to the API endpoint host, 0 disables keep-alives").Default("30s").Action(setTransportFlag("--keep-alive")).Duration()
+	noCompression       = app.Flag("no-compression", "Do not ask for gzip compressed API responses, for endpoints which mishandle compression").Bool()
+	compressUploads     = app.Flag("compress-uploads", "Send large API request bodies such as RightScript source gzip compressed, if the endpoint accepts it").Bool()
+	httpDumpFile        = app.Flag("http-dump-file", "Write dumps of all HTTP requests and responses to a file, the previous dump file is kept with a .1 suffix").String()
+	explain             = app.Flag("explain", "Print the steps taken to resolve names, IDs, and HREFs to resources").Bool()
+	timeout             = app.Flag("timeout", "Give up on the command and cancel its requests after this long, e.g. 10m, 0 waits forever (overrides the per-command timeouts)").Action(setTimeout).Duration()
+	timeoutSet          bool
+	transportFlagsSet   []string
+	followSymlinks      = app.Flag("follow-symlinks", "Walk directories which symlinks found when walking directories point to").Bool()
+	assumeYes           = app.Flag("assume-yes", "Answer yes to every confirmation prompt, e.g. when stdin is not a terminal").Short('y').Bool()
+	includeHidden       = app.Flag("include-hidden", "Include hidden files and directories, VCS directories, and editor backup files when walking directories").Bool()
+	cpuProfileFile      = app.Flag("cpuprofile", "Write a pprof CPU profile of the command to a file").Hidden().String()
+	memProfileFile      = app.Flag("memprofile", "Write a pprof memory profile of the command to a file").Hidden().String()
 
 	// ----- ServerTemplates -----
 	stCmd = app.Command("st", "ServerTemplate")
@@ -54,25 +75,138 @@ var (
 	// ----- RightScripts -----
 	rightScriptCmd = app.Command("rightscript", "RightScript")
 
-	rightScriptShowCmd        = rightScriptCmd.Command("show", "Show a single RightScript and its attachments")
-	rightScriptShowNameOrHref = rightScriptShowCmd.Arg("name|href|id", "Script Name or HREF or Id").Required().String()
+	rightScriptListCmd            = rightScriptCmd.Command("list", "List RightScripts")
+	rightScriptListFilter         = rightScriptListCmd.Arg("filter", "Only list RightScripts with names matching the filter").String()
+	rightScriptListOutputTemplate = rightScriptListCmd.Flag("output-template", "Go text/template evaluated for each RightScript with the fields .Id, .Href, .Revision, and .Name").String()
+	rightScriptListTags           = rightScriptListCmd.Flag("tag", "Only list RightScripts with the tag, may be repeated to require several tags").Strings()
+	rightScriptListNamesFrom      = rightScriptListCmd.Flag("names-from", "Only list the RightScripts named in a file with one name per line").ExistingFile()
+	rightScriptListFormat         = rightScriptListCmd.Flag("format", "Output format, text, json, or csv").Default("text").Enum("text", "json", "csv")
+
+	rightScriptSearchCmd                 = rightScriptCmd.Command("search", "Search RightScripts by name, description, attachments, and revision")
+	rightScriptSearchFilter              = rightScriptSearchCmd.Arg("filter", "Only search RightScripts with names matching the filter").String()
+	rightScriptSearchDescriptionContains = rightScriptSearchCmd.Flag("description-contains", "Only RightScripts with descriptions containing the text").String()
+	rightScriptSearchHeadOnly            = rightScriptSearchCmd.Flag("head-only", "Only HEAD revisions, not committed revisions").Bool()
+	rightScriptSearchHasAttachments      = rightScriptSearchCmd.Flag("has-attachments", "Only RightScripts with attachments").Bool()
+	rightScriptSearchFormat              = rightScriptSearchCmd.Flag("format", "Output format, text, json, or csv").Default("text").Enum("text", "json", "csv")
+
+	rightScriptExportCmd    = rightScriptCmd.Command("export", "Write the metadata of HEAD RightScripts to a single YAML or JSON document")
+	rightScriptExportFilter = rightScriptExportCmd.Arg("filter", "Only export RightScripts with names matching the filter").String()
+	rightScriptExportOutput = rightScriptExportCmd.Flag("output", "File to write the document to instead of stdout").String()
+	rightScriptExportFormat = rightScriptExportCmd.Flag("format", "Document format, yaml or json").Default("yaml").Enum("yaml", "json")
+
+	rightScriptImportCmd    = rightScriptCmd.Command("import", "Update the metadata of HEAD RightScripts from a document written by export")
+	rightScriptImportFile   = rightScriptImportCmd.Arg("file", "YAML or JSON document written by export").Required().ExistingFile()
+	rightScriptImportDryRun = rightScriptImportCmd.Flag("dry-run", "Only print which RightScripts would be updated").Bool()
 
-	rightScriptUploadCmd    = rightScriptCmd.Command("upload", "Upload a RightScript")
-	rightScriptUploadPaths  = rightScriptUploadCmd.Arg("path", "File or directory containing script files to upload").Required().ExistingFilesOrDirs()
-	rightScriptUploadPrefix = rightScriptUploadCmd.Flag("prefix", "Add prefix to name all RightScripts uploaded (for testing purposes)").Short('x').String()
-	rightScriptUploadForce  = rightScriptUploadCmd.Flag("force", "Force upload of file if metadata is not present").Short('f').Bool()
+	rightScriptShowCmd            = rightScriptCmd.Command("show", "Show a single RightScript and its attachments")
+	rightScriptShowNameOrHref     = rightScriptShowCmd.Arg("name|href|id", "Script Name or HREF or Id").Required().String()
+	rightScriptShowTags           = rightScriptShowCmd.Flag("tags", "Also show the tags on the RightScript").Bool()
+	rightScriptShowLinks          = rightScriptShowCmd.Flag("links", "Also show the rel and HREF of the links to related resources").Bool()
+	rightScriptShowMd5Only        = rightScriptShowCmd.Flag("attachment-md5-only", "Only show the md5 digests of the attachments").Bool()
+	rightScriptShowWithNames      = rightScriptShowCmd.Flag("with-names", "With --attachment-md5-only, show the attachment names like md5sum").Bool()
+	rightScriptShowJSONSchema     = rightScriptShowCmd.Flag("json-schema", "Only show a JSON Schema describing the inputs").Bool()
+	rightScriptShowComparePath    = rightScriptShowCmd.Flag("compare", "Only show whether a local script matches the RightScript, exiting with 7 if it does not").PlaceHolder("PATH").ExistingFile()
+	rightScriptShowNormalizeEOL   = rightScriptShowCmd.Flag("normalize-eol", "With --compare, convert CRLF line endings to LF like rightscript upload --normalize-eol").Bool()
+	rightScriptShowSourceEncoding = rightScriptShowCmd.Flag("source-encoding", "With --compare, the encoding of the local script like rightscript upload --source-encoding").PlaceHolder("ENCODING").Enum(rightscript.SourceEncodings()...)
+
+	rightScriptHistoryCmd        = rightScriptCmd.Command("history", "Show when the revisions of a RightScript were committed and HEAD was last modified")
+	rightScriptHistoryNameOrHref = rightScriptHistoryCmd.Arg("name|href|id", "Script Name or HREF or Id").Required().String()
+	rightScriptHistoryFormat     = rightScriptHistoryCmd.Flag("format", "Output format, text or json").Default("text").Enum("text", "json")
+
+	rightScriptUploadCmd              = rightScriptCmd.Command("upload", "Upload a RightScript")
+	rightScriptUploadPaths            = rightScriptUploadCmd.Arg("path", "File, directory, or tar/zip archive containing script files to upload").Required().ExistingFilesOrDirs()
+	rightScriptUploadPrefix           = rightScriptUploadCmd.Flag("prefix", "Add prefix to name all RightScripts uploaded (for testing purposes)").Short('x').String()
+	rightScriptUploadSuffix           = rightScriptUploadCmd.Flag("suffix", "Add suffix to name all RightScripts uploaded (for testing purposes)").String()
+	rightScriptUploadForce            = rightScriptUploadCmd.Flag("force", "Force upload of file if metadata is not present").Short('f').Bool()
+	rightScriptUploadNameFromPath     = rightScriptUploadCmd.Flag("name-from-path", "Name RightScripts without metadata after their path relative to the uploaded directory instead of just their file name").Bool()
+	rightScriptUploadNameSep          = rightScriptUploadCmd.Flag("name-separator", "Separator used to join the path components of names from --name-from-path").Default("/").String()
+	rightScriptUploadMetadataOnly     = rightScriptUploadCmd.Flag("metadata-only", "Only update the name, description, and packages of existing RightScripts, do not upload source").Bool()
+	rightScriptUploadSince            = rightScriptUploadCmd.Flag("since", "Only upload files modified since a duration ago (e.g. 24h) or an RFC 3339 timestamp").String()
+	rightScriptUploadStateFile        = rightScriptUploadCmd.Flag("state-file", "File recording the time of the last successful upload, used as --since when it is not given").String()
+	rightScriptUploadNoAttach         = rightScriptUploadCmd.Flag("no-attachments", "Do not upload, delete, or rename attachments, only the script itself").Bool()
+	rightScriptUploadNormalizeEOL     = rightScriptUploadCmd.Flag("normalize-eol", "Convert CRLF line endings to LF in the uploaded source, PowerShell scripts are left alone").Bool()
+	rightScriptUploadReadme           = rightScriptUploadCmd.Flag("description-from-readme", "Use the README.md in the directory of a script as its description when its metadata has none").Bool()
+	rightScriptUploadSourceEncoding   = rightScriptUploadCmd.Flag("source-encoding", "Encoding of the script files to convert the uploaded source to UTF-8 from").PlaceHolder("ENCODING").Enum(rightscript.SourceEncodings()...)
+	rightScriptUploadReport           = rightScriptUploadCmd.Flag("report", "Write a JSON manifest of what was created, updated, or skipped to a file").String()
+	rightScriptUploadRenameOnConflict = rightScriptUploadCmd.Flag("rename-on-conflict", "Create a new RightScript with a numeric suffix instead of updating an existing one that was not uploaded from the same script").Bool()
+	rightScriptUploadNoMarker         = rightScriptUploadCmd.Flag("no-marker", "Do not tag newly created RightScripts with "+rightscript.ManagedTag).Bool()
+	rightScriptUploadCreateOnly       = rightScriptUploadCmd.Flag("create-only", "Fail instead of updating a RightScript which already exists").Bool()
+	rightScriptUploadUpdateOnly       = rightScriptUploadCmd.Flag("update-only", "Fail instead of creating a RightScript which does not exist yet").Bool()
+	rightScriptUploadIfMatch          = rightScriptUploadCmd.Flag("if-match", "Only update an existing RightScript whose latest committed revision or HEAD source md5 is this").String()
+	rightScriptUploadConfirmEach      = rightScriptUploadCmd.Flag("confirm-each", "Print what will be done for each RightScript and ask before uploading it").Bool()
+	rightScriptUploadStopOnError      = rightScriptUploadCmd.Flag("stop-on-first-error", "Fail at the first RightScript which fails to upload instead of carrying on uploading the rest").Bool()
+	rightScriptUploadContinue         = rightScriptUploadCmd.Flag("continue-on-error", "Carry on uploading after a RightScript fails to upload, which is the default").Hidden().Bool()
+	rightScriptUploadStrict           = rightScriptUploadCmd.Flag("strict", "Treat validation warnings as errors before uploading anything").Bool()
+	rightScriptUploadValidateFirst    = rightScriptUploadCmd.Flag("validate-first", "Validate every file like rightscript validate and upload nothing if any of them is invalid").Bool()
+	rightScriptUploadDiff             = rightScriptUploadCmd.Flag("diff", "Print a diff of what changes before updating an existing RightScript").Bool()
+	rightScriptUploadAttachPrefix     = rightScriptUploadCmd.Flag("attachment-prefix", "Prefix to add to the names of the attachments uploaded, e.g. to keep them apart from the attachments of other RightScripts").String()
+	rightScriptUploadSourceFile       = rightScriptUploadCmd.Flag("source-file", "Upload the source from this file, e.g. build output, with the metadata of the single script given as the path").ExistingFile()
+	rightScriptUploadMaxFileSize      = rightScriptUploadCmd.Flag("max-file-size", "Refuse to upload a script or attachment file larger than this (e.g. 50MB)").PlaceHolder("SIZE").Bytes()
+	rightScriptUploadWatch            = rightScriptUploadCmd.Flag("watch", "Keep running and upload scripts again each time they or their attachments change").Bool()
+	rightScriptUploadAccounts         = rightScriptUploadCmd.Flag("accounts", "Comma separated names of accounts from the config file to upload to one after the other instead of just --account").String()
+	rightScriptUploadEnvironments     = rightScriptUploadCmd.Flag("environments", "Alias of --accounts").String()
 
 	rightScriptDownloadCmd        = rightScriptCmd.Command("download", "Download a RightScript to a file or files")
-	rightScriptDownloadNameOrHref = rightScriptDownloadCmd.Arg("name|href|id", "Script Name or HREF or Id").Required().String()
+	rightScriptDownloadNameOrHref = rightScriptDownloadCmd.Arg("name|href|id", "Script Name or HREF or Id").String()
 	rightScriptDownloadTo         = rightScriptDownloadCmd.Arg("path", "Download location").String()
+	rightScriptDownloadFilter     = rightScriptDownloadCmd.Flag("filter", "Download all HEAD RightScripts with names matching the filter instead of a single RightScript").String()
+	rightScriptDownloadNamesFrom  = rightScriptDownloadCmd.Flag("names-from", "Download the HEAD RightScripts named in a file with one name per line instead of a single RightScript").ExistingFile()
+	rightScriptDownloadTags       = rightScriptDownloadCmd.Flag("tag", "Download all HEAD RightScripts with the tag instead of a single RightScript, may be repeated to require several tags").Strings()
+	rightScriptDownloadOutputDir  = rightScriptDownloadCmd.Flag("output-dir", "Directory to download RightScripts matching --filter or --tag to, one subdirectory per RightScript").Default(".").String()
+	rightScriptDownloadNoAttach   = rightScriptDownloadCmd.Flag("no-attachments", "Do not download attachments, only the script itself").Bool()
+	rightScriptDownloadFileMode   = rightScriptDownloadCmd.Flag("file-mode", "Octal file mode for the downloaded script and attachments, use 0755 to make them executable").Default("0644").String()
+	rightScriptDownloadCanonical  = rightScriptDownloadCmd.Flag("canonical", "Download in a layout which only changes when the RightScript does, for version control").Bool()
+
+	rightScriptCloneCmd        = rightScriptCmd.Command("clone", "Copy a RightScript and its attachments to a new RightScript")
+	rightScriptCloneNameOrHref = rightScriptCloneCmd.Arg("name|href|id", "Script Name or HREF or Id to copy").Required().String()
+	rightScriptCloneNewName    = rightScriptCloneCmd.Arg("new-name", "Name of the new RightScript").Required().String()
+
+	rightScriptMoveCmd          = rightScriptCmd.Command("move", "Update a RightScript with the source and attachments of another to merge duplicate lineages")
+	rightScriptMoveNameOrHref   = rightScriptMoveCmd.Arg("name|href|id", "Script Name or HREF or Id to move").Required().String()
+	rightScriptMoveTarget       = rightScriptMoveCmd.Arg("target", "Script Name or HREF or Id to update").Required().String()
+	rightScriptMoveDeleteSource = rightScriptMoveCmd.Flag("delete-source", "Delete the moved RightScript afterwards").Bool()
+	rightScriptMoveForce        = rightScriptMoveCmd.Flag("force", "Do not ask for confirmation, the same as --assume-yes").Short('f').Bool()
+
+	rightScriptTagCmd        = rightScriptCmd.Command("tag", "Add tags to a RightScript")
+	rightScriptTagNameOrHref = rightScriptTagCmd.Arg("name|href|id", "Script Name or HREF or Id").Required().String()
+	rightScriptTagTags       = rightScriptTagCmd.Arg("tag", "Tags to add, such as namespace:predicate=value").Required().Strings()
+
+	rightScriptUntagCmd        = rightScriptCmd.Command("untag", "Remove tags from a RightScript")
+	rightScriptUntagNameOrHref = rightScriptUntagCmd.Arg("name|href|id", "Script Name or HREF or Id").Required().String()
+	rightScriptUntagTags       = rightScriptUntagCmd.Arg("tag", "Tags to remove, such as namespace:predicate=value").Required().Strings()
+
+	rightScriptAttachmentCmd = rightScriptCmd.Command("attachment", "Manage the attachments of a RightScript")
+
+	rightScriptAttachmentListCmd        = rightScriptAttachmentCmd.Command("list", "List the attachments of a RightScript")
+	rightScriptAttachmentListNameOrHref = rightScriptAttachmentListCmd.Arg("name|href|id", "Script Name or HREF or Id").Required().String()
+	rightScriptAttachmentListFormat     = rightScriptAttachmentListCmd.Flag("format", "Output format, text or json").Default("text").Enum("text", "json")
+
+	rightScriptAttachmentAddCmd        = rightScriptAttachmentCmd.Command("add", "Upload a file as an attachment of a RightScript, replacing one with the same name")
+	rightScriptAttachmentAddNameOrHref = rightScriptAttachmentAddCmd.Arg("name|href|id", "Script Name or HREF or Id").Required().String()
+	rightScriptAttachmentAddFile       = rightScriptAttachmentAddCmd.Arg("file", "File to attach").Required().ExistingFile()
+	rightScriptAttachmentAddName       = rightScriptAttachmentAddCmd.Flag("name", "Name of the attachment, the base name of the file by default").String()
+
+	rightScriptAttachmentRemoveCmd        = rightScriptAttachmentCmd.Command("remove", "Delete an attachment of a RightScript")
+	rightScriptAttachmentRemoveNameOrHref = rightScriptAttachmentRemoveCmd.Arg("name|href|id", "Script Name or HREF or Id").Required().String()
+	rightScriptAttachmentRemoveName       = rightScriptAttachmentRemoveCmd.Arg("attachment", "Name of the attachment").Required().String()
 
 	rightScriptScaffoldCmd      = rightScriptCmd.Command("scaffold", "Add RightScript YAML metadata comments to a file or files")
 	rightScriptScaffoldPaths    = rightScriptScaffoldCmd.Arg("path", "File or directory to set metadata for").Required().ExistingFilesOrDirs()
 	rightScriptScaffoldNoBackup = rightScriptScaffoldCmd.Flag("no-backup", "Do not create backup files before scaffolding").Short('n').Bool()
 	rightScriptScaffoldForce    = rightScriptScaffoldCmd.Flag("force", "Force re-scaffolding").Short('f').Bool()
+	rightScriptScaffoldInterp   = rightScriptScaffoldCmd.Flag("interpreter", "Detect inputs for this interpreter instead of guessing from the extension and shebang").Enum(rightscript.ScaffoldInterpreters()...)
+
+	rightScriptLintCmd    = rightScriptCmd.Command("lint", "Check the source of RightScripts for style problems")
+	rightScriptLintPaths  = rightScriptLintCmd.Arg("path", "Path to script file or directory containing script files").Required().ExistingFilesOrDirs()
+	rightScriptLintStrict = rightScriptLintCmd.Flag("strict", "Exit with a non-zero status when there are any problems").Bool()
 
-	rightScriptValidateCmd   = rightScriptCmd.Command("validate", "Validate RightScript YAML metadata comments in a file or files")
-	rightScriptValidatePaths = rightScriptValidateCmd.Arg("path", "Path to script file or directory containing script files").Required().ExistingFilesOrDirs()
+	rightScriptValidateCmd    = rightScriptCmd.Command("validate", "Validate RightScript YAML metadata comments in a file or files")
+	rightScriptValidatePaths  = rightScriptValidateCmd.Arg("path", "Path to script file or directory containing script files").Required().ExistingFilesOrDirs()
+	rightScriptValidateCats   = rightScriptValidateCmd.Flag("categories", "Comma separated categories inputs may use, inputs with other categories fail validation").String()
+	rightScriptValidatePar    = rightScriptValidateCmd.Flag("parallel-validate", "Number of files to validate at once").Default("1").Int()
+	rightScriptValidateStrict = rightScriptValidateCmd.Flag("strict", "Treat warnings as errors").Bool()
+	rightScriptValidateSchema = rightScriptValidateCmd.Flag("schema", "YAML file of rules for names, required metadata fields and inputs, categories, and interpreters to check scripts against").ExistingFile()
+	rightScriptValidateEnv    = rightScriptValidateCmd.Flag("env-file", "Dotenv file of input values to check against the inputs the scripts declare").ExistingFile()
 
 	// ----- Configuration -----
 	configCmd = app.Command("config", "Manage Configuration")
@@ -83,6 +217,24 @@ var (
 
 	configShowCmd = configCmd.Command("show", "Show configuration")
 
+	// ----- Cache -----
+	cacheCmd = app.Command("cache", "Manage the local cache")
+
+	cacheClearCmd = cacheCmd.Command("clear", "Remove all cached data")
+
+	// ----- Doctor -----
+	doctorCmd = app.Command("doctor", "Check the configuration, network, token, and clock for common setup problems")
+
+	// ----- Completion -----
+	completionCmd   = app.Command("completion", "Print a script which completes commands, flags, and names in bash, zsh, or fish")
+	completionShell = completionCmd.Arg("shell", "Shell to complete in: bash, zsh, or fish").Required().Enum("bash", "zsh", "fish")
+
+	completeCmd   = app.Command(completeCommand, "Print the candidates for completing the last of the words").Hidden()
+	completeWords = completeCmd.Arg("words", "Words on the command line after the executable name").Strings()
+
+	// ----- Version -----
+	versionCmd = app.Command("version", "Show the version, commit, and Go version of the "+app.Name+" executable")
+
 	// ----- Update right_st -----
 	updateCmd = app.Command("update", "Update "+app.Name+" executable")
 
@@ -93,15 +245,43 @@ var (
 )
 
 func main() {
-	app.Writer(os.Stdout)
-	app.Version(VV)
+	app.Writer(Stdout)
+	app.Version(VersionInfo(VV))
 	app.HelpFlag.Short('h')
 	app.VersionFlag.Short('v')
 	command := kingpin.MustParse(app.Parse(os.Args[1:]))
+	if err := startProfiling(*cpuProfileFile, *memProfileFile); err != nil {
+		fatalError(exitGeneral, "Could not start profiling: %s", err.Error())
+	}
+	defer stopProfiling()
+
+	// Commands that only work on local files do not read credentials or touch the network at all so they can be used
+	// in places like pre-commit hooks on machines without any configuration.
+	offline := command == rightScriptValidateCmd.FullCommand() || command == rightScriptScaffoldCmd.FullCommand() ||
+		command == rightScriptLintCmd.FullCommand() || command == versionCmd.FullCommand() ||
+		command == completionCmd.FullCommand()
+
+	var err error
+	if cwd, err := os.Getwd(); err == nil {
+		*configFile = FindConfigFile(*configFile, cwd)
+	}
+	if !offline || *configPrint {
+		err = ReadConfig(*configFile, *account)
+	}
+	configErr := err
+	if err != nil && !offline && !strings.HasPrefix(command, "config") && !strings.HasPrefix(command, "update") &&
+		!strings.HasPrefix(command, "cache") && command != doctorCmd.FullCommand() && command != completeCmd.FullCommand() {
+		fatalError(exitConfig, "%s: Error reading config file: %s\n", filepath.Base(os.Args[0]), err.Error())
+	}
 
-	err := ReadConfig(*configFile, *account)
-	if err != nil && !strings.HasPrefix(command, "config") && !strings.HasPrefix(command, "update") {
-		fatalError("%s: Error reading config file: %s\n", filepath.Base(os.Args[0]), err.Error())
+	if *configPrint {
+		if err == nil {
+			err = Config.ShowAccount(Stdout)
+		}
+		if err != nil {
+			fatalError(exitConfig, "%s\n", err.Error())
+		}
+		return
 	}
 
 	// Handle logging
@@ -115,83 +295,314 @@ func main() {
 		httpclient.DumpFormat = httpclient.Debug
 		logLevel = log15.LvlDebug
 	}
+	if *httpDumpFile != "" {
+		dumpFile, err := openHTTPDumpFile(*httpDumpFile, time.Now())
+		if err != nil {
+			fatalError(exitGeneral, "Could not open HTTP dump file: %s", err.Error())
+		}
+		defer dumpFile.Close()
+		httpclient.DumpFormat = httpclient.Debug
+		httpclient.OsStderr = dumpFile
+	}
 	handler := log15.LvlFilterHandler(logLevel, log15.StreamHandler(colorable.NewColorableStdout(), log15.TerminalFormat()))
 	log15.Root().SetHandler(handler)
 
-	if Config.GetBool("update.check") && !strings.HasPrefix(command, "update") {
-		defer UpdateCheck(VV, os.Stderr)
+	MaxIdleConnsPerHost, KeepAlive = *maxIdleConnsPerHost, *keepAlive
+	Compression, CompressUploads = !*noCompression, *compressUploads && !*noCompression
+	if *baseURL != "" {
+		BaseURL, err = ParseBaseURL(*baseURL)
+		if err != nil {
+			fatalError(exitConfig, "%s", err.Error())
+		}
+	}
+	if *insecureSkipVerify {
+		fmt.Fprintln(Stderr, "WARNING: TLS certificate verification is disabled by --insecure-skip-verify, "+
+			"API requests can be intercepted. Never use this against production endpoints.")
+		httpclient.NoCertCheck = true
+	}
+
+	if *caCert != "" {
+		transportFlagsSet = append(transportFlagsSet, "--ca-cert")
+	}
+	if err := CheckDumpFlags(httpclient.DumpFormat != httpclient.NoDump, transportFlagsSet); err != nil {
+		fatalError(exitConfig, "%s", err.Error())
+	}
+	if *caCert != "" {
+		RootCAs, err = LoadCACert(*caCert)
+		if err != nil {
+			fatalError(exitConfig, "Could not load --ca-cert: %s", err.Error())
+		}
+		// attachment downloads and update checks use the default transport
+		http.DefaultTransport = NewTransport(MaxIdleConnsPerHost, KeepAlive)
+	}
+
+	if !offline {
+		requestTimeout, err = CommandTimeout(command, *timeout, timeoutSet, Config.GetStringMapString("timeouts"))
+		if err != nil {
+			fatalError(exitConfig, "%s", err.Error())
+		}
+		ctx, cancel := commandContext(requestTimeout)
+		defer cancel()
+		RequestContext = ctx
+	}
+
+	if Config.GetBool("update.check") && !strings.HasPrefix(command, "update") && !offline && command != completeCmd.FullCommand() {
+		defer UpdateCheck(VV, Stderr)
 	}
 
 	switch command {
 	case stShowCmd.FullCommand():
 		href, err := paramToHref("server_templates", *stShowNameOrHref, 0)
 		if err != nil {
-			fatalError("%s", err.Error())
+			fatalError(ErrorExitCode(err), "%s", err.Error())
 		}
 		stShow(href)
 	case stUploadCmd.FullCommand():
 		files, err := walkPaths(*stUploadPaths)
 		if err != nil {
-			fatalError("%s\n", err.Error())
+			fatalError(exitGeneral, "%s\n", err.Error())
 		}
 		stUpload(files, *stUploadPrefix)
 	case stDownloadCmd.FullCommand():
 		href, err := paramToHref("server_templates", *stDownloadNameOrHref, 0)
 		if err != nil {
-			fatalError("%s", err.Error())
+			fatalError(ErrorExitCode(err), "%s", err.Error())
 		}
 		stDownload(href, *stDownloadTo, *stDownloadPublished, *stDownloadMciSettings, *stDownloadScriptPath)
 	case stValidateCmd.FullCommand():
 		files, err := walkPaths(*stValidatePaths)
 		if err != nil {
-			fatalError("%s\n", err.Error())
+			fatalError(exitGeneral, "%s\n", err.Error())
 		}
 		stValidate(files)
+	case rightScriptExportCmd.FullCommand():
+		rightScriptExport(*rightScriptExportFilter, *rightScriptExportOutput, *rightScriptExportFormat)
+	case rightScriptImportCmd.FullCommand():
+		rightScriptImport(*rightScriptImportFile, *rightScriptImportDryRun)
+	case rightScriptListCmd.FullCommand():
+		rightScriptList(*rightScriptListFilter, *rightScriptListTags, *rightScriptListNamesFrom, *rightScriptListOutputTemplate, *rightScriptListFormat)
+	case rightScriptSearchCmd.FullCommand():
+		rightScriptSearch(*rightScriptSearchFilter, RightScriptSearch{
+			DescriptionContains: *rightScriptSearchDescriptionContains,
+			HeadOnly:            *rightScriptSearchHeadOnly,
+			HasAttachments:      *rightScriptSearchHasAttachments,
+		}, *rightScriptSearchFormat)
 	case rightScriptShowCmd.FullCommand():
-		href, err := paramToHref("right_scripts", *rightScriptShowNameOrHref, 0)
+		href, err := cachedParamToHref("right_scripts", *rightScriptShowNameOrHref, 0)
+		if _, ok := err.(*rightscript.NotFoundError); ok {
+			href, err = chooseRightScript(*rightScriptShowNameOrHref, 0, err), nil
+		}
 		if err != nil {
-			fatalError("%s", err.Error())
+			fatalError(ErrorExitCode(err), "%s", err.Error())
 		}
-		rightScriptShow(href)
+		switch {
+		case *rightScriptShowMd5Only && *rightScriptShowJSONSchema:
+			fatalError(exitGeneral, "Cannot specify both --attachment-md5-only and --json-schema")
+		case *rightScriptShowComparePath != "" && (*rightScriptShowMd5Only || *rightScriptShowJSONSchema):
+			fatalError(exitGeneral, "Cannot specify --compare with --attachment-md5-only or --json-schema")
+		case *rightScriptShowComparePath != "":
+			rightScriptShowCompare(href, *rightScriptShowComparePath,
+				rightscript.PushOptions{NormalizeEOL: *rightScriptShowNormalizeEOL, SourceEncoding: *rightScriptShowSourceEncoding})
+		case *rightScriptShowMd5Only:
+			rightScriptShowDigests(href, *rightScriptShowWithNames)
+		case *rightScriptShowJSONSchema:
+			rightScriptShowInputsSchema(href)
+		default:
+			rightScriptShow(href, *rightScriptShowTags, *rightScriptShowLinks)
+		}
+	case rightScriptHistoryCmd.FullCommand():
+		href, err := paramToHref("right_scripts", *rightScriptHistoryNameOrHref, 0)
+		if err != nil {
+			fatalError(ErrorExitCode(err), "%s", err.Error())
+		}
+		rightScriptHistory(href, *rightScriptHistoryFormat)
 	case rightScriptUploadCmd.FullCommand():
-		rightScriptUpload(*rightScriptUploadPaths, *rightScriptUploadForce, *rightScriptUploadPrefix)
+		if *rightScriptUploadAccounts != "" && *rightScriptUploadEnvironments != "" {
+			fatalError(exitGeneral, "--accounts and --environments cannot be combined")
+		}
+		accounts := []string{}
+		if *rightScriptUploadAccounts != "" {
+			accounts = strings.Split(*rightScriptUploadAccounts, ",")
+		} else if *rightScriptUploadEnvironments != "" {
+			accounts = strings.Split(*rightScriptUploadEnvironments, ",")
+		}
+		if *rightScriptUploadContinue && *rightScriptUploadStopOnError {
+			fatalError(exitGeneral, "--continue-on-error and --stop-on-first-error cannot be combined")
+		}
+		if *rightScriptUploadCreateOnly && (*rightScriptUploadUpdateOnly || *rightScriptUploadIfMatch != "") {
+			fatalError(exitGeneral, "--create-only cannot be combined with --update-only or --if-match")
+		}
+		nameSeparator := ""
+		if *rightScriptUploadNameFromPath {
+			nameSeparator = *rightScriptUploadNameSep
+		}
+		options := rightscript.PushOptions{
+			Prefix:                *rightScriptUploadPrefix,
+			Suffix:                *rightScriptUploadSuffix,
+			MetadataOnly:          *rightScriptUploadMetadataOnly,
+			NoAttachments:         *rightScriptUploadNoAttach,
+			NormalizeEOL:          *rightScriptUploadNormalizeEOL,
+			SourceEncoding:        *rightScriptUploadSourceEncoding,
+			DescriptionFromReadme: *rightScriptUploadReadme,
+			RenameOnConflict:      *rightScriptUploadRenameOnConflict,
+			NoMarker:              *rightScriptUploadNoMarker,
+			Diff:                  *rightScriptUploadDiff,
+			Strict:                *rightScriptUploadStrict,
+			StopOnFirstError:      *rightScriptUploadStopOnError,
+			ConfirmEach:           *rightScriptUploadConfirmEach,
+			IfMatch:               *rightScriptUploadIfMatch,
+			MaxFileSize:           int64(*rightScriptUploadMaxFileSize),
+			CreateOnly:            *rightScriptUploadCreateOnly,
+			UpdateOnly:            *rightScriptUploadUpdateOnly,
+			SourceFile:            *rightScriptUploadSourceFile,
+			AttachmentPrefix:      *rightScriptUploadAttachPrefix,
+			ValidateFirst:         *rightScriptUploadValidateFirst,
+		}
+		if options.SourceFile != "" {
+			if paths := *rightScriptUploadPaths; len(paths) != 1 || rightscript.IsDirectory(paths[0]) || IsArchive(paths[0]) {
+				fatalError(exitGeneral, "--source-file needs exactly one script to take the metadata from")
+			}
+		}
+		if *rightScriptUploadWatch {
+			if len(accounts) > 0 || options.ConfirmEach || *rightScriptUploadReport != "" || *rightScriptUploadSince != "" ||
+				*rightScriptUploadStateFile != "" || options.SourceFile != "" {
+				fatalError(exitGeneral, "--watch cannot be combined with --accounts, --confirm-each, --report, --since, --state-file, or --source-file")
+			}
+			rightScriptWatch(*rightScriptUploadPaths, *rightScriptUploadForce, nameSeparator, options)
+			break
+		}
+		rightScriptUpload(*rightScriptUploadPaths, *rightScriptUploadForce, *rightScriptUploadSince, *rightScriptUploadStateFile, nameSeparator, *rightScriptUploadReport, accounts, options)
 	case rightScriptDownloadCmd.FullCommand():
+		fileMode, err := parseFileMode(*rightScriptDownloadFileMode)
+		if err != nil {
+			fatalError(exitGeneral, "%s\n", err.Error())
+		}
+		if *rightScriptDownloadFilter != "" || len(*rightScriptDownloadTags) > 0 || *rightScriptDownloadNamesFrom != "" {
+			if *rightScriptDownloadNameOrHref != "" {
+				fatalError(exitGeneral, "Cannot specify both a RightScript name|href|id and --filter, --tag, or --names-from")
+			}
+			rightScriptDownloadAll(*rightScriptDownloadFilter, *rightScriptDownloadTags, *rightScriptDownloadNamesFrom, *rightScriptDownloadOutputDir, *rightScriptDownloadNoAttach, fileMode, *rightScriptDownloadCanonical)
+			break
+		}
+		if *rightScriptDownloadNameOrHref == "" {
+			fatalError(exitGeneral, "Either a RightScript name|href|id, --filter, --tag, or --names-from must be specified")
+		}
 		href, err := paramToHref("right_scripts", *rightScriptDownloadNameOrHref, 0)
 		if err != nil {
-			fatalError("%s", err.Error())
+			fatalError(ErrorExitCode(err), "%s", err.Error())
+		}
+		rightScriptDownload(href, *rightScriptDownloadTo, *rightScriptDownloadNoAttach, fileMode, *rightScriptDownloadCanonical)
+	case rightScriptCloneCmd.FullCommand():
+		href, err := paramToHref("right_scripts", *rightScriptCloneNameOrHref, 0)
+		if err != nil {
+			fatalError(ErrorExitCode(err), "%s", err.Error())
+		}
+		rightScriptClone(href, *rightScriptCloneNewName)
+	case rightScriptMoveCmd.FullCommand():
+		href, err := paramToHref("right_scripts", *rightScriptMoveNameOrHref, 0)
+		if err != nil {
+			fatalError(ErrorExitCode(err), "%s", err.Error())
 		}
-		rightScriptDownload(href, *rightScriptDownloadTo)
+		targetHref, err := paramToHref("right_scripts", *rightScriptMoveTarget, 0)
+		if err != nil {
+			fatalError(ErrorExitCode(err), "%s", err.Error())
+		}
+		rightScriptMove(href, targetHref, *rightScriptMoveDeleteSource, *rightScriptMoveForce)
+	case rightScriptTagCmd.FullCommand():
+		href, err := paramToHref("right_scripts", *rightScriptTagNameOrHref, 0)
+		if err != nil {
+			fatalError(ErrorExitCode(err), "%s", err.Error())
+		}
+		rightScriptTag(href, *rightScriptTagTags)
+	case rightScriptUntagCmd.FullCommand():
+		href, err := paramToHref("right_scripts", *rightScriptUntagNameOrHref, 0)
+		if err != nil {
+			fatalError(ErrorExitCode(err), "%s", err.Error())
+		}
+		rightScriptUntag(href, *rightScriptUntagTags)
+	case rightScriptAttachmentListCmd.FullCommand():
+		href, err := paramToHref("right_scripts", *rightScriptAttachmentListNameOrHref, 0)
+		if err != nil {
+			fatalError(ErrorExitCode(err), "%s", err.Error())
+		}
+		rightScriptAttachmentList(href, *rightScriptAttachmentListFormat)
+	case rightScriptAttachmentAddCmd.FullCommand():
+		href, err := paramToHref("right_scripts", *rightScriptAttachmentAddNameOrHref, 0)
+		if err != nil {
+			fatalError(ErrorExitCode(err), "%s", err.Error())
+		}
+		rightScriptAttachmentAdd(href, *rightScriptAttachmentAddFile, *rightScriptAttachmentAddName)
+	case rightScriptAttachmentRemoveCmd.FullCommand():
+		href, err := paramToHref("right_scripts", *rightScriptAttachmentRemoveNameOrHref, 0)
+		if err != nil {
+			fatalError(ErrorExitCode(err), "%s", err.Error())
+		}
+		rightScriptAttachmentRemove(href, *rightScriptAttachmentRemoveName)
 	case rightScriptScaffoldCmd.FullCommand():
 		files, err := walkPaths(*rightScriptScaffoldPaths)
 		if err != nil {
-			fatalError("%s\n", err.Error())
+			fatalError(exitGeneral, "%s\n", err.Error())
 		}
-		rightScriptScaffold(files, !*rightScriptScaffoldNoBackup, *rightScriptScaffoldForce)
+		rightScriptScaffold(files, !*rightScriptScaffoldNoBackup, *rightScriptScaffoldForce, *rightScriptScaffoldInterp)
+	case rightScriptLintCmd.FullCommand():
+		files, err := walkPaths(*rightScriptLintPaths)
+		if err != nil {
+			fatalError(exitGeneral, "%s\n", err.Error())
+		}
+		rightScriptLint(rightscript.DropSidecars(files), *rightScriptLintStrict)
 	case rightScriptValidateCmd.FullCommand():
 		files, err := walkPaths(*rightScriptValidatePaths)
 		if err != nil {
-			fatalError("%s\n", err.Error())
+			fatalError(exitGeneral, "%s\n", err.Error())
+		}
+		files = rightscript.DropSidecars(files)
+		categories := []string{}
+		if *rightScriptValidateCats != "" {
+			categories = strings.Split(*rightScriptValidateCats, ",")
 		}
-		rightScriptValidate(files)
+		rightScriptValidate(files, categories, *rightScriptValidateSchema, *rightScriptValidateEnv, *rightScriptValidatePar, *rightScriptValidateStrict)
 	case configAccountCmd.FullCommand():
-		err := Config.SetAccount(*configAccountName, *configAccountDefault, os.Stdin, os.Stdout)
+		if len(SplitConfigFiles(*configFile)) > 1 {
+			fatalError(exitConfig, "Cannot write an account to several merged config files, select one with --config")
+		}
+		err := Config.SetAccount(*configAccountName, *configAccountDefault, os.Stdin, Stdout)
 		if err != nil {
-			fatalError("%s\n", err.Error())
+			fatalError(exitConfig, "%s\n", err.Error())
 		}
 	case configShowCmd.FullCommand():
-		err := Config.ShowConfiguration(os.Stdout)
+		err := Config.ShowConfiguration(Stdout)
+		if err != nil {
+			fatalError(exitConfig, "%s\n", err.Error())
+		}
+	case cacheClearCmd.FullCommand():
+		err := ClearHrefCache(DefaultCacheDir())
 		if err != nil {
-			fatalError("%s\n", err.Error())
+			fatalError(exitGeneral, "%s\n", err.Error())
+		}
+		fmt.Fprintln(Stdout, "Cache cleared")
+	case doctorCmd.FullCommand():
+		doctor(*configFile, configErr)
+	case completionCmd.FullCommand():
+		script, err := CompletionScript(*completionShell, app.Name)
+		if err != nil {
+			fatalError(exitGeneral, "%s", err.Error())
+		}
+		fmt.Fprint(Stdout, script)
+	case completeCmd.FullCommand():
+		for _, candidate := range Complete(app.Model(), *completeWords, completeNames) {
+			fmt.Fprintln(Stdout, candidate)
 		}
+	case versionCmd.FullCommand():
+		fmt.Fprintln(Stdout, VersionInfo(VV))
 	case updateListCmd.FullCommand():
-		err := UpdateList(VV, os.Stdout)
+		err := UpdateList(VV, Stdout)
 		if err != nil {
-			fatalError("%s\n", err.Error())
+			fatalError(exitGeneral, "%s\n", err.Error())
 		}
 	case updateApplyCmd.FullCommand():
-		err := UpdateApply(VV, os.Stdout, *updateApplyMajorVersion, "")
+		err := UpdateApply(VV, Stdout, *updateApplyMajorVersion, "")
 		if err != nil {
-			fatalError("%s\n", err.Error())
+			fatalError(exitGeneral, "%s\n", err.Error())
 		}
 	}
 }
@@ -208,223 +619,381 @@ func paramToHref(resourceType, param string, revision int) (string, error) {
 	var href string
 	if idMatch.Match([]byte(param)) {
 		href = fmt.Sprintf("/api/%s/%s", resourceType, param)
+		explainf("'%s' is an ID, using %s", param, href)
 	} else if hrefMatch.Match([]byte(param)) {
 		href = param
+		explainf("'%s' is an HREF, using it as is", param)
 	} else {
-		payload := rsapi.APIParams{}
-		params := rsapi.APIParams{"filter[]": []string{"name==" + param}}
-		uriPath := fmt.Sprintf("/api/%s", resourceType)
-
-		req, err := client.BuildHTTPRequest("GET", uriPath, "1.5", params, payload)
+		explainf("'%s' is not an ID or HREF, looking up %s with name filter '%s'", param, resourceType, param)
+		filter, err := rightscript.NameFilter(param)
 		if err != nil {
 			return "", err
 		}
-		resp, err := client.PerformRequest(req)
+		payload := rsapi.APIParams{}
+		params := rsapi.APIParams{"filter[]": []string{filter}}
+		uriPath := fmt.Sprintf("/api/%s", resourceType)
+
+		var respBody []byte
+		err = Retry(lookupAttempts, retryDelay, func() error {
+			req, err := client.BuildHTTPRequest("GET", uriPath, *apiVersion, params, payload)
+			if err != nil {
+				return err
+			}
+			resp, err := client.PerformRequest(req)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			respBody, _ = ioutil.ReadAll(resp.Body)
+			if resp.StatusCode < 200 || resp.StatusCode > 299 {
+				return rightscript.NewResponseError(resp, respBody)
+			}
+			return nil
+		})
 		if err != nil {
 			return "", err
 		}
-		defer resp.Body.Close()
-		respBody, _ := ioutil.ReadAll(resp.Body)
-		if resp.StatusCode < 200 || resp.StatusCode > 299 {
-			return "", fmt.Errorf("invalid response %s: %s", resp.Status, string(respBody))
-		}
 		items := []Iterable{}
 		err = json.Unmarshal(respBody, &items)
 		if err != nil {
 			return "", err
 		}
+		explainf("The name filter returned %d %s", len(items), resourceType)
 		count := 0
 		for _, item := range items {
 			if item.Name == param && item.Revision == revision {
-				href = getLink(item.Links, "self")
+				href = rightscript.Link(item.Links, "self")
 				count = count + 1
+				explainf("  %s (revision %d) matches the name and revision exactly", href, item.Revision)
+			} else {
+				explainf("  %s '%s' (revision %d) does not match exactly, skipping", rightscript.Link(item.Links, "self"), item.Name, item.Revision)
 			}
 		}
 		revMessage := " and HEAD revision. "
 		if revision != 0 {
 			revMessage = " and revision " + strconv.Itoa(revision) + ". "
 		}
+		explainf("%d exact match(es) for revision %d (0 is HEAD)", count, revision)
+		if count == 1 {
+			explainf("Selected %s", href)
+		}
 		if count == 0 {
-			return "", fmt.Errorf("Found no %s matching '%s'%s", resourceType, param, revMessage)
+			return "", &rightscript.NotFoundError{Msg: fmt.Sprintf("Found no %s matching '%s'%s", resourceType, param, revMessage)}
 		} else if count > 1 {
 
-			return "", fmt.Errorf("Matched multiple %s with the name %s"+revMessage+
-				"Don't know which one to use. Please delete one or specify an HREF to use such as %s", resourceType, param, href)
+			return "", &ambiguousError{fmt.Sprintf("Matched multiple %s with the name %s"+revMessage+
+				"Don't know which one to use. Please delete one or specify an HREF to use such as %s (pass --explain to list all of the matches)", resourceType, param, href)}
 		}
 	}
 	return href, nil
 }
 
-func getLink(links []map[string]string, name string) string {
-	href := ""
-	for _, l := range links {
-		if l["rel"] == name {
-			href = l["href"]
+// cachedParamToHref is paramToHref backed by the local HREF cache. Only name lookups are cached since IDs and HREFs
+// resolve without calling the API. The cache is skipped entirely with --no-cache.
+func cachedParamToHref(resourceType, param string, revision int) (string, error) {
+	if *noCache || Config.Account == nil {
+		return paramToHref(resourceType, param, revision)
+	}
+
+	now := time.Now()
+	cache := LoadHrefCache(DefaultCacheDir(), *cacheTTL)
+	key := HrefCacheKey(Config.Account, resourceType, param, revision)
+	if entry, ok := cache.Get(key, now); ok {
+		explainf("Using cached HREF %s for '%s' (pass --no-cache to look it up again)", entry.Href, param)
+		if *debug {
+			fmt.Fprintf(Stdout, "DEBUG: using cached HREF %s for %s\n", entry.Href, param)
 		}
+		return entry.Href, nil
+	}
+
+	href, err := paramToHref(resourceType, param, revision)
+	if err != nil {
+		return "", err
+	}
+	if href != param && !regexp.MustCompile(`^\d+$`).MatchString(param) {
+		cache.Put(key, href, revision, now)
+		if err := cache.Save(now); err != nil && *debug {
+			fmt.Fprintf(Stdout, "DEBUG: could not save cache: %s\n", err.Error())
+		}
+	}
+	return href, nil
+}
+
+// openHTTPDumpFile creates a new HTTP dump file starting with the time it was opened, an existing dump file is rotated
+// to a .1 suffix first so the dump of the previous run is still around.
+func openHTTPDumpFile(file string, now time.Time) (*os.File, error) {
+	if _, err := os.Stat(file); err == nil {
+		if err := os.Rename(file, file+".1"); err != nil {
+			return nil, err
+		}
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(f, "# %s HTTP dump started at %s\n", app.Name, now.Format(time.RFC3339))
+	return f, nil
+}
+
+// explainf prints a step of resolving a resource to stderr when --explain is given.
+func explainf(format string, a ...interface{}) {
+	if *explain {
+		fmt.Fprintf(Stderr, "EXPLAIN: "+format+"\n", a...)
 	}
-	return href
+}
+
+// nameContains does the same case insensitive partial match of a name as a name filter.
+func nameContains(name, filter string) bool {
+	return strings.Contains(strings.ToLower(name), strings.ToLower(filter))
 }
 
 // Turn a mixed array of directories and files into a linear list of files
 func walkPaths(paths []string) ([]string, error) {
-	files := []string{}
+	return WalkPaths(paths, *followSymlinks, *includeHidden)
+}
+
+// WalkPaths turns a mixed array of directories and files into a linear list of the paths and everything below the
+// directories. Ignored paths are left out unless includeHidden is set. With followSymlinks, directories which symlinks
+// point to are walked as if they were below the symlink, but each directory is only walked once so symlinks which
+// point at each other or at a directory containing them do not loop.
+func WalkPaths(paths []string, followSymlinks, includeHidden bool) ([]string, error) {
+	w := &pathWalker{
+		followSymlinks: followSymlinks,
+		includeHidden:  includeHidden,
+		visited:        make(map[string]bool),
+		files:          []string{},
+	}
 	for _, path := range paths {
 		info, err := os.Stat(path)
 		if err != nil {
-			return files, err
+			return w.files, err
 		}
+		w.files = append(w.files, path)
 		if info.IsDir() {
-			err = filepath.Walk(path, func(p string, f os.FileInfo, err error) error {
-				files = append(files, p)
-				_, e := os.Stat(p)
-				return e
-			})
-			if err != nil {
-				return files, err
+			if err := w.walkDirectory(path); err != nil {
+				return w.files, err
 			}
-		} else {
-			files = append(files, path)
 		}
 	}
-	return files, nil
-
+	return w.files, nil
 }
 
-func fatalError(format string, v ...interface{}) {
-	msg := fmt.Sprintf("ERROR: "+format, v...)
-	fmt.Fprintf(os.Stderr, "%s\n", msg)
+// pathWalker collects the files found by WalkPaths. visited has the real paths of the directories walked so far when
+// following symlinks.
+type pathWalker struct {
+	followSymlinks bool
+	includeHidden  bool
+	visited        map[string]bool
+	files          []string
+}
 
-	os.Exit(1)
+// walkDirectory appends everything below the directory path to the files found.
+func (w *pathWalker) walkDirectory(path string) error {
+	if w.followSymlinks {
+		if first, err := w.visit(path); err != nil || !first {
+			return err
+		}
+	}
+	// filepath.Walk does not descend into a symlink given as the root unless it ends with a separator
+	root := path
+	if !strings.HasSuffix(root, string(os.PathSeparator)) {
+		root += string(os.PathSeparator)
+	}
+	return filepath.Walk(root, func(p string, f os.FileInfo, err error) error {
+		if p == root {
+			return err
+		}
+		if err == nil && !w.includeHidden && IgnoredPath(f.Name(), f.IsDir()) {
+			if f.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// a directory already walked through a symlink
+		if err == nil && w.followSymlinks && f.IsDir() {
+			if first, err := w.visit(p); err != nil || !first {
+				return skipDir(err)
+			}
+		}
+		w.files = append(w.files, p)
+		info, err := os.Stat(p)
+		if err != nil || !w.followSymlinks || f.Mode()&os.ModeSymlink == 0 || !info.IsDir() {
+			return err
+		}
+		loops, err := symlinkLoops(p)
+		if err != nil {
+			return err
+		}
+		if loops {
+			fmt.Fprintf(Stderr, "WARNING: Not following symlink %s to a directory containing it\n", p)
+			return nil
+		}
+		target, err := filepath.EvalSymlinks(p)
+		if err != nil {
+			return err
+		}
+		if w.visited[target] {
+			fmt.Fprintf(Stderr, "WARNING: Not following symlink %s to %s which was already walked\n", p, target)
+			return nil
+		}
+		return w.walkDirectory(p)
+	})
 }
 
-func fmd5sum(path string) (string, error) {
-	file, err := os.Open(path)
+// visit records the real path of the directory at path as walked and returns whether it had not been walked before.
+func (w *pathWalker) visit(path string) (bool, error) {
+	real, err := filepath.EvalSymlinks(path)
 	if err != nil {
-		return "", err
+		return false, err
+	}
+	if w.visited[real] {
+		return false, nil
 	}
-	defer file.Close()
-	return md5sum(file)
+	w.visited[real] = true
+	return true, nil
 }
 
-func md5sum(data io.Reader) (string, error) {
-	hash := md5.New()
-
-	_, err := io.Copy(hash, data)
+// skipDir returns err if there is one so filepath.Walk stops, or filepath.SkipDir so it only skips the directory.
+func skipDir(err error) error {
 	if err != nil {
-		return "", err
+		return err
 	}
-
-	return hex.EncodeToString(hash.Sum(nil)), nil
+	return filepath.SkipDir
 }
 
-// Allows p{L} is a UTF8 equivalent of \w which will allow should allow for
-// non ascii words
-var disallowedFileChars = regexp.MustCompile(`[^\p{L}0-9_-]+`)
-
-func cleanFileName(file string) string {
-	s := disallowedFileChars.ReplaceAllString(file, "_") // KISS hopefully
-	s = strings.Trim(s, "_")                             // axe trailing _ from ) type endings
-	s = strings.Replace(s, "_-_", "-", -1)               // space dash space comes up quite a bit
-	return s
+// symlinkLoops reports whether the symlink at path points to a directory which contains the symlink itself.
+func symlinkLoops(path string) (bool, error) {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false, err
+	}
+	parent, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return false, err
+	}
+	return parent == target || strings.HasPrefix(parent, target+string(os.PathSeparator)), nil
 }
 
-func isDirectory(path string) bool {
-	fileInfo, err := os.Stat(path)
-	if err != nil {
+// IgnoredPath returns whether a file or directory found while walking a directory should be left out: hidden files and
+// directories (which include VCS directories such as .git and .svn), editor swap and backup files, and .DS_Store.
+func IgnoredPath(name string, dir bool) bool {
+	if strings.HasPrefix(name, ".") {
+		return true
+	}
+	if dir {
 		return false
 	}
-	return fileInfo.IsDir()
+	return strings.HasSuffix(name, "~") || strings.HasSuffix(name, ".swp") || strings.HasSuffix(name, ".swo")
 }
 
-// Finds a publication in the MultiCloud Marketplace.
-// Params:
-//   kind: one of RightScript, MultiCloudImage, ServerTemplate
-//   name: name of publication to search for
-//   revision: revision of the publication to search for
-//   matchers: Hash of string (field name) -> string (match value). additional matching criteria in case there are
-//     multiple publications with the same name and revision. Usually `Publisher` is used as a tie breaker.
-// Returns:
-//   Publication if found. nil if not found. errors fatally if multiple publications are found.
-func findPublication(kind string, name string, revision int, matchers map[string]string) (*cm15.Publication, error) {
-	client, _ := Config.Account.Client15()
+// Exit codes used by fatalError so that scripts can tell the class of failure apart.
+const (
+	exitGeneral     = 1   // Anything not covered below, e.g. local file errors
+	exitConfig      = 2   // Missing or invalid configuration
+	exitAuth        = 3   // The API rejected our credentials
+	exitNotFound    = 4   // A named resource does not exist
+	exitValidation  = 5   // Invalid RightScript metadata or ServerTemplate YAML
+	exitAPI         = 6   // Any other failed API call
+	exitDiffers     = 7   // rightscript show --compare found the local script differs
+	exitInterrupted = 130 // Stopped by SIGINT or SIGTERM
+)
 
-	pubLocator := client.PublicationLocator("/api/publications")
+// ambiguousError is returned when looking up a resource by name matches more than one.
+type ambiguousError struct {
+	msg string
+}
 
-	if name == "" {
-		return nil, fmt.Errorf("No Name given when looking up %s publication", kind)
-	}
+func (e *ambiguousError) Error() string {
+	return e.msg
+}
 
-	filters := []string{
-		"name==" + name,
-		"revision==" + fmt.Sprintf("%d", revision),
+// ErrorExitCode picks the exit code for an error returned from a call to the API.
+func ErrorExitCode(err error) int {
+	if _, ok := err.(*rightscript.NotFoundError); ok {
+		return exitNotFound
 	}
-	if *debug {
-		fmt.Printf("DEBUG: looking for publication with KIND:%s NAME:%s REVISION:%d MATCHERS:%v\n", kind, name, revision, matchers)
+	if _, ok := err.(*tokenError); ok {
+		return exitAuth
 	}
-	pubsUnfiltered, err := pubLocator.Index(rsapi.APIParams{"filter": filters})
-	if err != nil {
-		return nil, fmt.Errorf("Call to /api/publications failed: %s", err.Error())
-	}
-	var pubs []*cm15.Publication
-	for _, pub := range pubsUnfiltered {
-		// Recheck the name here, filter does a partial match and we need an exact one.
-		// Also make sure the type is correct
-		if pub.Name == name && kind == pub.ContentType {
-			// We may provide additional matchers to break ties, i.e. the Description/Publisher field. In any are supplied
-			// we make sure they all match.
-			matched_all_matchers := true
-			for fieldName, value := range matchers {
-				v := reflect.Indirect(reflect.ValueOf(pub)).FieldByName(fieldName)
-				if v.IsValid() {
-					if v.String() != value {
-						matched_all_matchers = false
-					}
-				}
-			}
-			if matched_all_matchers {
-				pubs = append(pubs, pub)
-			}
-		}
+	switch rightscript.ResponseStatusCode(err) {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return exitAuth
+	case http.StatusNotFound:
+		return exitNotFound
 	}
+	return exitAPI
+}
 
-	if len(pubs) == 0 {
-		return nil, nil
-	} else if len(pubs) == 2 {
-		fmt.Printf("Too many %s publications matching %s with revision %d\n", kind, name, revision)
-		for _, pub := range pubs {
-			pubHref := getLink(pub.Links, "self")
-			fmt.Printf("  Publisher:%s Revision:%d Href:%s\n", pub.Publisher, pub.Revision, pubHref)
-		}
-		return nil, fmt.Errorf("Too many publications")
-	} else {
-		return pubs[0], nil
+func fatalError(code int, format string, v ...interface{}) {
+	msg := fmt.Sprintf("ERROR: "+format, v...)
+	fmt.Fprintf(Stderr, "%s\n", strings.TrimRight(msg, "\n"))
+	switch RequestContext.Err() {
+	case context.Canceled:
+		code = exitInterrupted
+	case context.DeadlineExceeded:
+		fmt.Fprintf(Stderr, "The command did not finish within its timeout of %s, see --timeout\n", requestTimeout)
 	}
+	if code == exitAuth && Config.Account != nil && !strings.Contains(msg, "token has expired or is invalid") {
+		fmt.Fprintf(Stderr, "%s\n", Config.TokenHelp())
+	}
+
+	exit(code)
 }
 
-func getTagsByHref(href string) ([]string, error) {
-	client, _ := Config.Account.Client15()
-	var tags []string
-	tagsLoc := client.TagLocator("/api/tags/by_resource")
-	res, err := tagsLoc.ByResource([]string{href})
-	if err != nil {
-		return tags, err
+// setTimeout records that --timeout was given so it is used instead of the timeout of the command.
+func setTimeout(*kingpin.ParseContext) error {
+	timeoutSet = true
+	return nil
+}
+
+// setTransportFlag returns an action recording that the flag name, which configures the pooled transport, was given.
+func setTransportFlag(name string) kingpin.Action {
+	return func(*kingpin.ParseContext) error {
+		transportFlagsSet = append(transportFlagsSet, name)
+		return nil
 	}
-	if len(res) != 1 {
-		return tags, fmt.Errorf("Could not find tags for href %s", href)
+}
+
+// isInteractive reports whether stdin is a terminal someone can answer questions on.
+func isInteractive() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// canConfirm reports whether confirmation prompts can be answered, by someone at a terminal or by --assume-yes.
+func canConfirm() bool {
+	return *assumeYes || isInteractive()
+}
+
+// confirm asks question on stderr and reports whether it was answered with yes. With --assume-yes it is answered with yes
+// without waiting for an answer.
+func confirm(question string) bool {
+	if *assumeYes {
+		fmt.Fprintf(Stderr, "%s [y/N]: y (--assume-yes)\n", question)
+		return true
 	}
-	tagset := res[0]["tags"].([]interface{})
-	for _, t := range tagset {
-		th := t.(map[string]interface{})
-		tags = append(tags, th["name"].(string))
+	fmt.Fprintf(Stderr, "%s [y/N]: ", question)
+	var answer string
+	if _, err := fmt.Fscanln(os.Stdin, &answer); err != nil {
+		return false
 	}
+	answer = strings.ToLower(answer)
+	return answer == "y" || answer == "yes"
+}
 
-	return tags, nil
+// parseFileMode parses an octal file mode such as 0644
+func parseFileMode(mode string) (os.FileMode, error) {
+	m, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || m > 0777 {
+		return 0, fmt.Errorf("Invalid file mode '%s': must be an octal mode such as 0644", mode)
+	}
+	return os.FileMode(m), nil
 }
 
 func setTagsByHref(href string, tags []string) error {
 	client, _ := Config.Account.Client15()
 
-	existingTags, err := getTagsByHref(href)
+	existingTags, err := rightScriptClient(client).Tags(href)
 	if err != nil {
 		return err
 	}
@@ -454,8 +1023,3 @@ func setTagsByHref(href string, tags []string) error {
 	}
 	return nil
 }
-
-// Carriage returns get inserted by the API and mess up formatting of the YAML file.
-func removeCarriageReturns(s string) string {
-	return strings.Replace(s, "\r", "", -1)
-}
@@ -6,6 +6,8 @@ import (
 
 	"github.com/rightscale/rsc/cm15"
 	"github.com/rightscale/rsc/rsapi"
+
+	"github.com/rightscale/right_st/rightscript"
 )
 
 type Setting struct {
@@ -44,7 +46,7 @@ func validateMultiCloudImage(mciDef *MultiCloudImage) (errors []error) {
 	if instanceTypesLookup == nil {
 		cl, err := client.CloudLocator("/api/clouds").Index(rsapi.APIParams{})
 		if err != nil {
-			fatalError("Could not execute API call to get clouds: %s", err.Error())
+			fatalError(ErrorExitCode(err), "Could not execute API call to get clouds: %s", err.Error())
 		}
 		cloudsLookup = cl
 		instanceTypesLookup = make(map[string][]*cm15.InstanceType)
@@ -65,8 +67,8 @@ func validateMultiCloudImage(mciDef *MultiCloudImage) (errors []error) {
 				return
 			}
 			for _, c := range cloudsLookup {
-				if getLink(c.Links, "self") == s.Cloud || c.DisplayName == s.Cloud || c.Name == s.Cloud {
-					mciDef.Settings[i].cloudHref = getLink(c.Links, "self")
+				if rightscript.Link(c.Links, "self") == s.Cloud || c.DisplayName == s.Cloud || c.Name == s.Cloud {
+					mciDef.Settings[i].cloudHref = rightscript.Link(c.Links, "self")
 				}
 			}
 			if mciDef.Settings[i].cloudHref == "" {
@@ -82,8 +84,8 @@ func validateMultiCloudImage(mciDef *MultiCloudImage) (errors []error) {
 				instanceTypesLookup[mciDef.Settings[i].cloudHref] = its
 			}
 			for _, it := range instanceTypesLookup[mciDef.Settings[i].cloudHref] {
-				if getLink(it.Links, "self") == s.InstanceType || it.Name == s.InstanceType || it.ResourceUid == s.InstanceType {
-					mciDef.Settings[i].instanceTypeHref = getLink(it.Links, "self")
+				if rightscript.Link(it.Links, "self") == s.InstanceType || it.Name == s.InstanceType || it.ResourceUid == s.InstanceType {
+					mciDef.Settings[i].instanceTypeHref = rightscript.Link(it.Links, "self")
 				}
 			}
 			if mciDef.Settings[i].instanceTypeHref == "" {
@@ -101,12 +103,12 @@ func validateMultiCloudImage(mciDef *MultiCloudImage) (errors []error) {
 				errors = append(errors, fmt.Errorf("Cannot find image with resource_uid %s for MCI '%s' cloud %s",
 					s.Image, mciDef.Name, mciDef.Settings[i].Cloud))
 			} else {
-				mciDef.Settings[i].imageHref = getLink(images[0].Links, "self")
+				mciDef.Settings[i].imageHref = rightscript.Link(images[0].Links, "self")
 			}
 
 		}
 	} else if mciDef.Publisher != "" {
-		pub, err := findPublication("MultiCloudImage", mciDef.Name, mciDef.Revision,
+		pub, err := rightScriptClient(client).FindPublication("MultiCloudImage", mciDef.Name, mciDef.Revision,
 			map[string]string{`Publisher`: mciDef.Publisher})
 		if err != nil {
 			errors = append(errors, fmt.Errorf("Error finding publication for MultiCloudImage: %s\n", err.Error()))
@@ -131,9 +133,9 @@ func validateMultiCloudImage(mciDef *MultiCloudImage) (errors []error) {
 func downloadMultiCloudImages(st *cm15.ServerTemplate, downloadMciSettings bool) ([]*MultiCloudImage, error) {
 	client, _ := Config.Account.Client15()
 
-	defaultMciHref := getLink(st.Links, "default_multi_cloud_image")
+	defaultMciHref := rightscript.Link(st.Links, "default_multi_cloud_image")
 
-	mciLocator := client.MultiCloudImageLocator(getLink(st.Links, "multi_cloud_images"))
+	mciLocator := client.MultiCloudImageLocator(rightscript.Link(st.Links, "multi_cloud_images"))
 
 	apiMcis, err := mciLocator.Index(rsapi.APIParams{})
 	if err != nil {
@@ -142,41 +144,41 @@ func downloadMultiCloudImages(st *cm15.ServerTemplate, downloadMciSettings bool)
 	mciImages := make([]*MultiCloudImage, 0)
 	for _, mci := range apiMcis {
 		if downloadMciSettings {
-			tags, err := getTagsByHref(getLink(mci.Links, "self"))
+			tags, err := rightScriptClient(client).Tags(rightscript.Link(mci.Links, "self"))
 			if err != nil {
-				return nil, fmt.Errorf("Could not get tags for MultiCloudImage '%s': %s\n", getLink(mci.Links, "self"), err.Error())
+				return nil, fmt.Errorf("Could not get tags for MultiCloudImage '%s': %s\n", rightscript.Link(mci.Links, "self"), err.Error())
 			}
 
-			settingsLoc := client.MultiCloudImageSettingLocator(getLink(mci.Links, "settings"))
+			settingsLoc := client.MultiCloudImageSettingLocator(rightscript.Link(mci.Links, "settings"))
 			settings, err := settingsLoc.Index(rsapi.APIParams{})
 			if err != nil {
-				return nil, fmt.Errorf("Could not get MultiCloudImage settings %s: %s\n", getLink(mci.Links, "settings"), err.Error())
+				return nil, fmt.Errorf("Could not get MultiCloudImage settings %s: %s\n", rightscript.Link(mci.Links, "settings"), err.Error())
 			}
 			mciSettings := make([]*Setting, 0)
 			for _, s := range settings {
-				cloud, err := client.CloudLocator(getLink(s.Links, "cloud")).Show(rsapi.APIParams{})
+				cloud, err := client.CloudLocator(rightscript.Link(s.Links, "cloud")).Show(rsapi.APIParams{})
 				if err != nil {
 					if strings.Contains(err.Error(), "ResourceNotFound") {
-						fmt.Printf("WARNING: For MCI '%s', skipping setting for cloud %s: cloud isn't registered in this account.\n",
-							mci.Name, getLink(s.Links, "cloud"))
+						fmt.Fprintf(Stdout, "WARNING: For MCI '%s', skipping setting for cloud %s: cloud isn't registered in this account.\n",
+							mci.Name, rightscript.Link(s.Links, "cloud"))
 						continue
 					} else {
 						return nil, fmt.Errorf("Could not complete API call for MCI '%s' cloud %s: %s\n",
-							mci.Name, getLink(s.Links, "cloud"), err.Error())
+							mci.Name, rightscript.Link(s.Links, "cloud"), err.Error())
 					}
 				}
-				if getLink(s.Links, "instance_type") == "" {
-					fmt.Printf("WARNING: For MCI '%s', skipping setting for cloud %s: fingerprinted MCIs not supported by this tool.\n",
+				if rightscript.Link(s.Links, "instance_type") == "" {
+					fmt.Fprintf(Stdout, "WARNING: For MCI '%s', skipping setting for cloud %s: fingerprinted MCIs not supported by this tool.\n",
 						mci.Name, cloud.Name)
 					continue
 				}
-				instanceType, err := client.InstanceTypeLocator(getLink(s.Links, "instance_type")).Show(rsapi.APIParams{})
+				instanceType, err := client.InstanceTypeLocator(rightscript.Link(s.Links, "instance_type")).Show(rsapi.APIParams{})
 				if err != nil {
 					return nil, fmt.Errorf("Could not complete API call for MCI '%s' cloud %s: %s\n", mci.Name, cloud.Name, err.Error())
 				}
-				image, err := client.ImageLocator(getLink(s.Links, "image")).Show(rsapi.APIParams{})
+				image, err := client.ImageLocator(rightscript.Link(s.Links, "image")).Show(rsapi.APIParams{})
 				if err != nil {
-					fmt.Printf("WARNING: Could not complete API call for MCI '%s' cloud %s: %s\n", mci.Name, cloud.Name, err.Error())
+					fmt.Fprintf(Stdout, "WARNING: Could not complete API call for MCI '%s' cloud %s: %s\n", mci.Name, cloud.Name, err.Error())
 					continue
 				}
 
@@ -188,26 +190,26 @@ func downloadMultiCloudImages(st *cm15.ServerTemplate, downloadMciSettings bool)
 				mciImage := MultiCloudImage{
 					Name:        mci.Name,
 					Tags:        tags,
-					Description: removeCarriageReturns(mci.Description),
+					Description: rightscript.RemoveCarriageReturns(mci.Description),
 					Settings:    mciSettings,
 				}
-				if getLink(mci.Links, "self") == defaultMciHref {
+				if rightscript.Link(mci.Links, "self") == defaultMciHref {
 					mciImages = append([]*MultiCloudImage{&mciImage}, mciImages...)
 				} else {
 					mciImages = append(mciImages, &mciImage)
 				}
 			} else {
-				fmt.Printf("WARNING: skipping MCI '%s', contains no usable settings\n", mci.Name)
+				fmt.Fprintf(Stdout, "WARNING: skipping MCI '%s', contains no usable settings\n", mci.Name)
 			}
 		} else {
 			// We repull the MCI here to get the description field, which we need to break ties between
 			// similarly named publications!
-			mciLoc := client.MultiCloudImageLocator(getLink(mci.Links, "self"))
+			mciLoc := client.MultiCloudImageLocator(rightscript.Link(mci.Links, "self"))
 			mci, err := mciLoc.Show()
 			if err != nil {
-				return nil, fmt.Errorf("Could not get MultiCloudImage %s: %s\n", getLink(mci.Links, "self"), err.Error())
+				return nil, fmt.Errorf("Could not get MultiCloudImage %s: %s\n", rightscript.Link(mci.Links, "self"), err.Error())
 			}
-			pub, err := findPublication("MultiCloudImage", mci.Name, mci.Revision, map[string]string{`Description`: mci.Description})
+			pub, err := rightScriptClient(client).FindPublication("MultiCloudImage", mci.Name, mci.Revision, map[string]string{`Description`: mci.Description})
 			if err != nil {
 				return nil, fmt.Errorf("Error finding publication: %s\n", err.Error())
 			}
@@ -216,7 +218,7 @@ func downloadMultiCloudImages(st *cm15.ServerTemplate, downloadMciSettings bool)
 				mciImage.Publisher = pub.Publisher
 			}
 			// Default MCI is the first in the list
-			if getLink(mci.Links, "self") == defaultMciHref {
+			if rightscript.Link(mci.Links, "self") == defaultMciHref {
 				mciImages = append([]*MultiCloudImage{&mciImage}, mciImages...)
 			} else {
 				mciImages = append(mciImages, &mciImage)
@@ -245,7 +247,7 @@ func uploadMultiCloudImages(stDef *ServerTemplate, prefix string) error {
 	//   4. Insert HREF into r struct for later use.
 	for _, mciDef := range stDef.MultiCloudImages {
 		if mciDef.Publisher != "" {
-			pub, err := findPublication("MultiCloudImage", mciDef.Name, mciDef.Revision,
+			pub, err := rightScriptClient(client).FindPublication("MultiCloudImage", mciDef.Name, mciDef.Revision,
 				map[string]string{`Publisher`: mciDef.Publisher})
 			if err != nil {
 				return fmt.Errorf("Could not lookup publication %s", err.Error())
@@ -256,8 +258,12 @@ func uploadMultiCloudImages(stDef *ServerTemplate, prefix string) error {
 			}
 
 			mciLocator := client.MultiCloudImageLocator("/api/multi_cloud_images")
+			filter, err := rightscript.NameFilter(mciDef.Name)
+			if err != nil {
+				return fmt.Errorf("Error looking up MCI: %s", err.Error())
+			}
 			filters := []string{
-				"name==" + mciDef.Name,
+				filter,
 			}
 
 			mciUnfiltered, err := mciLocator.Index(rsapi.APIParams{"filter": filters})
@@ -269,7 +275,7 @@ func uploadMultiCloudImages(stDef *ServerTemplate, prefix string) error {
 				// Matching the descriptions helps to disambiguate if we have multiple publications
 				// with that same name/revision pair.
 				if mci.Name == mciDef.Name && mci.Revision == mciDef.Revision && mci.Description == pub.Description {
-					mciDef.Href = getLink(mci.Links, "self")
+					mciDef.Href = rightscript.Link(mci.Links, "self")
 				}
 			}
 
@@ -280,7 +286,7 @@ func uploadMultiCloudImages(stDef *ServerTemplate, prefix string) error {
 
 				if err != nil {
 					return fmt.Errorf("Failed to import publication %s for MultiCloudImage '%s' Revision %d Publisher %s\n",
-						getLink(pub.Links, "self"), mciDef.Name, mciDef.Revision, mciDef.Publisher)
+						rightscript.Link(pub.Links, "self"), mciDef.Name, mciDef.Revision, mciDef.Publisher)
 				}
 
 				mciUnfiltered, err := mciLocator.Index(rsapi.APIParams{"filter": filters})
@@ -289,7 +295,7 @@ func uploadMultiCloudImages(stDef *ServerTemplate, prefix string) error {
 				}
 				for _, mci := range mciUnfiltered {
 					if mci.Name == mciDef.Name && mci.Revision == mciDef.Revision && mci.Description == pub.Description {
-						mciDef.Href = getLink(mci.Links, "self")
+						mciDef.Href = rightscript.Link(mci.Links, "self")
 					}
 				}
 				if mciDef.Href == "" {
@@ -319,13 +325,13 @@ func uploadMultiCloudImages(stDef *ServerTemplate, prefix string) error {
 					return fmt.Errorf("API call to create MultiCloudImage '%s' failed: %s", mciName, err.Error())
 				}
 				href = string(loc.Href)
-				fmt.Printf("  Created MultiCloudImage with name '%s': %s\n", mciName, href)
+				fmt.Fprintf(Stdout, "  Created MultiCloudImage with name '%s': %s\n", mciName, href)
 			} else {
 				mci, err := client.MultiCloudImageLocator(href).Show()
 				if err != nil {
 					return fmt.Errorf("API call failed: %s", err.Error())
 				}
-				fmt.Printf("  Updating MultiCloudImage '%s'\n", mciName)
+				fmt.Fprintf(Stdout, "  Updating MultiCloudImage '%s'\n", mciName)
 				if mci.Description != mciDef.Description {
 					err := mci.Locator(client).Update(&cm15.MultiCloudImageParam{Description: mciDef.Description})
 					if err != nil {
@@ -343,7 +349,7 @@ func uploadMultiCloudImages(stDef *ServerTemplate, prefix string) error {
 			settingsLoc := client.MultiCloudImageSettingLocator(mciDef.Href + "/settings")
 			settings, err := settingsLoc.Index(rsapi.APIParams{})
 			if err != nil {
-				fatalError("Could not get MultiCloudImage settings %s: %s\n", mciDef.Href, err.Error())
+				fatalError(ErrorExitCode(err), "Could not get MultiCloudImage settings %s: %s\n", mciDef.Href, err.Error())
 			}
 			seenSettings := make(map[string]bool)
 
@@ -352,7 +358,7 @@ func uploadMultiCloudImages(stDef *ServerTemplate, prefix string) error {
 				updated := false
 				seenSettings[s.cloudHref] = true
 				for _, s2 := range settings {
-					if s.cloudHref == getLink(s2.Links, "cloud") {
+					if s.cloudHref == rightscript.Link(s2.Links, "cloud") {
 						updateParams := cm15.MultiCloudImageSettingParam{
 							CloudHref:        s.cloudHref,
 							ImageHref:        s.imageHref,
@@ -363,7 +369,7 @@ func uploadMultiCloudImages(stDef *ServerTemplate, prefix string) error {
 
 						err := s2.Locator(client).Update(&updateParams)
 						if err != nil {
-							fatalError("Could not update MultiCloudImage setting %s: %s\n", getLink(s2.Links, "self"), err.Error())
+							fatalError(ErrorExitCode(err), "Could not update MultiCloudImage setting %s: %s\n", rightscript.Link(s2.Links, "self"), err.Error())
 						}
 						updated = true
 					}
@@ -378,17 +384,17 @@ func uploadMultiCloudImages(stDef *ServerTemplate, prefix string) error {
 					}
 					_, err := settingsLoc.Create(&createParams)
 					if err != nil {
-						fatalError("Could not create MultiCloudImage setting %s: %s\n", mciDef.Href, err.Error())
+						fatalError(ErrorExitCode(err), "Could not create MultiCloudImage setting %s: %s\n", mciDef.Href, err.Error())
 					}
 				}
 			}
 			// for existing settings not in desired settings, remove them
 			for _, s := range settings {
-				if !seenSettings[getLink(s.Links, "cloud")] {
+				if !seenSettings[rightscript.Link(s.Links, "cloud")] {
 					err := s.Locator(client).Destroy()
 					if err != nil {
-						fatalError("  Could not Remove MCI Setting for MCI '%s' with cloud %s: %s",
-							mciName, getLink(s.Links, "cloud"), err.Error())
+						fatalError(exitGeneral, "  Could not Remove MCI Setting for MCI '%s' with cloud %s: %s",
+							mciName, rightscript.Link(s.Links, "cloud"), err.Error())
 					}
 				}
 			}
@@ -402,7 +408,7 @@ func uploadMultiCloudImages(stDef *ServerTemplate, prefix string) error {
 	// make the firstValidMci the default in that case then proceed with the delete.
 	var firstValidMci *cm15.ServerTemplateMultiCloudImageLocator
 	for _, mci := range existingMcis {
-		mciHref := getLink(mci.Links, "multi_cloud_image")
+		mciHref := rightscript.Link(mci.Links, "multi_cloud_image")
 		for _, mciDef := range stDef.MultiCloudImages {
 			if mciDef.Href == mciHref {
 				firstValidMci = mci.Locator(client)
@@ -421,21 +427,21 @@ func uploadMultiCloudImages(stDef *ServerTemplate, prefix string) error {
 
 		dummyMcis, err := mciLocator.Index(rsapi.APIParams{})
 		if err != nil {
-			fatalError("Failed to find dummy MCIs: %s", mciLocator.Href, err.Error())
+			fatalError(ErrorExitCode(err), "Failed to find dummy MCIs: %s", mciLocator.Href, err.Error())
 		}
 		params := cm15.ServerTemplateMultiCloudImageParam{
-			MultiCloudImageHref: getLink(dummyMcis[0].Links, "self"),
+			MultiCloudImageHref: rightscript.Link(dummyMcis[0].Links, "self"),
 			ServerTemplateHref:  stDef.href,
 		}
 		loc, err := stMciLocator.Create(&params)
 		if err != nil {
-			fatalError("  Failed to associate Dummy MCI '%s' with ServerTemplate '%s': %s", getLink(dummyMcis[0].Links, "self"), stDef.href, err.Error())
+			fatalError(ErrorExitCode(err), "  Failed to associate Dummy MCI '%s' with ServerTemplate '%s': %s", rightscript.Link(dummyMcis[0].Links, "self"), stDef.href, err.Error())
 		}
 		firstValidMci = loc
 		defer loc.Destroy()
 	}
 	for _, mci := range existingMcis {
-		mciHref := getLink(mci.Links, "multi_cloud_image")
+		mciHref := rightscript.Link(mci.Links, "multi_cloud_image")
 		foundMci := false // found on ST definition
 		for _, mciDef := range stDef.MultiCloudImages {
 			if mciDef.Href == mciHref {
@@ -443,13 +449,13 @@ func uploadMultiCloudImages(stDef *ServerTemplate, prefix string) error {
 			}
 		}
 		if !foundMci {
-			fmt.Printf("  Removing MCI %s\n", mciHref)
+			fmt.Fprintf(Stdout, "  Removing MCI %s\n", mciHref)
 			if mci.IsDefault {
 				firstValidMci.MakeDefault()
 			}
 			err := mci.Locator(client).Destroy()
 			if err != nil {
-				fatalError("  Could not Remove MCI %s", mciHref)
+				fatalError(exitGeneral, "  Could not Remove MCI %s", mciHref)
 			}
 		}
 	}
@@ -458,7 +464,7 @@ func uploadMultiCloudImages(stDef *ServerTemplate, prefix string) error {
 	for i, mciDef := range stDef.MultiCloudImages {
 		foundMci := false // found on ST
 		for _, mci := range existingMcis {
-			mciHref := getLink(mci.Links, "multi_cloud_image")
+			mciHref := rightscript.Link(mci.Links, "multi_cloud_image")
 			if mciDef.Href == mciHref {
 				foundMci = true
 			}
@@ -472,10 +478,10 @@ func uploadMultiCloudImages(stDef *ServerTemplate, prefix string) error {
 			if prefix != "" {
 				mciName = fmt.Sprintf("%s_%s", prefix, mciName)
 			}
-			fmt.Printf("  Adding MCI '%s' revision '%d' (%s)\n", mciName, mciDef.Revision, mciDef.Href)
+			fmt.Fprintf(Stdout, "  Adding MCI '%s' revision '%d' (%s)\n", mciName, mciDef.Revision, mciDef.Href)
 			loc, err := stMciLocator.Create(&params)
 			if err != nil {
-				fatalError("  Failed to associate MCI '%s' with ServerTemplate '%s': %s", mciDef.Href, stDef.href, err.Error())
+				fatalError(ErrorExitCode(err), "  Failed to associate MCI '%s' with ServerTemplate '%s': %s", mciDef.Href, stDef.href, err.Error())
 			}
 			if i == 0 {
 				_ = loc.MakeDefault()
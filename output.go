@@ -0,0 +1,13 @@
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// Commands write their output to Stdout and their warnings and errors to Stderr rather than straight to the streams of
+// the process, so tests can capture what a command prints by pointing them at a buffer.
+var (
+	Stdout io.Writer = os.Stdout
+	Stderr io.Writer = os.Stderr
+)
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+
+	"github.com/rightscale/right_st/rightscript"
+)
+
+// The files the hidden --cpuprofile and --memprofile flags write pprof profiles to, kept so the profiles can still be
+// written when the command exits early
+var cpuProfile, memProfile *os.File
+
+// startProfiling starts writing a CPU profile to cpuFile and opens memFile to write a heap profile to when the command
+// finishes. Either may be empty to not profile that.
+func startProfiling(cpuFile, memFile string) error {
+	if cpuFile != "" {
+		f, err := os.Create(cpuFile)
+		if err != nil {
+			return err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return err
+		}
+		cpuProfile = f
+	}
+	if memFile != "" {
+		f, err := os.Create(memFile)
+		if err != nil {
+			return err
+		}
+		memProfile = f
+	}
+	return nil
+}
+
+// stopProfiling finishes any profiles started by startProfiling. A heap profile records the allocations made over the
+// whole command as well as what is still in use at the end.
+func stopProfiling() {
+	if cpuProfile != nil {
+		pprof.StopCPUProfile()
+		cpuProfile.Close()
+		cpuProfile = nil
+	}
+	if memProfile != nil {
+		runtime.GC()
+		if err := pprof.WriteHeapProfile(memProfile); err != nil {
+			fmt.Fprintf(Stderr, "WARNING: Could not write memory profile: %s\n", err.Error())
+		}
+		memProfile.Close()
+		memProfile = nil
+	}
+}
+
+// exit stops profiling and removes temporary directories before exiting with code since deferred functions do not run
+// on os.Exit.
+func exit(code int) {
+	stopProfiling()
+	rightscript.RemoveTempDirs()
+	os.Exit(code)
+}
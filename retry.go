@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rightscale/rsc/httpclient"
+
+	"github.com/rightscale/right_st/rightscript"
+)
+
+// Transient failures of name lookups are retried this many times in total, waiting retryDelay before the first retry
+// and twice as long before each one after that
+var (
+	lookupAttempts = 3
+	retryDelay     = time.Second
+)
+
+// Retry is rightscript.Retry with RequestContext, explaining each retry with --explain.
+func Retry(attempts int, delay time.Duration, f func() error) error {
+	return rightscript.Retry(RequestContext, attempts, delay, explainf, f)
+}
+
+// A request which is rate limited with a 429 response is sent again up to this many times, waiting as long as the
+// Retry-After header of the response says but never longer than maxRetryAfter
+var (
+	rateLimitRetries = 5
+	maxRetryAfter    = 2 * time.Minute
+)
+
+// Request bodies up to MaxReplayBodySize bytes are kept in memory so a rate limited request can be sent again. Larger
+// ones, such as multipart attachment uploads, are streamed and a rate limited response to them is returned as is.
+var MaxReplayBodySize = 1024 * 1024
+
+// BufferRequestBody reads the body of req into memory and returns it so req can be sent again, as long as it is no
+// larger than maxSize bytes. A larger body is left to be streamed with everything read so far put back in front of it,
+// and nil is returned.
+func BufferRequestBody(req *http.Request, maxSize int) ([]byte, error) {
+	if req.Body == nil || req.ContentLength > int64(maxSize) {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(req.Body, int64(maxSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxSize {
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), req.Body), req.Body}
+		return nil, nil
+	}
+	req.Body.Close()
+	return body, nil
+}
+
+// RetryAfter returns how long the Retry-After header value asks to wait from now, which is either a number of seconds
+// or an HTTP date. ok is false when there is no valid value.
+func RetryAfter(value string, now time.Time) (wait time.Duration, ok bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait = date.Sub(now); wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+// rateLimitedClient sends a request again after waiting when the response is 429 Too Many Requests, so bulk
+// operations back off instead of failing.
+type rateLimitedClient struct {
+	next httpclient.HTTPClient
+}
+
+func (c *rateLimitedClient) Do(req *http.Request) (*http.Response, error) {
+	return doRateLimited(req, c.next.Do)
+}
+
+func (c *rateLimitedClient) DoHidden(req *http.Request) (*http.Response, error) {
+	return doRateLimited(req, c.next.DoHidden)
+}
+
+func doRateLimited(req *http.Request, do func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	// the body is read by sending the request so keep a copy to send it again, unless it is too large to keep
+	body, err := BufferRequestBody(req, MaxReplayBodySize)
+	if err != nil {
+		return nil, err
+	}
+	replayable := req.Body == nil || body != nil
+	delay := retryDelay
+	for attempt := 0; ; attempt++ {
+		if body != nil {
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+		resp, err := do(req)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests || attempt >= rateLimitRetries || !replayable {
+			return resp, err
+		}
+		wait, ok := RetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if !ok {
+			wait, delay = delay, delay*2
+		}
+		if wait > maxRetryAfter {
+			wait = maxRetryAfter
+		}
+		resp.Body.Close()
+		explainf("Rate limited by %s %s, retrying in %s", req.Method, req.URL.Path, wait)
+		if err := sleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package main_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	. "github.com/rightscale/right_st"
+	"github.com/rightscale/right_st/rightscript"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Retry", func() {
+	It("Retries transient errors until it succeeds", func() {
+		calls := 0
+		err := Retry(3, time.Millisecond, func() error {
+			calls++
+			if calls < 3 {
+				return errors.New("invalid response 503 Service Unavailable")
+			}
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(calls).To(Equal(3))
+	})
+
+	It("Gives up after the attempts", func() {
+		calls := 0
+		err := Retry(2, time.Millisecond, func() error {
+			calls++
+			return errors.New("invalid response 502 Bad Gateway")
+		})
+		Expect(err).To(MatchError("invalid response 502 Bad Gateway"))
+		Expect(calls).To(Equal(2))
+	})
+
+	It("Does not retry other errors", func() {
+		calls := 0
+		err := Retry(3, time.Millisecond, func() error {
+			calls++
+			return errors.New("invalid response 403 Forbidden")
+		})
+		Expect(err).To(HaveOccurred())
+		Expect(calls).To(Equal(1))
+	})
+
+	It("Stops waiting to retry when the command is cancelled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		RequestContext = ctx
+		defer func() { RequestContext = context.Background() }()
+
+		calls := 0
+		err := Retry(3, time.Hour, func() error {
+			calls++
+			return errors.New("invalid response 503 Service Unavailable")
+		})
+		Expect(err).To(MatchError("invalid response 503 Service Unavailable"))
+		Expect(calls).To(Equal(1))
+	})
+})
+
+var _ = Describe("Buffer request body", func() {
+	It("Keeps a small body so the request can be sent again", func() {
+		req, err := http.NewRequest("PUT", "https://us-3.rightscale.com/api/right_scripts/1", strings.NewReader("source"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(BufferRequestBody(req, 16)).To(Equal([]byte("source")))
+	})
+
+	It("Streams a large body without keeping it", func() {
+		req, err := http.NewRequest("POST", "https://us-3.rightscale.com/api/right_scripts/1/attachments",
+			bytes.NewReader(bytes.Repeat([]byte("a"), 32)))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(BufferRequestBody(req, 16)).To(BeNil())
+		Expect(ioutil.ReadAll(req.Body)).To(HaveLen(32))
+	})
+
+	It("Puts back what was read of a large body of unknown length", func() {
+		req, err := http.NewRequest("POST", "https://us-3.rightscale.com/api/right_scripts/1/attachments",
+			ioutil.NopCloser(strings.NewReader(strings.Repeat("a", 31)+"b")))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(req.ContentLength).To(BeZero())
+		Expect(BufferRequestBody(req, 16)).To(BeNil())
+		Expect(ioutil.ReadAll(req.Body)).To(Equal([]byte(strings.Repeat("a", 31) + "b")))
+	})
+})
+
+var _ = Describe("Error exit code", func() {
+	// the exit codes documented in the README
+	const (
+		exitAuth     = 3
+		exitNotFound = 4
+		exitAPI      = 6
+	)
+
+	It("Exits with the auth code for 401 and 403 responses", func() {
+		Expect(ErrorExitCode(errors.New("invalid response 401 Unauthorized"))).To(Equal(exitAuth))
+		resp := &http.Response{Status: "403 Forbidden", StatusCode: 403, Header: http.Header{}}
+		Expect(ErrorExitCode(rightscript.NewResponseError(resp, nil))).To(Equal(exitAuth))
+	})
+
+	It("Exits with the not found code for 404 responses", func() {
+		Expect(ErrorExitCode(errors.New("Could not find RightScript with href /api/right_scripts/1: " +
+			"invalid response 404 Not Found: Not found"))).To(Equal(exitNotFound))
+	})
+
+	It("Exits with the API code for other responses and errors", func() {
+		Expect(ErrorExitCode(errors.New("invalid response 500 Internal Server Error"))).To(Equal(exitAPI))
+		Expect(ErrorExitCode(errors.New("connection reset by peer"))).To(Equal(exitAPI))
+	})
+
+	It("Does not take IDs or names which look like an auth failure for one", func() {
+		Expect(ErrorExitCode(errors.New("Could not update RightScript with href /api/right_scripts/401: " +
+			"invalid response 422 Unprocessable Entity: Name has already been taken"))).To(Equal(exitAPI))
+		Expect(ErrorExitCode(errors.New("Could not upload attachment 'forbidden-403.txt': " +
+			"invalid response 500 Internal Server Error"))).To(Equal(exitAPI))
+	})
+})
+
+var _ = Describe("Retry after", func() {
+	now := time.Date(2016, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	It("Parses a number of seconds", func() {
+		wait, ok := RetryAfter("30", now)
+		Expect(ok).To(BeTrue())
+		Expect(wait).To(Equal(30 * time.Second))
+	})
+
+	It("Parses an HTTP date", func() {
+		wait, ok := RetryAfter("Tue, 10 May 2016 12:01:30 GMT", now)
+		Expect(ok).To(BeTrue())
+		Expect(wait).To(Equal(90 * time.Second))
+	})
+
+	It("Does not wait for a date in the past", func() {
+		wait, ok := RetryAfter("Tue, 10 May 2016 11:00:00 GMT", now)
+		Expect(ok).To(BeTrue())
+		Expect(wait).To(BeZero())
+	})
+
+	It("Rejects missing and invalid values", func() {
+		_, ok := RetryAfter("", now)
+		Expect(ok).To(BeFalse())
+		_, ok = RetryAfter("soon", now)
+		Expect(ok).To(BeFalse())
+	})
+})
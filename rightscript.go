@@ -1,768 +1,1496 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"net/url"
+	"net/http"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/rightscale/rsc/cm15"
 	"github.com/rightscale/rsc/rsapi"
 	"github.com/tonnerre/golang-pretty"
+
+	"github.com/rightscale/right_st/rightscript"
 )
 
+// rightScriptClient returns a rightscript.Client for client which prints progress to Stdout and makes its requests the
+// way the command line says: with RequestContext, --api-version, --debug, and --explain.
+func rightScriptClient(client *cm15.API) *rightscript.Client {
+	return &rightscript.Client{
+		API:        client,
+		APIVersion: *apiVersion,
+		Context:    RequestContext,
+		Stdout:     Stdout,
+		Debug:      *debug,
+		Explain:    explainf,
+	}
+}
+
+// rightScriptDownload downloads the RightScript at href and its attachments with rightscript.Client.Download and
+// returns the file the script was written to.
+func rightScriptDownload(href, downloadTo string, noAttachments bool, fileMode os.FileMode, canonical bool) string {
+	client, err := Config.Account.Client15()
+	if err != nil {
+		fatalError(exitConfig, "Could not find RightScript with href %s: %s", href, err.Error())
+	}
+	options := rightscript.DownloadOptions{NoAttachments: noAttachments, FileMode: fileMode, Canonical: canonical}
+	downloadTo, err = rightScriptClient(client).Download(href, downloadTo, options)
+	switch err.(type) {
+	case nil:
+	case *os.PathError, *os.LinkError:
+		fatalError(exitGeneral, "Could not create file: %s", err.Error())
+	default:
+		fatalError(ErrorExitCode(err), "%s", err.Error())
+	}
+	return downloadTo
+}
+
+// pushRightScript pushes script to the current account with rightscript.Client.Push.
+func pushRightScript(script *rightscript.RightScript, options rightscript.PushOptions) error {
+	client, err := Config.Account.Client15()
+	if err != nil {
+		return err
+	}
+	return rightScriptClient(client).Push(script, options)
+}
+
+// planRightScript describes what pushRightScript will do with rightscript.Client.Plan.
+func planRightScript(script *rightscript.RightScript, options rightscript.PushOptions) ([]string, error) {
+	client, err := Config.Account.Client15()
+	if err != nil {
+		return nil, err
+	}
+	return rightScriptClient(client).Plan(script, options)
+}
+
+// validateRightScript is rightscript.ValidateRightScript which prints the metadata it found with --debug.
+func validateRightScript(file string, ignoreMissingMetadata bool) (*rightscript.RightScript, error) {
+	script, err := rightscript.ValidateRightScript(file, ignoreMissingMetadata)
+	if *debug && script != nil {
+		pretty.Println(script.Metadata)
+	}
+	return script, err
+}
+
 type Iterable struct {
 	Links    []map[string]string `json:"links,omitempty"`
 	Name     string              `json:"name,omitempty"`
 	Revision int                 `json:"revision,omitempty"`
 }
 
-// RightScripts as saved in the YAML on disk come in two varieties:
-// Type == local. This means that the source code of the RightScript is managed
-//   locally on disk. Path will be populated with the location of the file
-// Type == remote. This means that the source code lives in RightScale and is
-//   merely linked here. A few different combinations are possible then:
-//   (Name, Revision) or (Href)
-const (
-	LocalRightScript int = iota
-	PublishedRightScript
-)
+// WriteUploadReport writes the results of a rightscript upload to a JSON manifest.
+func WriteUploadReport(file string, results []*rightscript.PushResult) error {
+	for _, result := range results {
+		if a := result.Attachments; a != nil {
+			sort.Strings(a.Uploaded)
+			sort.Strings(a.Deleted)
+			sort.Strings(a.Renamed)
+			sort.Strings(a.Unchanged)
+		}
+	}
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, append(data, '\n'), 0644)
+}
+
+// uploaded returns whether the result is for a RightScript which was created or updated.
+func uploaded(result *rightscript.PushResult) bool {
+	return result.Action == "created" || result.Action == "updated"
+}
+
+// RightScriptListItem holds the fields printed for each RightScript by rightscript list. They are also what an
+// --output-template can refer to.
+type RightScriptListItem struct {
+	Id       string `json:"id"`
+	Href     string `json:"href"`
+	Revision int    `json:"revision"`
+	Name     string `json:"name"`
+}
+
+// RightScriptSearch holds the client side predicates of rightscript search which are applied to the RightScripts
+// returned by the name filter.
+type RightScriptSearch struct {
+	DescriptionContains string // Case insensitive substring of the description
+	HeadOnly            bool   // Only the HEAD revision, not committed revisions
+	HasAttachments      bool   // Only RightScripts with at least one attachment, this needs a request per RightScript
+}
+
+// Match applies the predicates which only need the RightScript itself, HasAttachments is checked separately.
+func (search RightScriptSearch) Match(rs *cm15.RightScript) bool {
+	if search.HeadOnly && rs.Revision != 0 {
+		return false
+	}
+	if search.DescriptionContains != "" &&
+		!strings.Contains(strings.ToLower(rs.Description), strings.ToLower(search.DescriptionContains)) {
+		return false
+	}
+	return true
+}
 
-type RightScript struct {
-	Type      int // LocalRightScript or PublishedRightScript
-	Href      string
-	Path      string // Needed for local case
-	Name      string // Needed for remote case
-	Revision  int    // Needed for remote case
-	Publisher string // Needed for remote case
-	Metadata  RightScriptMetadata
+// Maximum number of close candidates offered when a RightScript name does not match exactly
+const maxCandidates = 10
+
+// RankCandidates picks the RightScripts whose names are close to name, either containing it or differing from it by a
+// few characters ignoring case, and orders them from the closest to the furthest.
+func RankCandidates(name string, items []RightScriptListItem) []RightScriptListItem {
+	lower := strings.ToLower(name)
+	maxDistance := len(lower) / 4
+	if maxDistance < 2 {
+		maxDistance = 2
+	}
+	candidates := candidatesByDistance{}
+	for _, item := range items {
+		itemName := strings.ToLower(item.Name)
+		distance := rightscript.EditDistance(lower, itemName)
+		if distance <= maxDistance || strings.Contains(itemName, lower) {
+			candidates = append(candidates, candidate{item, distance})
+		}
+	}
+	sort.Stable(candidates)
+	if len(candidates) > maxCandidates {
+		candidates = candidates[:maxCandidates]
+	}
+	ranked := make([]RightScriptListItem, len(candidates))
+	for i, c := range candidates {
+		ranked[i] = c.item
+	}
+	return ranked
+}
+
+type candidate struct {
+	item     RightScriptListItem
+	distance int
+}
+
+type candidatesByDistance []candidate
+
+func (c candidatesByDistance) Len() int      { return len(c) }
+func (c candidatesByDistance) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c candidatesByDistance) Less(i, j int) bool {
+	if c[i].distance != c[j].distance {
+		return c[i].distance < c[j].distance
+	}
+	return c[i].item.Name < c[j].item.Name
 }
 
-func rightScriptShow(href string) {
+// chooseRightScript is the fallback when name matches no RightScript exactly. The RightScripts with close names are
+// offered to choose from when stdin is a terminal, otherwise they are only printed along with notFound.
+func chooseRightScript(name string, revision int, notFound error) string {
 	client, err := Config.Account.Client15()
 	if err != nil {
-		fatalError("Could not find rightscript with href %s: %s", href, err.Error())
+		fatalError(exitConfig, "%s", notFound.Error())
+	}
+	locator := client.RightScriptLocator("/api/right_scripts")
+	var rightscripts []*cm15.RightScript
+	if filter, filterErr := rightscript.NameFilter(name); filterErr == nil {
+		rightscripts, err = locator.Index(rsapi.APIParams{"filter": []string{filter}})
+	}
+	if err == nil && len(rightscripts) == 0 {
+		// a mistyped name may not match the name filter at all and a name made up entirely of filter syntax cannot be
+		// filtered on, so compare it with every RightScript instead
+		rightscripts, err = locator.Index(rsapi.APIParams{})
+	}
+	if err != nil {
+		fatalError(ErrorExitCode(err), "%s", notFound.Error())
+	}
+	items := []RightScriptListItem{}
+	for _, rs := range rightscripts {
+		if rs.Revision == revision {
+			items = append(items, RightScriptListItem{Id: rs.Id, Href: rightscript.Link(rs.Links, "self"), Revision: rs.Revision, Name: rs.Name})
+		}
+	}
+	candidates := RankCandidates(name, items)
+	if len(candidates) == 0 {
+		fatalError(exitNotFound, "%s", notFound.Error())
 	}
 
-	attachmentsHref := fmt.Sprintf("%s/attachments", href)
-	rightscriptLocator := client.RightScriptLocator(href)
-	attachmentsLocator := client.RightScriptAttachmentLocator(attachmentsHref)
+	fmt.Fprintf(Stderr, "%s\nDid you mean one of these?\n", strings.TrimSpace(notFound.Error()))
+	for i, c := range candidates {
+		fmt.Fprintf(Stderr, "  %d) %s (%s)\n", i+1, c.Name, c.Href)
+	}
+	if !isInteractive() {
+		exit(exitNotFound)
+	}
+	fmt.Fprintf(Stderr, "Choose a RightScript [1-%d]: ", len(candidates))
+	var choice int
+	if _, err := fmt.Fscanln(os.Stdin, &choice); err != nil || choice < 1 || choice > len(candidates) {
+		fatalError(exitNotFound, "No RightScript chosen")
+	}
+	return candidates[choice-1].Href
+}
 
-	rightscript, err := rightscriptLocator.Show(rsapi.APIParams{"view": "inputs_2_0"})
+func rightScriptSearch(filter string, search RightScriptSearch, format string) {
+	client, err := Config.Account.Client15()
 	if err != nil {
-		fatalError("Could not find rightscript with href %s: %s", href, err.Error())
+		fatalError(exitConfig, "Could not search RightScripts: %s", err.Error())
 	}
-	attachments, err := attachmentsLocator.Index(rsapi.APIParams{})
-	if err != nil {
-		fatalError("Could not find attachments with href %s: %s", attachmentsHref, err.Error())
+
+	params := rsapi.APIParams{}
+	if filter != "" {
+		nameFilter, err := rightscript.NameFilter(filter)
+		if err != nil {
+			fatalError(exitGeneral, "Could not search RightScripts: %s", err.Error())
+		}
+		params["filter"] = []string{nameFilter}
 	}
-	source, err := getSource(rightscriptLocator)
+	rightscripts, err := client.RightScriptLocator("/api/right_scripts").Index(params)
 	if err != nil {
-		fatalError("Could get source for RightScript with href %s: %s", href, err.Error())
+		fatalError(ErrorExitCode(err), "Could not search RightScripts: %s", err.Error())
 	}
-	rev := "HEAD"
-	if rightscript.Revision != 0 {
-		rev = fmt.Sprintf("%d", rightscript.Revision)
-	}
-	fmt.Printf("Name: %s\n", rightscript.Name)
-	fmt.Printf("HREF: /api/right_scripts/%s\n", rightscript.Id)
-	fmt.Printf("Revision: %5s\n", rev)
-	fmt.Printf("Inputs:\n")
-	for _, input := range rightscript.Inputs {
-		i := jsonMapToInput(input)
-		fmt.Printf("  %s\n", i.Name)
-		fmt.Printf("    Category: %s\n", i.Category)
-		fmt.Printf("    Description: %s\n", i.Description)
-		fmt.Printf("    Input Type: %s\n", i.InputType.String())
-		fmt.Printf("    Required: %t\n", i.Required)
-		fmt.Printf("    Advanced: %t\n", i.Advanced)
-		if i.Default != nil {
-			fmt.Printf("    Default: %s\n", i.Default.String())
+
+	items := []RightScriptListItem{}
+	for _, rs := range rightscripts {
+		if !nameContains(rs.Name, filter) || !search.Match(rs) {
+			continue
 		}
-		if len(i.PossibleValues) > 0 {
-			vals := []string{}
-			for _, pv := range i.PossibleValues {
-				vals = append(vals, pv.String())
+		href := rightscript.Link(rs.Links, "self")
+		if search.HasAttachments {
+			attachments, err := client.RightScriptAttachmentLocator(href + "/attachments").Index(rsapi.APIParams{})
+			if err != nil {
+				fatalError(ErrorExitCode(err), "Could not find attachments for RightScript with href %s: %s", href, err.Error())
+			}
+			if len(attachments) == 0 {
+				continue
 			}
-			fmt.Printf("    Possible Values: %s\n", strings.Join(vals, ", "))
 		}
-
+		items = append(items, RightScriptListItem{Id: rs.Id, Href: href, Revision: rs.Revision, Name: rs.Name})
 	}
-	fmt.Printf("Attachments (id, md5, name):\n")
-	for _, a := range attachments {
-		fmt.Printf("  %s %s %s\n", a.Id, a.Digest, a.Filename)
-	}
-	fmt.Println("Body:")
-	fmt.Println(string(source))
+
+	printRightScriptItems(items, format, nil)
 }
 
-func rightScriptUpload(files []string, force bool, prefix string) {
-	// Pass 1, perform validations, gather up results
-	scripts := []*RightScript{}
-	files, err := walkPaths(files)
-	if err != nil {
-		fatalError("%s\n", err.Error())
-		os.Exit(1)
+// printRightScriptItems prints the RightScripts found by list or search in format, which is text, json, or csv.
+func printRightScriptItems(items []RightScriptListItem, format string, tmpl *template.Template) {
+	if err := PrintRightScriptItems(Stdout, items, format, tmpl); err != nil {
+		fatalError(exitGeneral, "%s", err.Error())
 	}
+}
 
-	for _, p := range files {
-		fmt.Printf("Uploading %s\n", p)
-		f, err := os.Open(p)
+// PrintRightScriptItems writes RightScripts in format: "json" for a JSON array, "csv" for CSV, or otherwise one line
+// per RightScript, see PrintRightScriptList.
+func PrintRightScriptItems(w io.Writer, items []RightScriptListItem, format string, tmpl *template.Template) error {
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(items, "", "  ")
 		if err != nil {
-			fatalError("Cannot open %s", p)
+			return err
 		}
-		defer f.Close()
-		script, err := validateRightScript(p, force)
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	case "csv":
+		return PrintRightScriptCSV(w, items)
+	default:
+		return PrintRightScriptList(w, items, tmpl)
+	}
+}
+
+func rightScriptList(filter string, tags []string, namesFrom, outputTemplate, format string) {
+	var tmpl *template.Template
+	if outputTemplate != "" {
+		if format != "text" {
+			fatalError(exitGeneral, "Cannot specify both --output-template and --format %s", format)
+		}
+		var err error
+		tmpl, err = template.New("output").Parse(outputTemplate)
 		if err != nil {
-			fatalError("%s: %s\n", p, err.Error())
+			fatalError(exitGeneral, "Invalid output template: %s", err.Error())
 		}
+	}
 
-		scripts = append(scripts, script)
+	client, err := Config.Account.Client15()
+	if err != nil {
+		fatalError(exitConfig, "Could not list RightScripts: %s", err.Error())
 	}
 
-	// Pass 2, upload
-	for _, script := range scripts {
-		err = script.Push(prefix)
+	params := rsapi.APIParams{}
+	if filter != "" {
+		nameFilter, err := rightscript.NameFilter(filter)
 		if err != nil {
-			fatalError("%s", err.Error())
+			fatalError(exitGeneral, "Could not list RightScripts: %s", err.Error())
 		}
+		params["filter"] = []string{nameFilter}
+	}
+	rightscripts, err := client.RightScriptLocator("/api/right_scripts").Index(params)
+	if err != nil {
+		fatalError(ErrorExitCode(err), "Could not list RightScripts: %s", err.Error())
 	}
+	tagged, err := rightScriptsTagged(client, tags)
+	if err != nil {
+		fatalError(ErrorExitCode(err), "Could not list RightScripts tagged %s: %s", strings.Join(tags, ", "), err.Error())
+	}
+	named := rightScriptsNamed(namesFrom)
+
+	items := []RightScriptListItem{}
+	for _, rs := range rightscripts {
+		href := rightscript.Link(rs.Links, "self")
+		// Recheck the name here, the name filter only has the longest part of filter without filter syntax
+		if !nameContains(rs.Name, filter) || (tagged != nil && !tagged[href]) || (named != nil && !named[href]) {
+			continue
+		}
+		items = append(items, RightScriptListItem{
+			Id:       rs.Id,
+			Href:     href,
+			Revision: rs.Revision,
+			Name:     rs.Name,
+		})
+	}
+	printRightScriptItems(items, format, tmpl)
 }
 
-// This can be improved to look for bash'isms for older style scripts, powershellisms, etc.
-func guessExtension(source string) string {
-	if matches := shebang.FindStringSubmatch(source); len(matches) > 0 {
-		if strings.Contains(matches[0], "ruby") {
-			return ".rb"
-		} else if strings.Contains(matches[0], "perl") {
-			return ".pl"
-		} else if strings.Contains(matches[0], "sh") { // bash + sh
-			return ".sh"
+// PrintRightScriptList writes one line per RightScript, either as HREF, revision, and name or by executing tmpl for
+// each item when it is not nil.
+func PrintRightScriptList(w io.Writer, items []RightScriptListItem, tmpl *template.Template) error {
+	for _, item := range items {
+		if tmpl != nil {
+			if err := tmpl.Execute(w, item); err != nil {
+				return err
+			}
+			fmt.Fprintln(w)
+			continue
 		}
+		rev := "HEAD"
+		if item.Revision != 0 {
+			rev = fmt.Sprintf("%d", item.Revision)
+		}
+		fmt.Fprintf(w, "%s %5s %s\n", item.Href, rev, item.Name)
 	}
+	return nil
+}
 
-	return ""
+// PrintRightScriptCSV writes the RightScripts as CSV with a header row. The revision of HEAD is 0.
+func PrintRightScriptCSV(w io.Writer, items []RightScriptListItem) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"id", "href", "revision", "name"}); err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err := writer.Write([]string{item.Id, item.Href, strconv.Itoa(item.Revision), item.Name}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
 }
 
-func rightScriptDownload(href, downloadTo string) string {
+func rightScriptShow(href string, showTags, showLinks bool) {
 	client, err := Config.Account.Client15()
 	if err != nil {
-		fatalError("Could not find RightScript with href %s: %s", href, err.Error())
+		fatalError(exitConfig, "Could not find rightscript with href %s: %s", href, err.Error())
 	}
 
 	attachmentsHref := fmt.Sprintf("%s/attachments", href)
 	rightscriptLocator := client.RightScriptLocator(href)
 	attachmentsLocator := client.RightScriptAttachmentLocator(attachmentsHref)
 
-	rightscript, err := rightscriptLocator.Show(rsapi.APIParams{"view": "inputs_2_0"})
+	rs, err := rightscriptLocator.Show(rsapi.APIParams{"view": "inputs_2_0"})
 	if err != nil {
-		fatalError("Could not find RightScript with href %s: %s", href, err.Error())
+		fatalError(ErrorExitCode(err), "Could not find rightscript with href %s: %s", href, err.Error())
 	}
-	source, err := getSource(rightscriptLocator)
-	if err != nil {
-		fatalError("Could get source for RightScript with href %s: %s", href, err.Error())
-	}
-	sourceMetadata, err := ParseRightScriptMetadata(bytes.NewReader(source))
-	if err != nil {
-		fmt.Printf("WARNING: Metadata in %s is malformed: %s\n", rightscript.Name, err.Error())
-	}
-
 	attachments, err := attachmentsLocator.Index(rsapi.APIParams{})
 	if err != nil {
-		fatalError("Could get attachments for RightScript from href %s: %s", attachmentsHref, err.Error())
+		fatalError(ErrorExitCode(err), "Could not find attachments with href %s: %s", attachmentsHref, err.Error())
 	}
-
-	guessedExtension := guessExtension(string(source))
-
-	if downloadTo == "" {
-		downloadTo = cleanFileName(rightscript.Name) + guessedExtension
-	} else if isDirectory(downloadTo) {
-		downloadTo = filepath.Join(downloadTo, cleanFileName(rightscript.Name)+guessedExtension)
+	source, err := rightScriptClient(client).Source(rightscriptLocator)
+	if err != nil {
+		fatalError(ErrorExitCode(err), "Could get source for RightScript with href %s: %s", href, err.Error())
 	}
-	fmt.Printf("Downloading '%s' to '%s'\n", rightscript.Name, downloadTo)
-
-	for i, attachment := range attachments {
-		// API attachments are always just plain names without path information.
-		// SourceMetadata attachment names may have path components describing where
-		// to put the file on disk, thus are truthier, so we merge those in.
-		if sourceMetadata != nil {
-			for _, aSrc := range sourceMetadata.Attachments {
-				if path.Base(attachment.Filename) == path.Base(aSrc) {
-					attachments[i].Filename = aSrc
-				}
-			}
+	rev := "HEAD"
+	if rs.Revision != 0 {
+		rev = fmt.Sprintf("%d", rs.Revision)
+	}
+	fmt.Fprintf(Stdout, "Name: %s\n", rs.Name)
+	fmt.Fprintf(Stdout, "HREF: /api/right_scripts/%s\n", rs.Id)
+	fmt.Fprintf(Stdout, "Revision: %5s\n", rev)
+	fmt.Fprintf(Stdout, "Source MD5: %s\n", rightscript.SourceDigest(source))
+	fmt.Fprintf(Stdout, "Inputs:\n")
+	for _, input := range rs.Inputs {
+		i := rightscript.JsonMapToInput(input)
+		fmt.Fprintf(Stdout, "  %s\n", i.Name)
+		fmt.Fprintf(Stdout, "    Category: %s\n", i.Category)
+		fmt.Fprintf(Stdout, "    Description: %s\n", i.Description)
+		fmt.Fprintf(Stdout, "    Input Type: %s\n", i.InputType.String())
+		fmt.Fprintf(Stdout, "    Required: %t\n", i.Required)
+		fmt.Fprintf(Stdout, "    Advanced: %t\n", i.Advanced)
+		if i.Default != nil {
+			fmt.Fprintf(Stdout, "    Default: %s\n", i.Default.String())
+			fmt.Fprintf(Stdout, "    Default Source: %s\n", i.Default.Source())
 		}
-	}
-	downloadItems := []*downloadItem{}
-	pathPrepend := filepath.Join(filepath.Dir(downloadTo), "attachments") + string(os.PathSeparator)
-	for _, attachment := range attachments {
-		var downloadLocations []string
-
-		if filepath.IsAbs(attachment.Filename) {
-			downloadLocations = []string{attachment.Filename}
-		} else {
-			// We have a primary filename and a backup filename to try in case there's
-			// a conflict. Conflicts will arise when you have multiple RightScripts
-			// attached to the same ServerTemplate with the same attachment name, i.e.
-			// something generic like 'config.xml'
-			downloadLocations = []string{
-				filepath.Join(pathPrepend, attachment.Filename),
-				filepath.Join(pathPrepend, cleanFileName(rightscript.Name), attachment.Filename),
+		if len(i.PossibleValues) > 0 {
+			vals := []string{}
+			for _, pv := range i.PossibleValues {
+				vals = append(vals, pv.String())
 			}
+			fmt.Fprintf(Stdout, "    Possible Values: %s\n", strings.Join(vals, ", "))
 		}
 
-		downloadUrl, err := url.Parse(attachment.DownloadUrl)
+	}
+	fmt.Fprintf(Stdout, "Attachments (id, md5, size, name):\n")
+	var totalSize int64
+	for _, a := range attachments {
+		size := attachmentSize(a)
+		totalSize += size
+		fmt.Fprintf(Stdout, "  %s %s %10d %s\n", a.Id, a.Digest, size, a.Filename)
+	}
+	fmt.Fprintf(Stdout, "Total attachment size: %d bytes in %d attachments\n", totalSize, len(attachments))
+	if showTags {
+		tags, err := rightScriptClient(client).Tags(href)
 		if err != nil {
-			fatalError("Could not parse URL of attachment: %s", err.Error())
+			fatalError(ErrorExitCode(err), "Could not get tags for RightScript with href %s: %s", href, err.Error())
 		}
-		downloadItem := downloadItem{
-			url:       *downloadUrl,
-			locations: downloadLocations,
-			md5:       attachment.Digest,
+		fmt.Fprintf(Stdout, "Tags:\n")
+		for _, t := range tags {
+			fmt.Fprintf(Stdout, "  %s\n", t)
 		}
-		downloadItems = append(downloadItems, &downloadItem)
 	}
-	if len(downloadItems) == 0 {
-		fmt.Println("No attachments to download")
-	} else {
-		fmt.Printf("Download %d attachments:\n", len(downloadItems))
-		err = downloadManager(downloadItems)
-		if err != nil {
-			fatalError("Failed to download all attachments: %s", err.Error())
+	if showLinks {
+		fmt.Fprintf(Stdout, "Links:\n")
+		if err := PrintLinks(Stdout, rs.Links); err != nil {
+			fatalError(exitGeneral, "Could not print links: %s", err.Error())
 		}
-		for _, d := range downloadItems {
-			for i, attachment := range attachments {
-				if filepath.Base(attachment.Filename) == filepath.Base(d.downloadedTo) {
-					attachments[i].Filename = strings.Replace(d.downloadedTo, pathPrepend, ``, 1)
-				}
-			}
+	}
+	fmt.Fprintln(Stdout, "Body:")
+	fmt.Fprintln(Stdout, string(source))
+}
+
+// PrintLinks writes the rel and HREF of each link of a resource on its own line in the order the API returned them.
+func PrintLinks(w io.Writer, links []map[string]string) error {
+	for _, link := range links {
+		if _, err := fmt.Fprintf(w, "  %s: %s\n", link["rel"], link["href"]); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	inputs := InputMap{}
-	for _, input := range rightscript.Inputs {
-		inputs = append(inputs, jsonMapToInput(input))
+// attachmentSize returns the size of an attachment in bytes. Older attachments may not report a size, so fall back
+// to asking the download URL for just the first byte, which presigned download URLs allow unlike HEAD requests, and
+// taking the size from the Content-Range of the response. The size is 0 when it cannot be told.
+func attachmentSize(a *cm15.RightScriptAttachment) int64 {
+	if a.Size > 0 || a.DownloadUrl == "" {
+		return int64(a.Size)
 	}
-	attachmentNames := make([]string, len(attachments))
-	for i, a := range attachments {
-		attachmentNames[i] = a.Filename
+	req, err := http.NewRequest("GET", a.DownloadUrl, nil)
+	if err != nil {
+		return 0
+	}
+	req.Header.Set("Range", "bytes=0-0")
+	resp, err := http.DefaultClient.Do(req.WithContext(RequestContext))
+	if err != nil {
+		return 0
 	}
-	apiMetadata := RightScriptMetadata{
-		Name:        rightscript.Name,
-		Description: removeCarriageReturns(rightscript.Description),
-		Packages:    rightscript.Packages,
-		Inputs:      inputs,
-		Attachments: attachmentNames,
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if size, ok := ContentRangeSize(resp.Header.Get("Content-Range")); ok {
+			return size
+		}
+	case http.StatusOK:
+		// the whole attachment is coming back, which is not read just for its size
+		if resp.ContentLength >= 0 {
+			return resp.ContentLength
+		}
 	}
+	return 0
+}
 
-	// Re-running it through scaffoldBuffer has the benefit of cleaning up any errors in how
-	// the inputs are described. Also any attachments added or removed manually will be
-	// handled in that the builtin metadata will reflect whats on disk
-	scaffoldedSourceBytes, err := scaffoldBuffer(source, apiMetadata, "", false)
-	if err == nil {
-		if bytes.Compare(scaffoldedSourceBytes, source) != 0 {
-			fmt.Println("Automatically inserted RightScript metadata.")
-		}
-		err = ioutil.WriteFile(downloadTo, scaffoldedSourceBytes, 0755)
-	} else {
-		fmt.Printf("Downloaded script as is. An error occurred generating metadata to insert into the RightScript: %s", err.Error())
-		err = ioutil.WriteFile(downloadTo, source, 0755)
+// ContentRangeSize returns the complete length from the Content-Range header of a partial response, e.g. 1234 for
+// "bytes 0-0/1234". ok is false when the length is missing or unknown.
+func ContentRangeSize(contentRange string) (size int64, ok bool) {
+	slash := strings.LastIndex(contentRange, "/")
+	if !strings.HasPrefix(contentRange, "bytes ") || slash < 0 {
+		return 0, false
 	}
-	if err != nil {
-		fatalError("Could not create file: %s", err.Error())
+	size, err := strconv.ParseInt(contentRange[slash+1:], 10, 64)
+	if err != nil || size < 0 {
+		return 0, false
 	}
+	return size, true
+}
 
-	return downloadTo
+// AttachmentListItem holds the fields printed for each attachment by rightscript attachment list.
+type AttachmentListItem struct {
+	Id     string `json:"id"`
+	Digest string `json:"digest"`
+	Name   string `json:"name"`
 }
 
-// Convert a JSON response to InputMetadata struct
-func jsonMapToInput(input map[string]interface{}) InputMetadata {
-	var defaultValue *InputValue
-	if rawValue, ok := input["default_value"].(string); ok {
-		defaultValue, _ = parseInputValue(rawValue)
+// attachmentListItems returns the attachments of the RightScript at href with the fields which are printed for them.
+func attachmentListItems(href string) []AttachmentListItem {
+	client, err := Config.Account.Client15()
+	if err != nil {
+		fatalError(exitConfig, "Could not find attachments for RightScript with href %s: %s", href, err.Error())
 	}
 
-	possibleValues := []*InputValue{}
-	if rawPossibleValues, ok := input["possible_values"].([]interface{}); ok {
-		for _, rawValue := range rawPossibleValues {
-			possibleValue, _ := parseInputValue(rawValue.(string))
-			possibleValues = append(possibleValues, possibleValue)
-		}
+	attachmentsHref := fmt.Sprintf("%s/attachments", href)
+	attachments, err := client.RightScriptAttachmentLocator(attachmentsHref).Index(rsapi.APIParams{})
+	if err != nil {
+		fatalError(ErrorExitCode(err), "Could not find attachments with href %s: %s", attachmentsHref, err.Error())
 	}
 
-	inputType, _ := parseInputType(input["kind"].(string))
-
-	categoryName, _ := input["category_name"].(string) // May be null
-	description, _ := input["description"].(string)    // May be null
+	items := make([]AttachmentListItem, len(attachments))
+	for i, a := range attachments {
+		items[i] = AttachmentListItem{Id: a.Id, Digest: a.Digest, Name: a.Filename}
+	}
+	return items
+}
 
-	return InputMetadata{
-		Name:           input["name"].(string),
-		Category:       categoryName,
-		Description:    description,
-		InputType:      inputType,
-		Required:       input["required"].(bool),
-		Advanced:       input["advanced"].(bool),
-		Default:        defaultValue,
-		PossibleValues: possibleValues,
+func rightScriptAttachmentList(href, format string) {
+	items := attachmentListItems(href)
+	if err := PrintAttachmentList(Stdout, items, format); err != nil {
+		fatalError(exitGeneral, "Could not print attachments: %s", err.Error())
 	}
 }
 
-func rightScriptScaffold(files []string, backup bool, force bool) {
-	files, err := walkPaths(files)
+// rightScriptAttachmentAdd uploads file as an attachment of the RightScript at href without touching its source. An
+// existing attachment with the same name is replaced unless it already has the same content.
+func rightScriptAttachmentAdd(href, file, name string) {
+	client, err := Config.Account.Client15()
 	if err != nil {
-		fatalError("%s\n", err.Error())
+		fatalError(exitConfig, "Could not add attachment to RightScript with href %s: %s", href, err.Error())
+	}
+	if name == "" {
+		name = filepath.Base(file)
+	}
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		fatalError(exitGeneral, "Could not read attachment %s: %s", file, err.Error())
+	}
+	md5, err := rightscript.Md5sum(bytes.NewReader(content))
+	if err != nil {
+		fatalError(exitGeneral, "Could not read attachment %s: %s", file, err.Error())
 	}
 
-	for _, file := range files {
-		err = ScaffoldRightScript(file, backup, os.Stdout, force)
-		if err != nil {
-			fatalError("%s\n", err.Error())
+	attachmentsHref := fmt.Sprintf("%s/attachments", href)
+	attachmentsLocator := client.RightScriptAttachmentLocator(attachmentsHref)
+	attachments, err := attachmentsLocator.Index(rsapi.APIParams{})
+	if err != nil {
+		fatalError(ErrorExitCode(err), "Could not find attachments with href %s: %s", attachmentsHref, err.Error())
+	}
+	for _, a := range attachments {
+		if a.Filename != name {
+			continue
 		}
+		if a.Digest == md5 {
+			fmt.Fprintf(Stdout, "Attachment '%s' already uploaded with md5 %s\n", name, md5)
+			return
+		}
+		loc := a.Locator(client)
+		fmt.Fprintf(Stdout, "Deleting attachment '%s' with HREF '%s'\n", a.Filename, loc.Href)
+		if err := loc.Destroy(); err != nil {
+			fatalError(ErrorExitCode(err), "Could not delete attachment '%s': %s", a.Filename, err.Error())
+		}
+	}
+
+	fmt.Fprintf(Stdout, "Uploading attachment '%s' with md5 %s\n", name, md5)
+	upload := rsapi.FileUpload{Name: "right_script_attachment[content]", Reader: bytes.NewReader(content), Filename: name}
+	attachmentHref, err := rightScriptClient(client).UploadAttachment(attachmentsLocator, &upload, name)
+	if err != nil {
+		fatalError(ErrorExitCode(err), "Could not upload attachment '%s': %s", name, err.Error())
+	}
+	if attachmentHref != "" {
+		fmt.Fprintf(Stdout, "Attachment '%s' created with HREF %s\n", name, attachmentHref)
 	}
 }
 
-func rightScriptValidate(files []string) {
+// rightScriptAttachmentRemove deletes the attachment called name from the RightScript at href without touching its
+// source.
+func rightScriptAttachmentRemove(href, name string) {
+	client, err := Config.Account.Client15()
+	if err != nil {
+		fatalError(exitConfig, "Could not remove attachment from RightScript with href %s: %s", href, err.Error())
+	}
 
-	err_encountered := false
-	for _, file := range files {
-		_, err := validateRightScript(file, true)
+	attachmentsHref := fmt.Sprintf("%s/attachments", href)
+	attachments, err := client.RightScriptAttachmentLocator(attachmentsHref).Index(rsapi.APIParams{})
+	if err != nil {
+		fatalError(ErrorExitCode(err), "Could not find attachments with href %s: %s", attachmentsHref, err.Error())
+	}
+	for _, a := range attachments {
+		if a.Filename != name {
+			continue
+		}
+		loc := a.Locator(client)
+		fmt.Fprintf(Stdout, "Deleting attachment '%s' with HREF '%s'\n", a.Filename, loc.Href)
+		if err := loc.Destroy(); err != nil {
+			fatalError(ErrorExitCode(err), "Could not delete attachment '%s': %s", a.Filename, err.Error())
+		}
+		return
+	}
+	fatalError(exitNotFound, "Could not find attachment '%s' on RightScript with href %s", name, href)
+}
+
+// PrintAttachmentList writes the attachments either one per line as ID, md5 digest, and name or, when format is
+// "json", as a JSON array.
+func PrintAttachmentList(w io.Writer, items []AttachmentListItem, format string) error {
+	if format == "json" {
+		data, err := json.MarshalIndent(items, "", "  ")
 		if err != nil {
-			err_encountered = true
-			fmt.Fprintf(os.Stderr, "%s: %s\n", file, err.Error())
-		} else {
-			fmt.Printf("%s: Valid metadata\n", file)
+			return err
 		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
 	}
-	if err_encountered {
-		os.Exit(1)
+	for _, item := range items {
+		fmt.Fprintf(w, "%s %s %s\n", item.Id, item.Digest, item.Name)
 	}
+	return nil
 }
 
-// Crappy workaround. RSC doesn't return the body of the http request which contains
-// the script source, so do the same lower level calls it does to get it.
-func getSource(loc *cm15.RightScriptLocator) (respBody []byte, err error) {
-	var params rsapi.APIParams
-	var p rsapi.APIParams
-	APIVersion := "1.5"
+// rightScriptShowInputsSchema prints a JSON Schema describing the inputs of the RightScript at href.
+func rightScriptShowInputsSchema(href string) {
 	client, err := Config.Account.Client15()
 	if err != nil {
-		return nil, err
+		fatalError(exitConfig, "Could not find rightscript with href %s: %s", href, err.Error())
 	}
 
-	uri, err := loc.ActionPath("RightScript", "show_source")
+	rs, err := client.RightScriptLocator(href).Show(rsapi.APIParams{"view": "inputs_2_0"})
 	if err != nil {
-		return respBody, err
+		fatalError(ErrorExitCode(err), "Could not find rightscript with href %s: %s", href, err.Error())
 	}
-	req, err := client.BuildHTTPRequest(uri.HTTPMethod, uri.Path, APIVersion, params, p)
-	if err != nil {
-		return respBody, err
+	inputs := rightscript.InputMap{}
+	for _, input := range rs.Inputs {
+		inputs = append(inputs, rightscript.JsonMapToInput(input))
 	}
-	resp, err := client.PerformRequest(req)
+	data, err := json.MarshalIndent(inputs.JSONSchema(rs.Name, rightscript.RemoveCarriageReturns(rs.Description)), "", "  ")
 	if err != nil {
-		return respBody, err
+		fatalError(exitGeneral, "Could not print JSON Schema: %s", err.Error())
 	}
-	defer resp.Body.Close()
-	respBody, _ = ioutil.ReadAll(resp.Body)
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return respBody, fmt.Errorf("invalid response %s: %s", resp.Status, string(respBody))
-	}
-	return respBody, nil
-}
-
-// Crappy workaround 2. the RightScriptAttachmentLocator.Create call doesn't work
-// because RSCs countless concrete types screw things up. The RSC create call calls BuildHttpRequest
-// with the type passed in, which is serializes to JSON. Under different code paths
-// (such as here or the command line) it passes in rsapi.APIParams instead of a fixed type of
-// cm15.RightScriptAttachmentParams. BuildHTTPRequest has code to iterate over APIParams and
-// turn it into a a multipart mime doc if it sees a FileUpload type. But it doesn't have
-// code knowing about every concrete type to handle that.
-func uploadAttachment(loc *cm15.RightScriptAttachmentLocator,
-	file *rsapi.FileUpload, name string) error {
-	var params rsapi.APIParams
-	var p rsapi.APIParams
-	APIVersion := "1.5"
+	fmt.Fprintf(Stdout, "%s\n", data)
+}
+
+// rightScriptShowDigests prints only the md5 digests of the attachments of the RightScript at href for comparing with
+// the output of md5sum.
+func rightScriptShowDigests(href string, withNames bool) {
+	items := attachmentListItems(href)
+	if err := PrintAttachmentDigests(Stdout, items, withNames); err != nil {
+		fatalError(exitGeneral, "Could not print attachments: %s", err.Error())
+	}
+}
+
+// rightScriptShowCompare prints whether the local script in file matches the RightScript at href, comparing the source
+// which would be uploaded with options and the names and md5 digests of the attachments, and exits with exitDiffers if
+// it does not.
+func rightScriptShowCompare(href, file string, options rightscript.PushOptions) {
 	client, err := Config.Account.Client15()
 	if err != nil {
-		return err
+		fatalError(exitConfig, "Could not find rightscript with href %s: %s", href, err.Error())
 	}
 
-	p_inner := rsapi.APIParams{
-		"content":  file,
-		"filename": name,
-	}
-	p = rsapi.APIParams{
-		"right_script_attachment": p_inner,
+	script, err := validateRightScript(file, true)
+	if err != nil {
+		fatalError(exitValidation, "%s: %s", file, err.Error())
 	}
-	uri, err := loc.ActionPath("RightScriptAttachment", "create")
+	defer script.RemoveFetchedAttachments()
+	localSource, _, err := script.UploadSource(options)
 	if err != nil {
-		return err
+		fatalError(exitGeneral, "%s: %s", file, err.Error())
 	}
-	req, err := client.BuildHTTPRequest(uri.HTTPMethod, uri.Path, APIVersion, params, p)
+	localDigests := make(map[string]string, len(script.Metadata.Attachments))
+	for _, name := range script.Metadata.Attachments {
+		digest, err := rightScriptClient(client).AttachmentDigest(script, name)
+		if err != nil {
+			fatalError(exitGeneral, "%s: %s", file, err.Error())
+		}
+		localDigests[path.Base(name)] = digest
+	}
+
+	rightscriptLocator := client.RightScriptLocator(href)
+	remoteSource, err := rightScriptClient(client).Source(rightscriptLocator)
 	if err != nil {
-		return err
+		fatalError(ErrorExitCode(err), "Could not get source for RightScript with href %s: %s", href, err.Error())
 	}
-	resp, err := client.PerformRequest(req)
+	attachmentsHref := fmt.Sprintf("%s/attachments", href)
+	attachments, err := client.RightScriptAttachmentLocator(attachmentsHref).Index(rsapi.APIParams{})
 	if err != nil {
-		return err
+		fatalError(ErrorExitCode(err), "Could not find attachments with href %s: %s", attachmentsHref, err.Error())
 	}
-	defer resp.Body.Close()
-	respBody, _ := ioutil.ReadAll(resp.Body)
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return fmt.Errorf("invalid response %s: %s", resp.Status, string(respBody))
+	remoteDigests := make(map[string]string, len(attachments))
+	for _, a := range attachments {
+		remoteDigests[a.Filename] = a.Digest
+	}
+
+	differences := SyncDifferences(localSource, remoteSource, localDigests, remoteDigests)
+	if len(differences) == 0 {
+		fmt.Fprintln(Stdout, "IN SYNC")
+		return
+	}
+	fmt.Fprintf(Stdout, "DIFFERS (%s)\n", strings.Join(differences, ", "))
+	// the deferred removal does not run on exit
+	script.RemoveFetchedAttachments()
+	exit(exitDiffers)
+}
+
+// SyncDifferences returns what differs between a local script and a RightScript: "source" unless the sources are
+// identical and "attachments" unless both have attachments with the same names and md5 digests.
+func SyncDifferences(localSource, remoteSource []byte, localDigests, remoteDigests map[string]string) []string {
+	differences := []string{}
+	if !bytes.Equal(localSource, remoteSource) {
+		differences = append(differences, "source")
+	}
+	attachmentsDiffer := len(localDigests) != len(remoteDigests)
+	for name, digest := range localDigests {
+		if remoteDigest, ok := remoteDigests[name]; !ok || remoteDigest != digest {
+			attachmentsDiffer = true
+		}
+	}
+	if attachmentsDiffer {
+		differences = append(differences, "attachments")
+	}
+	return differences
+}
+
+// PrintAttachmentDigests writes the md5 digest of each attachment on its own line sorted by name. With names each
+// line is the digest and name separated by two spaces just like md5sum prints them.
+func PrintAttachmentDigests(w io.Writer, items []AttachmentListItem, withNames bool) error {
+	sorted := make([]AttachmentListItem, len(items))
+	copy(sorted, items)
+	sort.Sort(attachmentListByName(sorted))
+	for _, item := range sorted {
+		var err error
+		if withNames {
+			_, err = fmt.Fprintf(w, "%s  %s\n", item.Digest, item.Name)
+		} else {
+			_, err = fmt.Fprintf(w, "%s\n", item.Digest)
+		}
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func rightScriptIdByName(name string) (string, error) {
+type attachmentListByName []AttachmentListItem
+
+func (items attachmentListByName) Len() int           { return len(items) }
+func (items attachmentListByName) Less(i, j int) bool { return items[i].Name < items[j].Name }
+func (items attachmentListByName) Swap(i, j int)      { items[i], items[j] = items[j], items[i] }
+
+// rightScriptClone creates a brand new RightScript named newName with the source and attachments of the RightScript
+// at href. The attachments are downloaded and uploaded again so the clone does not share anything with the original.
+func rightScriptClone(href, newName string) {
 	client, err := Config.Account.Client15()
 	if err != nil {
-		return "", err
+		fatalError(exitConfig, "Could not find RightScript with href %s: %s", href, err.Error())
 	}
 
-	createLocator := client.RightScriptLocator("/api/right_scripts")
-	apiParams := rsapi.APIParams{"filter": []string{"name==" + name}}
-	rightscripts, err := createLocator.Index(apiParams)
+	rightscriptLocator := client.RightScriptLocator(href)
+	rs, err := rightscriptLocator.Show(rsapi.APIParams{})
 	if err != nil {
-		return "", err
+		fatalError(ErrorExitCode(err), "Could not find RightScript with href %s: %s", href, err.Error())
 	}
-	foundId := ""
-	for _, rs := range rightscripts {
-		// Recheck the name here, filter does a partial match and we need an exact one
-		if rs.Name == name && rs.Revision == 0 {
-			if foundId != "" {
-				return "", fmt.Errorf("Error, matched multiple RightScripts with the same name, please delete one: %s %s", rs.Id, foundId)
-			} else {
-				foundId = rs.Id
-			}
+	source, err := rightScriptClient(client).Source(rightscriptLocator)
+	if err != nil {
+		fatalError(ErrorExitCode(err), "Could not get source for RightScript with href %s: %s", href, err.Error())
+	}
+	attachments, err := client.RightScriptAttachmentLocator(href + "/attachments").Index(rsapi.APIParams{})
+	if err != nil {
+		fatalError(ErrorExitCode(err), "Could not get attachments for RightScript with href %s: %s", href, err.Error())
+	}
+
+	foundId, err := rightScriptClient(client).IdByName(newName)
+	if err != nil {
+		fatalError(ErrorExitCode(err), "%s", err.Error())
+	}
+	if foundId != "" {
+		fatalError(exitGeneral, "A RightScript named '%s' already exists with HREF /api/right_scripts/%s", newName, foundId)
+	}
+
+	// Keep the name in the embedded metadata in sync with the new name
+	sourceMetadata, err := rightscript.ParseRightScriptMetadata(bytes.NewReader(source))
+	if err == nil && sourceMetadata != nil {
+		sourceMetadata.Name = newName
+		if renamedSource, err := rightscript.ScaffoldBuffer(source, *sourceMetadata, "", "", false); err == nil {
+			source = renamedSource
 		}
 	}
-	return foundId, nil
-}
 
-func (r *RightScript) Push(prefix string) error {
-	if r.Type == PublishedRightScript {
-		return r.PushRemote()
-	} else {
-		return r.PushLocal(prefix)
+	fmt.Fprintf(Stdout, "Cloning '%s' to a new RightScript named '%s'\n", rs.Name, newName)
+	cloneLocator, err := client.RightScriptLocator("/api/right_scripts").Create(&cm15.RightScriptParam2{
+		Name:        newName,
+		Description: rs.Description,
+		Packages:    rs.Packages,
+		Source:      string(source),
+	})
+	if err != nil {
+		fatalError(ErrorExitCode(err), "Could not create RightScript '%s': %s", newName, err.Error())
+	}
+	fmt.Fprintf(Stdout, "  RightScript created with HREF %s\n", cloneLocator.Href)
+
+	cloneAttachmentsLocator := client.RightScriptAttachmentLocator(string(cloneLocator.Href) + "/attachments")
+	deleteClone := func() error {
+		fmt.Fprintf(Stdout, "  Deleting RightScript with HREF %s since its attachments could not be copied\n", cloneLocator.Href)
+		return cloneLocator.Destroy()
+	}
+	if err := CloneAttachments(attachments, apiAttachmentSteps(client, cloneAttachmentsLocator), deleteClone); err != nil {
+		fatalError(ErrorExitCode(err), "Could not clone RightScript with href %s: %s", href, err.Error())
 	}
 }
 
-func (r *RightScript) PushRemote() error {
+// rightScriptMove merges the lineage of the RightScript at sourceHref into the one at targetHref by updating the HEAD
+// of the target with the source, description, packages, and attachments of the source. The name of the target is
+// kept. With deleteSource the source RightScript is deleted afterwards. Unless force is set the changes are printed
+// and have to be confirmed first.
+func rightScriptMove(sourceHref, targetHref string, deleteSource, force bool) {
+	if sourceHref == targetHref {
+		fatalError(exitGeneral, "Cannot move RightScript with href %s onto itself", sourceHref)
+	}
 	client, err := Config.Account.Client15()
 	if err != nil {
-		return err
-	}
-	// Algorithm:
-	//   1. Find the RightScript in publications first. Get the name/description/publisher
-	//   2. If we don't find it, throw an error
-	//   3. Get the imported RightScript. If it doesn't exist, import it then get the href
-	//   4. Insert HREF into r struct for later use.
-	matchers := map[string]string{}
-	if r.Publisher != "" {
-		matchers[`Publisher`] = r.Publisher
+		fatalError(exitConfig, "Could not find RightScript with href %s: %s", sourceHref, err.Error())
 	}
 
-	pub, err := findPublication("RightScript", r.Name, r.Revision, matchers)
+	sourceLocator := client.RightScriptLocator(sourceHref)
+	sourceScript, err := sourceLocator.Show(rsapi.APIParams{})
 	if err != nil {
-		return err
+		fatalError(ErrorExitCode(err), "Could not find RightScript with href %s: %s", sourceHref, err.Error())
 	}
-	if pub == nil {
-		return fmt.Errorf("Could not find a publication in the MultiCloud Marketplace for RightScript '%s' Revision %d Publisher '%s'", r.Name, r.Revision, r.Publisher)
+	targetLocator := client.RightScriptLocator(targetHref)
+	targetScript, err := targetLocator.Show(rsapi.APIParams{})
+	if err != nil {
+		fatalError(ErrorExitCode(err), "Could not find RightScript with href %s: %s", targetHref, err.Error())
 	}
-
-	rsLocator := client.RightScriptLocator("/api/right_scripts")
-	filters := []string{
-		"name==" + r.Name,
+	if targetScript.Revision != 0 {
+		fatalError(exitGeneral, "RightScript '%s' with href %s is a committed revision, move onto its HEAD revision instead", targetScript.Name, targetHref)
 	}
-
-	rsUnfiltered, err := rsLocator.Index(rsapi.APIParams{"filter": filters})
+	source, err := rightScriptClient(client).Source(sourceLocator)
 	if err != nil {
-		return err
+		fatalError(ErrorExitCode(err), "Could not get source for RightScript with href %s: %s", sourceHref, err.Error())
+	}
+	sourceAttachments, err := client.RightScriptAttachmentLocator(sourceHref + "/attachments").Index(rsapi.APIParams{})
+	if err != nil {
+		fatalError(ErrorExitCode(err), "Could not get attachments for RightScript with href %s: %s", sourceHref, err.Error())
 	}
-	for _, rs := range rsUnfiltered {
-		// Recheck the name here, filter does a partial match and we need an exact one.
-		// Matching the descriptions helps to disambiguate if we have multiple publications
-		// with that same name/revision pair.
-		if rs.Name == r.Name && rs.Revision == r.Revision && rs.Description == pub.Description {
-			r.Href = getLink(rs.Links, "self")
+	targetAttachmentsLocator := client.RightScriptAttachmentLocator(targetHref + "/attachments")
+	targetAttachments, err := targetAttachmentsLocator.Index(rsapi.APIParams{})
+	if err != nil {
+		fatalError(ErrorExitCode(err), "Could not get attachments for RightScript with href %s: %s", targetHref, err.Error())
+	}
+
+	// Keep the name in the embedded metadata in sync with the name of the target
+	sourceMetadata, err := rightscript.ParseRightScriptMetadata(bytes.NewReader(source))
+	if err == nil && sourceMetadata != nil {
+		sourceMetadata.Name = targetScript.Name
+		if renamedSource, err := rightscript.ScaffoldBuffer(source, *sourceMetadata, "", "", false); err == nil {
+			source = renamedSource
 		}
 	}
 
-	if r.Href == "" {
-		loc := pub.Locator(client)
+	move := PlanAttachmentMove(sourceAttachments, targetAttachments)
 
-		err = loc.Import()
+	fmt.Fprintf(Stdout, "Moving '%s' with HREF %s onto '%s' with HREF %s\n", sourceScript.Name, sourceHref, targetScript.Name, targetHref)
+	for _, a := range append(append([]*cm15.RightScriptAttachment{}, move.Add...), move.Replace...) {
+		fmt.Fprintf(Stdout, "  Attachment '%s' will be copied\n", a.Filename)
+	}
+	for _, a := range move.Remove {
+		fmt.Fprintf(Stdout, "  Attachment '%s' will be removed\n", a.Filename)
+	}
+	if deleteSource {
+		fmt.Fprintf(Stdout, "  RightScript '%s' with HREF %s will be deleted\n", sourceScript.Name, sourceHref)
+	}
+	if !force {
+		if !canConfirm() {
+			fatalError(exitGeneral, "Moving needs confirmation, use --assume-yes or --force when stdin is not a terminal")
+		}
+		if !confirm("Move?") {
+			fatalError(exitGeneral, "Move cancelled")
+		}
+	}
 
+	updateTarget := func() error {
+		err := targetLocator.Update(&cm15.RightScriptParam3{
+			Name:        targetScript.Name,
+			Description: sourceScript.Description,
+			Packages:    sourceScript.Packages,
+			Source:      string(source),
+		})
 		if err != nil {
-			return fmt.Errorf("Failed to import publication %s for RightScript '%s' Revision %d Publisher %s\n",
-				getLink(pub.Links, "self"), r.Name, r.Revision, r.Publisher)
+			return fmt.Errorf("Could not update RightScript with href %s: %s", targetHref, err.Error())
 		}
+		fmt.Fprintf(Stdout, "  Updated RightScript with HREF %s\n", targetHref)
+		return nil
+	}
+	if err := MoveAttachments(move, apiAttachmentSteps(client, targetAttachmentsLocator), updateTarget); err != nil {
+		fatalError(ErrorExitCode(err), "Could not move RightScript with href %s onto %s: %s", sourceHref, targetHref, err.Error())
+	}
 
-		rsUnfiltered, err := rsLocator.Index(rsapi.APIParams{"filter": filters})
-		if err != nil {
-			return err
+	if deleteSource {
+		fmt.Fprintf(Stdout, "  Deleting RightScript with HREF %s\n", sourceHref)
+		if err := sourceLocator.Destroy(); err != nil {
+			fatalError(ErrorExitCode(err), "Could not delete RightScript with href %s: %s", sourceHref, err.Error())
 		}
-		for _, rs := range rsUnfiltered {
-			if rs.Name == r.Name && rs.Revision == r.Revision && rs.Description == pub.Description {
-				r.Href = getLink(rs.Links, "self")
+	}
+}
+
+// TaggedHrefs returns the HREFs of the resources in a response from the tags by_tag action.
+func TaggedHrefs(response []map[string]interface{}) []string {
+	hrefs := []string{}
+	for _, resourceTags := range response {
+		links, _ := resourceTags["links"].([]interface{})
+		for _, link := range links {
+			link, _ := link.(map[string]interface{})
+			if href, ok := link["href"].(string); ok && link["rel"] == "resource" {
+				hrefs = append(hrefs, href)
 			}
 		}
-		if r.Href == "" {
-			return fmt.Errorf("Could not refind RightScript '%s' Revision %d after import!", r.Name, r.Revision)
+	}
+	return hrefs
+}
+
+// rightScriptsTagged returns the set of HREFs of the RightScripts which have all of tags, or nil when there are no
+// tags to select by.
+func rightScriptsTagged(client *cm15.API, tags []string) (map[string]bool, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	response, err := client.TagLocator("/api/tags/by_tag").ByTag("right_scripts", tags, rsapi.APIParams{"match_all": "true"})
+	if err != nil {
+		return nil, err
+	}
+	tagged := map[string]bool{}
+	for _, href := range TaggedHrefs(response) {
+		tagged[href] = true
+	}
+	return tagged, nil
+}
+
+// ReadNames reads one RightScript name per line for --names-from. Surrounding whitespace is ignored, as are blank lines,
+// lines starting with #, and names which were already read.
+func ReadNames(r io.Reader) ([]string, error) {
+	names := []string{}
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		name := strings.TrimSpace(scanner.Text())
+		if name == "" || strings.HasPrefix(name, "#") || seen[name] {
+			continue
 		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
 	}
+	return names, nil
+}
 
-	return nil
+// rightScriptsNamed looks up each of the names in file the same way a single name argument is looked up and returns
+// the set of HREFs of the HEAD RightScripts it found, or nil when there is no file to select by. The names which
+// matched no RightScript or more than one are reported.
+func rightScriptsNamed(file string) map[string]bool {
+	if file == "" {
+		return nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		fatalError(exitGeneral, "Could not read names: %s", err.Error())
+	}
+	names, err := ReadNames(f)
+	f.Close()
+	if err != nil {
+		fatalError(exitGeneral, "Could not read names from %s: %s", file, err.Error())
+	}
+
+	named := map[string]bool{}
+	ambiguous, missing := []string{}, []string{}
+	for _, name := range names {
+		href, err := cachedParamToHref("right_scripts", name, 0)
+		switch err.(type) {
+		case nil:
+			named[href] = true
+		case *ambiguousError:
+			ambiguous = append(ambiguous, name)
+		case *rightscript.NotFoundError:
+			missing = append(missing, name)
+		default:
+			fatalError(ErrorExitCode(err), "Could not look up RightScript %s: %s", name, err.Error())
+		}
+	}
+	fmt.Fprintf(Stderr, "Found %d of %d names from %s\n", len(names)-len(ambiguous)-len(missing), len(names), file)
+	for _, name := range ambiguous {
+		fmt.Fprintf(Stderr, "  Ambiguous %s: more than one RightScript has the name\n", name)
+	}
+	for _, name := range missing {
+		fmt.Fprintf(Stderr, "  Missing %s: no RightScript has the name\n", name)
+	}
+	return named
 }
 
-func (r *RightScript) PushLocal(prefix string) error {
+func rightScriptTag(href string, tags []string) {
 	client, err := Config.Account.Client15()
 	if err != nil {
-		return err
+		fatalError(exitConfig, "Could not tag RightScript with href %s: %s", href, err.Error())
 	}
 
-	createLocator := client.RightScriptLocator("/api/right_scripts")
-	scriptName := r.Metadata.Name
-	if prefix != "" {
-		scriptName = fmt.Sprintf("%s_%s", prefix, r.Metadata.Name)
+	err = client.TagLocator("/api/tags/multi_add").MultiAdd([]string{href}, tags)
+	if err != nil {
+		fatalError(ErrorExitCode(err), "Could not tag RightScript with href %s: %s", href, err.Error())
 	}
-	foundId, err := rightScriptIdByName(scriptName)
+	fmt.Fprintf(Stdout, "Added %d tag(s) to %s\n", len(tags), href)
+}
+
+func rightScriptUntag(href string, tags []string) {
+	client, err := Config.Account.Client15()
 	if err != nil {
-		return err
+		fatalError(exitConfig, "Could not untag RightScript with href %s: %s", href, err.Error())
 	}
 
-	fileSrc, err := ioutil.ReadFile(r.Path)
+	err = client.TagLocator("/api/tags/multi_delete").MultiDelete([]string{href}, tags)
 	if err != nil {
-		return err
+		fatalError(ErrorExitCode(err), "Could not untag RightScript with href %s: %s", href, err.Error())
 	}
+	fmt.Fprintf(Stdout, "Removed %d tag(s) from %s\n", len(tags), href)
+}
 
-	var rightscriptLocator *cm15.RightScriptLocator
+func rightScriptUpload(paths []string, force bool, since, stateFile, nameSeparator, report string, accounts []string, options rightscript.PushOptions) {
+	// Asking for confirmation without anyone to answer would wait forever
+	if options.ConfirmEach && !canConfirm() {
+		fatalError(exitGeneral, "--confirm-each needs stdin to be a terminal to ask for confirmation, or --assume-yes")
+	}
 
-	if foundId == "" {
-		fmt.Printf("  Creating a new RightScript named '%s' from %s\n", scriptName, r.Path)
-		// New one, perform create call
-		params := cm15.RightScriptParam2{
-			Name:        scriptName,
-			Description: r.Metadata.Description,
-			Packages:    r.Metadata.Packages,
-			Source:      string(fileSrc),
+	// Pass 1, perform validations, gather up results
+	scripts := []*rightscript.RightScript{}
+	files := []string{}
+	roots := make(map[string]string)    // path argument each file was found under
+	archives := make(map[string]string) // archive each temporary extraction directory was extracted from
+	for _, root := range paths {
+		if IsArchive(root) && !rightscript.IsDirectory(root) {
+			dir, err := rightscript.MakeTempDir("right_st_upload")
+			if err != nil {
+				fatalError(exitGeneral, "%s\n", err.Error())
+			}
+			defer rightscript.RemoveTempDir(dir)
+			if err := ExtractArchive(root, dir); err != nil {
+				fatalError(exitGeneral, "%s\n", err.Error())
+			}
+			archives[dir] = root
+			root = dir
 		}
-		rightscriptLocator, err = createLocator.Create(&params)
+		found, err := walkPaths([]string{root})
 		if err != nil {
-			return err
+			fatalError(exitGeneral, "%s\n", err.Error())
 		}
-		fmt.Printf("    RightScript created with HREF %s\n", rightscriptLocator.Href)
-		r.Href = string(rightscriptLocator.Href)
-	} else {
-		// Found existing, do an update
-		href := fmt.Sprintf("/api/right_scripts/%s", foundId)
-		fmt.Printf("  Updating existing RightScript named '%s' with HREF %s from %s\n", scriptName, href, r.Path)
-
-		params := cm15.RightScriptParam3{
-			Name:        scriptName,
-			Description: r.Metadata.Description,
-			Packages:    r.Metadata.Packages,
-			Source:      string(fileSrc),
-		}
-		rightscriptLocator = client.RightScriptLocator(href)
-		err = rightscriptLocator.Update(&params)
-		if err != nil {
-			return err
+		for _, file := range found {
+			roots[file] = root
 		}
-		r.Href = href
+		files = append(files, found...)
 	}
+	files = rightscript.DropSidecars(files)
+	var err error
 
-	attachmentsHref := fmt.Sprintf("%s/attachments", rightscriptLocator.Href)
-	attachmentsLocator := client.RightScriptAttachmentLocator(attachmentsHref)
-	attachments, err := attachmentsLocator.Index(rsapi.APIParams{})
+	// Skip unchanged files before even parsing their metadata. An explicit --since wins over the state file.
+	uploadStarted := time.Now()
+	var modifiedSince time.Time
+	if since != "" {
+		modifiedSince, err = ParseSince(since, uploadStarted)
+	} else if stateFile != "" {
+		modifiedSince, err = ReadUploadState(stateFile)
+	}
 	if err != nil {
-		return err
+		fatalError(exitGeneral, "%s\n", err.Error())
 	}
-
-	toUpload := make(map[string]string)                           // scripts we want to upload
-	onRightscript := make(map[string]*cm15.RightScriptAttachment) // scripts attached to the rightsript
-	for _, a := range r.Metadata.Attachments {
-		fullPath := filepath.Join(filepath.Dir(r.Path), "attachments", a)
-		md5, err := fmd5sum(fullPath)
-		if err != nil {
-			return err
+	results := []*rightscript.PushResult{}
+	writeReport := func() {
+		if report == "" {
+			return
+		}
+		if err := WriteUploadReport(report, results); err != nil {
+			fatalError(exitGeneral, "Could not write upload report %s: %s", report, err.Error())
 		}
-		// We use a compound key with the name+md5 here to work around a couple corner cases
-		//   - if the file is renamed, it'll be deleted and reuploaded
-		//   - if two files have the same md5 for whatever reason they won't clash
-		toUpload[path.Base(a)+"_"+md5] = a
 	}
-	for _, a := range attachments {
-		onRightscript[path.Base(a.Filename)+"_"+a.Digest] = a
+	if !modifiedSince.IsZero() {
+		modified, err := FilterModifiedSince(files, modifiedSince)
+		if err != nil {
+			fatalError(exitGeneral, "%s\n", err.Error())
+		}
+		if report != "" {
+			wasModified := make(map[string]bool)
+			for _, file := range modified {
+				wasModified[file] = true
+			}
+			for _, file := range files {
+				if !wasModified[file] && !rightscript.IsDirectory(file) {
+					results = append(results, &rightscript.PushResult{Path: file, Action: "skipped"})
+				}
+			}
+		}
+		files = modified
+		if len(files) == 0 {
+			fmt.Fprintf(Stdout, "No files modified since %s\n", modifiedSince.Format(time.RFC3339))
+		}
 	}
 
-	// Two passes. First pass we delete RightScripts. This comes up when a file was
-	// removed from the RightScript, or when the contents of a file on disk changed.
-	// In the second case, the second pass will reupload the correct attachment.
-	for digestKey, a := range onRightscript {
-		if _, ok := toUpload[digestKey]; !ok {
-			loc := a.Locator(client)
+	// Files extracted from an archive are shown as archive:path within the archive
+	displayPath := func(text string) string {
+		for dir, archive := range archives {
+			text = strings.Replace(text, dir+string(os.PathSeparator), archive+":", -1)
+		}
+		return text
+	}
 
-			fmt.Printf("  Deleting attachment '%s' with HREF '%s'\n", a.Filename, loc.Href)
-			err := loc.Destroy()
-			if err != nil {
-				return err
+	if options.ValidateFirst {
+		valid := true
+		for _, result := range rightscript.ValidateRightScripts(files, nil, nil, 1) {
+			result.File = displayPath(result.File)
+			if result.Err != nil {
+				result.Err = fmt.Errorf("%s", displayPath(result.Err.Error()))
 			}
+			valid = reportValidation(result, options.Strict) && valid
+		}
+		if !valid {
+			fatalError(exitValidation, "Not uploading anything since not every file is valid")
 		}
 	}
 
-	// Second pass, now upload any missing attachment and any attachments that were
-	// deleted because we changed file contents.
-	for digestKey, name := range toUpload {
-		digestKeyParts := strings.Split(digestKey, "_")
-		md5 := digestKeyParts[len(digestKeyParts)-1]
-		if _, ok := onRightscript[digestKey]; ok {
-			fmt.Printf("  Attachment '%s' already uploaded with md5 %s\n", name, md5)
-			// TBD -- update if a.Name != name?
-		} else {
-			fullPath := filepath.Join(filepath.Dir(r.Path), "attachments", name)
-			fmt.Printf("  Uploading attachment '%s' with md5 %s\n", name, md5)
-			f, err := os.Open(fullPath)
-			if err != nil {
-				return err
+	for _, p := range files {
+		fmt.Fprintf(Stdout, "Uploading %s\n", displayPath(p))
+		f, err := os.Open(p)
+		if err != nil {
+			fatalError(exitGeneral, "Cannot open %s", displayPath(p))
+		}
+		f.Close()
+		if err := rightscript.CheckFileSize(p, options.MaxFileSize); err != nil {
+			fatalError(exitValidation, "%s\n", displayPath(err.Error()))
+		}
+		script, err := validateRightScript(p, force)
+		if err != nil {
+			fatalError(exitValidation, "%s: %s\n", displayPath(p), displayPath(err.Error()))
+		}
+		if err := script.CheckAttachmentSizes(options.MaxFileSize); err != nil {
+			fatalError(exitValidation, "%s: %s\n", displayPath(p), displayPath(err.Error()))
+		}
+		if options.SourceFile != "" {
+			if err := rightscript.CheckFileSize(options.SourceFile, options.MaxFileSize); err != nil {
+				fatalError(exitValidation, "%s\n", err.Error())
 			}
-			// FileUpload represents payload fields that correspond to multipart file uploads.
-			file := rsapi.FileUpload{Name: "right_script_attachment[content]", Reader: f, Filename: name}
-			//params := cm15.RightScriptAttachmentParam{Content: &file, Name: a}
-			err = uploadAttachment(attachmentsLocator, &file, path.Base(name))
+			fmt.Fprintf(Stdout, "  Using source from %s\n", options.SourceFile)
+			script.SourcePath = options.SourceFile
+		}
+		// Metadata parsed from the file always records its comment style, so an empty one without a sidecar file means
+		// the script had no metadata and its name was only guessed from the file name
+		if nameSeparator != "" && script.Metadata.Comment == "" && script.Metadata.Sidecar == "" {
+			script.Metadata.Name = NameFromPath(roots[p], p, nameSeparator)
+			script.Name = script.Metadata.Name
+		}
+
+		if options.Strict {
+			warnings, err := script.Warnings(options.NormalizeEOL, options.SourceEncoding)
 			if err != nil {
-				return err
+				fatalError(exitGeneral, "%s\n", displayPath(err.Error()))
+			}
+			if len(warnings) > 0 {
+				for _, warning := range warnings {
+					fmt.Fprintf(Stderr, "%s: ERROR: %s\n", displayPath(p), warning)
+				}
+				fatalError(exitValidation, "%s: warnings are errors with --strict", displayPath(p))
 			}
 		}
-	}
-
-	return err
-}
 
-// Validates that a file has valid metadata, including attachments.
-// No metadata is considered valid, although the RightScriptMetadata returned will
-// be intialized to default values. A RightScriptMetadata struct might still be
-// returned if there are errors if the metadata was partially specified.
-func validateRightScript(file string, ignoreMissingMetadata bool) (*RightScript, error) {
-	script, err := os.Open(file)
-	if err != nil {
-		return nil, err
+		scripts = append(scripts, script)
 	}
-	defer script.Close()
 
-	metadata, err := ParseRightScriptMetadata(script)
-	if err != nil {
-		return nil, err
+	// When uploading to several accounts each one gets its own account struct, and therefore its own API clients, so
+	// nothing authenticated for one account is ever used for another. They are all looked up before uploading anything.
+	targets := []*Account{Config.Account}
+	if len(accounts) > 0 {
+		targets = make([]*Account, len(accounts))
+		for i, name := range accounts {
+			a, ok := Config.Accounts[name]
+			if !ok {
+				fatalError(exitConfig, "Could not find account: %s", name)
+			}
+			targets[i] = &Account{Id: a.Id, Host: a.Host, RefreshToken: a.RefreshToken, AccessToken: a.AccessToken,
+				AccessTokenExpiresAt: a.AccessTokenExpiresAt}
+			if name != Config.AccountName {
+				if err := expandAccount(targets[i]); err != nil {
+					fatalError(exitConfig, "Account %s: %s", name, err.Error())
+				}
+			}
+		}
 	}
 
-	if metadata == nil {
-		if ignoreMissingMetadata {
-			metadata = new(RightScriptMetadata)
-			scriptname := path.Base(file)
-			scriptext := path.Ext(scriptname)
-			scriptname = strings.TrimRight(scriptname, scriptext)
-			metadata.Name = scriptname
-		} else {
-			return nil, fmt.Errorf("No embedded metadata for %s. Use --force to upload anyways.", file)
+	// Pass 2, upload. On SIGINT/SIGTERM the RightScript currently being pushed is
+	// allowed to finish, but no new ones are started. A second signal cancels it. A RightScript which fails to upload
+	// is recorded and the rest are still uploaded, unless --stop-on-first-error is given.
+	failed := []*rightscript.PushResult{}
+	failedExitCode := 0
+	interrupted := finishOnInterrupt()
+	for t, target := range targets {
+		accountName := ""
+		if len(accounts) > 0 {
+			accountName = accounts[t]
+			Config.Account, Config.AccountName = target, accountName
+			fmt.Fprintf(Stdout, "Uploading to account %s (%d on %s)\n", accountName, target.Id, target.Host)
 		}
+		for i, script := range scripts {
+			select {
+			case sig := <-interrupted:
+				for _, skipped := range scripts[i:] {
+					results = append(results, &rightscript.PushResult{Path: skipped.Path, Name: options.Name(skipped.Metadata.Name),
+						Account: accountName, Action: "skipped"})
+				}
+				if len(accounts) > 0 {
+					for _, name := range accounts[t+1:] {
+						for _, skipped := range scripts {
+							results = append(results, &rightscript.PushResult{Path: skipped.Path,
+								Name: options.Name(skipped.Metadata.Name), Account: name, Action: "skipped"})
+						}
+					}
+				}
+				// Report what actually happened to each script, which is not just uploaded for the ones before i: they
+				// may also have failed or been skipped
+				count := 0
+				for _, result := range results {
+					if uploaded(result) {
+						count++
+					}
+				}
+				fmt.Fprintf(Stderr, "Received %s, stopped after uploading %d of %d RightScripts:\n", sig, count,
+					len(scripts)*len(targets))
+				for _, result := range results {
+					if result.Account != "" {
+						fmt.Fprintf(Stderr, "  %s %s in account %s\n", strings.Title(result.Action), displayPath(result.Path), result.Account)
+					} else {
+						fmt.Fprintf(Stderr, "  %s %s\n", strings.Title(result.Action), displayPath(result.Path))
+					}
+				}
+				writeReport()
+				fatalError(exitInterrupted, "Upload interrupted")
+			default:
+			}
+			script.Result = nil
+			if options.ConfirmEach {
+				plan, err := planRightScript(script, options)
+				if err != nil {
+					writeReport()
+					fatalError(ErrorExitCode(err), "%s", err.Error())
+				}
+				for _, step := range plan {
+					fmt.Fprintf(Stdout, "  %s\n", step)
+				}
+				if !confirm(fmt.Sprintf("Upload %s?", displayPath(script.Path))) {
+					fmt.Fprintf(Stdout, "  Skipped %s\n", displayPath(script.Path))
+					script.RemoveFetchedAttachments()
+					results = append(results, &rightscript.PushResult{Path: script.Path, Name: options.Name(script.Metadata.Name),
+						Account: accountName, Action: "skipped"})
+					continue
+				}
+			}
+			err = pushRightScript(script, options)
+			if script.Result != nil {
+				script.Result.Account = accountName
+				results = append(results, script.Result)
+			}
+			if err != nil && !options.StopOnFirstError {
+				fmt.Fprintf(Stderr, "ERROR: %s: %s\n", displayPath(script.Path), err.Error())
+				if script.Result == nil {
+					script.Result = &rightscript.PushResult{Path: script.Path, Name: options.Name(script.Metadata.Name), Account: accountName}
+					results = append(results, script.Result)
+				}
+				script.Result.Action, script.Result.Error = "failed", err.Error()
+				failed = append(failed, script.Result)
+				if failedExitCode == 0 {
+					failedExitCode = ErrorExitCode(err)
+				}
+			} else if err != nil {
+				writeReport()
+				fatalError(ErrorExitCode(err), "%s", err.Error())
+			}
+		}
+		if accountName != "" {
+			count := 0
+			for _, result := range results {
+				if result.Account == accountName && uploaded(result) {
+					count++
+				}
+			}
+			fmt.Fprintf(Stdout, "Uploaded %d RightScripts to account %s\n", count, accountName)
+		}
+	}
+	writeReport()
+	if len(failed) > 0 {
+		for _, result := range failed {
+			fmt.Fprintf(Stderr, "  Failed %s: %s\n", displayPath(result.Path), result.Error)
+		}
+		fatalError(failedExitCode, "%d of %d RightScripts failed to upload", len(failed), len(scripts)*len(targets))
 	}
 
-	rightScript := RightScript{
-		Type:     LocalRightScript,
-		Href:     "",
-		Path:     file,
-		Name:     metadata.Name,
-		Metadata: *metadata,
+	if stateFile != "" {
+		err = WriteUploadState(stateFile, uploadStarted)
+		if err != nil {
+			fatalError(exitGeneral, "Could not write upload state file %s: %s", stateFile, err.Error())
+		}
 	}
+}
 
-	if *debug {
-		pretty.Println(metadata)
+// NameFromPath derives a RightScript name from the path of a file relative to the directory it was found under, so
+// category/subcategory/name.sh becomes "category/subcategory/name" when joined with "/".
+func NameFromPath(root, file, separator string) string {
+	if root == file {
+		root = filepath.Dir(file)
 	}
+	rel, err := filepath.Rel(root, file)
+	if err != nil {
+		rel = filepath.Base(file)
+	}
+	rel = strings.TrimSuffix(rel, filepath.Ext(rel))
+	return strings.Join(strings.Split(rel, string(filepath.Separator)), separator)
+}
 
-	if metadata.Inputs == nil {
-		return &rightScript, fmt.Errorf("Inputs must be specified")
+// rightScriptDownloadAll downloads every HEAD RightScript with a name matching filter and all of tags into its own
+// subdirectory of outputDir. Each subdirectory gets the script with its metadata and an attachments directory so it can
+// be uploaded again as is.
+func rightScriptDownloadAll(filter string, tags []string, namesFrom, outputDir string, noAttachments bool, fileMode os.FileMode, canonical bool) {
+	client, err := Config.Account.Client15()
+	if err != nil {
+		fatalError(exitConfig, "Could not list RightScripts: %s", err.Error())
 	}
 
-	seenAttachments := make(map[string]bool)
-	for _, attachment := range metadata.Attachments {
-		if seenAttachments[path.Base(attachment)] {
-			return nil, fmt.Errorf("Attachment name %s appears twice", attachment)
+	criteria := []string{}
+	if filter != "" {
+		criteria = append(criteria, fmt.Sprintf("matching '%s'", filter))
+	}
+	if len(tags) > 0 {
+		criteria = append(criteria, fmt.Sprintf("tagged %s", strings.Join(tags, ", ")))
+	}
+	if namesFrom != "" {
+		criteria = append(criteria, fmt.Sprintf("named in %s", namesFrom))
+	}
+	selection := strings.Join(criteria, " and ")
+	params := rsapi.APIParams{}
+	if filter != "" {
+		nameFilter, err := rightscript.NameFilter(filter)
+		if err != nil {
+			fatalError(exitGeneral, "Could not list RightScripts %s: %s", selection, err.Error())
 		}
-		seenAttachments[path.Base(attachment)] = true
-		// Support both relative and full paths
-		fullPath := filepath.Join(filepath.Dir(file), "attachments", attachment)
-		if filepath.IsAbs(attachment) {
-			fullPath = attachment
+		params["filter"] = []string{nameFilter}
+	}
+	rightscripts, err := client.RightScriptLocator("/api/right_scripts").Index(params)
+	if err != nil {
+		fatalError(ErrorExitCode(err), "Could not list RightScripts %s: %s", selection, err.Error())
+	}
+	tagged, err := rightScriptsTagged(client, tags)
+	if err != nil {
+		fatalError(ErrorExitCode(err), "Could not list RightScripts %s: %s", selection, err.Error())
+	}
+	named := rightScriptsNamed(namesFrom)
+	hrefs := []string{}
+	names := map[string]string{}
+	for _, rs := range rightscripts {
+		href := rightscript.Link(rs.Links, "self")
+		if rs.Revision != 0 || !nameContains(rs.Name, filter) || (tagged != nil && !tagged[href]) || (named != nil && !named[href]) {
+			continue
 		}
+		hrefs = append(hrefs, href)
+		names[href] = rs.Name
+	}
+	if len(hrefs) == 0 {
+		fatalError(exitNotFound, "Found no HEAD RightScripts %s", selection)
+	}
+	sort.Strings(hrefs)
 
-		file, err := os.Open(fullPath)
+	fmt.Fprintf(Stdout, "Downloading %d RightScripts %s to '%s'\n", len(hrefs), selection, outputDir)
+	for _, href := range hrefs {
+		scriptDir := filepath.Join(outputDir, rightscript.CleanFileName(names[href]))
+		err = os.MkdirAll(scriptDir, 0755)
 		if err != nil {
-			return &rightScript, fmt.Errorf("Could not open attachment: %s. Make sure attachment is in \"attachments/\" subdirectory or an absolute path", err.Error())
-		}
-		_, err = md5sum(file)
-		file.Close()
-		if err != nil {
-			return &rightScript, err
+			fatalError(exitGeneral, "Could not create directory: %s", err.Error())
 		}
+		rightScriptDownload(href, scriptDir, noAttachments, fileMode, canonical)
 	}
+}
 
-	if metadata.Name == "" {
-		return &rightScript, fmt.Errorf("Name must be specified")
+func rightScriptScaffold(files []string, backup bool, force bool, interpreter string) {
+	files, err := walkPaths(files)
+	if err != nil {
+		fatalError(exitGeneral, "%s\n", err.Error())
 	}
+	files = rightscript.DropSidecars(files)
 
-	return &rightScript, nil
-}
-
-func (rs RightScript) MarshalYAML() (interface{}, error) {
-	if rs.Type == LocalRightScript {
-		return rs.Path, nil
-	} else {
-		destMap := make(map[string]interface{})
-		destMap["Name"] = rs.Name
-		destMap["Revision"] = rs.Revision
-		destMap["Publisher"] = rs.Publisher
-		return destMap, nil
+	for _, file := range files {
+		err = rightscript.ScaffoldRightScript(file, backup, Stdout, force, interpreter)
+		if err != nil {
+			fatalError(exitValidation, "%s\n", err.Error())
+		}
 	}
 }
 
-func (rs *RightScript) UnmarshalYAML(unmarshal func(interface{}) error) error {
-	var pathType string
-	var mapType map[string]string
-	errorMsg := "Could not unmarshal RightScript. Must be either a path to file on disk or a hash with a Name/Revision keys"
-	err := unmarshal(&pathType)
-	if err == nil {
-		rs.Type = LocalRightScript
-		rs.Path = pathType
-	} else {
-		err = unmarshal(&mapType)
-		if err != nil {
-			return fmt.Errorf(errorMsg)
+func rightScriptValidate(files []string, categories []string, schemaFile, envFile string, parallel int, strict bool) {
+	var schema *rightscript.ValidationSchema
+	if schemaFile != "" {
+		var err error
+		if schema, err = rightscript.LoadValidationSchema(schemaFile); err != nil {
+			fatalError(exitConfig, "%s", err.Error())
 		}
-		name, ok := mapType["Name"]
-		if !ok {
-			return fmt.Errorf(errorMsg)
+	}
+	var env map[string]string
+	if envFile != "" {
+		var err error
+		if env, err = rightscript.LoadEnvFile(envFile); err != nil {
+			fatalError(exitConfig, "%s", err.Error())
 		}
-		rs.Name = name
-		publisher, ok := mapType["Publisher"]
-		if ok {
-			rs.Publisher = publisher
+	}
+
+	err_encountered := false
+	scripts := []*rightscript.RightScript{}
+	for _, result := range rightscript.ValidateRightScripts(files, categories, schema, parallel) {
+		if env != nil && result.Err == nil {
+			scripts = append(scripts, result.Script)
+			required, optional := result.Script.Metadata.Inputs.MissingFromEnv(env)
+			if len(required) > 0 {
+				result.Err = fmt.Errorf("Required inputs missing from %s: %s", envFile, strings.Join(required, ", "))
+			} else if len(optional) > 0 {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("Inputs missing from %s: %s", envFile,
+					strings.Join(optional, ", ")))
+			}
 		}
-		revStr, ok := mapType["Revision"]
-		if !ok {
-			return fmt.Errorf(errorMsg)
+		if !reportValidation(result, strict) {
+			err_encountered = true
 		}
-		rev, err := strconv.Atoi(revStr)
-		if err != nil {
-			return fmt.Errorf("Revision must be an integer")
+	}
+	if env != nil {
+		level := "WARNING"
+		for _, name := range rightscript.UndeclaredEnv(env, scripts) {
+			if strict {
+				level, err_encountered = "ERROR", true
+			}
+			fmt.Fprintf(Stderr, "%s: %s: %s is not an input of any of the scripts\n", envFile, level, name)
 		}
-		rs.Type = PublishedRightScript
-		rs.Revision = rev
 	}
+	if err_encountered {
+		exit(exitValidation)
+	}
+}
 
-	return nil
+// reportValidation prints the outcome of validating a file and returns whether it is valid, which it is not with
+// warnings when strict.
+func reportValidation(result rightscript.ValidateResult, strict bool) bool {
+	if result.Err != nil {
+		fmt.Fprintf(Stderr, "%s: %s\n", result.File, result.Err.Error())
+		return false
+	}
+	if strict && len(result.Warnings) > 0 {
+		for _, warning := range result.Warnings {
+			fmt.Fprintf(Stderr, "%s: ERROR: %s\n", result.File, warning)
+		}
+		return false
+	}
+	for _, warning := range result.Warnings {
+		fmt.Fprintf(Stderr, "%s: WARNING: %s\n", result.File, warning)
+	}
+	fmt.Fprintf(Stdout, "%s: Valid metadata\n", result.File)
+	return true
 }
@@ -0,0 +1,356 @@
+// Package rightscript parses, validates, uploads, and downloads RightScripts. It is the core of the right_st command
+// line tool and can be used by other programs to manage RightScripts the same way: everything which talks to the API
+// goes through a Client, and failures are returned as errors rather than exiting.
+package rightscript
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rightscale/rsc/cm15"
+	"github.com/rightscale/rsc/rsapi"
+)
+
+// DefaultAPIVersion is the RightScale API version sent with the requests a Client builds itself when it has no
+// APIVersion.
+const DefaultAPIVersion = "1.5"
+
+// Transient failures of name lookups are retried this many times in total, waiting lookupRetryDelay before the first
+// retry and twice as long before each one after that
+const (
+	lookupAttempts   = 3
+	lookupRetryDelay = time.Second
+)
+
+// Client manages the RightScripts of the RightScale account API is a client for.
+type Client struct {
+	API *cm15.API
+	// RightScale API version sent with the requests the client builds itself, DefaultAPIVersion when empty
+	APIVersion string
+	// Context every request is made with, including attachment downloads, so cancelling it abandons them;
+	// context.Background() when nil
+	Context context.Context
+	// Where progress is printed to, nothing is printed when it is nil
+	Stdout io.Writer
+	// Debug prints details of publication lookups to Stdout
+	Debug bool
+	// Explain, unless it is nil, is told about the steps taken to find resources, such as retried lookups
+	Explain func(format string, a ...interface{})
+}
+
+func (c *Client) apiVersion() string {
+	if c.APIVersion == "" {
+		return DefaultAPIVersion
+	}
+	return c.APIVersion
+}
+
+func (c *Client) context() context.Context {
+	if c.Context == nil {
+		return context.Background()
+	}
+	return c.Context
+}
+
+func (c *Client) stdout() io.Writer {
+	if c.Stdout == nil {
+		return ioutil.Discard
+	}
+	return c.Stdout
+}
+
+// HTTPGet is http.Get with the context of the client, for downloads which are not API requests.
+func (c *Client) HTTPGet(url string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(req.WithContext(c.context()))
+}
+
+// Crappy workaround. RSC doesn't return the body of the http request which contains
+// the script source, so do the same lower level calls it does to get it.
+func (c *Client) Source(loc *cm15.RightScriptLocator) (respBody []byte, err error) {
+	var params rsapi.APIParams
+	var p rsapi.APIParams
+	client := c.API
+
+	uri, err := loc.ActionPath("RightScript", "show_source")
+	if err != nil {
+		return respBody, err
+	}
+	req, err := client.BuildHTTPRequest(uri.HTTPMethod, uri.Path, c.apiVersion(), params, p)
+	if err != nil {
+		return respBody, err
+	}
+	resp, err := client.PerformRequest(req)
+	if err != nil {
+		return respBody, err
+	}
+	defer resp.Body.Close()
+	respBody, _ = ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return respBody, NewResponseError(resp, respBody)
+	}
+	return respBody, nil
+}
+
+// Crappy workaround 2. the RightScriptAttachmentLocator.Create call doesn't work
+// because RSCs countless concrete types screw things up. The RSC create call calls BuildHttpRequest
+// with the type passed in, which is serializes to JSON. Under different code paths
+// (such as here or the command line) it passes in rsapi.APIParams instead of a fixed type of
+// cm15.RightScriptAttachmentParams. BuildHTTPRequest has code to iterate over APIParams and
+// turn it into a a multipart mime doc if it sees a FileUpload type. But it doesn't have
+// code knowing about every concrete type to handle that. It also always sends the file as
+// application/octet-stream, so the multipart body is built by AttachmentBody instead.
+// The HREF of the created attachment is returned from the Location header of the response, it is empty when the
+// response has none.
+func (c *Client) UploadAttachment(loc *cm15.RightScriptAttachmentLocator,
+	file *rsapi.FileUpload, name string) (string, error) {
+	var params rsapi.APIParams
+	client := c.API
+
+	body, contentType, err := AttachmentBody(file, name)
+	if err != nil {
+		return "", err
+	}
+	uri, err := loc.ActionPath("RightScriptAttachment", "create")
+	if err != nil {
+		return "", err
+	}
+	req, err := client.BuildHTTPRequest(uri.HTTPMethod, uri.Path, c.apiVersion(), params, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Body = ioutil.NopCloser(body)
+	req.ContentLength = int64(body.Len())
+	req.Header.Set("Content-Type", contentType)
+	resp, err := client.PerformRequest(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	respBody, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", NewResponseError(resp, respBody)
+	}
+	return LocationHref(resp.Header.Get("Location")), nil
+}
+
+// LocationHref returns the HREF of the resource a Location header points at, which may be a full URL of the API
+// endpoint host or just the HREF itself. It is empty when there is no location.
+func LocationHref(location string) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return ""
+	}
+	return u.Path
+}
+
+// AttachmentBody builds the multipart form creating an attachment called name with the content of file. The content
+// part has the content type detected by AttachmentContentType. The content type of the form itself is returned too.
+func AttachmentBody(file *rsapi.FileUpload, name string) (*bytes.Buffer, string, error) {
+	content, err := ioutil.ReadAll(file.Reader)
+	if err != nil {
+		return nil, "", err
+	}
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	if err := writer.WriteField("right_script_attachment[filename]", name); err != nil {
+		return nil, "", err
+	}
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		quoteEscaper.Replace(file.Name), quoteEscaper.Replace(file.Filename)))
+	header.Set("Content-Type", AttachmentContentType(file.Filename, content))
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := part.Write(content); err != nil {
+		return nil, "", err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return body, writer.FormDataContentType(), nil
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// AttachmentContentType detects the content type of an attachment from its content, or from its extension when the
+// content does not tell, falling back to application/octet-stream.
+func AttachmentContentType(filename string, content []byte) string {
+	contentType := http.DetectContentType(content)
+	if contentType == "application/octet-stream" {
+		if byExtension := mime.TypeByExtension(filepath.Ext(filename)); byExtension != "" {
+			contentType = byExtension
+		}
+	}
+	return contentType
+}
+
+// IdByName returns the ID of the HEAD RightScript with exactly name, or an empty ID when there is none.
+func (c *Client) IdByName(name string) (string, error) {
+	filter, err := NameFilter(name)
+	if err != nil {
+		return "", err
+	}
+	createLocator := c.API.RightScriptLocator("/api/right_scripts")
+	apiParams := rsapi.APIParams{"filter": []string{filter}}
+	var rightscripts []*cm15.RightScript
+	err = Retry(c.context(), lookupAttempts, lookupRetryDelay, c.Explain, func() error {
+		var err error
+		rightscripts, err = createLocator.Index(apiParams)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	foundId := ""
+	for _, rs := range rightscripts {
+		// Recheck the name here, filter does a partial match and we need an exact one
+		if rs.Name == name && rs.Revision == 0 {
+			if foundId != "" {
+				return "", fmt.Errorf("Error, matched multiple RightScripts with the same name, please delete one: %s %s", rs.Id, foundId)
+			} else {
+				foundId = rs.Id
+			}
+		}
+	}
+	return foundId, nil
+}
+
+// filterSeparators are the sequences the API uses to split a filter into a field, operator, and value(s). A name
+// containing one of them cannot be sent in a filter as is.
+var filterSeparators = regexp.MustCompile(`==|<>|,`)
+
+// NameFilter builds a name filter for an index call which is safe for names containing filter syntax. The API has no
+// way to escape it, so such a name is filtered on just its longest part without any. Name filters are a partial match
+// anyway, so callers must always recheck the names of the resources returned. Only a name made up entirely of filter
+// syntax is rejected since there is nothing left to filter on.
+func NameFilter(name string) (string, error) {
+	value := ""
+	for _, part := range filterSeparators.Split(name, -1) {
+		if len(part) > len(value) {
+			value = part
+		}
+	}
+	if value == "" && name != "" {
+		return "", fmt.Errorf("'%s' cannot be used as a name filter since it is made up entirely of filter syntax", name)
+	}
+	return "name==" + value, nil
+}
+
+// Link returns the HREF of the link with the relation name, such as "self".
+func Link(links []map[string]string, name string) string {
+	href := ""
+	for _, l := range links {
+		if l["rel"] == name {
+			href = l["href"]
+		}
+	}
+	return href
+}
+
+// Finds a publication in the MultiCloud Marketplace.
+// Params:
+//   kind: one of RightScript, MultiCloudImage, ServerTemplate
+//   name: name of publication to search for
+//   revision: revision of the publication to search for
+//   matchers: Hash of string (field name) -> string (match value). additional matching criteria in case there are
+//     multiple publications with the same name and revision. Usually `Publisher` is used as a tie breaker.
+// Returns:
+//   Publication if found. nil if not found. An error if multiple publications are found.
+func (c *Client) FindPublication(kind string, name string, revision int, matchers map[string]string) (*cm15.Publication, error) {
+	client := c.API
+
+	pubLocator := client.PublicationLocator("/api/publications")
+
+	if name == "" {
+		return nil, fmt.Errorf("No Name given when looking up %s publication", kind)
+	}
+
+	filter, err := NameFilter(name)
+	if err != nil {
+		return nil, err
+	}
+	filters := []string{
+		filter,
+		"revision==" + fmt.Sprintf("%d", revision),
+	}
+	if c.Debug {
+		fmt.Fprintf(c.stdout(), "DEBUG: looking for publication with KIND:%s NAME:%s REVISION:%d MATCHERS:%v\n", kind, name, revision, matchers)
+	}
+	pubsUnfiltered, err := pubLocator.Index(rsapi.APIParams{"filter": filters})
+	if err != nil {
+		return nil, fmt.Errorf("Call to /api/publications failed: %s", err.Error())
+	}
+	var pubs []*cm15.Publication
+	for _, pub := range pubsUnfiltered {
+		// Recheck the name here, filter does a partial match and we need an exact one.
+		// Also make sure the type is correct
+		if pub.Name == name && kind == pub.ContentType {
+			// We may provide additional matchers to break ties, i.e. the Description/Publisher field. In any are supplied
+			// we make sure they all match.
+			matched_all_matchers := true
+			for fieldName, value := range matchers {
+				v := reflect.Indirect(reflect.ValueOf(pub)).FieldByName(fieldName)
+				if v.IsValid() {
+					if v.String() != value {
+						matched_all_matchers = false
+					}
+				}
+			}
+			if matched_all_matchers {
+				pubs = append(pubs, pub)
+			}
+		}
+	}
+
+	if len(pubs) == 0 {
+		return nil, nil
+	} else if len(pubs) == 2 {
+		fmt.Fprintf(c.stdout(), "Too many %s publications matching %s with revision %d\n", kind, name, revision)
+		for _, pub := range pubs {
+			pubHref := Link(pub.Links, "self")
+			fmt.Fprintf(c.stdout(), "  Publisher:%s Revision:%d Href:%s\n", pub.Publisher, pub.Revision, pubHref)
+		}
+		return nil, fmt.Errorf("Too many publications")
+	} else {
+		return pubs[0], nil
+	}
+}
+
+// Tags returns the tags of the resource at href.
+func (c *Client) Tags(href string) ([]string, error) {
+	var tags []string
+	tagsLoc := c.API.TagLocator("/api/tags/by_resource")
+	res, err := tagsLoc.ByResource([]string{href})
+	if err != nil {
+		return tags, err
+	}
+	if len(res) != 1 {
+		return tags, fmt.Errorf("Could not find tags for href %s", href)
+	}
+	tagset := res[0]["tags"].([]interface{})
+	for _, t := range tagset {
+		th := t.(map[string]interface{})
+		tags = append(tags, th["name"].(string))
+	}
+
+	return tags, nil
+}
@@ -0,0 +1,185 @@
+package rightscript
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Number of unchanged lines shown around each change in a unified diff
+const diffContext = 3
+
+type diffOp struct {
+	kind byte // ' ' for a line in both, '-' for a line only in the old text, '+' for a line only in the new text
+	line string
+	a, b int // index of the line in the old and new text, or of the next line when it is not in that text
+}
+
+// UnifiedDiff returns a unified diff of the lines of oldText and newText labelled with oldName and newName, or an empty
+// string when they are the same.
+func UnifiedDiff(oldName, newName string, oldText, newText []byte) string {
+	if bytes.Equal(oldText, newText) {
+		return ""
+	}
+	ops := diffLines(splitLines(oldText), splitLines(newText))
+
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "--- %s\n+++ %s\n", oldName, newName)
+	for start := 0; start < len(ops); {
+		// find the next change and extend the hunk until there are more than twice the context lines unchanged
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+		end, unchanged := start, 0
+		for index := start; index < len(ops) && unchanged <= 2*diffContext; index++ {
+			if ops[index].kind == ' ' {
+				unchanged++
+			} else {
+				unchanged, end = 0, index+1
+			}
+		}
+		first, last := start-diffContext, end+diffContext
+		if first < 0 {
+			first = 0
+		}
+		if last > len(ops) {
+			last = len(ops)
+		}
+
+		aCount, bCount := 0, 0
+		for _, op := range ops[first:last] {
+			if op.kind != '+' {
+				aCount++
+			}
+			if op.kind != '-' {
+				bCount++
+			}
+		}
+		fmt.Fprintf(&buffer, "@@ -%s +%s @@\n", hunkRange(ops[first].a, aCount), hunkRange(ops[first].b, bCount))
+		for _, op := range ops[first:last] {
+			fmt.Fprintf(&buffer, "%c%s\n", op.kind, op.line)
+		}
+		start = last
+	}
+	return buffer.String()
+}
+
+func hunkRange(index, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", index)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", index+1)
+	}
+	return fmt.Sprintf("%d,%d", index+1, count)
+}
+
+func splitLines(text []byte) []string {
+	if len(text) == 0 {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(string(text), "\n"), "\n")
+}
+
+// diffLines finds the smallest set of lines to remove and add with Myers' algorithm. The texts are split where the
+// middle snake of the shortest edit path lies and each side is worked on in turn, so it only needs memory in proportion
+// to the number of lines rather than to their product.
+func diffLines(a, b []string) []diffOp {
+	removed, added := make([]bool, len(a)), make([]bool, len(b))
+	markChanges(a, b, 0, len(a), 0, len(b), removed, added)
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && removed[i]:
+			ops = append(ops, diffOp{'-', a[i], i, j})
+			i++
+		case j < len(b) && added[j]:
+			ops = append(ops, diffOp{'+', b[j], i, j})
+			j++
+		default:
+			ops = append(ops, diffOp{' ', a[i], i, j})
+			i++
+			j++
+		}
+	}
+	return ops
+}
+
+// markChanges marks the lines of a[aLo:aHi] which are removed and the lines of b[bLo:bHi] which are added.
+func markChanges(a, b []string, aLo, aHi, bLo, bHi int, removed, added []bool) {
+	for aLo < aHi && bLo < bHi && a[aLo] == b[bLo] {
+		aLo++
+		bLo++
+	}
+	for aLo < aHi && bLo < bHi && a[aHi-1] == b[bHi-1] {
+		aHi--
+		bHi--
+	}
+	switch {
+	case aLo == aHi:
+		for ; bLo < bHi; bLo++ {
+			added[bLo] = true
+		}
+	case bLo == bHi:
+		for ; aLo < aHi; aLo++ {
+			removed[aLo] = true
+		}
+	default:
+		x, y, u, v := middleSnake(a[aLo:aHi], b[bLo:bHi])
+		markChanges(a, b, aLo, aLo+x, bLo, bLo+y, removed, added)
+		markChanges(a, b, aLo+u, aHi, bLo+v, bHi, removed, added)
+	}
+}
+
+// middleSnake returns where the middle snake of the shortest edit path from a to b starts, at a[x] and b[y], and where
+// it ends, at a[u] and b[v]. It follows the furthest reaching paths forward from the start and backward from the end
+// one edit at a time until they meet.
+func middleSnake(a, b []string) (x, y, u, v int) {
+	n, m := len(a), len(b)
+	delta := n - m
+	offset := (n+m+1)/2 + 1
+	// forward[offset+k] is how far along a the furthest path forward on diagonal k = x - y has got, and
+	// backward[offset+c] how far back from the end of a the furthest path backward on diagonal c = (n - x) - (m - y) has
+	forward := make([]int, 2*offset+1)
+	backward := make([]int, 2*offset+1)
+	for d := 0; ; d++ {
+		for k := -d; k <= d; k += 2 {
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y = x - k
+			u, v = x, y
+			for u < n && v < m && a[u] == b[v] {
+				u++
+				v++
+			}
+			forward[offset+k] = u
+			if c := delta - k; delta%2 != 0 && c >= -(d-1) && c <= d-1 && u+backward[offset+c] >= n {
+				return x, y, u, v
+			}
+		}
+		for c := -d; c <= d; c += 2 {
+			var back int
+			if c == -d || (c != d && backward[offset+c-1] < backward[offset+c+1]) {
+				back = backward[offset+c+1]
+			} else {
+				back = backward[offset+c-1] + 1
+			}
+			end := back
+			for end < n && end-c < m && a[n-1-end] == b[m-1-(end-c)] {
+				end++
+			}
+			backward[offset+c] = end
+			if k := delta - c; delta%2 == 0 && k >= -d && k <= d && forward[offset+k]+end >= n {
+				return n - end, m - (end - c), n - back, m - (back - c)
+			}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package rightscript_test
+
+import (
+	"fmt"
+	"strings"
+
+	. "github.com/rightscale/right_st/rightscript"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Unified diff", func() {
+	It("Is empty for the same text", func() {
+		Expect(UnifiedDiff("a", "b", []byte("one\ntwo\n"), []byte("one\ntwo\n"))).To(Equal(""))
+	})
+
+	It("Shows changed lines with context", func() {
+		oldText := []byte("1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n")
+		newText := []byte("1\n2\n3\n4\nfive\n6\n7\n8\n9\n10\n")
+		Expect(UnifiedDiff("old", "new", oldText, newText)).To(Equal(`--- old
++++ new
+@@ -2,7 +2,7 @@
+ 2
+ 3
+ 4
+-5
++five
+ 6
+ 7
+ 8
+`))
+	})
+
+	It("Splits changes far apart into hunks", func() {
+		oldText := []byte("a\n1\n2\n3\n4\n5\n6\n7\n8\nb\n")
+		newText := []byte("A\n1\n2\n3\n4\n5\n6\n7\n8\nB\n")
+		Expect(UnifiedDiff("old", "new", oldText, newText)).To(Equal(`--- old
++++ new
+@@ -1,4 +1,4 @@
+-a
++A
+ 1
+ 2
+ 3
+@@ -7,4 +7,4 @@
+ 6
+ 7
+ 8
+-b
++B
+`))
+	})
+
+	It("Diffs long texts", func() {
+		lines := make([]string, 100000)
+		for index := range lines {
+			lines[index] = fmt.Sprintf("echo %d", index)
+		}
+		oldText := []byte(strings.Join(lines, "\n") + "\n")
+		lines[50000] = "echo changed"
+		newText := []byte(strings.Join(lines, "\n") + "\n")
+		Expect(UnifiedDiff("old", "new", oldText, newText)).To(Equal(`--- old
++++ new
+@@ -49998,7 +49998,7 @@
+ echo 49997
+ echo 49998
+ echo 49999
+-echo 50000
++echo changed
+ echo 50001
+ echo 50002
+ echo 50003
+`))
+	})
+
+	It("Shows added lines to an empty text", func() {
+		Expect(UnifiedDiff("old", "new", []byte{}, []byte("one\n"))).To(Equal("--- old\n+++ new\n@@ -0,0 +1 @@\n+one\n"))
+	})
+})
@@ -2,13 +2,14 @@
 
 // Manager that downloads items cookbooks or attachments
 
-package main
+package rightscript
 
 import (
+	"crypto/md5"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net"
-	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -22,8 +23,9 @@ type downloadItem struct {
 	// try and download the file to the following locations. we allow multiple locations in case the first one is taken
 	locations    []string
 	md5          string
-	downloadedTo string // which of the locations did we use when we downloaded the file?
-	size         int64  // size if bytes that were downloaded
+	downloadedTo string      // which of the locations did we use when we downloaded the file?
+	size         int64       // size if bytes that were downloaded
+	mode         os.FileMode // file mode to give the downloaded file, 0 to use the default
 }
 
 // Limit concurrency of downloads
@@ -38,7 +40,7 @@ func init() {
 }
 
 // downloadManager download a bunch of "items", typically attachments or cookbooks
-func downloadManager(items []*downloadItem) error {
+func (c *Client) downloadManager(items []*downloadItem) error {
 	var err error
 	var size int64
 	var lock sync.Mutex // for both size and err
@@ -79,7 +81,7 @@ func downloadManager(items []*downloadItem) error {
 					return
 				}
 				var retry bool
-				retry, e = downloadOneItem(i)
+				retry, e = c.downloadOneItem(i)
 				if e == nil {
 					sumSize(i.size)
 					return
@@ -97,7 +99,7 @@ func downloadManager(items []*downloadItem) error {
 	}
 	wg.Wait()
 	dt := time.Since(t)
-	fmt.Printf("    Done with %d attachments: %dKB in %.1fs -> %.3fMB/s\n", len(items),
+	fmt.Fprintf(c.stdout(), "    Done with %d attachments: %dKB in %.1fs -> %.3fMB/s\n", len(items),
 		size/1024, float32(dt)/float32(time.Second),
 		float32(size)/1024/1024/(float32(dt)/float32(time.Second)))
 	return err
@@ -105,14 +107,14 @@ func downloadManager(items []*downloadItem) error {
 
 // downloadItem downloads an individual item to disk. It returns a boolean that
 // is true if an error occurred that is retryable
-func downloadOneItem(item *downloadItem) (bool, error) {
+func (c *Client) downloadOneItem(item *downloadItem) (bool, error) {
 	effectiveName := ""
 	for _, filename := range item.locations {
 		md5sum, err := fmd5sum(filename)
 		if err == nil {
 			// File already exists. If the md5sum matches, we're golden. else do nothing and try the next location
 			if item.md5 == md5sum {
-				fmt.Printf("    Skipping attachment '%s', already downloaded\n", filepath.Base(filename))
+				fmt.Fprintf(c.stdout(), "    Skipping attachment '%s', already downloaded\n", filepath.Base(filename))
 				item.downloadedTo = filename
 				return false, nil
 			}
@@ -134,20 +136,11 @@ func downloadOneItem(item *downloadItem) (bool, error) {
 		return false, fmt.Errorf("Erroring creating directory: %s", err.Error())
 	}
 
-	// Open the file
-	f, err := os.Create(effectiveName)
-	if err != nil {
-		return false, fmt.Errorf("Error creating: %s", err.Error())
-	}
-	defer f.Close()
-
 	// Do the download
 	startAt := time.Now()
-	fmt.Printf("    Downloading attachment '%s' to '%s'\n", filepath.Base(effectiveName), effectiveName)
-	resp, err := http.Get(item.url.String())
+	fmt.Fprintf(c.stdout(), "    Downloading attachment '%s' to '%s'\n", filepath.Base(effectiveName), effectiveName)
+	resp, err := c.HTTPGet(item.url.String())
 	if err != nil {
-		f.Close() // on Windows you cannot remove a file that has an open file handle
-		os.Remove(effectiveName)
 		retry := false
 		if netErr, ok := err.(net.Error); ok {
 			retry = netErr.Timeout() || netErr.Temporary()
@@ -156,19 +149,37 @@ func downloadOneItem(item *downloadItem) (bool, error) {
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
-		f.Close() // on Windows you cannot remove a file that has an open file handle
-		os.Remove(effectiveName)
 		return resp.StatusCode >= 500, fmt.Errorf("%s -- URL=%s", resp.Status, item.url.String())
 	}
-	// read the attachment body
-	size, err := io.Copy(f, resp.Body)
+	mode := item.mode
+	if mode == 0 {
+		mode = 0644
+	}
+	// read the attachment body into a temporary file so a partial or corrupt download never ends up at the location
+	var size int64
+	var mismatch error
+	err = WriteFileAtomic(effectiveName, mode, func(w io.Writer) error {
+		hash := md5.New()
+		var err error
+		size, err = io.Copy(io.MultiWriter(w, hash), resp.Body)
+		if err != nil {
+			return err
+		}
+		if digest := hex.EncodeToString(hash.Sum(nil)); item.md5 != "" && digest != item.md5 {
+			mismatch = fmt.Errorf("Downloaded attachment '%s' has md5 %s instead of %s -- URL=%s",
+				filepath.Base(effectiveName), digest, item.md5, item.url.String())
+			return mismatch
+		}
+		return nil
+	})
+	if mismatch != nil {
+		return false, mismatch
+	}
 	if err != nil {
-		f.Close() // on Windows you cannot remove a file that has an open file handle
-		os.Remove(effectiveName)
 		return true, fmt.Errorf("%s -- Reading %s", err.Error(), filepath.Base(effectiveName))
 	}
-	if *debug {
-		fmt.Printf("    %.1fKB in %.1fs for %s", float32(size)/1024,
+	if c.Debug {
+		fmt.Fprintf(c.stdout(), "    %.1fKB in %.1fs for %s", float32(size)/1024,
 			time.Since(startAt).Seconds(), filepath.Base(effectiveName))
 	}
 	item.size = size
@@ -0,0 +1,169 @@
+package rightscript
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/rightscale/rsc/rsapi"
+)
+
+// DownloadOptions controls how Download writes a RightScript and its attachments.
+type DownloadOptions struct {
+	NoAttachments bool        // Only download the script, not its attachments
+	FileMode      os.FileMode // Mode of the files written, 0 for the default
+	// Always put the attachments directly in the attachments directory next to the script, write canonical metadata,
+	// and convert CRLF line endings to LF (except in PowerShell scripts) so downloading again only changes what changed
+	// in RightScale
+	Canonical bool
+}
+
+// Download downloads the RightScript at href and its attachments and returns the file the script was written to.
+// When downloadTo is empty the script is written to the current directory with a name made from the name of the
+// RightScript, and when it is a directory it is written into it.
+func (c *Client) Download(href, downloadTo string, options DownloadOptions) (string, error) {
+	client := c.API
+	fileMode, canonical := options.FileMode, options.Canonical
+
+	attachmentsHref := fmt.Sprintf("%s/attachments", href)
+	rightscriptLocator := client.RightScriptLocator(href)
+	attachmentsLocator := client.RightScriptAttachmentLocator(attachmentsHref)
+
+	rightscript, err := rightscriptLocator.Show(rsapi.APIParams{"view": "inputs_2_0"})
+	if err != nil {
+		return "", fmt.Errorf("Could not find RightScript with href %s: %s", href, err.Error())
+	}
+	source, err := c.Source(rightscriptLocator)
+	if err != nil {
+		return "", fmt.Errorf("Could not get source for RightScript with href %s: %s", href, err.Error())
+	}
+	sourceMetadata, err := ParseRightScriptMetadata(bytes.NewReader(source))
+	if err != nil {
+		fmt.Fprintf(c.stdout(), "WARNING: Metadata in %s is malformed: %s\n", rightscript.Name, err.Error())
+	}
+
+	attachments, err := attachmentsLocator.Index(rsapi.APIParams{})
+	if err != nil {
+		return "", fmt.Errorf("Could not get attachments for RightScript from href %s: %s", attachmentsHref, err.Error())
+	}
+
+	guessedExtension := guessExtension(string(source))
+
+	if downloadTo == "" {
+		downloadTo = CleanFileName(rightscript.Name) + guessedExtension
+	} else if IsDirectory(downloadTo) {
+		downloadTo = filepath.Join(downloadTo, CleanFileName(rightscript.Name)+guessedExtension)
+	}
+	fmt.Fprintf(c.stdout(), "Downloading '%s' to '%s'\n", rightscript.Name, downloadTo)
+
+	for i, attachment := range attachments {
+		// API attachments are always just plain names without path information.
+		// SourceMetadata attachment names may have path components describing where
+		// to put the file on disk, thus are truthier, so we merge those in.
+		if sourceMetadata != nil {
+			for _, aSrc := range sourceMetadata.Attachments {
+				if path.Base(attachment.Filename) == path.Base(aSrc) {
+					attachments[i].Filename = aSrc
+				}
+			}
+		}
+	}
+	downloadItems := []*downloadItem{}
+	pathPrepend := filepath.Join(filepath.Dir(downloadTo), "attachments") + string(os.PathSeparator)
+	for _, attachment := range attachments {
+		var downloadLocations []string
+
+		if filepath.IsAbs(attachment.Filename) {
+			downloadLocations = []string{attachment.Filename}
+		} else {
+			// We have a primary filename and a backup filename to try in case there's
+			// a conflict. Conflicts will arise when you have multiple RightScripts
+			// attached to the same ServerTemplate with the same attachment name, i.e.
+			// something generic like 'config.xml'
+			downloadLocations = []string{
+				filepath.Join(pathPrepend, attachment.Filename),
+				filepath.Join(pathPrepend, CleanFileName(rightscript.Name), attachment.Filename),
+			}
+			if canonical {
+				downloadLocations = downloadLocations[:1]
+			}
+		}
+
+		downloadUrl, err := url.Parse(attachment.DownloadUrl)
+		if err != nil {
+			return "", fmt.Errorf("Could not parse URL of attachment: %s", err.Error())
+		}
+		downloadItem := downloadItem{
+			url:       *downloadUrl,
+			locations: downloadLocations,
+			md5:       attachment.Digest,
+			mode:      fileMode,
+		}
+		downloadItems = append(downloadItems, &downloadItem)
+	}
+	if options.NoAttachments {
+		fmt.Fprintln(c.stdout(), "Skipping attachments")
+	} else if len(downloadItems) == 0 {
+		fmt.Fprintln(c.stdout(), "No attachments to download")
+	} else {
+		fmt.Fprintf(c.stdout(), "Download %d attachments:\n", len(downloadItems))
+		err = c.downloadManager(downloadItems)
+		if err != nil {
+			return "", fmt.Errorf("Failed to download all attachments: %s", err.Error())
+		}
+		for _, d := range downloadItems {
+			for i, attachment := range attachments {
+				if filepath.Base(attachment.Filename) == filepath.Base(d.downloadedTo) {
+					attachments[i].Filename = strings.Replace(d.downloadedTo, pathPrepend, ``, 1)
+				}
+			}
+		}
+	}
+
+	inputs := InputMap{}
+	for _, input := range rightscript.Inputs {
+		inputs = append(inputs, JsonMapToInput(input))
+	}
+	attachmentNames := make([]string, len(attachments))
+	for i, a := range attachments {
+		attachmentNames[i] = a.Filename
+	}
+	apiMetadata := RightScriptMetadata{
+		Name:        rightscript.Name,
+		Description: RemoveCarriageReturns(rightscript.Description),
+		Packages:    rightscript.Packages,
+		Inputs:      inputs,
+		Attachments: attachmentNames,
+	}
+	if canonical {
+		apiMetadata = CanonicalMetadata(apiMetadata)
+		source, _ = NormalizeEOL(downloadTo, source)
+	}
+
+	// Re-running it through ScaffoldBuffer has the benefit of cleaning up any errors in how
+	// the inputs are described. Also any attachments added or removed manually will be
+	// handled in that the builtin metadata will reflect whats on disk
+	scaffoldedSourceBytes, err := ScaffoldBuffer(source, apiMetadata, "", "", false)
+	if err == nil {
+		if bytes.Compare(scaffoldedSourceBytes, source) != 0 {
+			fmt.Fprintln(c.stdout(), "Automatically inserted RightScript metadata.")
+		}
+	} else {
+		fmt.Fprintf(c.stdout(), "Downloaded script as is. An error occurred generating metadata to insert into the RightScript: %s", err.Error())
+		scaffoldedSourceBytes = source
+	}
+	err = WriteFileAtomic(downloadTo, fileMode, func(w io.Writer) error {
+		_, err := w.Write(scaffoldedSourceBytes)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return downloadTo, nil
+}
@@ -0,0 +1,119 @@
+package rightscript
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// Characters of Windows-1252 from 0x80 to 0x9f where it differs from ISO-8859-1, the undefined ones are left as is
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8d, 'Ž', 0x8f,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9d, 'ž', 'Ÿ',
+}
+
+// Encodings which script sources can be converted from with --source-encoding
+var sourceDecoders = map[string]func([]byte) ([]byte, error){
+	"utf-8":        func(source []byte) ([]byte, error) { return source, nil },
+	"iso-8859-1":   decodeLatin1,
+	"latin1":       decodeLatin1,
+	"windows-1252": decodeWindows1252,
+	"utf-16le":     func(source []byte) ([]byte, error) { return decodeUTF16(source, false) },
+	"utf-16be":     func(source []byte) ([]byte, error) { return decodeUTF16(source, true) },
+}
+
+// SourceEncodings lists the encodings accepted by DecodeSource.
+func SourceEncodings() []string {
+	encodings := make([]string, 0, len(sourceDecoders))
+	for encoding := range sourceDecoders {
+		encodings = append(encodings, encoding)
+	}
+	sort.Strings(encodings)
+	return encodings
+}
+
+// DecodeSource converts the source of a script from encoding, one of SourceEncodings or empty for UTF-8, to UTF-8.
+// A leading UTF-8 byte order mark is removed except from PowerShell scripts, where Windows PowerShell relies on it to
+// read the script as UTF-8. The boolean result is true if a byte order mark was removed.
+func DecodeSource(file string, source []byte, encoding string) ([]byte, bool, error) {
+	if encoding == "" {
+		encoding = "utf-8"
+	}
+	decode, ok := sourceDecoders[strings.ToLower(encoding)]
+	if !ok {
+		return nil, false, fmt.Errorf("Unknown source encoding %s, must be one of: %s", encoding, strings.Join(SourceEncodings(), ", "))
+	}
+	source, err := decode(source)
+	if err != nil {
+		return nil, false, err
+	}
+	if !bytes.HasPrefix(source, utf8BOM) || isPowerShell(file, source[len(utf8BOM):]) {
+		return source, false, nil
+	}
+	return source[len(utf8BOM):], true, nil
+}
+
+// EncodingWarnings describes a byte order mark or invalid UTF-8 in the source of a script.
+func EncodingWarnings(file string, source []byte) []string {
+	warnings := []string{}
+	if bytes.HasPrefix(source, utf8BOM) && !isPowerShell(file, source[len(utf8BOM):]) {
+		warnings = append(warnings, "Source starts with a UTF-8 byte order mark which breaks the shebang line, it is removed on upload")
+	}
+	if !utf8.Valid(source) {
+		line := 1 + bytes.Count(source[:invalidUTF8Offset(source)], []byte("\n"))
+		warnings = append(warnings, fmt.Sprintf("Source is not valid UTF-8 from line %d, save it as UTF-8 or upload it with --source-encoding", line))
+	}
+	return warnings
+}
+
+func invalidUTF8Offset(source []byte) int {
+	for offset := 0; offset < len(source); {
+		r, size := utf8.DecodeRune(source[offset:])
+		if r == utf8.RuneError && size == 1 {
+			return offset
+		}
+		offset += size
+	}
+	return len(source)
+}
+
+func decodeLatin1(source []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	for _, b := range source {
+		buffer.WriteRune(rune(b))
+	}
+	return buffer.Bytes(), nil
+}
+
+func decodeWindows1252(source []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	for _, b := range source {
+		if b >= 0x80 && b <= 0x9f {
+			buffer.WriteRune(windows1252[b-0x80])
+		} else {
+			buffer.WriteRune(rune(b))
+		}
+	}
+	return buffer.Bytes(), nil
+}
+
+func decodeUTF16(source []byte, bigEndian bool) ([]byte, error) {
+	if len(source)%2 != 0 {
+		return nil, fmt.Errorf("Source has an odd number of bytes so it is not UTF-16")
+	}
+	units := make([]uint16, len(source)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(source[2*i])<<8 | uint16(source[2*i+1])
+		} else {
+			units[i] = uint16(source[2*i+1])<<8 | uint16(source[2*i])
+		}
+	}
+	// a UTF-16 byte order mark becomes a UTF-8 one which is then removed like any other
+	return []byte(string(utf16.Decode(units))), nil
+}
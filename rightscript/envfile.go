@@ -0,0 +1,103 @@
+package rightscript
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Names dotenv files may give variables, the same as RightScript inputs can have
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// LoadEnvFile reads the variables of a dotenv file, see ReadEnvFile.
+func LoadEnvFile(file string) (map[string]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	env, err := ReadEnvFile(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err.Error())
+	}
+	return env, nil
+}
+
+// ReadEnvFile reads variables from a dotenv file of NAME=value lines. Blank lines and lines starting with # are
+// skipped, a leading "export " is allowed, and values may be single quoted to be taken literally or double quoted to
+// have escapes such as \n. A # after whitespace starts a comment in unquoted values.
+func ReadEnvFile(r io.Reader) (map[string]string, error) {
+	env := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+		parts := strings.SplitN(line, "=", 2)
+		name := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || !envNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("line %d: expected NAME=value", number)
+		}
+		value := strings.TrimSpace(parts[1])
+		switch {
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid quoted value for %s", number, name)
+			}
+			value = unquoted
+		default:
+			if index := strings.Index(value, " #"); index >= 0 {
+				value = strings.TrimSpace(value[:index])
+			}
+		}
+		env[name] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// MissingFromEnv returns the names of the inputs which have no variable in env, split into required inputs without a
+// default, which could not get a value when the script runs, and the others.
+func (inputs InputMap) MissingFromEnv(env map[string]string) (required, optional []string) {
+	for _, input := range inputs {
+		if _, ok := env[input.Name]; ok {
+			continue
+		}
+		if input.Required && input.Default == nil {
+			required = append(required, input.Name)
+		} else {
+			optional = append(optional, input.Name)
+		}
+	}
+	return required, optional
+}
+
+// UndeclaredEnv returns the sorted names of the variables in env which none of the scripts declare as inputs.
+func UndeclaredEnv(env map[string]string, scripts []*RightScript) []string {
+	declared := make(map[string]bool)
+	for _, script := range scripts {
+		for _, input := range script.Metadata.Inputs {
+			declared[input.Name] = true
+		}
+	}
+	undeclared := []string{}
+	for name := range env {
+		if !declared[name] {
+			undeclared = append(undeclared, name)
+		}
+	}
+	sort.Strings(undeclared)
+	return undeclared
+}
@@ -0,0 +1,50 @@
+package rightscript_test
+
+import (
+	"strings"
+
+	. "github.com/rightscale/right_st/rightscript"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Env file", func() {
+	It("Reads variables", func() {
+		env, err := ReadEnvFile(strings.NewReader(`# test values
+APP_NAME=shop
+export APP_PORT = 8080 # the default port
+
+GREETING="hello\nworld"
+LITERAL='a "b" \n'
+EMPTY=
+`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(env).To(Equal(map[string]string{
+			"APP_NAME": "shop",
+			"APP_PORT": "8080",
+			"GREETING": "hello\nworld",
+			"LITERAL":  `a "b" \n`,
+			"EMPTY":    "",
+		}))
+	})
+
+	It("Fails on lines which are not variables", func() {
+		_, err := ReadEnvFile(strings.NewReader("APP_NAME=shop\nnot a variable\n"))
+		Expect(err).To(MatchError("line 2: expected NAME=value"))
+	})
+
+	It("Finds inputs missing from the env file and variables which are not inputs", func() {
+		env := map[string]string{"APP_NAME": "shop", "UNUSED": "x"}
+		inputs := InputMap{
+			{Name: "APP_NAME", Required: true},
+			{Name: "APP_PORT", Required: true},
+			{Name: "APP_DEBUG"},
+		}
+		required, optional := inputs.MissingFromEnv(env)
+		Expect(required).To(Equal([]string{"APP_PORT"}))
+		Expect(optional).To(Equal([]string{"APP_DEBUG"}))
+		Expect(UndeclaredEnv(env, []*RightScript{{Metadata: RightScriptMetadata{Inputs: inputs}}})).To(Equal(
+			[]string{"UNUSED"}))
+	})
+})
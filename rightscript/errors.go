@@ -0,0 +1,103 @@
+package rightscript
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NotFoundError is returned when looking up a resource by name matches nothing.
+type NotFoundError struct {
+	Msg string
+}
+
+func (e *NotFoundError) Error() string {
+	return e.Msg
+}
+
+// Response headers which may hold the identifier RightScale support needs to find a request, in order of preference
+var requestIdHeaders = []string{"X-Request-Uuid", "X-Request-Id", "X-Trace-Id"}
+
+// ResponseError is an unsuccessful response to a request made without rsc, carrying the status and request id so
+// they can be handed to RightScale support.
+type ResponseError struct {
+	Status     string
+	StatusCode int
+	RequestId  string
+	Body       string
+}
+
+// NewResponseError builds the error for resp, whose body has already been read.
+func NewResponseError(resp *http.Response, body []byte) *ResponseError {
+	e := &ResponseError{Status: resp.Status, StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	for _, header := range requestIdHeaders {
+		if id := resp.Header.Get(header); id != "" {
+			e.RequestId = id
+			break
+		}
+	}
+	return e
+}
+
+func (e *ResponseError) Error() string {
+	msg := "invalid response " + e.Status
+	if e.RequestId != "" {
+		msg += " (request id " + e.RequestId + ")"
+	}
+	return msg + ": " + e.Body
+}
+
+// rsc reports an unsuccessful response from the API as "invalid response <status>: <body>" and one from the OAuth token
+// exchange as "Authentication failed: <status>", so the status code is right after either
+var responseStatusMatcher = regexp.MustCompile(`(?:invalid response|Authentication failed:) ([1-5]\d\d)\b`)
+
+// ResponseStatusCode returns the HTTP status code of the unsuccessful response an API call failed with, or 0 when it
+// failed without a response, e.g. with a network error. Only the status rsc puts at the start of its errors is looked
+// at, never numbers elsewhere in the message such as IDs or names.
+func ResponseStatusCode(err error) int {
+	if respErr, ok := err.(*ResponseError); ok {
+		return respErr.StatusCode
+	}
+	if submatches := responseStatusMatcher.FindStringSubmatch(err.Error()); submatches != nil {
+		code, _ := strconv.Atoi(submatches[1])
+		return code
+	}
+	return 0
+}
+
+// IsTransientError returns whether an API call failed in a way which may well succeed when tried again: a network
+// timeout or temporary network error, a 5xx response from the server, or a 429 response when rate limited.
+func IsTransientError(err error) bool {
+	if netErr, ok := err.(net.Error); ok {
+		return netErr.Timeout() || netErr.Temporary()
+	}
+	code := ResponseStatusCode(err)
+	return code >= 500 || code == http.StatusTooManyRequests
+}
+
+// Retry calls f until it succeeds, fails with an error which is not transient, or has been called attempts times,
+// waiting delay before the first retry and twice as long before each one after that. Waiting stops early when ctx is
+// done. explain, unless it is nil, is told about each failed attempt. The error from the last call is returned.
+func Retry(ctx context.Context, attempts int, delay time.Duration, explain func(format string, a ...interface{}), f func() error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = f(); err == nil || attempt >= attempts || !IsTransientError(err) {
+			return err
+		}
+		if explain != nil {
+			explain("Attempt %d of %d failed with %s, retrying in %s", attempt, attempts, err.Error(), delay)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+		delay *= 2
+	}
+}
@@ -0,0 +1,84 @@
+package rightscript_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	. "github.com/rightscale/right_st/rightscript"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Retry", func() {
+	It("Stops waiting to retry when the context is cancelled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		explained := []string{}
+		explain := func(format string, a ...interface{}) {
+			explained = append(explained, format)
+		}
+
+		calls := 0
+		err := Retry(ctx, 3, time.Hour, explain, func() error {
+			calls++
+			return errors.New("invalid response 503 Service Unavailable")
+		})
+		Expect(err).To(MatchError("invalid response 503 Service Unavailable"))
+		Expect(calls).To(Equal(1))
+		Expect(explained).To(HaveLen(1))
+	})
+})
+
+var _ = Describe("Transient error", func() {
+	It("Does not retry errors which only mention a number which looks like a server error", func() {
+		Expect(IsTransientError(errors.New("Could not find RightScript with href /api/right_scripts/503: " +
+			"invalid response 404 Not Found: Not found"))).To(BeFalse())
+		Expect(IsTransientError(errors.New("Could not create RightScript 'Install 429 patch': " +
+			"invalid response 422 Unprocessable Entity: Name has already been taken"))).To(BeFalse())
+	})
+
+	It("Retries server errors with a request id which looks like another status", func() {
+		resp := &http.Response{Status: "500 Internal Server Error", StatusCode: 500, Header: http.Header{}}
+		resp.Header.Set("X-Request-Uuid", "403")
+		Expect(IsTransientError(NewResponseError(resp, nil))).To(BeTrue())
+	})
+
+	It("Treats rate limiting as transient", func() {
+		resp := &http.Response{Status: "429 Too Many Requests", StatusCode: 429, Header: http.Header{}}
+		Expect(IsTransientError(NewResponseError(resp, nil))).To(BeTrue())
+	})
+})
+
+var _ = Describe("Response error", func() {
+	It("Includes the status, request id, and body", func() {
+		resp := &http.Response{Status: "422 Unprocessable Entity", StatusCode: 422, Header: http.Header{}}
+		resp.Header.Set("X-Request-Uuid", "6b3a2c71f0a84b9d")
+		err := NewResponseError(resp, []byte("Name has already been taken\n"))
+		Expect(err).To(MatchError("invalid response 422 Unprocessable Entity (request id 6b3a2c71f0a84b9d): Name has already been taken"))
+		Expect(err.RequestId).To(Equal("6b3a2c71f0a84b9d"))
+	})
+
+	It("Leaves out a missing request id", func() {
+		resp := &http.Response{Status: "404 Not Found", StatusCode: 404, Header: http.Header{}}
+		Expect(NewResponseError(resp, []byte("Not found"))).To(MatchError("invalid response 404 Not Found: Not found"))
+	})
+})
+
+var _ = Describe("Response status code", func() {
+	It("Takes the status code of a response error", func() {
+		resp := &http.Response{Status: "403 Forbidden", StatusCode: 403, Header: http.Header{}}
+		Expect(ResponseStatusCode(NewResponseError(resp, []byte("Permission denied")))).To(Equal(403))
+	})
+
+	It("Takes the status code of an rsc error wrapped in another message", func() {
+		Expect(ResponseStatusCode(errors.New("Could not list RightScripts: invalid response 502 Bad Gateway"))).To(Equal(502))
+		Expect(ResponseStatusCode(errors.New("Authentication failed: 401 Unauthorized"))).To(Equal(401))
+	})
+
+	It("Returns 0 for errors without a response", func() {
+		Expect(ResponseStatusCode(errors.New("dial tcp: lookup us-3.rightscale.com: no such host"))).To(BeZero())
+	})
+})
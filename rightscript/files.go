@@ -0,0 +1,85 @@
+package rightscript
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+func fmd5sum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	return Md5sum(file)
+}
+
+// Md5sum returns the hex encoded md5 digest of everything read from data.
+func Md5sum(data io.Reader) (string, error) {
+	hash := md5.New()
+
+	_, err := io.Copy(hash, data)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Allows p{L} is a UTF8 equivalent of \w which will allow should allow for
+// non ascii words
+var disallowedFileChars = regexp.MustCompile(`[^\p{L}0-9_-]+`)
+
+// CleanFileName makes a file name out of the name of a resource by replacing everything but letters, digits,
+// underscores, and dashes.
+func CleanFileName(file string) string {
+	s := disallowedFileChars.ReplaceAllString(file, "_") // KISS hopefully
+	s = strings.Trim(s, "_")                             // axe trailing _ from ) type endings
+	s = strings.Replace(s, "_-_", "-", -1)               // space dash space comes up quite a bit
+	return s
+}
+
+// IsDirectory returns whether path is an existing directory.
+func IsDirectory(path string) bool {
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return fileInfo.IsDir()
+}
+
+// WriteFileAtomic creates filename with the content produced by write. The content is written to a temporary file in the
+// same directory which is only renamed into place once write has succeeded, so an interrupted or failed write never
+// leaves a truncated file behind or clobbers a good existing one.
+func WriteFileAtomic(filename string, mode os.FileMode, write func(w io.Writer) error) error {
+	f, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".")
+	if err != nil {
+		return err
+	}
+	tempName := f.Name()
+	err = write(f)
+	if err == nil {
+		err = f.Chmod(mode)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempName, filename)
+	}
+	if err != nil {
+		os.Remove(tempName)
+	}
+	return err
+}
+
+// Carriage returns get inserted by the API and mess up formatting of the YAML file.
+func RemoveCarriageReturns(s string) string {
+	return strings.Replace(s, "\r", "", -1)
+}
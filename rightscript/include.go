@@ -0,0 +1,122 @@
+package rightscript
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-yaml/yaml"
+)
+
+// Includes can include other files, but not more than this deep so an include cycle fails instead of recursing
+// forever
+const maxIncludeDepth = 10
+
+// includeDirective matches a line of metadata YAML which is replaced by the content of another file. The directive is
+// either on its own, where the included lines get the same indentation, or the value of a key, where they are indented
+// below the key.
+var includeDirective = regexp.MustCompile(`^(\s*)(?:([^\s#][^#]*?:)\s+)?!include\s+(\S+)\s*$`)
+
+// lineOrigin is where a line of metadata YAML came from, Line counting from 1. File is empty for lines of the script or
+// sidecar file itself.
+type lineOrigin struct {
+	File string
+	Line int
+}
+
+// expandIncludes replaces every !include directive in the metadata YAML in data with the content of the file it names,
+// relative to dir. origins has where each line of data came from; where each line of the result came from is returned
+// too, along with the paths of all files included.
+func expandIncludes(data []byte, dir string, origins []lineOrigin, depth int) ([]byte, []lineOrigin, []string, error) {
+	var buffer bytes.Buffer
+	expandedOrigins := []lineOrigin{}
+	includes := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for index := 0; scanner.Scan(); index++ {
+		line := scanner.Text()
+		origin := origins[index]
+		submatches := includeDirective.FindStringSubmatch(line)
+		if submatches == nil {
+			buffer.WriteString(line + "\n")
+			expandedOrigins = append(expandedOrigins, origin)
+			continue
+		}
+
+		indent, key, file := submatches[1], submatches[2], submatches[3]
+		if depth >= maxIncludeDepth {
+			return nil, nil, nil, fmt.Errorf("%s: includes nested more than %d deep including %s", origin, maxIncludeDepth, file)
+		}
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		included, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("%s: could not include %s: %s", origin, file, err.Error())
+		}
+		includedOrigins := []lineOrigin{}
+		for number := 1; number <= bytes.Count(included, []byte("\n"))+1; number++ {
+			includedOrigins = append(includedOrigins, lineOrigin{File: file, Line: number})
+		}
+		included, includedOrigins, nested, err := expandIncludes(included, filepath.Dir(file), includedOrigins, depth+1)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		includes = append(includes, file)
+		includes = append(includes, nested...)
+		if len(included) == 0 {
+			continue
+		}
+
+		if key != "" {
+			buffer.WriteString(indent + key + "\n")
+			expandedOrigins = append(expandedOrigins, origin)
+			indent += "  "
+		}
+		for i, includedLine := range strings.Split(strings.TrimSuffix(string(included), "\n"), "\n") {
+			if strings.TrimSpace(includedLine) == "---" || strings.TrimSpace(includedLine) == "..." {
+				includedLine = ""
+			}
+			buffer.WriteString(indent + includedLine + "\n")
+			expandedOrigins = append(expandedOrigins, includedOrigins[i])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, nil, err
+	}
+	return buffer.Bytes(), expandedOrigins, includes, nil
+}
+
+func (origin lineOrigin) String() string {
+	if origin.File == "" {
+		return fmt.Sprintf("line %d", origin.Line)
+	}
+	return fmt.Sprintf("line %d of %s", origin.Line, origin.File)
+}
+
+// relocateYAMLError rewrites the line numbers in an error from parsing the YAML of metadata with includes expanded
+// into the file and line each line came from. YAML errors count lines from 1 like origins do, so line 1 is origins[0].
+func relocateYAMLError(err error, origins []lineOrigin) error {
+	replace := func(line string) string {
+		submatches := yamlLineError.FindStringSubmatch(line)
+		number, _ := strconv.ParseUint(submatches[2], 10, 0)
+		if number >= 1 && int(number) <= len(origins) {
+			return submatches[1] + origins[number-1].String() + ":"
+		}
+		return line
+	}
+
+	switch yamlErr := err.(type) {
+	case *yaml.TypeError:
+		for index, lineError := range yamlErr.Errors {
+			yamlErr.Errors[index] = yamlLineError.ReplaceAllStringFunc(lineError, replace)
+		}
+		return yamlErr
+	default:
+		return fmt.Errorf("%s", yamlLineError.ReplaceAllStringFunc(err.Error(), replace))
+	}
+}
@@ -0,0 +1,153 @@
+package rightscript
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	shellAssignment     = regexp.MustCompile(`^\s*(?:export\s+|local\s+|readonly\s+|declare\s+(?:-\w+\s+)*)?([A-Z][A-Z0-9_]*)(?:\[[^]]*\])?\+?=`)
+	shellLoop           = regexp.MustCompile(`^\s*for\s+([A-Z][A-Z0-9_]*)\s+in\b`)
+	shellRead           = regexp.MustCompile(`^\s*read\s+(?:-\w+\s+)*((?:[A-Z][A-Z0-9_]*\s*)+)`)
+	blockCommentEndLine = regexp.MustCompile(`#>\s*$`)
+)
+
+// LintIssue is a style problem found in the source of a RightScript.
+type LintIssue struct {
+	Line    int
+	Message string
+}
+
+// LintRightScript checks the source of the script at path for a missing shebang, trailing whitespace, indentation
+// mixing tabs and spaces, and environment variables which look like inputs but are not declared in the metadata.
+func LintRightScript(path string) ([]LintIssue, error) {
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := ParseRightScriptMetadataIn(bytes.NewReader(source), filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	if sidecar, err := os.Open(path + SidecarSuffix); err == nil {
+		metadata, err = ParseRightScriptSidecar(sidecar, filepath.Dir(path))
+		sidecar.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path+SidecarSuffix, err.Error())
+		}
+	}
+
+	declared := make(map[string]bool)
+	if metadata != nil {
+		for _, input := range metadata.Inputs {
+			declared[input.Name] = true
+		}
+	}
+
+	variable := shellVariable
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".rb":
+		variable = rubyVariable
+	case ".pl":
+		variable = perlVariable
+	case ".ps1", ".psm1", ".psd1":
+		variable = powershellVariable
+	}
+
+	issues := []LintIssue{}
+	lines := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(source))
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimSuffix(scanner.Text(), "\r"))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(lines) > 0 {
+		if shebang.MatchString(lines[0]) {
+			switch {
+			case strings.Contains(lines[0], "ruby"):
+				variable = rubyVariable
+			case strings.Contains(lines[0], "perl"):
+				variable = perlVariable
+			}
+		} else if !isPowerShell(path, source) {
+			issues = append(issues, LintIssue{1, "Missing shebang line, e.g. #!/bin/bash"})
+		}
+	}
+
+	// variables the script sets itself are not inputs
+	if variable == shellVariable {
+		for _, line := range lines {
+			for _, re := range []*regexp.Regexp{shellAssignment, shellLoop} {
+				if submatches := re.FindStringSubmatch(line); submatches != nil {
+					declared[submatches[1]] = true
+				}
+			}
+			if submatches := shellRead.FindStringSubmatch(line); submatches != nil {
+				for _, name := range strings.Fields(submatches[1]) {
+					declared[name] = true
+				}
+			}
+		}
+	}
+
+	indentation := "" // the first indentation character seen, tab or space
+	reported := make(map[string]bool)
+	inBlockComment := false
+	for index, line := range lines {
+		number := index + 1
+		if strings.TrimRight(line, " \t") != line {
+			issues = append(issues, LintIssue{number, "Trailing whitespace"})
+		}
+
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		switch {
+		case indent == line:
+			// blank lines are only checked for trailing whitespace
+		case strings.Contains(indent, " ") && strings.Contains(indent, "\t"):
+			issues = append(issues, LintIssue{number, "Indentation mixes tabs and spaces"})
+		case indent == "":
+		case indentation == "":
+			indentation = indent[:1]
+		case indent[:1] != indentation:
+			issues = append(issues, LintIssue{number, fmt.Sprintf("Indented with %s but earlier lines are indented with %s",
+				indentationName(indent[:1]), indentationName(indentation))})
+		}
+
+		// comments, including the metadata, do not use inputs
+		if blockCommentStart.MatchString(line) {
+			inBlockComment = true
+		}
+		if inBlockComment {
+			inBlockComment = !blockCommentEndLine.MatchString(line)
+			continue
+		}
+		if comment.MatchString(line) {
+			continue
+		}
+		for _, submatches := range variable.FindAllStringSubmatch(line, -1) {
+			name := submatches[1]
+			if declared[name] || reported[name] || ignoreVariables.MatchString(name) {
+				continue
+			}
+			reported[name] = true
+			issues = append(issues, LintIssue{number, fmt.Sprintf("Input %s is not declared in the metadata", name)})
+		}
+	}
+	return issues, nil
+}
+
+func indentationName(indent string) string {
+	if indent == "\t" {
+		return "tabs"
+	}
+	return "spaces"
+}
@@ -0,0 +1,77 @@
+package rightscript_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/rightscale/right_st/rightscript"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Lint", func() {
+	var tempDir string
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = ioutil.TempDir("", "lint")
+		if err != nil {
+			panic(err)
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tempDir)
+	})
+
+	lint := func(name, source string) []LintIssue {
+		script := filepath.Join(tempDir, name)
+		if err := ioutil.WriteFile(script, []byte(source), 0644); err != nil {
+			panic(err)
+		}
+		issues, err := LintRightScript(script)
+		Expect(err).NotTo(HaveOccurred())
+		return issues
+	}
+
+	It("Finds nothing wrong with a clean script", func() {
+		Expect(lint("clean.sh", `#!/bin/bash
+# ---
+# RightScript Name: Clean
+# Inputs:
+#   GREETING:
+#     Input Type: single
+# Attachments: []
+# ...
+
+NAME=world
+if [ -n "$GREETING" ]; then
+  echo "$GREETING $NAME in $HOME"
+fi
+`)).To(BeEmpty())
+	})
+
+	It("Reports a missing shebang", func() {
+		Expect(lint("noshebang.sh", "echo hi\n")).To(Equal([]LintIssue{{1, "Missing shebang line, e.g. #!/bin/bash"}}))
+	})
+
+	It("Does not require a shebang for PowerShell scripts", func() {
+		Expect(lint("script.ps1", "Write-Output hi\n")).To(BeEmpty())
+	})
+
+	It("Reports trailing whitespace and mixed indentation", func() {
+		Expect(lint("style.sh", "#!/bin/bash\nif true; then \n  echo a\n\techo b\n \techo c\nfi\n")).To(Equal([]LintIssue{
+			{2, "Trailing whitespace"},
+			{4, "Indented with tabs but earlier lines are indented with spaces"},
+			{5, "Indentation mixes tabs and spaces"},
+		}))
+	})
+
+	It("Reports undeclared inputs once", func() {
+		Expect(lint("inputs.sh", "#!/bin/bash\n# uses $IN_COMMENT\necho $UNDECLARED ${UNDECLARED}\n")).To(Equal([]LintIssue{
+			{3, "Input UNDECLARED is not declared in the metadata"},
+		}))
+	})
+})
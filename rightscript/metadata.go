@@ -0,0 +1,635 @@
+package rightscript
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-yaml/yaml"
+)
+
+const (
+	Single InputType = iota
+	Array
+)
+
+// PowerShell style block comments can contain metadata as plain YAML between <# and #>
+const blockComment = "<#"
+
+var (
+	comment       = regexp.MustCompile(`^\s*(?:#|//|--)\s?(.*)$`)
+	metadataStart = regexp.MustCompile(`^\s*(#|//|--)\s?(\s*-{3}\s*)$`)
+	metadataEnd   = regexp.MustCompile(`^\s*(?:#|//|--)\s?(\s*\.{3}\s*)$`)
+	yamlLineError = regexp.MustCompile(`^(yaml: )?line (\d+):`)
+
+	blockCommentStart  = regexp.MustCompile(`^\s*<#\s*$`)
+	blockCommentEnd    = regexp.MustCompile(`^\s*#>\s*$`)
+	blockMetadataStart = regexp.MustCompile(`^(\s*-{3}\s*)$`)
+	blockMetadataEnd   = regexp.MustCompile(`^(\s*\.{3}\s*)$`)
+)
+
+// The order of the fields is the order of the keys when metadata is written
+type RightScriptMetadata struct {
+	Name        string   `yaml:"RightScript Name"`
+	Description string   `yaml:"Description,omitempty"`
+	Inputs      InputMap `yaml:"Inputs"`
+	Attachments []string `yaml:"Attachments"`
+	Packages    string   `yaml:"Packages,omitempty"`
+	Comment     string   `yaml:"-"`
+	Sidecar     string   `yaml:"-"` // Path of the sidecar file the metadata was read from instead of the script
+	Includes    []string `yaml:"-"` // Paths of the files included with !include
+}
+
+// SidecarSuffix is appended to the path of a script to get the path of a sidecar file holding its metadata as plain
+// YAML, e.g. script.sh.meta.yml for script.sh, so the script itself can be kept free of the metadata comment.
+const SidecarSuffix = ".meta.yml"
+
+// ParseRightScriptSidecar parses the metadata of a RightScript from a sidecar file, which holds the same YAML as the
+// metadata comment without any comment characters. Files it includes are relative to dir.
+func ParseRightScriptSidecar(sidecar io.Reader, dir string) (*RightScriptMetadata, error) {
+	data, err := ioutil.ReadAll(sidecar)
+	if err != nil {
+		return nil, err
+	}
+	origins := make([]lineOrigin, bytes.Count(data, []byte("\n"))+1)
+	for index := range origins {
+		origins[index].Line = index + 1
+	}
+	var metadata RightScriptMetadata
+	data, origins, metadata.Includes, err = expandIncludes(data, dir, origins, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, &metadata); err != nil {
+		return &metadata, relocateYAMLError(err, origins)
+	}
+	return &metadata, nil
+}
+
+// DropSidecars removes the sidecar files of scripts which are also in files so they are not treated as scripts
+// themselves.
+func DropSidecars(files []string) []string {
+	scripts := make(map[string]bool, len(files))
+	for _, file := range files {
+		scripts[file] = true
+	}
+	kept := make([]string, 0, len(files))
+	for _, file := range files {
+		if strings.HasSuffix(file, SidecarSuffix) && scripts[strings.TrimSuffix(file, SidecarSuffix)] {
+			continue
+		}
+		kept = append(kept, file)
+	}
+	return kept
+}
+
+type InputMetadata struct {
+	Name           string        `yaml:"-"`
+	Category       string        `yaml:"Category"`
+	Description    string        `yaml:"Description,omitempty"`
+	InputType      InputType     `yaml:"Input Type"`
+	Required       bool          `yaml:"Required"`
+	Advanced       bool          `yaml:"Advanced"`
+	Default        *InputValue   `yaml:"Default,omitempty"`
+	PossibleValues []*InputValue `yaml:"Possible Values,omitempty"`
+}
+
+// We use an array internally but serialize to a YAML map by performing transformations on this.
+// See UnmarshalYAML/MarshalYAML functions for details
+type InputMap []InputMetadata
+
+type InputType int
+
+type InputValue struct {
+	Type  string
+	Value string
+}
+
+// ParseRightScriptMetadata parses the metadata comment of a script, resolving any !include directives relative to the
+// current directory.
+func ParseRightScriptMetadata(script io.ReadSeeker) (*RightScriptMetadata, error) {
+	return ParseRightScriptMetadataIn(script, ".")
+}
+
+// ParseRightScriptMetadataIn parses the metadata comment of a script, resolving any !include directives relative to
+// dir, which should be the directory of the script.
+func ParseRightScriptMetadataIn(script io.ReadSeeker, dir string) (*RightScriptMetadata, error) {
+	defer script.Seek(0, os.SEEK_SET)
+
+	scanner := bufio.NewScanner(script)
+	var buffer bytes.Buffer
+	var lineNumber, offset uint
+	inMetadata, inBlockComment := false, false
+	var metadata RightScriptMetadata
+
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		switch {
+		case inMetadata && metadata.Comment == blockComment:
+			submatches := blockMetadataEnd.FindStringSubmatch(line)
+			if submatches != nil {
+				buffer.WriteString(submatches[1] + "\n")
+				inMetadata = false
+				break
+			}
+			buffer.WriteString(line + "\n")
+		case inMetadata:
+			submatches := metadataEnd.FindStringSubmatch(line)
+			if submatches != nil {
+				buffer.WriteString(submatches[1] + "\n")
+				inMetadata = false
+				break
+			}
+			submatches = comment.FindStringSubmatch(line)
+			if submatches != nil {
+				buffer.WriteString(submatches[1] + "\n")
+			}
+		case inBlockComment && blockMetadataStart.MatchString(line):
+			submatches := blockMetadataStart.FindStringSubmatch(line)
+			metadata.Comment = blockComment
+			buffer.WriteString(submatches[1] + "\n")
+			inMetadata = true
+			offset = lineNumber
+		case metadataStart.MatchString(line):
+			submatches := metadataStart.FindStringSubmatch(line)
+			metadata.Comment = submatches[1]
+			buffer.WriteString(submatches[2] + "\n")
+			inMetadata = true
+			offset = lineNumber
+		}
+		// metadata in a block comment must start on the line right after the block comment is opened
+		inBlockComment = !inMetadata && blockCommentStart.MatchString(line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if inMetadata {
+		return nil, fmt.Errorf("Unterminated RightScript metadata comment")
+	}
+	if buffer.Len() == 0 {
+		return nil, nil
+	}
+
+	// each line of the buffer is a line of the script from the start of the metadata
+	origins := make([]lineOrigin, bytes.Count(buffer.Bytes(), []byte("\n"))+1)
+	for index := range origins {
+		origins[index].Line = int(offset) + index
+	}
+	data, origins, includes, err := expandIncludes(buffer.Bytes(), dir, origins, 0)
+	if err != nil {
+		return nil, err
+	}
+	metadata.Includes = includes
+
+	err = yaml.Unmarshal(data, &metadata)
+	if err != nil {
+		return &metadata, relocateYAMLError(err, origins)
+	}
+	return &metadata, nil
+}
+
+func (metadata *RightScriptMetadata) WriteTo(script io.Writer) (n int64, err error) {
+	if metadata.Comment == "" {
+		metadata.Comment = "#"
+	}
+
+	if metadata.Inputs == nil {
+		metadata.Inputs = InputMap{}
+	}
+
+	start, prefix, end := metadata.Comment+" ---\n", metadata.Comment+" ", metadata.Comment+" ...\n"
+	if metadata.Comment == blockComment {
+		start, prefix, end = blockComment+"\n---\n", "", "...\n#>\n"
+	}
+
+	c, err := fmt.Fprint(script, start)
+	if n += int64(c); err != nil {
+		return
+	}
+
+	yml, err := yaml.Marshal(metadata)
+	scanner := bufio.NewScanner(bytes.NewBuffer(yml))
+
+	for scanner.Scan() {
+		c, err = fmt.Fprintf(script, "%s%s\n", prefix, scanner.Text())
+		if n += int64(c); err != nil {
+			return
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return
+	}
+
+	c, err = fmt.Fprint(script, end)
+	if n += int64(c); err != nil {
+		return
+	}
+
+	return
+}
+
+func (i InputType) String() string {
+	switch i {
+	case Single:
+		return "single"
+	case Array:
+		return "array"
+	default:
+		return fmt.Sprintf("%d", i)
+	}
+}
+
+func (i InputType) MarshalYAML() (interface{}, error) {
+	switch i {
+	case Single, Array:
+		return i.String(), nil
+	default:
+		return "", fmt.Errorf("Invalid input type value: %d", i)
+	}
+}
+
+func (i *InputType) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	err := unmarshal(&value)
+	if err != nil {
+		return err
+	}
+	inputType, err := parseInputType(value)
+	if err != nil {
+		return fmt.Errorf("Invalid input type value: %s", value)
+	}
+	*i = inputType
+	return nil
+}
+
+func parseInputType(value string) (InputType, error) {
+	switch value {
+	case "single":
+		return Single, nil
+	case "array":
+		return Array, nil
+	default:
+		return Single, fmt.Errorf("Invalid input type value: %s", value)
+	}
+}
+
+func (i InputValue) String() string {
+	switch i.Type {
+	case "blank", "ignore":
+		return i.Type
+	default:
+		return i.Type + ":" + i.Value
+	}
+}
+
+func (i InputValue) MarshalYAML() (interface{}, error) {
+	return i.String(), nil
+}
+
+func (i *InputValue) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	err := unmarshal(&value)
+	if err != nil {
+		return err
+	}
+
+	iv_pnt, err := ParseInputValue(value)
+	if err != nil {
+		return err
+	}
+	*i = *iv_pnt
+	return nil
+}
+
+// The Marshal/Unmarshal for InputMap bears some explanation. So we have a simple hash/map defined as InputMap in the
+// publicly facing Input Definition. Hash/maps in golang however aren't ordered. Luckily yaml.v2 for golang has an
+// ordered Hash called MapSlice, which is an array of MapItems which have a Key and Value field. So we turn our internal
+// array into this MapSlice when Marshalling to get something that looks like a hash but is ordered. When Unmarshalling,
+// we unmarshall to BOTH a MapSlice and a Hash of InputDefinitions. The MapSlice is simply mined to get the order.
+// The hash of InputDefinitions is so we can make sure we unmarshal into a well ordered struct instead of the untyped
+// interface{} pile that is MapSlice. Magic!
+func (im InputMap) MarshalYAML() (interface{}, error) {
+	// with the API, internally we store Inputs as an array of values.
+	destMap := yaml.MapSlice{}
+	for _, i := range im {
+		destMap = append(destMap, yaml.MapItem{Key: i.Name, Value: i})
+	}
+	return destMap, nil
+}
+
+func (im *InputMap) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var valueOrder yaml.MapSlice
+	var value map[string]InputMetadata
+	err := unmarshal(&value) // For convenient strong typed-ness
+	if err != nil {
+		return err
+	}
+	err = unmarshal(&valueOrder) // For ordering information
+	if err != nil {
+		return err
+	}
+	inputMap := InputMap{}
+	for _, v := range valueOrder {
+		inputName := v.Key.(string)
+
+		inputMetadata := value[inputName]
+		inputMetadata.Name = inputName
+		inputMap = append(inputMap, inputMetadata)
+	}
+
+	*im = inputMap
+	return nil
+}
+
+// Validate cross checks the default value of an input against its input type and possible values.
+func (input *InputMetadata) Validate() error {
+	// Possible values of an array input describe the individual items, so they are always single values
+	for _, pv := range input.PossibleValues {
+		if err := validateInputValue(pv, Single); err != nil {
+			return fmt.Errorf("Input %s has an invalid possible value %s: %s", input.Name, pv.String(), err.Error())
+		}
+	}
+	if input.Default == nil {
+		return nil
+	}
+	if err := validateInputValue(input.Default, input.InputType); err != nil {
+		return fmt.Errorf("Input %s has an invalid default value %s: %s", input.Name, input.Default.String(), err.Error())
+	}
+	if len(input.PossibleValues) > 0 && input.Default.Type == "text" {
+		for _, pv := range input.PossibleValues {
+			if *pv == *input.Default {
+				return nil
+			}
+		}
+		vals := make([]string, len(input.PossibleValues))
+		for i, pv := range input.PossibleValues {
+			vals[i] = pv.String()
+		}
+		return fmt.Errorf("Input %s has default value %s which is not one of the possible values: %s", input.Name,
+			input.Default.String(), strings.Join(vals, ", "))
+	}
+	return nil
+}
+
+// JSONSchema describes the inputs as a JSON Schema object for generating forms: each input is a string or array of
+// strings property with the text value of its default and its possible values as an enum. Defaults from other
+// sources, like credentials, cannot be expressed in the schema so they are only given as x-rightscale-default.
+func (inputs InputMap) JSONSchema(title, description string) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+	for _, input := range inputs {
+		property := map[string]interface{}{
+			"title":                 input.Name,
+			"type":                  "string",
+			"x-rightscale-category": input.Category,
+			"x-rightscale-advanced": input.Advanced,
+		}
+		if input.Description != "" {
+			property["description"] = input.Description
+		}
+		values := property
+		if input.InputType == Array {
+			values = map[string]interface{}{"type": "string"}
+			property["type"] = "array"
+			property["items"] = values
+		}
+		if enum := textValues(input.PossibleValues); len(enum) > 0 {
+			values["enum"] = enum
+		}
+		if input.Default != nil {
+			if value, ok := schemaDefault(*input.Default); ok {
+				property["default"] = value
+			} else {
+				property["x-rightscale-default"] = input.Default.String()
+			}
+		}
+		properties[input.Name] = property
+		if input.Required {
+			required = append(required, input.Name)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"$schema":    "http://json-schema.org/draft-04/schema#",
+		"title":      title,
+		"type":       "object",
+		"properties": properties,
+	}
+	if description != "" {
+		schema["description"] = description
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// textValues returns the values of the text input values, skipping the others.
+func textValues(values []*InputValue) []string {
+	texts := []string{}
+	for _, value := range values {
+		if value.Type == "text" {
+			texts = append(texts, value.Value)
+		}
+	}
+	return texts
+}
+
+// schemaDefault returns the JSON value of an input value if it is text, blank, or an array of text.
+func schemaDefault(value InputValue) (interface{}, bool) {
+	switch value.Type {
+	case "text":
+		return value.Value, true
+	case "blank":
+		return "", true
+	case "array":
+		var items []string
+		if err := json.Unmarshal([]byte(value.Value), &items); err != nil {
+			return nil, false
+		}
+		texts := []string{}
+		for _, item := range items {
+			itemValue, err := ParseInputValue(item)
+			if err != nil || itemValue.Type != "text" {
+				return nil, false
+			}
+			texts = append(texts, itemValue.Value)
+		}
+		return texts, true
+	}
+	return nil, false
+}
+
+// CategoryWarnings returns warnings about the categories the inputs are grouped by in the dashboard: inputs without a
+// category and pairs of categories which are so similar that one is probably a typo of the other, like "Database" and
+// "Databse".
+func (inputs InputMap) CategoryWarnings() []string {
+	warnings := []string{}
+	categoryInputs := make(map[string][]string)
+	for _, input := range inputs {
+		if strings.TrimSpace(input.Category) == "" {
+			warnings = append(warnings, fmt.Sprintf("Input %s has no category", input.Name))
+			continue
+		}
+		categoryInputs[input.Category] = append(categoryInputs[input.Category], input.Name)
+	}
+
+	categories := make([]string, 0, len(categoryInputs))
+	for category := range categoryInputs {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for i, a := range categories {
+		for _, b := range categories[i+1:] {
+			if similarCategories(a, b) {
+				warnings = append(warnings, fmt.Sprintf("Categories %q (%s) and %q (%s) are similar, is one of them a typo?",
+					a, strings.Join(categoryInputs[a], ", "), b, strings.Join(categoryInputs[b], ", ")))
+			}
+		}
+	}
+	return warnings
+}
+
+// ValidateCategories returns an error naming every input with a category which is not one of allowed.
+func (inputs InputMap) ValidateCategories(allowed []string) error {
+	isAllowed := make(map[string]bool, len(allowed))
+	for _, category := range allowed {
+		isAllowed[category] = true
+	}
+	unknown := []string{}
+	for _, input := range inputs {
+		if !isAllowed[input.Category] {
+			unknown = append(unknown, fmt.Sprintf("Input %s has category %q which is not one of: %s", input.Name,
+				input.Category, strings.Join(allowed, ", ")))
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("%s", strings.Join(unknown, "\n  "))
+	}
+	return nil
+}
+
+// similarCategories returns whether two different categories only differ by case and spacing or by a single edit.
+func similarCategories(a, b string) bool {
+	normalize := func(category string) string {
+		return strings.ToLower(strings.Join(strings.Fields(category), " "))
+	}
+	a, b = normalize(a), normalize(b)
+	if a == b {
+		return true
+	}
+	if len(a) < 4 || len(b) < 4 {
+		return false
+	}
+	return EditDistance(a, b) == 1
+}
+
+// EditDistance computes the Levenshtein distance between two strings.
+func EditDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = previous[j-1] + cost
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+// Sources an input value can be bound to, this is the prefix before the colon of an input value
+var inputSources = map[string]string{
+	"array":  "array of values",
+	"blank":  "blank value",
+	"cred":   "credential",
+	"env":    "environment variable",
+	"ignore": "no value",
+	"key":    "SSH key",
+	"text":   "text value",
+}
+
+// Source describes the source an input value is bound to, such as "credential" for "cred:PASSWORD".
+func (i InputValue) Source() string {
+	if source, ok := inputSources[i.Type]; ok {
+		return source
+	}
+	return "unknown source " + i.Type
+}
+
+func validateInputValue(value *InputValue, inputType InputType) error {
+	if _, ok := inputSources[value.Type]; !ok {
+		sources := make([]string, 0, len(inputSources))
+		for source := range inputSources {
+			sources = append(sources, source)
+		}
+		sort.Strings(sources)
+		return fmt.Errorf("unknown input source %s, must be one of: %s", value.Type, strings.Join(sources, ", "))
+	}
+
+	switch value.Type {
+	case "text":
+		if inputType == Array {
+			return fmt.Errorf("an array input requires an array value such as array:[\"text:foo\"]")
+		}
+	case "array":
+		if inputType != Array {
+			return fmt.Errorf("a single input cannot have an array value")
+		}
+		var items []string
+		if err := json.Unmarshal([]byte(value.Value), &items); err != nil {
+			return fmt.Errorf("array value must be a JSON list of input values: %s", err.Error())
+		}
+		for _, item := range items {
+			itemValue, err := ParseInputValue(item)
+			if err != nil {
+				return err
+			}
+			if itemValue.Type == "array" {
+				return fmt.Errorf("array values cannot be nested")
+			}
+			if err := validateInputValue(itemValue, Single); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func ParseInputValue(value string) (*InputValue, error) {
+	values := strings.SplitN(value, ":", 2)
+	switch values[0] {
+	case "blank", "ignore":
+		return &InputValue{Type: values[0]}, nil
+	default:
+		if len(values) < 2 {
+			return nil, fmt.Errorf("Invalid input value: %s", value)
+		}
+		i := InputValue{Type: values[0], Value: values[1]}
+		if i.Type == "text" && i.Value == "" {
+			return nil, fmt.Errorf("Use 'blank' or 'ignore' instead of 'text:'")
+		}
+		return &i, nil
+	}
+}
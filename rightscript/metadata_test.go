@@ -0,0 +1,730 @@
+package rightscript_test
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	. "github.com/rightscale/right_st/rightscript"
+
+	"github.com/go-yaml/yaml"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("RightScript Metadata", func() {
+	var (
+		validScript                     io.ReadSeeker
+		noMetadataScript                io.ReadSeeker
+		missingEndDelimiterScript       io.ReadSeeker
+		invalidYamlSyntaxScript         io.ReadSeeker
+		invalidMetadataStructureScript  io.ReadSeeker
+		incorrectInputTypeSyntaxScript  io.ReadSeeker
+		incorrectInputValueSyntaxScript io.ReadSeeker
+		emptyTextValueScript            io.ReadSeeker
+		unknownFieldScript              io.ReadSeeker
+		doubleSlashCommentScript        io.ReadSeeker
+		doubleDashCommentScript         io.ReadSeeker
+		blockCommentScript              io.ReadSeeker
+		unterminatedBlockCommentScript  io.ReadSeeker
+		buffer                          *gbytes.Buffer
+		emptyMetadata                   RightScriptMetadata
+		emptyMetadataScript             string
+		populatedMetadata               RightScriptMetadata
+		populatedMetadataScript         string
+		differentCommentMetadata        RightScriptMetadata
+		differentCommentMetadataScript  string
+		blockCommentMetadata            RightScriptMetadata
+		blockCommentMetadataScript      string
+	)
+
+	BeforeEach(func() {
+		validScript = strings.NewReader(`#!/bin/bash
+# ---
+# RightScript Name: Some RightScript Name
+# Description: Some description of stuff
+# Inputs:
+#   TEXT_INPUT:
+#     Category: Uncategorized
+#     Description: Some test input
+#     Input Type: single
+#     Required: true
+#     Advanced: false
+#     Default: text:foobar
+#     Possible Values:
+#       - text:foobar
+#       - text:barfoo
+#   SUPPORTED_VERSIONS:
+#     Category: Uncategorized
+#     Description: Some array input
+#     Input Type: array
+#     Required: false
+#     Advanced: true
+#     Default: array:["text:v1","text:v2"]
+# Attachments:
+#   - attachments/some_attachment.zip
+#   - attachments/another_attachment.tar.xz
+# ...
+`)
+		noMetadataScript = strings.NewReader(`#!/bin/bash
+# There is no metadata comment here
+`)
+		missingEndDelimiterScript = strings.NewReader(`#!/bin/bash
+# ---
+# RightScript Name: Some RightScript Name
+# Description: Some description of stuff
+# Inputs:
+#   TEXT_INPUT:
+#     Category: Uncategorized
+#     Description: Some test input
+#     Input Type: single
+#     Required: true
+#     Advanced: false
+#     Default: text:foobar
+#     Possible Values:
+#       - text:foobar
+#       - text:barfoo
+#
+# We should have used the '...' end delimiter above
+`)
+		invalidYamlSyntaxScript = strings.NewReader(`#!/bin/bash
+# ---
+# RightScript Name: Some RightScript Name
+# Description: Some description of stuff
+# Inputs:
+#   TEXT_INPUT:
+#     Category: Uncategorized
+#     Description: Some test input
+#     Input Type: bogus
+#     Required: true
+#     Advanced: false
+#     Default: text:
+# ...
+# The Default line is invalid YAML
+`)
+		invalidMetadataStructureScript = strings.NewReader(`#!/bin/bash
+# ---
+# RightScript Name: Some RightScript Name
+# Description: Some description of stuff
+# Inputs:
+#   - TEXT_INPUT
+# ...
+# The Inputs field should have a map not an array
+`)
+		incorrectInputTypeSyntaxScript = strings.NewReader(`#!/bin/bash
+# ---
+# RightScript Name: Some RightScript Name
+# Description: Some description of stuff
+# Inputs:
+#   TEXT_INPUT:
+#     Category: Uncategorized
+#     Description: Some test input
+#     Input Type: bogus
+#     Required: true
+#     Advanced: false
+# ...
+# The Input Type line is not a valid type
+`)
+		incorrectInputValueSyntaxScript = strings.NewReader(`#!/bin/bash
+# ---
+# RightScript Name: Some RightScript Name
+# Description: Some description of stuff
+# Inputs:
+#   TEXT_INPUT:
+#     Category: Uncategorized
+#     Description: Some test input
+#     Input Type: single
+#     Required: true
+#     Advanced: false
+#     Default: foobar
+# ...
+# The Default line is not valid Inputs 2.0 syntax
+`)
+		emptyTextValueScript = strings.NewReader(`#!/bin/bash
+# ---
+# RightScript Name: Some RightScript Name
+# Description: Some description of stuff
+# Inputs:
+#   TEXT_INPUT:
+#     Category: Uncategorized
+#     Description: Some test input
+#     Input Type: single
+#     Required: true
+#     Advanced: false
+#     Default: "text:"
+# ...
+# The Default line should be blank or ignore in Inputs 2.0 syntax
+`)
+		unknownFieldScript = strings.NewReader(`#!/bin/bash
+# ---
+# RightScript Name: Some RightScript Name
+# Description: Some description of stuff
+# Some Bogus Field: Some bogus value
+# ...
+`)
+		doubleSlashCommentScript = strings.NewReader(`#!/usr/bin/env node
+// ---
+// RightScript Name: Some RightScript Name
+// Description: Some description of stuff
+// Inputs:
+//   TEXT_INPUT:
+//     Category: Uncategorized
+//     Description: Some test input
+//     Input Type: single
+//     Required: true
+//     Advanced: false
+// Attachments: []
+// ...
+`)
+		doubleDashCommentScript = strings.NewReader(`#!/usr/bin/env lua
+-- ---
+-- RightScript Name: Some RightScript Name
+-- Description: Some description of stuff
+-- Inputs:
+--   TEXT_INPUT:
+--     Category: Uncategorized
+--     Description: Some test input
+--     Input Type: single
+--     Required: true
+--     Advanced: false
+-- Attachments: []
+-- ...
+`)
+		blockCommentScript = strings.NewReader(`<#
+---
+RightScript Name: Some RightScript Name
+Description: Some description of stuff
+Inputs:
+  TEXT_INPUT:
+    Category: Uncategorized
+    Description: Some test input
+    Input Type: single
+    Required: true
+    Advanced: false
+Attachments: []
+...
+#>
+
+Write-Output $env:TEXT_INPUT
+`)
+		unterminatedBlockCommentScript = strings.NewReader(`<#
+---
+RightScript Name: Some RightScript Name
+Inputs: {}
+#>
+`)
+		buffer = gbytes.NewBuffer()
+		emptyMetadata = RightScriptMetadata{}
+		emptyMetadataScript = `# ---
+# RightScript Name: ""
+# Inputs: {}
+# Attachments: []
+# ...
+`
+		populatedMetadata = RightScriptMetadata{
+			Name:        "Some RightScript Name",
+			Description: "Some description of stuff",
+			Inputs: InputMap{
+				InputMetadata{
+					Name:           "TEXT_INPUT",
+					Category:       "Uncategorized",
+					Description:    "Some test input",
+					InputType:      0,
+					Required:       true,
+					Advanced:       false,
+					Default:        &InputValue{"text", "foobar"},
+					PossibleValues: []*InputValue{&InputValue{"text", "foobar"}, &InputValue{"text", "barfoo"}},
+				},
+				InputMetadata{
+					Name:        "SUPPORTED_VERSIONS",
+					Category:    "Uncategorized",
+					Description: "Some array input",
+					InputType:   1,
+					Required:    false,
+					Advanced:    true,
+					Default:     &InputValue{"array", `["text:v1","text:v2"]`},
+				},
+			},
+			Attachments: []string{
+				"attachments/some_attachment.zip",
+				"attachments/another_attachment.tar.xz",
+			},
+		}
+		populatedMetadataScript = `# ---
+# RightScript Name: Some RightScript Name
+# Description: Some description of stuff
+# Inputs:
+#   TEXT_INPUT:
+#     Category: Uncategorized
+#     Description: Some test input
+#     Input Type: single
+#     Required: true
+#     Advanced: false
+#     Default: text:foobar
+#     Possible Values:
+#     - text:foobar
+#     - text:barfoo
+#   SUPPORTED_VERSIONS:
+#     Category: Uncategorized
+#     Description: Some array input
+#     Input Type: array
+#     Required: false
+#     Advanced: true
+#     Default: array:["text:v1","text:v2"]
+# Attachments:
+# - attachments/some_attachment.zip
+# - attachments/another_attachment.tar.xz
+# ...
+`
+		differentCommentMetadata = RightScriptMetadata{Comment: "//"}
+		differentCommentMetadataScript = `// ---
+// RightScript Name: ""
+// Inputs: {}
+// Attachments: []
+// ...
+`
+		blockCommentMetadata = RightScriptMetadata{Comment: "<#"}
+		blockCommentMetadataScript = `<#
+---
+RightScript Name: ""
+Inputs: {}
+Attachments: []
+...
+#>
+`
+	})
+
+	Describe("Parse RightScript metadata", func() {
+		Context("With valid script metadata", func() {
+			It("should parse correctly", func() {
+				metadata, err := ParseRightScriptMetadata(validScript)
+				Expect(err).To(Succeed())
+				Expect(metadata).NotTo(BeNil())
+				Expect(metadata.Name).To(Equal("Some RightScript Name"))
+				Expect(metadata.Description).To(Equal("Some description of stuff"))
+				Expect(metadata.Inputs).To(Equal(InputMap{
+					InputMetadata{
+						Name:           "TEXT_INPUT",
+						Category:       "Uncategorized",
+						Description:    "Some test input",
+						InputType:      0,
+						Required:       true,
+						Advanced:       false,
+						Default:        &InputValue{"text", "foobar"},
+						PossibleValues: []*InputValue{&InputValue{"text", "foobar"}, &InputValue{"text", "barfoo"}},
+					},
+					InputMetadata{
+						Name:        "SUPPORTED_VERSIONS",
+						Category:    "Uncategorized",
+						Description: "Some array input",
+						InputType:   1,
+						Required:    false,
+						Advanced:    true,
+						Default:     &InputValue{"array", `["text:v1","text:v2"]`},
+					},
+				}))
+				Expect(metadata.Attachments).To(Equal([]string{
+					"attachments/some_attachment.zip",
+					"attachments/another_attachment.tar.xz",
+				}))
+			})
+		})
+
+		Context("With no script metadata", func() {
+			It("should not return metadata", func() {
+				metadata, err := ParseRightScriptMetadata(noMetadataScript)
+				Expect(err).To(Succeed())
+				Expect(metadata).To(BeNil())
+			})
+		})
+
+		Context("With missing end delimiter in script metadata", func() {
+			It("should return an error", func() {
+				_, err := ParseRightScriptMetadata(missingEndDelimiterScript)
+				Expect(err).To(HaveOccurred())
+				Expect(err).To(MatchError("Unterminated RightScript metadata comment"))
+			})
+		})
+
+		Context("With invalid YAML syntax in script metadata", func() {
+			It("should return an error", func() {
+				_, err := ParseRightScriptMetadata(invalidYamlSyntaxScript)
+				Expect(err).To(HaveOccurred())
+				Expect(err).To(MatchError("yaml: line 12: mapping values are not allowed in this context"))
+			})
+		})
+
+		Context("With invalid structure in script metadata", func() {
+			It("should return an error", func() {
+				_, err := ParseRightScriptMetadata(invalidMetadataStructureScript)
+				Expect(err).To(HaveOccurred())
+				Expect(err).To(MatchError(&yaml.TypeError{
+					Errors: []string{
+						"line 6: cannot unmarshal !!seq into map[string]rightscript.InputMetadata",
+					},
+				}))
+			})
+		})
+
+		Context("With incorrect input type syntax in script metadata", func() {
+			It("should return an error", func() {
+				_, err := ParseRightScriptMetadata(incorrectInputTypeSyntaxScript)
+				Expect(err).To(HaveOccurred())
+				Expect(err).To(MatchError("Invalid input type value: bogus"))
+			})
+		})
+
+		Context("With incorrect input value syntax in script metadata", func() {
+			It("should return an error", func() {
+				_, err := ParseRightScriptMetadata(incorrectInputValueSyntaxScript)
+				Expect(err).To(HaveOccurred())
+				Expect(err).To(MatchError("Invalid input value: foobar"))
+			})
+		})
+
+		Context("With a blank text input value in script metadata", func() {
+			It("should return an error", func() {
+				_, err := ParseRightScriptMetadata(emptyTextValueScript)
+				Expect(err).To(HaveOccurred())
+				Expect(err).To(MatchError("Use 'blank' or 'ignore' instead of 'text:'"))
+			})
+		})
+
+		Context("With different comment styles", func() {
+			textInput := InputMap{
+				InputMetadata{
+					Name:        "TEXT_INPUT",
+					Category:    "Uncategorized",
+					Description: "Some test input",
+					InputType:   Single,
+					Required:    true,
+					Advanced:    false,
+				},
+			}
+
+			It("should parse // comments", func() {
+				metadata, err := ParseRightScriptMetadata(doubleSlashCommentScript)
+				Expect(err).To(Succeed())
+				Expect(metadata).NotTo(BeNil())
+				Expect(metadata.Comment).To(Equal("//"))
+				Expect(metadata.Name).To(Equal("Some RightScript Name"))
+				Expect(metadata.Inputs).To(Equal(textInput))
+			})
+
+			It("should parse -- comments", func() {
+				metadata, err := ParseRightScriptMetadata(doubleDashCommentScript)
+				Expect(err).To(Succeed())
+				Expect(metadata).NotTo(BeNil())
+				Expect(metadata.Comment).To(Equal("--"))
+				Expect(metadata.Name).To(Equal("Some RightScript Name"))
+				Expect(metadata.Inputs).To(Equal(textInput))
+			})
+
+			It("should parse <# #> block comments", func() {
+				metadata, err := ParseRightScriptMetadata(blockCommentScript)
+				Expect(err).To(Succeed())
+				Expect(metadata).NotTo(BeNil())
+				Expect(metadata.Comment).To(Equal("<#"))
+				Expect(metadata.Name).To(Equal("Some RightScript Name"))
+				Expect(metadata.Description).To(Equal("Some description of stuff"))
+				Expect(metadata.Inputs).To(Equal(textInput))
+				Expect(metadata.Attachments).To(BeEmpty())
+			})
+
+			It("should return an error for an unterminated <# #> block comment", func() {
+				_, err := ParseRightScriptMetadata(unterminatedBlockCommentScript)
+				Expect(err).To(MatchError("Unterminated RightScript metadata comment"))
+			})
+		})
+
+		Context("With an unknown field in script metadata", func() {
+			It("should return an error", func() {
+				_, err := ParseRightScriptMetadata(unknownFieldScript)
+				Expect(err).To(HaveOccurred())
+				Expect(err).To(MatchError(&yaml.TypeError{
+					Errors: []string{
+						"line 4: no such field 'Some Bogus Field' in struct 'rightscript.RightScriptMetadata'",
+					},
+				}))
+			})
+		})
+	})
+
+	Describe("Includes", func() {
+		var tempDir string
+
+		BeforeEach(func() {
+			var err error
+			tempDir, err = ioutil.TempDir("", "right_st_include")
+			if err != nil {
+				panic(err)
+			}
+			shared := filepath.Join(tempDir, "shared")
+			if err := os.Mkdir(shared, 0755); err != nil {
+				panic(err)
+			}
+			if err := ioutil.WriteFile(filepath.Join(shared, "inputs.yml"), []byte(`---
+DB_NAME:
+  Category: Database
+  Input Type: single
+  Required: true
+  Advanced: false
+`), 0644); err != nil {
+				panic(err)
+			}
+			if err := ioutil.WriteFile(filepath.Join(shared, "invalid.yml"), []byte(`DB_NAME:
+  Description: Some: thing
+  Category: Database
+`), 0644); err != nil {
+				panic(err)
+			}
+			if err := ioutil.WriteFile(filepath.Join(shared, "frag.yml"), []byte(`# Inputs shared by the database scripts
+---
+- DB_NAME
+`), 0644); err != nil {
+				panic(err)
+			}
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(tempDir)
+		})
+
+		It("should merge included inputs with the inputs of the script", func() {
+			metadata, err := ParseRightScriptMetadataIn(strings.NewReader(`#!/bin/bash
+# ---
+# RightScript Name: Include Script
+# Inputs:
+#   !include shared/inputs.yml
+#   APP_NAME:
+#     Category: Application
+#     Input Type: single
+#     Required: false
+#     Advanced: false
+# Attachments: []
+# ...
+`), tempDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(metadata.Inputs).To(HaveLen(2))
+			Expect(metadata.Inputs[0].Name).To(Equal("DB_NAME"))
+			Expect(metadata.Inputs[0].Category).To(Equal("Database"))
+			Expect(metadata.Inputs[1].Name).To(Equal("APP_NAME"))
+			Expect(metadata.Includes).To(Equal([]string{filepath.Join(tempDir, "shared", "inputs.yml")}))
+		})
+
+		It("should include a file as the value of a key", func() {
+			metadata, err := ParseRightScriptSidecar(strings.NewReader("RightScript Name: Sidecar\nInputs: !include shared/inputs.yml\n"), tempDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(metadata.Inputs).To(HaveLen(1))
+			Expect(metadata.Inputs[0].Name).To(Equal("DB_NAME"))
+		})
+
+		It("should report errors with the file and line they came from", func() {
+			_, err := ParseRightScriptMetadataIn(strings.NewReader(`#!/bin/bash
+# ---
+# RightScript Name: Include Script
+# Inputs:
+#   !include shared/invalid.yml
+# ...
+`), tempDir)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(MatchRegexp(`^yaml: line \d+ of ` + regexp.QuoteMeta(filepath.Join(tempDir, "shared", "invalid.yml")) + ": mapping values"))
+		})
+
+		It("should report unmarshal errors with the included file and line they came from", func() {
+			_, err := ParseRightScriptSidecar(strings.NewReader("RightScript Name: Sidecar\nDescription: Some description\nInputs: !include shared/frag.yml\n"), tempDir)
+			Expect(err).To(MatchError(&yaml.TypeError{
+				Errors: []string{
+					"line 3 of " + filepath.Join(tempDir, "shared", "frag.yml") + ": cannot unmarshal !!seq into map[string]rightscript.InputMetadata",
+				},
+			}))
+		})
+
+		It("should report errors in a sidecar file with its own line numbers", func() {
+			_, err := ParseRightScriptSidecar(strings.NewReader("RightScript Name: Sidecar\nInputs:\n  - DB_NAME\n"), tempDir)
+			Expect(err).To(MatchError(&yaml.TypeError{
+				Errors: []string{"line 3: cannot unmarshal !!seq into map[string]rightscript.InputMetadata"},
+			}))
+		})
+
+		It("should report a missing included file", func() {
+			_, err := ParseRightScriptMetadataIn(strings.NewReader(`#!/bin/bash
+# ---
+# RightScript Name: Include Script
+# Inputs: !include shared/missing.yml
+# ...
+`), tempDir)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("line 4: could not include " + filepath.Join(tempDir, "shared", "missing.yml")))
+		})
+	})
+
+	Describe("Input categories", func() {
+		inputs := InputMap{
+			{Name: "DB_NAME", Category: "Database"},
+			{Name: "DB_USER", Category: "Databse"},
+			{Name: "APP_NAME", Category: "Application"},
+			{Name: "APP_PORT", Category: "application"},
+			{Name: "NO_CATEGORY"},
+		}
+
+		It("should warn about missing and similar categories", func() {
+			Expect(inputs.CategoryWarnings()).To(Equal([]string{
+				"Input NO_CATEGORY has no category",
+				`Categories "Application" (APP_NAME) and "application" (APP_PORT) are similar, is one of them a typo?`,
+				`Categories "Database" (DB_NAME) and "Databse" (DB_USER) are similar, is one of them a typo?`,
+			}))
+		})
+
+		It("should not warn about distinct categories", func() {
+			Expect(InputMap{{Name: "A", Category: "Apache"}, {Name: "B", Category: "Nginx"}}.CategoryWarnings()).To(BeEmpty())
+		})
+
+		It("should reject categories which are not allowed", func() {
+			Expect(inputs[:2].ValidateCategories([]string{"Database"})).To(MatchError(
+				`Input DB_USER has category "Databse" which is not one of: Database`))
+			Expect(inputs[:1].ValidateCategories([]string{"Database"})).To(Succeed())
+		})
+	})
+
+	Describe("Inputs JSON Schema", func() {
+		It("should describe the inputs as properties", func() {
+			inputs := InputMap{
+				{
+					Name:           "LOG_LEVEL",
+					Category:       "Logging",
+					Description:    "How much to log",
+					InputType:      Single,
+					Required:       true,
+					Default:        &InputValue{Type: "text", Value: "info"},
+					PossibleValues: []*InputValue{{Type: "text", Value: "info"}, {Type: "text", Value: "debug"}},
+				},
+				{Name: "SERVERS", Category: "Cluster", InputType: Array, Advanced: true, Default: &InputValue{Type: "array", Value: `["text:a","text:b"]`}},
+				{Name: "PASSWORD", Category: "Database", InputType: Single, Default: &InputValue{Type: "cred", Value: "DB_PASSWORD"}},
+			}
+			data, err := json.Marshal(inputs.JSONSchema("Configure Logging", "Sets up logging"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data).To(MatchJSON(`{
+				"$schema": "http://json-schema.org/draft-04/schema#",
+				"title": "Configure Logging",
+				"description": "Sets up logging",
+				"type": "object",
+				"required": ["LOG_LEVEL"],
+				"properties": {
+					"LOG_LEVEL": {
+						"title": "LOG_LEVEL", "type": "string", "description": "How much to log",
+						"default": "info", "enum": ["info", "debug"],
+						"x-rightscale-category": "Logging", "x-rightscale-advanced": false
+					},
+					"SERVERS": {
+						"title": "SERVERS", "type": "array", "items": {"type": "string"}, "default": ["a", "b"],
+						"x-rightscale-category": "Cluster", "x-rightscale-advanced": true
+					},
+					"PASSWORD": {
+						"title": "PASSWORD", "type": "string", "x-rightscale-default": "cred:DB_PASSWORD",
+						"x-rightscale-category": "Database", "x-rightscale-advanced": false
+					}
+				}
+			}`))
+		})
+	})
+
+	Describe("Validate input", func() {
+		parse := func(value string) *InputValue {
+			v := new(InputValue)
+			Expect(yaml.Unmarshal([]byte(`"`+value+`"`), v)).To(Succeed())
+			return v
+		}
+
+		It("should accept a single input with a text default from the possible values", func() {
+			input := InputMetadata{Name: "INPUT", InputType: Single, Default: parse("text:foo1"),
+				PossibleValues: []*InputValue{parse("text:foo1"), parse("text:foo2")}}
+			Expect(input.Validate()).To(Succeed())
+		})
+
+		It("should accept an array input with an array default", func() {
+			input := InputMetadata{Name: "INPUT", InputType: Array, Default: &InputValue{Type: "array", Value: `["text:v1","text:v2"]`}}
+			Expect(input.Validate()).To(Succeed())
+		})
+
+		It("should reject an array input with a text default", func() {
+			input := InputMetadata{Name: "INPUT", InputType: Array, Default: parse("text:foo")}
+			Expect(input.Validate()).To(MatchError(`Input INPUT has an invalid default value text:foo: an array input requires an array value such as array:["text:foo"]`))
+		})
+
+		It("should reject a single input with an array default", func() {
+			input := InputMetadata{Name: "INPUT", InputType: Single, Default: &InputValue{Type: "array", Value: `["text:v1"]`}}
+			Expect(input.Validate()).To(MatchError(`Input INPUT has an invalid default value array:["text:v1"]: a single input cannot have an array value`))
+		})
+
+		It("should reject an array default that is not a list", func() {
+			input := InputMetadata{Name: "INPUT", InputType: Array, Default: &InputValue{Type: "array", Value: `text:v1`}}
+			Expect(input.Validate()).To(HaveOccurred())
+		})
+
+		It("should reject a text default which is not one of the possible values", func() {
+			input := InputMetadata{Name: "INPUT", InputType: Single, Default: parse("text:foo3"),
+				PossibleValues: []*InputValue{parse("text:foo1"), parse("text:foo2")}}
+			Expect(input.Validate()).To(MatchError("Input INPUT has default value text:foo3 which is not one of the possible values: text:foo1, text:foo2"))
+		})
+
+		It("should reject an unknown value type", func() {
+			input := InputMetadata{Name: "INPUT", InputType: Single, Default: parse("bogus:foo")}
+			Expect(input.Validate()).To(MatchError("Input INPUT has an invalid default value bogus:foo: " +
+				"unknown input source bogus, must be one of: array, blank, cred, env, ignore, key, text"))
+		})
+
+		It("should reject an unknown source in an array default", func() {
+			input := InputMetadata{Name: "INPUT", InputType: Array, Default: &InputValue{Type: "array", Value: `["text:v1","bogus:v2"]`}}
+			Expect(input.Validate()).To(HaveOccurred())
+		})
+
+		It("should describe the source of an input value", func() {
+			Expect(parse("cred:PASSWORD").Source()).To(Equal("credential"))
+			Expect(parse("env:RS_UUID").Source()).To(Equal("environment variable"))
+			Expect(parse("ignore").Source()).To(Equal("no value"))
+			Expect(parse("bogus:foo").Source()).To(Equal("unknown source bogus"))
+		})
+	})
+
+	Describe("Write RightScript metadata", func() {
+		Context("With empty metadata", func() {
+			It("should write a metadata comment", func() {
+				n, err := emptyMetadata.WriteTo(buffer)
+				Expect(err).To(Succeed())
+				Expect(buffer.Contents()).To(BeEquivalentTo(emptyMetadataScript))
+				Expect(n).To(BeEquivalentTo(66))
+			})
+		})
+		Context("With populated metadata", func() {
+			It("should write a metadata comment", func() {
+				n, err := populatedMetadata.WriteTo(buffer)
+				Expect(err).To(Succeed())
+				Expect(buffer.Contents()).To(BeEquivalentTo(populatedMetadataScript))
+				Expect(n).To(BeEquivalentTo(637))
+			})
+		})
+		Context("With a different comment string for metadata", func() {
+			It("should write a metadata comment", func() {
+				n, err := differentCommentMetadata.WriteTo(buffer)
+				Expect(err).To(Succeed())
+				Expect(buffer.Contents()).To(BeEquivalentTo(differentCommentMetadataScript))
+				Expect(n).To(BeEquivalentTo(71))
+			})
+		})
+		Context("With a block comment for metadata", func() {
+			It("should write a metadata block comment", func() {
+				n, err := blockCommentMetadata.WriteTo(buffer)
+				Expect(err).To(Succeed())
+				Expect(buffer.Contents()).To(BeEquivalentTo(blockCommentMetadataScript))
+				Expect(n).To(BeEquivalentTo(62))
+			})
+		})
+	})
+})
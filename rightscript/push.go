@@ -0,0 +1,757 @@
+package rightscript
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rightscale/rsc/cm15"
+	"github.com/rightscale/rsc/rsapi"
+)
+
+// PushOptions controls how a RightScript is created or updated by Push.
+type PushOptions struct {
+	Prefix        string // Add prefix to the name of the RightScript
+	Suffix        string // Add suffix to the name of the RightScript
+	MetadataOnly  bool   // Only update the metadata of an existing RightScript, not its source
+	NoAttachments bool   // Leave the attachments of the RightScript alone
+	NormalizeEOL  bool   // Convert CRLF line endings in the source to LF (except for PowerShell scripts)
+	// Use the README.md next to the script as the description when the metadata has none
+	DescriptionFromReadme bool
+	// Encoding of the source files to convert to UTF-8 from, one of SourceEncodings or empty for UTF-8
+	SourceEncoding string
+	// Create a new RightScript with a numeric suffix instead of updating an existing one which was neither created
+	// by right_st nor uploaded from the same script
+	RenameOnConflict bool
+	NoMarker         bool // Do not tag newly created RightScripts with ManagedTag
+	Strict           bool // Treat validation warnings as errors before uploading anything
+	ValidateFirst    bool // Validate every file like rightscript validate and fail if any is invalid before uploading
+	StopOnFirstError bool // Fail at the first RightScript which fails to upload instead of recording it and carrying on
+	ConfirmEach      bool // Ask before pushing each RightScript and skip the ones which are declined
+	// Only update an existing RightScript if its latest committed revision is this number or the md5 of its HEAD source
+	// is this digest, so changes someone else made in the meantime are not overwritten
+	IfMatch string
+	Diff    bool // Print a diff of the source and a summary of attachment changes before updating
+	// Fail instead of updating a RightScript which already exists, or instead of creating one which does not
+	CreateOnly, UpdateOnly bool
+	// Refuse script and attachment files larger than this many bytes, 0 for no limit
+	MaxFileSize int64
+	// Prefix added to the names of attachments when they are uploaded so they do not collide with the attachments of
+	// other RightScripts
+	AttachmentPrefix string
+	// Read the source of the one script being uploaded from this file, such as build output, instead of the file its
+	// metadata comes from
+	SourceFile string
+}
+
+// Name transforms the name from the metadata of a RightScript into the name it is looked up, created, and updated
+// with in RightScale by adding any prefix and suffix.
+func (options PushOptions) Name(name string) string {
+	if options.Prefix != "" {
+		name = fmt.Sprintf("%s_%s", options.Prefix, name)
+	}
+	if options.Suffix != "" {
+		name = fmt.Sprintf("%s_%s", name, options.Suffix)
+	}
+	return name
+}
+
+// AttachmentName is the name an attachment in the metadata of a RightScript is uploaded with: the name of its file
+// with any attachment prefix added.
+func (options PushOptions) AttachmentName(name string) string {
+	name = path.Base(name)
+	if options.AttachmentPrefix != "" {
+		name = fmt.Sprintf("%s_%s", options.AttachmentPrefix, name)
+	}
+	return name
+}
+
+// PushResult records what happened to a single file during rightscript upload for the --report manifest.
+type PushResult struct {
+	Path        string             `json:"path"`
+	Name        string             `json:"name,omitempty"`
+	Account     string             `json:"account,omitempty"` // Only set when uploading to several accounts
+	Action      string             `json:"action"`            // created, updated, imported, skipped, or failed
+	Error       string             `json:"error,omitempty"`   // Why it failed to upload
+	Href        string             `json:"href,omitempty"`
+	Revision    int                `json:"revision"` // 0 is HEAD
+	Attachments *AttachmentChanges `json:"attachments,omitempty"`
+}
+
+// AttachmentChanges lists the names of the attachments of a RightScript by what Push did with them.
+type AttachmentChanges struct {
+	Uploaded  []string `json:"uploaded"`
+	Deleted   []string `json:"deleted"`
+	Renamed   []string `json:"renamed"`
+	Unchanged []string `json:"unchanged"`
+	// HREFs of the uploaded attachments by their name, for the ones the API said where it created them
+	Hrefs map[string]string `json:"hrefs,omitempty"`
+}
+
+// Push imports a RightScript published in the MultiCloud Marketplace or creates or updates the RightScript in the
+// account from a local script and its attachments. What happened is recorded in r.Result.
+func (c *Client) Push(r *RightScript, options PushOptions) error {
+	r.maxFileSize = options.MaxFileSize
+	if r.Type == PublishedRightScript {
+		return c.pushRemote(r)
+	} else {
+		return c.pushLocal(r, options)
+	}
+}
+
+func (c *Client) pushRemote(r *RightScript) error {
+	client := c.API
+	// Algorithm:
+	//   1. Find the RightScript in publications first. Get the name/description/publisher
+	//   2. If we don't find it, throw an error
+	//   3. Get the imported RightScript. If it doesn't exist, import it then get the href
+	//   4. Insert HREF into r struct for later use.
+	matchers := map[string]string{}
+	if r.Publisher != "" {
+		matchers[`Publisher`] = r.Publisher
+	}
+
+	pub, err := c.FindPublication("RightScript", r.Name, r.Revision, matchers)
+	if err != nil {
+		return err
+	}
+	if pub == nil {
+		return fmt.Errorf("Could not find a publication in the MultiCloud Marketplace for RightScript '%s' Revision %d Publisher '%s'", r.Name, r.Revision, r.Publisher)
+	}
+
+	rsLocator := client.RightScriptLocator("/api/right_scripts")
+	filter, err := NameFilter(r.Name)
+	if err != nil {
+		return err
+	}
+	filters := []string{
+		filter,
+	}
+
+	rsUnfiltered, err := rsLocator.Index(rsapi.APIParams{"filter": filters})
+	if err != nil {
+		return err
+	}
+	for _, rs := range rsUnfiltered {
+		// Recheck the name here, filter does a partial match and we need an exact one.
+		// Matching the descriptions helps to disambiguate if we have multiple publications
+		// with that same name/revision pair.
+		if rs.Name == r.Name && rs.Revision == r.Revision && rs.Description == pub.Description {
+			r.Href = Link(rs.Links, "self")
+		}
+	}
+
+	r.Result = &PushResult{Path: r.Path, Name: r.Name, Action: "skipped", Revision: r.Revision}
+	if r.Href == "" {
+		r.Result.Action = "imported"
+		loc := pub.Locator(client)
+
+		err = loc.Import()
+
+		if err != nil {
+			return fmt.Errorf("Failed to import publication %s for RightScript '%s' Revision %d Publisher %s\n",
+				Link(pub.Links, "self"), r.Name, r.Revision, r.Publisher)
+		}
+
+		rsUnfiltered, err := rsLocator.Index(rsapi.APIParams{"filter": filters})
+		if err != nil {
+			return err
+		}
+		for _, rs := range rsUnfiltered {
+			if rs.Name == r.Name && rs.Revision == r.Revision && rs.Description == pub.Description {
+				r.Href = Link(rs.Links, "self")
+			}
+		}
+		if r.Href == "" {
+			return fmt.Errorf("Could not refind RightScript '%s' Revision %d after import!", r.Name, r.Revision)
+		}
+	}
+	r.Result.Href = r.Href
+
+	return nil
+}
+
+func (c *Client) pushLocal(r *RightScript, options PushOptions) error {
+	defer r.RemoveFetchedAttachments()
+	client := c.API
+
+	createLocator := client.RightScriptLocator("/api/right_scripts")
+	scriptName := options.Name(r.Metadata.Name)
+	foundId, err := c.IdByName(scriptName)
+	if err != nil {
+		return err
+	}
+
+	fileSrc, notes, err := r.UploadSource(options)
+	if err != nil {
+		return err
+	}
+	for _, note := range notes {
+		fmt.Fprintf(c.stdout(), "  %s\n", note)
+	}
+	description := r.Metadata.Description
+	if options.DescriptionFromReadme && description == "" {
+		readme := filepath.Join(filepath.Dir(r.Path), ReadmeFile)
+		if content, err := ioutil.ReadFile(readme); err == nil {
+			fmt.Fprintf(c.stdout(), "  Using %s as the description\n", readme)
+			description = strings.TrimSpace(RemoveCarriageReturns(string(content)))
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if foundId != "" && options.RenameOnConflict {
+		conflicting := func(id string) (bool, error) {
+			managed, err := c.isManaged("/api/right_scripts/" + id)
+			if err != nil || managed {
+				return false, err
+			}
+			existingSource, err := c.Source(client.RightScriptLocator("/api/right_scripts/" + id))
+			if err != nil {
+				return false, err
+			}
+			return ConflictingSource(existingSource, fileSrc, r.Metadata.Name), nil
+		}
+		// Use the first suffixed name which is either free or was uploaded by right_st or from this script before
+		for n := 2; ; n++ {
+			conflict, err := conflicting(foundId)
+			if err != nil {
+				return err
+			}
+			if !conflict {
+				break
+			}
+			newName := fmt.Sprintf("%s_%d", options.Name(r.Metadata.Name), n)
+			if foundId, err = c.IdByName(newName); err != nil {
+				return err
+			}
+			fmt.Fprintf(c.stdout(), "  RightScript named '%s' was not uploaded from %s, trying the name '%s' instead\n", scriptName, r.Path, newName)
+			scriptName = newName
+			if foundId == "" {
+				break
+			}
+		}
+	}
+
+	if err := CheckCreateOrUpdate(scriptName, foundId, options); err != nil {
+		return err
+	}
+
+	var rightscriptLocator *cm15.RightScriptLocator
+
+	if foundId == "" {
+		if options.IfMatch != "" {
+			return fmt.Errorf("RightScript '%s' does not exist, it cannot match --if-match %s", scriptName, options.IfMatch)
+		}
+		if options.MetadataOnly {
+			fmt.Fprintf(c.stdout(), "  RightScript named '%s' does not exist yet, its source will be uploaded as well\n", scriptName)
+		}
+		fmt.Fprintf(c.stdout(), "  Creating a new RightScript named '%s' from %s\n", scriptName, r.Path)
+		// New one, perform create call
+		params := cm15.RightScriptParam2{
+			Name:        scriptName,
+			Description: description,
+			Packages:    r.Metadata.Packages,
+			Source:      string(fileSrc),
+		}
+		rightscriptLocator, err = createLocator.Create(&params)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(c.stdout(), "    RightScript created with HREF %s\n", rightscriptLocator.Href)
+		r.Href = string(rightscriptLocator.Href)
+		if !options.NoMarker {
+			err = client.TagLocator("/api/tags/multi_add").MultiAdd([]string{r.Href}, []string{ManagedTag})
+			if err != nil {
+				return err
+			}
+		}
+		r.Result = &PushResult{Path: r.Path, Name: scriptName, Action: "created", Href: r.Href}
+	} else {
+		// Found existing, do an update
+		href := fmt.Sprintf("/api/right_scripts/%s", foundId)
+		rightscriptLocator = client.RightScriptLocator(href)
+		if options.IfMatch != "" {
+			if err := c.checkIfMatch(rightscriptLocator, scriptName, options.IfMatch); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(c.stdout(), "  Updating existing RightScript named '%s' with HREF %s from %s\n", scriptName, href, r.Path)
+
+		params := cm15.RightScriptParam3{
+			Name:        scriptName,
+			Description: description,
+			Packages:    r.Metadata.Packages,
+		}
+		// Leaving Source empty omits it from the update so the source is left untouched
+		if options.MetadataOnly {
+			fmt.Fprintf(c.stdout(), "    Only updating metadata, source will not be uploaded\n")
+		} else {
+			params.Source = string(fileSrc)
+		}
+		if options.Diff {
+			if err := c.printDiff(r, rightscriptLocator, fileSrc, options); err != nil {
+				return err
+			}
+		}
+		err = rightscriptLocator.Update(&params)
+		if err != nil {
+			return err
+		}
+		r.Href = href
+		r.Result = &PushResult{Path: r.Path, Name: scriptName, Action: "updated", Href: r.Href}
+	}
+
+	if options.NoAttachments {
+		fmt.Fprintf(c.stdout(), "    Skipping attachments\n")
+		return nil
+	}
+
+	attachmentsHref := fmt.Sprintf("%s/attachments", rightscriptLocator.Href)
+	attachmentsLocator := client.RightScriptAttachmentLocator(attachmentsHref)
+	attachments, err := attachmentsLocator.Index(rsapi.APIParams{})
+	if err != nil {
+		return err
+	}
+
+	changes := &AttachmentChanges{Uploaded: []string{}, Deleted: []string{}, Renamed: []string{}, Unchanged: []string{}}
+	r.Result.Attachments = changes
+
+	toUpload := make(map[string]string)                           // scripts we want to upload
+	onRightscript := make(map[string]*cm15.RightScriptAttachment) // scripts attached to the rightsript
+	for _, a := range r.Metadata.Attachments {
+		md5, err := c.AttachmentDigest(r, a)
+		if err != nil {
+			return err
+		}
+		// We use a compound key with the name+md5 here to work around a couple corner cases
+		//   - if the file is renamed, the remote attachment with the same md5 is renamed
+		//   - if two files have the same md5 for whatever reason they won't clash
+		toUpload[options.AttachmentName(a)+"_"+md5] = a
+	}
+	for _, a := range attachments {
+		onRightscript[path.Base(a.Filename)+"_"+a.Digest] = a
+	}
+	// Maps are iterated in random order, so go through the keys in order instead to rename, delete, and upload the
+	// attachments the same way every time
+	uploadKeys := make([]string, 0, len(toUpload))
+	for digestKey := range toUpload {
+		uploadKeys = append(uploadKeys, digestKey)
+	}
+	SortAttachmentKeys(uploadKeys)
+	remoteKeys := func() []string {
+		keys := make([]string, 0, len(onRightscript))
+		for remoteKey := range onRightscript {
+			keys = append(keys, remoteKey)
+		}
+		SortAttachmentKeys(keys)
+		return keys
+	}
+
+	// Before anything is deleted, look for attachments which were merely renamed on
+	// disk: same md5 but a different name. Those get renamed in place instead of being
+	// deleted and reuploaded.
+	for _, digestKey := range uploadKeys {
+		name := toUpload[digestKey]
+		if _, ok := onRightscript[digestKey]; ok {
+			continue
+		}
+		digestKeyParts := strings.Split(digestKey, "_")
+		md5 := digestKeyParts[len(digestKeyParts)-1]
+		for _, remoteKey := range remoteKeys() {
+			a := onRightscript[remoteKey]
+			if _, wanted := toUpload[remoteKey]; wanted || a.Digest != md5 {
+				continue
+			}
+			loc := a.Locator(client)
+
+			remoteName := options.AttachmentName(name)
+			fmt.Fprintf(c.stdout(), "  Renaming attachment '%s' to '%s' with HREF '%s'\n", a.Filename, remoteName, loc.Href)
+			err := loc.Update(&cm15.RightScriptAttachmentParam2{Filename: remoteName})
+			if err != nil {
+				return err
+			}
+			changes.Renamed = append(changes.Renamed, a.Filename+" -> "+remoteName)
+			a.Filename = remoteName
+			delete(onRightscript, remoteKey)
+			onRightscript[digestKey] = a
+			break
+		}
+	}
+
+	// Two passes. First pass we delete RightScripts. This comes up when a file was
+	// removed from the RightScript, or when the contents of a file on disk changed.
+	// In the second case, the second pass will reupload the correct attachment.
+	for _, digestKey := range remoteKeys() {
+		a := onRightscript[digestKey]
+		if _, ok := toUpload[digestKey]; !ok {
+			loc := a.Locator(client)
+
+			fmt.Fprintf(c.stdout(), "  Deleting attachment '%s' with HREF '%s'\n", a.Filename, loc.Href)
+			err := loc.Destroy()
+			if err != nil {
+				return err
+			}
+			changes.Deleted = append(changes.Deleted, a.Filename)
+		}
+	}
+
+	// Second pass, now upload any missing attachment and any attachments that were
+	// deleted because we changed file contents. Uploads run concurrently, bounded by
+	// maxConcurrentUploads, and every failure is collected rather than just the first.
+	var wg sync.WaitGroup
+	var lock sync.Mutex // for uploadErrors and changes
+	uploadErrors := []string{}
+	uploadTokens := make(chan struct{}, maxConcurrentUploads)
+	for _, digestKey := range uploadKeys {
+		name := toUpload[digestKey]
+		digestKeyParts := strings.Split(digestKey, "_")
+		md5 := digestKeyParts[len(digestKeyParts)-1]
+		if _, ok := onRightscript[digestKey]; ok {
+			fmt.Fprintf(c.stdout(), "  Attachment '%s' already uploaded with md5 %s\n", name, md5)
+			changes.Unchanged = append(changes.Unchanged, name)
+			continue
+		}
+		// printed before the upload is queued so the order does not depend on which upload starts first
+		remoteName := options.AttachmentName(name)
+		if remoteName != path.Base(name) {
+			fmt.Fprintf(c.stdout(), "  Uploading attachment '%s' as '%s' with md5 %s\n", name, remoteName, md5)
+		} else {
+			fmt.Fprintf(c.stdout(), "  Uploading attachment '%s' with md5 %s\n", name, md5)
+		}
+		wg.Add(1)
+		go func(name, remoteName string) {
+			defer wg.Done()
+			uploadTokens <- struct{}{}
+			defer func() {
+				<-uploadTokens
+			}()
+			href, err := c.uploadLocalAttachment(r, attachmentsLocator, name, remoteName)
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				uploadErrors = append(uploadErrors, fmt.Sprintf("%s: %s", name, err.Error()))
+				return
+			}
+			changes.Uploaded = append(changes.Uploaded, name)
+			if href != "" {
+				if changes.Hrefs == nil {
+					changes.Hrefs = make(map[string]string)
+				}
+				changes.Hrefs[name] = href
+			}
+		}(name, remoteName)
+	}
+	wg.Wait()
+	// uploads finish in any order
+	sort.Strings(changes.Uploaded)
+	for _, name := range changes.Uploaded {
+		if href, ok := changes.Hrefs[name]; ok {
+			fmt.Fprintf(c.stdout(), "    Attachment '%s' created with HREF %s\n", name, href)
+		}
+	}
+
+	if len(uploadErrors) > 0 {
+		sort.Strings(uploadErrors)
+		return fmt.Errorf("Failed to upload %d attachment(s) for RightScript '%s':\n  %s", len(uploadErrors),
+			scriptName, strings.Join(uploadErrors, "\n  "))
+	}
+
+	return nil
+}
+
+// SortAttachmentKeys sorts the name+md5 keys Push compares attachments by, by name and then md5. The name is
+// compared on its own since it may contain underscores itself.
+func SortAttachmentKeys(keys []string) {
+	sort.Sort(attachmentKeys(keys))
+}
+
+type attachmentKeys []string
+
+func (keys attachmentKeys) Len() int      { return len(keys) }
+func (keys attachmentKeys) Swap(i, j int) { keys[i], keys[j] = keys[j], keys[i] }
+func (keys attachmentKeys) Less(i, j int) bool {
+	iName, iDigest := splitAttachmentKey(keys[i])
+	jName, jDigest := splitAttachmentKey(keys[j])
+	if iName != jName {
+		return iName < jName
+	}
+	return iDigest < jDigest
+}
+
+func splitAttachmentKey(key string) (name, digest string) {
+	index := strings.LastIndex(key, "_")
+	if index < 0 {
+		return key, ""
+	}
+	return key[:index], key[index+1:]
+}
+
+// ManagedTag is added to the RightScripts created by upload so the RightScripts managed by right_st can be told apart
+// from ones created some other way.
+const ManagedTag = "right_st:managed=true"
+
+// isManaged returns whether the RightScript at href has ManagedTag.
+func (c *Client) isManaged(href string) (bool, error) {
+	tags, err := c.Tags(href)
+	if err != nil {
+		return false, err
+	}
+	for _, tag := range tags {
+		if tag == ManagedTag {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+var revisionMatcher = regexp.MustCompile(`^\d+$`)
+
+// checkIfMatch fails unless the RightScript at loc is still as expected by ifMatch: either a revision number which must
+// be its latest committed revision or an md5 digest which must be the digest of its HEAD source.
+func (c *Client) checkIfMatch(loc *cm15.RightScriptLocator, name, ifMatch string) error {
+	if revisionMatcher.MatchString(ifMatch) {
+		expected, _ := strconv.Atoi(ifMatch)
+		filter, err := NameFilter(name)
+		if err != nil {
+			return err
+		}
+		rightscripts, err := c.API.RightScriptLocator("/api/right_scripts").Index(rsapi.APIParams{"filter": []string{filter}})
+		if err != nil {
+			return err
+		}
+		latest := 0
+		for _, rs := range rightscripts {
+			if rs.Name == name && rs.Revision > latest {
+				latest = rs.Revision
+			}
+		}
+		if latest != expected {
+			return fmt.Errorf("RightScript '%s' changed remotely: expected latest revision %d but it is %d, "+
+				"download it again to review the changes or drop --if-match to overwrite them", name, expected, latest)
+		}
+		return nil
+	}
+
+	source, err := c.Source(loc)
+	if err != nil {
+		return err
+	}
+	if digest := SourceDigest(source); digest != strings.ToLower(ifMatch) {
+		return fmt.Errorf("RightScript '%s' changed remotely: expected source md5 %s but it is %s, "+
+			"download it again to review the changes or drop --if-match to overwrite them", name, ifMatch, digest)
+	}
+	return nil
+}
+
+// printDiff prints a unified diff from the source of the existing RightScript to the local source followed by which
+// attachments will be added, changed, or removed.
+func (c *Client) printDiff(r *RightScript, loc *cm15.RightScriptLocator, fileSrc []byte, options PushOptions) error {
+	if !options.MetadataOnly {
+		existingSource, err := c.Source(loc)
+		if err != nil {
+			return err
+		}
+		diff := UnifiedDiff(string(loc.Href), r.sourcePath(), existingSource, fileSrc)
+		if diff == "" {
+			fmt.Fprintf(c.stdout(), "    Source unchanged\n")
+		} else {
+			fmt.Fprint(c.stdout(), diff)
+		}
+	}
+	if options.NoAttachments {
+		return nil
+	}
+
+	changes, err := c.attachmentChanges(r, loc, options)
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		fmt.Fprintf(c.stdout(), "    %s\n", change)
+	}
+	return nil
+}
+
+// attachmentChanges describes which attachments will be added, changed, or removed when pushing to the existing
+// RightScript at loc.
+func (c *Client) attachmentChanges(r *RightScript, loc *cm15.RightScriptLocator, options PushOptions) ([]string, error) {
+	attachments, err := c.API.RightScriptAttachmentLocator(string(loc.Href) + "/attachments").Index(rsapi.APIParams{})
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]string, len(attachments))
+	for _, a := range attachments {
+		existing[a.Filename] = a.Digest
+	}
+	changes := []string{}
+	local := make(map[string]bool, len(r.Metadata.Attachments))
+	for _, name := range r.Metadata.Attachments {
+		remoteName := options.AttachmentName(name)
+		local[remoteName] = true
+		digest, ok := existing[remoteName]
+		localDigest := r.AttachmentDigests[name]
+		if ok {
+			if localDigest, err = c.AttachmentDigest(r, name); err != nil {
+				return nil, err
+			}
+		}
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("Attachment '%s' will be added", name))
+		case digest != localDigest:
+			changes = append(changes, fmt.Sprintf("Attachment '%s' will be changed", name))
+		}
+	}
+	for _, a := range attachments {
+		if !local[a.Filename] {
+			changes = append(changes, fmt.Sprintf("Attachment '%s' will be removed", a.Filename))
+		}
+	}
+	return changes, nil
+}
+
+// CheckCreateOrUpdate returns an error when pushing the RightScript named scriptName would update the existing one with
+// ID foundId despite --create-only, or create it because foundId is empty despite --update-only.
+func CheckCreateOrUpdate(scriptName, foundId string, options PushOptions) error {
+	if foundId != "" && options.CreateOnly {
+		return fmt.Errorf("RightScript '%s' already exists with HREF /api/right_scripts/%s, --create-only does not update it",
+			scriptName, foundId)
+	} else if foundId == "" && options.UpdateOnly {
+		return &NotFoundError{fmt.Sprintf("RightScript '%s' does not exist, --update-only does not create it", scriptName)}
+	}
+	return nil
+}
+
+// Plan describes what pushing the RightScript will do, without changing anything: whether it is imported, created, or
+// updated and, unless attachments are skipped, which attachments change.
+func (c *Client) Plan(r *RightScript, options PushOptions) ([]string, error) {
+	if r.Type == PublishedRightScript {
+		return []string{fmt.Sprintf("Import RightScript '%s' revision %d from the MultiCloud Marketplace unless it is already imported", r.Name, r.Revision)}, nil
+	}
+	r.maxFileSize = options.MaxFileSize
+	scriptName := options.Name(r.Metadata.Name)
+	foundId, err := c.IdByName(scriptName)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckCreateOrUpdate(scriptName, foundId, options); err != nil {
+		return nil, err
+	}
+
+	if foundId == "" {
+		plan := []string{fmt.Sprintf("Create a new RightScript named '%s' from %s", scriptName, r.Path)}
+		if !options.NoAttachments {
+			for _, name := range r.Metadata.Attachments {
+				plan = append(plan, fmt.Sprintf("Attachment '%s' will be added", name))
+			}
+		}
+		return plan, nil
+	}
+	href := fmt.Sprintf("/api/right_scripts/%s", foundId)
+	plan := []string{fmt.Sprintf("Update existing RightScript named '%s' with HREF %s from %s", scriptName, href, r.Path)}
+	if options.NoAttachments {
+		return plan, nil
+	}
+	changes, err := c.attachmentChanges(r, c.API.RightScriptLocator(href), options)
+	if err != nil {
+		return nil, err
+	}
+	return append(plan, changes...), nil
+}
+
+// attachmentFile returns the path of the file of an attachment in the metadata, downloading it first when it refers to
+// an object in S3. The download is kept until RemoveFetchedAttachments.
+func (c *Client) attachmentFile(r *RightScript, name string) (string, error) {
+	if _, _, ok, _ := ParseS3Reference(name); !ok {
+		return filepath.Join(filepath.Dir(r.Path), "attachments", name), nil
+	}
+	if file, ok := r.fetched[name]; ok {
+		return file, nil
+	}
+	creds, err := LoadAWSCredentials()
+	if err != nil {
+		return "", err
+	}
+	if r.fetchDir == "" {
+		if r.fetchDir, err = MakeTempDir("right_st"); err != nil {
+			return "", err
+		}
+		r.fetched = make(map[string]string)
+	}
+	// each object in a numbered directory of its own so keys with the same base name never overwrite each other
+	dir := filepath.Join(r.fetchDir, strconv.Itoa(len(r.fetched)))
+	if err := os.Mkdir(dir, 0700); err != nil {
+		return "", err
+	}
+	fmt.Fprintf(c.stdout(), "  Downloading attachment %s\n", name)
+	file, digest, err := c.fetchS3Attachment(name, dir, *creds, r.maxFileSize)
+	if err != nil {
+		return "", err
+	}
+	r.fetched[name] = file
+	if r.AttachmentDigests == nil {
+		r.AttachmentDigests = make(map[string]string)
+	}
+	r.AttachmentDigests[name] = digest
+	return file, nil
+}
+
+// AttachmentDigest returns the md5 digest of an attachment in the metadata of r, computing it if validation did not.
+func (c *Client) AttachmentDigest(r *RightScript, name string) (string, error) {
+	if digest, ok := r.AttachmentDigests[name]; ok {
+		return digest, nil
+	}
+	file, err := c.attachmentFile(r, name)
+	if err != nil {
+		return "", err
+	}
+	if digest, ok := r.AttachmentDigests[name]; ok {
+		return digest, nil
+	}
+	digest, err := fmd5sum(file)
+	if err != nil {
+		return "", err
+	}
+	if r.AttachmentDigests == nil {
+		r.AttachmentDigests = make(map[string]string)
+	}
+	r.AttachmentDigests[name] = digest
+	return digest, nil
+}
+
+// Limit concurrency of attachment uploads for a single RightScript
+const maxConcurrentUploads = 4
+
+// uploadLocalAttachment uploads a single attachment from the attachments directory next to the RightScript and returns
+// the HREF of the created attachment if the API gave one.
+// The content is read in full and checked against the digest computed during validation first so that a file which
+// changed in the meantime is never uploaded under a digest it does not have.
+func (c *Client) uploadLocalAttachment(r *RightScript, loc *cm15.RightScriptAttachmentLocator, name, remoteName string) (string, error) {
+	fullPath, err := c.attachmentFile(r, name)
+	if err != nil {
+		return "", err
+	}
+	content, err := ioutil.ReadFile(fullPath)
+	if err != nil {
+		return "", err
+	}
+	md5, err := Md5sum(bytes.NewReader(content))
+	if err != nil {
+		return "", err
+	}
+	if expected := r.AttachmentDigests[name]; md5 != expected {
+		return "", fmt.Errorf("%s changed while uploading, its md5 was %s but is now %s", fullPath, expected, md5)
+	}
+	// FileUpload represents payload fields that correspond to multipart file uploads.
+	file := rsapi.FileUpload{Name: "right_script_attachment[content]", Reader: bytes.NewReader(content), Filename: remoteName}
+	return c.UploadAttachment(loc, &file, remoteName)
+}
@@ -0,0 +1,478 @@
+package rightscript
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// RightScripts as saved in the YAML on disk come in two varieties:
+// Type == local. This means that the source code of the RightScript is managed
+//   locally on disk. Path will be populated with the location of the file
+// Type == remote. This means that the source code lives in RightScale and is
+//   merely linked here. A few different combinations are possible then:
+//   (Name, Revision) or (Href)
+const (
+	LocalRightScript int = iota
+	PublishedRightScript
+)
+
+// ReadmeFile is the file next to a script used as its description with PushOptions.DescriptionFromReadme
+const ReadmeFile = "README.md"
+
+type RightScript struct {
+	Type int // LocalRightScript or PublishedRightScript
+	Href string
+	Path string // Needed for local case
+	// File the source is uploaded from when it is not Path, whose metadata is then added to the source
+	SourcePath string
+	Name       string // Needed for remote case
+	Revision   int    // Needed for remote case
+	Publisher  string // Needed for remote case
+	Metadata   RightScriptMetadata
+	Result     *PushResult // What the last Push did, used for --report
+	// md5 digests of the local attachments by their name in the metadata, computed once during validation so the
+	// content uploaded can be checked against the same digest that was compared with the remote attachments
+	AttachmentDigests map[string]string
+	// Local copies of the attachments which refer to objects in S3 by their name in the metadata, downloaded to
+	// numbered directories in fetchDir when they are first needed
+	fetched  map[string]string
+	fetchDir string
+	// Largest attachment allowed to be downloaded from S3, 0 for no limit
+	maxFileSize int64
+}
+
+// NormalizeEOL converts CRLF line endings in script source to LF. PowerShell scripts run on Windows so they are
+// returned unchanged. The boolean result is true if anything was converted.
+func NormalizeEOL(file string, source []byte) ([]byte, bool) {
+	if isPowerShell(file, source) || !bytes.Contains(source, []byte("\r\n")) {
+		return source, false
+	}
+	return bytes.Replace(source, []byte("\r\n"), []byte("\n"), -1), true
+}
+
+// isPowerShell guesses whether a script is PowerShell from its extension or a PowerShell shebang.
+func isPowerShell(file string, source []byte) bool {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".ps1", ".psm1", ".psd1":
+		return true
+	}
+	if matches := shebang.FindString(string(source)); matches != "" {
+		return strings.Contains(strings.ToLower(matches), "pwsh") || strings.Contains(strings.ToLower(matches), "powershell")
+	}
+	return false
+}
+
+// This can be improved to look for bash'isms for older style scripts, powershellisms, etc.
+func guessExtension(source string) string {
+	if matches := shebang.FindStringSubmatch(source); len(matches) > 0 {
+		if strings.Contains(matches[0], "ruby") {
+			return ".rb"
+		} else if strings.Contains(matches[0], "perl") {
+			return ".pl"
+		} else if strings.Contains(matches[0], "sh") { // bash + sh
+			return ".sh"
+		}
+	}
+
+	return ""
+}
+
+// CanonicalMetadata returns metadata normalized for --canonical downloads so the metadata written for a RightScript
+// stays the same between downloads: attachments are sorted and trailing whitespace is removed from the description.
+func CanonicalMetadata(metadata RightScriptMetadata) RightScriptMetadata {
+	attachments := make([]string, len(metadata.Attachments))
+	copy(attachments, metadata.Attachments)
+	sort.Strings(attachments)
+	metadata.Attachments = attachments
+
+	lines := strings.Split(metadata.Description, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	metadata.Description = strings.TrimRight(strings.Join(lines, "\n"), "\n")
+	return metadata
+}
+
+// Convert a JSON response to InputMetadata struct
+func JsonMapToInput(input map[string]interface{}) InputMetadata {
+	var defaultValue *InputValue
+	if rawValue, ok := input["default_value"].(string); ok {
+		defaultValue, _ = ParseInputValue(rawValue)
+	}
+
+	possibleValues := []*InputValue{}
+	if rawPossibleValues, ok := input["possible_values"].([]interface{}); ok {
+		for _, rawValue := range rawPossibleValues {
+			possibleValue, _ := ParseInputValue(rawValue.(string))
+			possibleValues = append(possibleValues, possibleValue)
+		}
+	}
+
+	inputType, _ := parseInputType(input["kind"].(string))
+
+	categoryName, _ := input["category_name"].(string) // May be null
+	description, _ := input["description"].(string)    // May be null
+
+	return InputMetadata{
+		Name:           input["name"].(string),
+		Category:       categoryName,
+		Description:    description,
+		InputType:      inputType,
+		Required:       input["required"].(bool),
+		Advanced:       input["advanced"].(bool),
+		Default:        defaultValue,
+		PossibleValues: possibleValues,
+	}
+}
+
+// ValidateResult is the outcome of validating a single RightScript file.
+type ValidateResult struct {
+	File     string
+	Script   *RightScript
+	Warnings []string // Problems which do not make the RightScript invalid unless --strict is given
+	Err      error
+}
+
+// ValidateRightScripts validates files with up to parallel of them at once and returns the results in the same order
+// as files so output does not depend on which file finished first. Inputs must use one of categories if any are given
+// and the RightScripts must follow the rules of schema unless it is nil.
+func ValidateRightScripts(files []string, categories []string, schema *ValidationSchema, parallel int) []ValidateResult {
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	results := make([]ValidateResult, len(files))
+	var wg sync.WaitGroup
+	validateTokens := make(chan struct{}, parallel)
+	for index, file := range files {
+		wg.Add(1)
+		go func(index int, file string) {
+			defer wg.Done()
+			validateTokens <- struct{}{}
+			defer func() {
+				<-validateTokens
+			}()
+			script, err := ValidateRightScript(file, true)
+			if err == nil && len(categories) > 0 {
+				err = script.Metadata.Inputs.ValidateCategories(categories)
+			}
+			if err == nil && schema != nil {
+				err = schema.Validate(script)
+			}
+			var warnings []string
+			if err == nil {
+				warnings, err = script.Warnings(false, "")
+			}
+			results[index] = ValidateResult{File: file, Script: script, Warnings: warnings, Err: err}
+		}(index, file)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// ConflictingSource returns whether an existing RightScript with the source existingSource looks like it was not
+// uploaded from the local script with name in its metadata and source localSource. A RightScript is taken to be
+// uploaded from the local script if its source has metadata with the same name or if the sources are identical.
+func ConflictingSource(existingSource, localSource []byte, name string) bool {
+	if bytes.Equal(existingSource, localSource) {
+		return false
+	}
+	metadata, err := ParseRightScriptMetadata(bytes.NewReader(existingSource))
+	return err != nil || metadata == nil || metadata.Name != name
+}
+
+// SourceDigest returns the md5 digest of the source of a RightScript as shown by rightscript show, for use with
+// --if-match.
+func SourceDigest(source []byte) string {
+	digest, _ := Md5sum(bytes.NewReader(source))
+	return digest
+}
+
+// localAttachmentPath is the path of an attachment of the RightScript file which is either relative to the
+// "attachments" directory next to it or a full path.
+func localAttachmentPath(file, attachment string) string {
+	if filepath.IsAbs(attachment) {
+		return attachment
+	}
+	return filepath.Join(filepath.Dir(file), "attachments", attachment)
+}
+
+// CheckFileSize returns an error naming file and its size when it is larger than limit bytes. A limit of 0 means
+// there is none.
+func CheckFileSize(file string, limit int64) error {
+	if limit <= 0 {
+		return nil
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	if info.Size() > limit {
+		return fileTooLarge(file, info.Size(), limit)
+	}
+	return nil
+}
+
+// CheckAttachmentSizes returns an error for the first local attachment larger than limit bytes. Attachments in S3 are
+// checked as they are downloaded instead.
+func (r *RightScript) CheckAttachmentSizes(limit int64) error {
+	for _, attachment := range r.Metadata.Attachments {
+		if _, _, ok, _ := ParseS3Reference(attachment); ok {
+			continue
+		}
+		if err := CheckFileSize(localAttachmentPath(r.Path, attachment), limit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fileTooLarge(file string, size, limit int64) error {
+	return fmt.Errorf("%s is %d bytes, larger than the --max-file-size of %d bytes", file, size, limit)
+}
+
+// RemoveFetchedAttachments removes the attachments downloaded from S3.
+func (r *RightScript) RemoveFetchedAttachments() {
+	if r.fetchDir != "" {
+		RemoveTempDir(r.fetchDir)
+		r.fetched, r.fetchDir = nil, ""
+	}
+}
+
+// UploadSource returns the source of the script the way Push uploads it: decoded from options.SourceEncoding
+// without a UTF-8 byte order mark, with the metadata of a sidecar file added, and with CRLF line endings converted to
+// LF for options.NormalizeEOL. The notes describe the conversions made and warn about problems left in the source.
+func (r *RightScript) UploadSource(options PushOptions) ([]byte, []string, error) {
+	sourcePath := r.sourcePath()
+	source, err := ioutil.ReadFile(sourcePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	notes := []string{}
+	source, removedBOM, err := DecodeSource(sourcePath, source, options.SourceEncoding)
+	if err != nil {
+		return nil, nil, err
+	}
+	if removedBOM {
+		notes = append(notes, fmt.Sprintf("Removed UTF-8 byte order mark from %s", sourcePath))
+	}
+	if !utf8.Valid(source) {
+		notes = append(notes, fmt.Sprintf("WARNING: %s is not valid UTF-8 and may break on instances, use --source-encoding to convert it", sourcePath))
+	}
+	if source, err = r.withMetadata(source); err != nil {
+		return nil, nil, err
+	}
+	if options.NormalizeEOL {
+		var normalized bool
+		source, normalized = NormalizeEOL(sourcePath, source)
+		if normalized {
+			notes = append(notes, fmt.Sprintf("Converted CRLF line endings to LF in %s", sourcePath))
+		}
+	} else if bytes.Contains(source, []byte("\r\n")) && !isPowerShell(sourcePath, source) {
+		notes = append(notes, fmt.Sprintf("WARNING: %s has CRLF line endings which may break on Linux instances, use --normalize-eol to convert them", sourcePath))
+	}
+	return source, notes, nil
+}
+
+// withMetadata returns the source as it is uploaded. RightScale reads the inputs from the metadata comment in the source
+// so metadata from a sidecar file, included files, or the script of a separate source file has to be added to it.
+func (r *RightScript) withMetadata(source []byte) ([]byte, error) {
+	sourcePath := r.sourcePath()
+	if r.Metadata.Sidecar == "" && len(r.Metadata.Includes) == 0 && sourcePath == r.Path {
+		return source, nil
+	}
+	return ScaffoldBuffer(source, r.Metadata, sourcePath, "", false)
+}
+
+// sourcePath is the file the source of a local RightScript is uploaded from.
+func (r *RightScript) sourcePath() string {
+	if r.SourcePath != "" {
+		return r.SourcePath
+	}
+	return r.Path
+}
+
+// Warnings returns the problems with a valid RightScript which are likely mistakes: inputs with missing or similar
+// categories, a byte order mark or invalid UTF-8 after converting from encoding, and CRLF line endings, unless they
+// will be normalized, in anything other than a PowerShell script.
+func (r *RightScript) Warnings(normalizeEOL bool, encoding string) ([]string, error) {
+	warnings := r.Metadata.Inputs.CategoryWarnings()
+	sourcePath := r.sourcePath()
+	source, err := ioutil.ReadFile(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	if encoding != "" {
+		if source, _, err = DecodeSource(sourcePath, source, encoding); err != nil {
+			return nil, err
+		}
+	}
+	warnings = append(warnings, EncodingWarnings(sourcePath, source)...)
+	if !normalizeEOL {
+		if bytes.Contains(source, []byte("\r\n")) && !isPowerShell(sourcePath, source) {
+			warnings = append(warnings, "CRLF line endings may break on Linux instances, use --normalize-eol to convert them")
+		}
+	}
+	return warnings, nil
+}
+
+// Validates that a file has valid metadata, including attachments.
+// No metadata is considered valid, although the RightScriptMetadata returned will
+// be intialized to default values. A RightScriptMetadata struct might still be
+// returned if there are errors if the metadata was partially specified.
+// Validation only looks at local files, it must never create an API client.
+func ValidateRightScript(file string, ignoreMissingMetadata bool) (*RightScript, error) {
+	script, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer script.Close()
+
+	metadata, err := ParseRightScriptMetadataIn(script, filepath.Dir(file))
+	if err != nil {
+		return nil, err
+	}
+
+	sidecarFile := file + SidecarSuffix
+	if sidecar, err := os.Open(sidecarFile); err == nil {
+		defer sidecar.Close()
+		if metadata != nil {
+			return nil, fmt.Errorf("%s has both embedded metadata and a sidecar file %s, remove one of them", file, sidecarFile)
+		}
+		metadata, err = ParseRightScriptSidecar(sidecar, filepath.Dir(file))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", sidecarFile, err.Error())
+		}
+		metadata.Sidecar = sidecarFile
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if metadata == nil {
+		if ignoreMissingMetadata {
+			metadata = new(RightScriptMetadata)
+			scriptname := path.Base(file)
+			scriptext := path.Ext(scriptname)
+			scriptname = strings.TrimRight(scriptname, scriptext)
+			metadata.Name = scriptname
+		} else {
+			return nil, fmt.Errorf("No embedded metadata for %s. Use --force to upload anyways.", file)
+		}
+	}
+
+	rightScript := RightScript{
+		Type:     LocalRightScript,
+		Href:     "",
+		Path:     file,
+		Name:     metadata.Name,
+		Metadata: *metadata,
+
+		AttachmentDigests: make(map[string]string),
+	}
+
+	if metadata.Inputs == nil {
+		return &rightScript, fmt.Errorf("Inputs must be specified")
+	}
+
+	inputErrors := []string{}
+	for _, input := range metadata.Inputs {
+		if err := input.Validate(); err != nil {
+			inputErrors = append(inputErrors, err.Error())
+		}
+	}
+	if len(inputErrors) > 0 {
+		return &rightScript, fmt.Errorf("%s", strings.Join(inputErrors, "\n  "))
+	}
+
+	seenAttachments := make(map[string]bool)
+	for _, attachment := range metadata.Attachments {
+		if seenAttachments[path.Base(attachment)] {
+			return nil, fmt.Errorf("Attachment name %s appears twice", attachment)
+		}
+		seenAttachments[path.Base(attachment)] = true
+		// attachments in S3 are only downloaded when uploading since validation does not touch the network
+		if _, _, ok, err := ParseS3Reference(attachment); ok {
+			if err != nil {
+				return &rightScript, err
+			}
+			continue
+		}
+		fullPath := localAttachmentPath(file, attachment)
+
+		file, err := os.Open(fullPath)
+		if err != nil {
+			return &rightScript, fmt.Errorf("Could not open attachment: %s. Make sure attachment is in \"attachments/\" subdirectory or an absolute path", err.Error())
+		}
+		md5, err := Md5sum(file)
+		file.Close()
+		if err != nil {
+			return &rightScript, err
+		}
+		rightScript.AttachmentDigests[attachment] = md5
+	}
+
+	if metadata.Name == "" {
+		return &rightScript, fmt.Errorf("Name must be specified")
+	}
+
+	return &rightScript, nil
+}
+
+func (rs RightScript) MarshalYAML() (interface{}, error) {
+	if rs.Type == LocalRightScript {
+		return rs.Path, nil
+	} else {
+		destMap := make(map[string]interface{})
+		destMap["Name"] = rs.Name
+		destMap["Revision"] = rs.Revision
+		destMap["Publisher"] = rs.Publisher
+		return destMap, nil
+	}
+}
+
+func (rs *RightScript) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var pathType string
+	var mapType map[string]string
+	errorMsg := "Could not unmarshal RightScript. Must be either a path to file on disk or a hash with a Name/Revision keys"
+	err := unmarshal(&pathType)
+	if err == nil {
+		rs.Type = LocalRightScript
+		rs.Path = pathType
+	} else {
+		err = unmarshal(&mapType)
+		if err != nil {
+			return fmt.Errorf(errorMsg)
+		}
+		name, ok := mapType["Name"]
+		if !ok {
+			return fmt.Errorf(errorMsg)
+		}
+		rs.Name = name
+		publisher, ok := mapType["Publisher"]
+		if ok {
+			rs.Publisher = publisher
+		}
+		revStr, ok := mapType["Revision"]
+		if !ok {
+			return fmt.Errorf(errorMsg)
+		}
+		rev, err := strconv.Atoi(revStr)
+		if err != nil {
+			return fmt.Errorf("Revision must be an integer")
+		}
+		rs.Type = PublishedRightScript
+		rs.Revision = rev
+	}
+
+	return nil
+}
@@ -0,0 +1,13 @@
+package rightscript_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestRightScript(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "RightScript Suite")
+}
@@ -0,0 +1,549 @@
+package rightscript_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/rightscale/right_st/rightscript"
+	"github.com/rightscale/rsc/cm15"
+	"github.com/rightscale/rsc/rsapi"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RightScript", func() {
+	Describe("Push options name", func() {
+		It("Leaves the name alone without a prefix or suffix", func() {
+			Expect(PushOptions{}.Name("Script")).To(Equal("Script"))
+		})
+
+		It("Adds a prefix and a suffix", func() {
+			Expect(PushOptions{Prefix: "staging"}.Name("Script")).To(Equal("staging_Script"))
+			Expect(PushOptions{Suffix: "v2"}.Name("Script")).To(Equal("Script_v2"))
+			Expect(PushOptions{Prefix: "staging", Suffix: "v2"}.Name("Script")).To(Equal("staging_Script_v2"))
+		})
+	})
+
+	Describe("Check create or update", func() {
+		It("Allows creating and updating without --create-only or --update-only", func() {
+			Expect(CheckCreateOrUpdate("Script", "", PushOptions{})).To(Succeed())
+			Expect(CheckCreateOrUpdate("Script", "123", PushOptions{})).To(Succeed())
+		})
+
+		It("Only allows creating with --create-only", func() {
+			Expect(CheckCreateOrUpdate("Script", "", PushOptions{CreateOnly: true})).To(Succeed())
+			Expect(CheckCreateOrUpdate("Script", "123", PushOptions{CreateOnly: true})).To(MatchError(
+				"RightScript 'Script' already exists with HREF /api/right_scripts/123, --create-only does not update it"))
+		})
+
+		It("Only allows updating with --update-only", func() {
+			Expect(CheckCreateOrUpdate("Script", "123", PushOptions{UpdateOnly: true})).To(Succeed())
+			Expect(CheckCreateOrUpdate("Script", "", PushOptions{UpdateOnly: true})).To(MatchError(
+				"RightScript 'Script' does not exist, --update-only does not create it"))
+		})
+	})
+
+	Describe("Push options attachment name", func() {
+		It("Uses the name of the file", func() {
+			Expect(PushOptions{}.AttachmentName("app.tar.gz")).To(Equal("app.tar.gz"))
+			Expect(PushOptions{}.AttachmentName("s3://artifacts/tools/tool.tar.gz")).To(Equal("tool.tar.gz"))
+		})
+
+		It("Adds the attachment prefix", func() {
+			Expect(PushOptions{AttachmentPrefix: "staging"}.AttachmentName("app.tar.gz")).To(Equal("staging_app.tar.gz"))
+			Expect(PushOptions{AttachmentPrefix: "staging", Prefix: "other"}.AttachmentName("s3://artifacts/tool.tar.gz")).To(
+				Equal("staging_tool.tar.gz"))
+		})
+	})
+
+	Describe("Conflicting source", func() {
+		local := []byte("#!/bin/bash\n# ---\n# RightScript Name: Mine\n# Inputs: {}\n# Attachments: []\n# ...\necho new\n")
+
+		It("Does not conflict with a RightScript uploaded from the same script", func() {
+			existing := []byte("#!/bin/bash\n# ---\n# RightScript Name: Mine\n# Inputs: {}\n# Attachments: []\n# ...\necho old\n")
+			Expect(ConflictingSource(existing, local, "Mine")).To(BeFalse())
+			Expect(ConflictingSource([]byte("echo same\n"), []byte("echo same\n"), "Mine")).To(BeFalse())
+		})
+
+		It("Conflicts with a RightScript from another script", func() {
+			existing := []byte("#!/bin/bash\n# ---\n# RightScript Name: Theirs\n# Inputs: {}\n# Attachments: []\n# ...\necho old\n")
+			Expect(ConflictingSource(existing, local, "Mine")).To(BeTrue())
+			Expect(ConflictingSource([]byte("echo theirs\n"), local, "Mine")).To(BeTrue())
+		})
+	})
+
+	Describe("Name filter", func() {
+		It("Uses ordinary names as they are", func() {
+			for _, name := range []string{"Install Packages", "setup (v2) - 50%", "a = b", "<tag> & \"quoted\""} {
+				filter, err := NameFilter(name)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(filter).To(Equal("name==" + name))
+			}
+		})
+
+		It("Filters on the longest part of names with filter syntax", func() {
+			for name, expected := range map[string]string{
+				"a==b is true":     "name==b is true",
+				"Install foo, bar": "name==Install foo",
+				"x <> yz":          "name== yz",
+			} {
+				filter, err := NameFilter(name)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(filter).To(Equal(expected))
+			}
+		})
+
+		It("Rejects names made up entirely of filter syntax", func() {
+			_, err := NameFilter("==")
+			Expect(err).To(MatchError("'==' cannot be used as a name filter since it is made up entirely of filter syntax"))
+			_, err = NameFilter(", <>")
+			Expect(err).NotTo(HaveOccurred())
+			_, err = NameFilter(",<>")
+			Expect(err).To(HaveOccurred())
+		})
+
+		Context("With an API server", func() {
+			var (
+				server  *httptest.Server
+				filters []string
+				client  *Client
+			)
+
+			BeforeEach(func() {
+				filters = nil
+				server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					filters = append(filters, r.URL.Query()["filter[]"]...)
+					w.Header().Set("Content-Type", "application/json")
+					w.Write([]byte(`[
+  {"id": "1", "name": "a==b is true", "revision": 0},
+  {"id": "2", "name": "b is true", "revision": 0},
+  {"id": "3", "name": "Install foo, bar", "revision": 0},
+  {"id": "4", "name": "Install foo", "revision": 0},
+  {"id": "5", "name": "Install foo, bar", "revision": 1}
+]`))
+				}))
+				api := cm15.New(strings.TrimPrefix(server.URL, "http://"), rsapi.NewTokenAuthenticator("token", 1))
+				api.Insecure = true
+				client = &Client{API: api}
+			})
+
+			AfterEach(func() {
+				server.Close()
+			})
+
+			It("Resolves names with filter syntax", func() {
+				id, err := client.IdByName("a==b is true")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(id).To(Equal("1"))
+				id, err = client.IdByName("Install foo, bar")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(id).To(Equal("3"))
+				Expect(filters).To(Equal([]string{"name==b is true", "name==Install foo"}))
+			})
+		})
+	})
+
+	Describe("Attachment body", func() {
+		var gzipped []byte
+
+		BeforeEach(func() {
+			buffer := new(bytes.Buffer)
+			writer := gzip.NewWriter(buffer)
+			writer.Write([]byte("compressed"))
+			writer.Close()
+			gzipped = buffer.Bytes()
+		})
+
+		contentPart := func(filename string, content []byte) (*multipart.Part, []byte) {
+			file := rsapi.FileUpload{Name: "right_script_attachment[content]", Filename: filename, Reader: bytes.NewReader(content)}
+			body, contentType, err := AttachmentBody(&file, filename)
+			Expect(err).NotTo(HaveOccurred())
+			_, params, err := mime.ParseMediaType(contentType)
+			Expect(err).NotTo(HaveOccurred())
+			reader := multipart.NewReader(body, params["boundary"])
+			part, err := reader.NextPart()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(part.FormName()).To(Equal("right_script_attachment[filename]"))
+			part, err = reader.NextPart()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(part.FormName()).To(Equal("right_script_attachment[content]"))
+			Expect(part.FileName()).To(Equal(filename))
+			data, err := ioutil.ReadAll(part)
+			Expect(err).NotTo(HaveOccurred())
+			return part, data
+		}
+
+		It("Sends a text script as text", func() {
+			part, data := contentPart("setup.sh", []byte("#!/bin/bash\necho hi\n"))
+			Expect(part.Header.Get("Content-Type")).To(Equal("text/plain; charset=utf-8"))
+			Expect(string(data)).To(Equal("#!/bin/bash\necho hi\n"))
+		})
+
+		It("Sends a gzip attachment as gzip", func() {
+			part, data := contentPart("files.tar.gz", gzipped)
+			Expect(part.Header.Get("Content-Type")).To(Equal("application/x-gzip"))
+			Expect(data).To(Equal(gzipped))
+		})
+
+		It("Falls back to application/octet-stream", func() {
+			Expect(AttachmentContentType("blob", []byte{0, 1, 2, 3})).To(Equal("application/octet-stream"))
+		})
+	})
+
+	Describe("Location HREF", func() {
+		It("Returns the HREF from a full URL or an HREF", func() {
+			Expect(LocationHref("https://us-3.rightscale.com/api/right_scripts/1/attachments/2")).To(
+				Equal("/api/right_scripts/1/attachments/2"))
+			Expect(LocationHref("/api/right_scripts/1/attachments/2")).To(Equal("/api/right_scripts/1/attachments/2"))
+			Expect(LocationHref("")).To(BeEmpty())
+		})
+	})
+
+	Describe("Sort attachment keys", func() {
+		It("Orders attachments by name and then md5 regardless of the order they came in", func() {
+			expected := []string{"a_0cc175b9", "a_c4ca4238", "a_b_0cc175b9", "b.txt_4a8a08f0", "z_0cc175b9"}
+			for _, keys := range [][]string{
+				{"z_0cc175b9", "a_b_0cc175b9", "b.txt_4a8a08f0", "a_c4ca4238", "a_0cc175b9"},
+				{"a_b_0cc175b9", "a_c4ca4238", "z_0cc175b9", "a_0cc175b9", "b.txt_4a8a08f0"},
+			} {
+				SortAttachmentKeys(keys)
+				Expect(keys).To(Equal(expected))
+			}
+		})
+	})
+
+	Describe("Canonical metadata", func() {
+		It("Sorts attachments and trims the description", func() {
+			metadata := RightScriptMetadata{
+				Name:        "Script",
+				Description: "First line  \nSecond line\t\n\n",
+				Attachments: []string{"b.txt", "a.txt", "conf/c.txt"},
+			}
+			canonical := CanonicalMetadata(metadata)
+			Expect(canonical.Attachments).To(Equal([]string{"a.txt", "b.txt", "conf/c.txt"}))
+			Expect(canonical.Description).To(Equal("First line\nSecond line"))
+			Expect(metadata.Attachments).To(Equal([]string{"b.txt", "a.txt", "conf/c.txt"}))
+		})
+	})
+
+	Describe("Source digest", func() {
+		It("Returns the md5 of the source", func() {
+			Expect(SourceDigest([]byte("#!/bin/bash\necho hello\n"))).To(Equal("5d9c4609cd936e80bac8c9ef7b27ea73"))
+		})
+	})
+
+	Describe("Normalize EOL", func() {
+		It("Converts CRLF to LF", func() {
+			source, normalized := NormalizeEOL("script.sh", []byte("#!/bin/bash\r\necho hi\r\n"))
+			Expect(normalized).To(BeTrue())
+			Expect(string(source)).To(Equal("#!/bin/bash\necho hi\n"))
+		})
+
+		It("Leaves LF alone", func() {
+			_, normalized := NormalizeEOL("script.sh", []byte("#!/bin/bash\necho hi\n"))
+			Expect(normalized).To(BeFalse())
+		})
+
+		It("Leaves PowerShell scripts alone", func() {
+			source, normalized := NormalizeEOL("script.ps1", []byte("Write-Output hi\r\n"))
+			Expect(normalized).To(BeFalse())
+			Expect(string(source)).To(Equal("Write-Output hi\r\n"))
+			_, normalized = NormalizeEOL("script", []byte("#!/usr/bin/env pwsh\r\nWrite-Output hi\r\n"))
+			Expect(normalized).To(BeFalse())
+		})
+	})
+
+	Describe("Decode source", func() {
+		It("Removes a UTF-8 byte order mark", func() {
+			source, removed, err := DecodeSource("script.sh", []byte("\xef\xbb\xbf#!/bin/bash\necho hi\n"), "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(removed).To(BeTrue())
+			Expect(string(source)).To(Equal("#!/bin/bash\necho hi\n"))
+		})
+
+		It("Leaves the byte order mark of PowerShell scripts alone", func() {
+			source, removed, err := DecodeSource("script.ps1", []byte("\xef\xbb\xbfWrite-Output hi\n"), "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(removed).To(BeFalse())
+			Expect(string(source)).To(Equal("\xef\xbb\xbfWrite-Output hi\n"))
+		})
+
+		It("Converts from a declared encoding", func() {
+			source, _, err := DecodeSource("script.sh", []byte("echo caf\xe9 \x80\n"), "windows-1252")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(source)).To(Equal("echo café €\n"))
+			source, removed, err := DecodeSource("script.sh", []byte("\xff\xfee\x00c\x00h\x00o\x00\n\x00"), "utf-16le")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(removed).To(BeTrue())
+			Expect(string(source)).To(Equal("echo\n"))
+		})
+
+		It("Fails for an unknown encoding", func() {
+			_, _, err := DecodeSource("script.sh", []byte("echo hi\n"), "ebcdic")
+			Expect(err).To(MatchError(ContainSubstring("Unknown source encoding ebcdic")))
+		})
+
+		It("Warns about a byte order mark and invalid UTF-8", func() {
+			Expect(EncodingWarnings("script.sh", []byte("#!/bin/bash\necho café\n"))).To(BeEmpty())
+			Expect(EncodingWarnings("script.sh", []byte("\xef\xbb\xbf#!/bin/bash\n"))).To(ConsistOf(ContainSubstring("byte order mark")))
+			Expect(EncodingWarnings("script.sh", []byte("#!/bin/bash\necho caf\xe9\n"))).To(ConsistOf(ContainSubstring("not valid UTF-8 from line 2")))
+		})
+	})
+
+	Describe("Write file atomic", func() {
+		var tempDir string
+
+		BeforeEach(func() {
+			var err error
+			tempDir, err = ioutil.TempDir("", "atomic")
+			if err != nil {
+				panic(err)
+			}
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(tempDir)
+		})
+
+		It("Replaces the file once it has been written", func() {
+			file := filepath.Join(tempDir, "script.sh")
+			Expect(ioutil.WriteFile(file, []byte("old"), 0644)).To(Succeed())
+			Expect(WriteFileAtomic(file, 0755, func(w io.Writer) error {
+				_, err := w.Write([]byte("new"))
+				return err
+			})).To(Succeed())
+			Expect(ioutil.ReadFile(file)).To(Equal([]byte("new")))
+			info, err := os.Stat(file)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0755)))
+		})
+
+		It("Leaves the existing file alone and cleans up when the write fails", func() {
+			file := filepath.Join(tempDir, "script.sh")
+			Expect(ioutil.WriteFile(file, []byte("old"), 0644)).To(Succeed())
+			Expect(WriteFileAtomic(file, 0644, func(w io.Writer) error {
+				w.Write([]byte("partial"))
+				return errors.New("connection reset")
+			})).To(MatchError("connection reset"))
+			Expect(ioutil.ReadFile(file)).To(Equal([]byte("old")))
+			files, err := ioutil.ReadDir(tempDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(files).To(HaveLen(1))
+		})
+	})
+
+	Describe("Check file size", func() {
+		var file string
+
+		BeforeEach(func() {
+			f, err := ioutil.TempFile("", "size")
+			if err != nil {
+				panic(err)
+			}
+			f.Write([]byte("0123456789"))
+			f.Close()
+			file = f.Name()
+		})
+
+		AfterEach(func() {
+			os.Remove(file)
+		})
+
+		It("Allows files up to the limit", func() {
+			Expect(CheckFileSize(file, 10)).To(Succeed())
+			Expect(CheckFileSize(file, 0)).To(Succeed())
+		})
+
+		It("Names the file and its size when it is over the limit", func() {
+			Expect(CheckFileSize(file, 9)).To(MatchError(file + " is 10 bytes, larger than the --max-file-size of 9 bytes"))
+		})
+	})
+
+	Describe("Validate RightScript", func() {
+		var (
+			tempDir string
+			script  string
+		)
+
+		BeforeEach(func() {
+			var err error
+			tempDir, err = ioutil.TempDir("", "rightscript")
+			if err != nil {
+				panic(err)
+			}
+			script = filepath.Join(tempDir, "script.sh")
+			err = ioutil.WriteFile(script, []byte(`#!/bin/bash
+# ---
+# RightScript Name: Offline Script
+# Inputs:
+#   INPUT:
+#     Input Type: single
+#     Category: Application
+#     Required: true
+#     Advanced: false
+#     Default: text:foo
+# Attachments:
+# - attachment.txt
+# ...
+
+cat $RS_ATTACH_DIR/attachment.txt
+`), 0644)
+			if err != nil {
+				panic(err)
+			}
+			if err := os.Mkdir(filepath.Join(tempDir, "attachments"), 0755); err != nil {
+				panic(err)
+			}
+			if err := ioutil.WriteFile(filepath.Join(tempDir, "attachments", "attachment.txt"), []byte("attached\n"), 0644); err != nil {
+				panic(err)
+			}
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(tempDir)
+		})
+
+		It("Validates a RightScript without any API client", func() {
+			var (
+				rightScript *RightScript
+				err         error
+			)
+			Expect(func() {
+				rightScript, err = ValidateRightScript(script, false)
+			}).NotTo(Panic())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rightScript.Name).To(Equal("Offline Script"))
+			Expect(rightScript.Metadata.Attachments).To(Equal([]string{"attachment.txt"}))
+		})
+
+		It("Leaves attachments in S3 to be downloaded when uploading", func() {
+			source, err := ioutil.ReadFile(script)
+			Expect(err).NotTo(HaveOccurred())
+			source = bytes.Replace(source, []byte("# - attachment.txt\n"), []byte("# - attachment.txt\n# - s3://artifacts/tools/tool.tar.gz\n"), 1)
+			Expect(ioutil.WriteFile(script, source, 0644)).To(Succeed())
+			rightScript, err := ValidateRightScript(script, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rightScript.Metadata.Attachments).To(Equal([]string{"attachment.txt", "s3://artifacts/tools/tool.tar.gz"}))
+			Expect(rightScript.AttachmentDigests).NotTo(HaveKey("s3://artifacts/tools/tool.tar.gz"))
+
+			source = bytes.Replace(source, []byte("s3://artifacts/tools/tool.tar.gz"), []byte("s3://artifacts"), 1)
+			Expect(ioutil.WriteFile(script, source, 0644)).To(Succeed())
+			_, err = ValidateRightScript(script, false)
+			Expect(err).To(MatchError(ContainSubstring("must be of the form s3://bucket/key")))
+		})
+
+		It("Rejects attachments in S3 with the same name as another attachment", func() {
+			source, err := ioutil.ReadFile(script)
+			Expect(err).NotTo(HaveOccurred())
+			source = bytes.Replace(source, []byte("# - attachment.txt\n"), []byte("# - s3://artifacts/v1/tool.tar.gz\n"+
+				"# - s3://artifacts/v2/tool.tar.gz\n"), 1)
+			Expect(ioutil.WriteFile(script, source, 0644)).To(Succeed())
+			_, err = ValidateRightScript(script, false)
+			Expect(err).To(MatchError("Attachment name s3://artifacts/v2/tool.tar.gz appears twice"))
+		})
+
+		It("Records the md5 digests of the attachments", func() {
+			rightScript, err := ValidateRightScript(script, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rightScript.AttachmentDigests).To(Equal(map[string]string{
+				"attachment.txt": "029f03e6624a67393424f5c0cb5b1087",
+			}))
+		})
+
+		It("Warns about CRLF line endings unless they will be normalized", func() {
+			rightScript, err := ValidateRightScript(script, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rightScript.Warnings(false, "")).To(BeEmpty())
+
+			source, err := ioutil.ReadFile(script)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ioutil.WriteFile(script, bytes.Replace(source, []byte("\n"), []byte("\r\n"), -1), 0644)).To(Succeed())
+			Expect(rightScript.Warnings(false, "")).To(ConsistOf(ContainSubstring("CRLF line endings")))
+			Expect(rightScript.Warnings(true, "")).To(BeEmpty())
+		})
+
+		It("Checks the source file instead of the script when there is one", func() {
+			rightScript, err := ValidateRightScript(script, false)
+			Expect(err).NotTo(HaveOccurred())
+			built := filepath.Join(tempDir, "built.sh")
+			Expect(ioutil.WriteFile(built, []byte("#!/bin/bash\r\necho built\r\n"), 0644)).To(Succeed())
+			rightScript.SourcePath = built
+			Expect(rightScript.Warnings(false, "")).To(ConsistOf(ContainSubstring("CRLF line endings")))
+		})
+
+		It("Reads the metadata from a sidecar file", func() {
+			pure := filepath.Join(tempDir, "pure.sh")
+			if err := ioutil.WriteFile(pure, []byte("#!/bin/bash\necho $GREETING\n"), 0644); err != nil {
+				panic(err)
+			}
+			if err := ioutil.WriteFile(pure+SidecarSuffix, []byte(`RightScript Name: Pure Script
+Inputs:
+  GREETING:
+    Category: Greeting
+    Input Type: single
+Attachments: []
+`), 0644); err != nil {
+				panic(err)
+			}
+			rightScript, err := ValidateRightScript(pure, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rightScript.Name).To(Equal("Pure Script"))
+			Expect(rightScript.Metadata.Sidecar).To(Equal(pure + SidecarSuffix))
+			Expect(rightScript.Metadata.Inputs).To(HaveLen(1))
+		})
+
+		It("Returns an error for both embedded and sidecar metadata", func() {
+			if err := ioutil.WriteFile(script+SidecarSuffix, []byte("RightScript Name: Sidecar\nInputs: {}\n"), 0644); err != nil {
+				panic(err)
+			}
+			_, err := ValidateRightScript(script, false)
+			Expect(err).To(MatchError(ContainSubstring("has both embedded metadata and a sidecar file")))
+		})
+
+		It("Drops the sidecar files of scripts", func() {
+			files := []string{"a.sh", "a.sh" + SidecarSuffix, "orphan.sh" + SidecarSuffix}
+			Expect(DropSidecars(files)).To(Equal([]string{"a.sh", "orphan.sh" + SidecarSuffix}))
+		})
+
+		It("Validates several RightScripts in parallel in order", func() {
+			invalid := filepath.Join(tempDir, "invalid.sh")
+			if err := ioutil.WriteFile(invalid, []byte("#!/bin/bash\n# ---\n# RightScript Name: [\n# ...\n"), 0644); err != nil {
+				panic(err)
+			}
+			files := []string{script, invalid, script, invalid, script}
+			results := ValidateRightScripts(files, nil, nil, 3)
+			Expect(results).To(HaveLen(len(files)))
+			for index, result := range results {
+				Expect(result.File).To(Equal(files[index]))
+				if files[index] == invalid {
+					Expect(result.Err).To(HaveOccurred())
+				} else {
+					Expect(result.Err).NotTo(HaveOccurred())
+					Expect(result.Script.Name).To(Equal("Offline Script"))
+				}
+			}
+		})
+
+		It("Returns an error for a missing attachment without any API client", func() {
+			if err := os.Remove(filepath.Join(tempDir, "attachments", "attachment.txt")); err != nil {
+				panic(err)
+			}
+			var err error
+			Expect(func() {
+				_, err = ValidateRightScript(script, false)
+			}).NotTo(Panic())
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,295 @@
+package rightscript
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Attachments in metadata can refer to an object in S3 instead of a file in the attachments directory
+const s3Scheme = "s3://"
+
+// The SHA-256 of an empty payload, which is what every GET request has
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// AWSCredentials are the credentials S3 requests are signed with.
+type AWSCredentials struct {
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// ParseS3Reference splits an attachment of the form s3://bucket/key into its bucket and key. ok is false when it is
+// not an S3 reference at all.
+func ParseS3Reference(attachment string) (bucket, key string, ok bool, err error) {
+	if !strings.HasPrefix(attachment, s3Scheme) {
+		return "", "", false, nil
+	}
+	parts := strings.SplitN(strings.TrimPrefix(attachment, s3Scheme), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" || strings.HasSuffix(parts[1], "/") {
+		return "", "", true, fmt.Errorf("Attachment %s must be of the form s3://bucket/key", attachment)
+	}
+	return parts[0], parts[1], true, nil
+}
+
+// LoadAWSCredentials finds AWS credentials the same way the AWS CLI does: from the AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN environment variables, or else from the profile named by AWS_PROFILE
+// (default by default) in the shared credentials file.
+func LoadAWSCredentials() (*AWSCredentials, error) {
+	if id := os.Getenv("AWS_ACCESS_KEY_ID"); id != "" {
+		return &AWSCredentials{AccessKeyId: id, SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken: os.Getenv("AWS_SESSION_TOKEN")}, nil
+	}
+	file := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if file == "" {
+		currentUser, err := user.Current()
+		if err != nil {
+			return nil, err
+		}
+		file = filepath.Join(currentUser.HomeDir, ".aws", "credentials")
+	}
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("No AWS credentials in the environment and could not read %s: %s", file, err.Error())
+	}
+	defer f.Close()
+	creds, err := ReadAWSCredentials(f, profile)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err.Error())
+	}
+	return creds, nil
+}
+
+// ReadAWSCredentials reads the credentials of profile from an AWS shared credentials file.
+func ReadAWSCredentials(r io.Reader, profile string) (*AWSCredentials, error) {
+	creds := AWSCredentials{}
+	section := ""
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			section = strings.TrimSpace(line[1 : len(line)-1])
+		case section == profile:
+			parts := strings.SplitN(line, "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			value := strings.TrimSpace(parts[1])
+			switch strings.TrimSpace(parts[0]) {
+			case "aws_access_key_id":
+				creds.AccessKeyId = value
+			case "aws_secret_access_key":
+				creds.SecretAccessKey = value
+			case "aws_session_token":
+				creds.SessionToken = value
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if creds.AccessKeyId == "" || creds.SecretAccessKey == "" {
+		return nil, fmt.Errorf("No AWS credentials for profile %s", profile)
+	}
+	return &creds, nil
+}
+
+// awsRegion is the region of the S3 endpoint requests are sent to first.
+func awsRegion() string {
+	for _, variable := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if region := os.Getenv(variable); region != "" {
+			return region
+		}
+	}
+	return "us-east-1"
+}
+
+// SignS3Request signs req for S3 in region with AWS Signature Version 4 at now. Only requests without a payload
+// are supported.
+func SignS3Request(req *http.Request, creds AWSCredentials, region string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	scope := amzDate[:8] + "/" + region + "/s3/aws4_request"
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders string
+	for _, name := range names {
+		canonicalHeaders += name + ":" + headers[name] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{req.Method, s3EscapePath(req.URL.Path), req.URL.RawQuery,
+		canonicalHeaders, signedHeaders, emptyPayloadHash}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range []string{amzDate[:8], region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyId, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3EscapePath percent encodes everything but the unreserved characters and slashes in a path the way S3 expects
+// in canonical requests.
+func s3EscapePath(p string) string {
+	var escaped []byte
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			escaped = append(escaped, c)
+		} else {
+			escaped = append(escaped, fmt.Sprintf("%%%02X", c)...)
+		}
+	}
+	return string(escaped)
+}
+
+// s3ObjectURL is the URL of an object in region. Buckets with dots in their names do not match the wildcard
+// certificate of virtual hosted style URLs so they use path style ones.
+func s3ObjectURL(bucket, key, region string) string {
+	host := "s3.amazonaws.com"
+	if region != "us-east-1" {
+		host = "s3." + region + ".amazonaws.com"
+	}
+	if strings.Contains(bucket, ".") {
+		return "https://" + host + "/" + bucket + "/" + s3EscapePath(key)
+	}
+	return "https://" + bucket + "." + host + "/" + s3EscapePath(key)
+}
+
+// fetchS3Attachment downloads the object an s3://bucket/key attachment refers to into dir and returns the path of
+// the file and its md5 digest. The digest is checked against the one S3ObjectDigest finds in the response when it can
+// find one. An object larger than maxSize bytes is refused unless maxSize is 0.
+func (c *Client) fetchS3Attachment(attachment, dir string, creds AWSCredentials, maxSize int64) (string, string, error) {
+	bucket, key, _, err := ParseS3Reference(attachment)
+	if err != nil {
+		return "", "", err
+	}
+	region := awsRegion()
+	var resp *http.Response
+	for attempt := 0; attempt < 2; attempt++ {
+		req, err := http.NewRequest("GET", s3ObjectURL(bucket, key, region), nil)
+		if err != nil {
+			return "", "", err
+		}
+		// an S3 URL has the key escaped already, keep it from being escaped differently
+		req.URL.Opaque = "//" + req.URL.Host + s3EscapePath(req.URL.Path)
+		SignS3Request(req, creds, region, time.Now())
+		if resp, err = http.DefaultClient.Do(req.WithContext(c.context())); err != nil {
+			return "", "", err
+		}
+		// S3 says which region the bucket is in when asked in the wrong one
+		bucketRegion := resp.Header.Get("X-Amz-Bucket-Region")
+		if resp.StatusCode == http.StatusOK || bucketRegion == "" || bucketRegion == region {
+			break
+		}
+		resp.Body.Close()
+		region = bucketRegion
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return "", "", fmt.Errorf("Could not download %s: %s", attachment, NewResponseError(resp, body).Error())
+	}
+	if maxSize > 0 && resp.ContentLength > maxSize {
+		return "", "", fileTooLarge(attachment, resp.ContentLength, maxSize)
+	}
+
+	file := filepath.Join(dir, filepath.Base(key))
+	f, err := os.Create(file)
+	if err != nil {
+		return "", "", err
+	}
+	hash := md5.New()
+	var body io.Reader = resp.Body
+	if maxSize > 0 {
+		// the length is not always known up front, so stop reading just past the limit either way
+		body = io.LimitReader(resp.Body, maxSize+1)
+	}
+	size, err := io.Copy(io.MultiWriter(f, hash), body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("Could not download %s: %s", attachment, err.Error())
+	}
+	if maxSize > 0 && size > maxSize {
+		return "", "", fmt.Errorf("%s is larger than the --max-file-size of %d bytes", attachment, maxSize)
+	}
+	digest := hex.EncodeToString(hash.Sum(nil))
+	expected, reason := S3ObjectDigest(resp.Header)
+	switch {
+	case expected == "":
+		fmt.Fprintf(c.stdout(), "  WARNING: %s %s so its md5 %s cannot be verified\n", attachment, reason, digest)
+	case expected != digest:
+		return "", "", fmt.Errorf("Downloaded %s has md5 %s but S3 says it is %s", attachment, digest, expected)
+	}
+	return file, digest, nil
+}
+
+// S3ObjectDigest returns the md5 digest of an S3 object from the headers of the response it was downloaded with, or
+// an empty digest and the reason the digest is not known. A Content-MD5 header is used when there is one, otherwise
+// the ETag, which is only the md5 of objects uploaded in a single part which are not encrypted with SSE-KMS or SSE-C.
+func S3ObjectDigest(header http.Header) (digest, reason string) {
+	if contentMD5 := header.Get("Content-Md5"); contentMD5 != "" {
+		if sum, err := base64.StdEncoding.DecodeString(contentMD5); err == nil && len(sum) == md5.Size {
+			return hex.EncodeToString(sum), ""
+		}
+	}
+	etag := strings.Trim(header.Get("ETag"), `"`)
+	switch {
+	case etag == "":
+		return "", "has no ETag in S3"
+	case strings.Contains(etag, "-"):
+		return "", "was uploaded to S3 in parts"
+	case header.Get("X-Amz-Server-Side-Encryption") == "aws:kms":
+		return "", "is encrypted with SSE-KMS in S3"
+	case header.Get("X-Amz-Server-Side-Encryption-Customer-Algorithm") != "":
+		return "", "is encrypted with a customer provided key in S3"
+	}
+	return etag, ""
+}
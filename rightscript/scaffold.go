@@ -1,4 +1,4 @@
-package main
+package rightscript
 
 import (
 	"bufio"
@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -22,13 +23,47 @@ var (
 	ignoreVariables    = regexp.MustCompile(`^(?:ATTACH_DIR|SHELL|TERM|USER|PATH|MAIL|PWD|HOME|RS_.*|INSTANCE_ID|PRIVATE_ID|DATACENTER|EC2_.*)$`)
 )
 
+// Interpreters that can be given to scaffold to force how inputs are detected when the extension or shebang of a
+// script does not tell
+var interpreterVariables = map[string]*regexp.Regexp{
+	"bash":       shellVariable,
+	"perl":       perlVariable,
+	"powershell": powershellVariable,
+	"ruby":       rubyVariable,
+}
+
+// ScaffoldInterpreters lists the interpreters accepted by ScaffoldRightScript.
+func ScaffoldInterpreters() []string {
+	interpreters := make([]string, 0, len(interpreterVariables))
+	for interpreter := range interpreterVariables {
+		interpreters = append(interpreters, interpreter)
+	}
+	sort.Strings(interpreters)
+	return interpreters
+}
+
+type inputsByName InputMap
+
+func (inputs inputsByName) Len() int           { return len(inputs) }
+func (inputs inputsByName) Less(i, j int) bool { return inputs[i].Name < inputs[j].Name }
+func (inputs inputsByName) Swap(i, j int)      { inputs[i], inputs[j] = inputs[j], inputs[i] }
+
 const (
 	PreMetadata = iota
 	InMetadata
 	PostMetadata
 )
 
-func ScaffoldRightScript(path string, backup bool, stdout io.Writer, force bool) error {
+// ScaffoldRightScript adds metadata to the script at path. The interpreter, one of ScaffoldInterpreters, decides how
+// inputs are detected; when it is empty it is guessed from the extension and shebang of the script.
+func ScaffoldRightScript(path string, backup bool, stdout io.Writer, force bool, interpreter string) error {
+	if _, ok := interpreterVariables[interpreter]; interpreter != "" && !ok {
+		return fmt.Errorf("Unknown interpreter %s, must be one of: %s", interpreter, strings.Join(ScaffoldInterpreters(), ", "))
+	}
+	if _, err := os.Stat(path + SidecarSuffix); err == nil {
+		fmt.Fprintf(stdout, "%s: Script unchanged, its metadata is in %s\n", path, path+SidecarSuffix)
+		return nil
+	}
 	scriptBytes, err := ioutil.ReadFile(path)
 	if err != nil {
 		return err
@@ -38,11 +73,15 @@ func ScaffoldRightScript(path string, backup bool, stdout io.Writer, force bool)
 		return err
 	}
 
-	metadata, err := ParseRightScriptMetadata(bytes.NewReader(scriptBytes))
+	metadata, err := ParseRightScriptMetadataIn(bytes.NewReader(scriptBytes), filepath.Dir(path))
 	if err != nil {
 		return err
 	}
 	if metadata != nil {
+		if len(metadata.Includes) > 0 {
+			fmt.Fprintf(stdout, "%s: Script unchanged, its metadata includes other files\n", path)
+			return nil
+		}
 		if !force {
 			fmt.Fprintf(stdout, "%s: Script unchanged, already contains metadata. Use --force to force redetection.\n", path)
 			return nil
@@ -55,7 +94,7 @@ func ScaffoldRightScript(path string, backup bool, stdout io.Writer, force bool)
 		}
 	}
 
-	scaffoldedScriptBytes, err := scaffoldBuffer(scriptBytes, *metadata, path, true)
+	scaffoldedScriptBytes, err := ScaffoldBuffer(scriptBytes, *metadata, path, interpreter, true)
 	if err != nil {
 		return err
 	}
@@ -78,11 +117,12 @@ func ScaffoldRightScript(path string, backup bool, stdout io.Writer, force bool)
 //   source - Source buffer. Will not be modified
 //   defaults - Default values. Parsed values will be merged in.
 //   filename - Used to help determine the script type.
+//   interpreter - Forces the script type instead of determining it from filename and the shebang, may be empty.
 // Return values:
 //   *bytes.Buffer - New buffer with added metadata. Currently metadata will only be added if there is none. We don't
 //                   currently bother with any fancy merging or updating if new inputs/attachments get added in the API or disk
 //   err error - error value
-func scaffoldBuffer(source []byte, defaults RightScriptMetadata, filename string, detectInputs bool) ([]byte, error) {
+func ScaffoldBuffer(source []byte, defaults RightScriptMetadata, filename, interpreter string, detectInputs bool) ([]byte, error) {
 	// We simply start with the defaults passed in as our base set of metadata.
 	// Merging of defaults with exisiting metadata items happens before this function as strategies willl be different
 	// based on the source.
@@ -97,20 +137,46 @@ func scaffoldBuffer(source []byte, defaults RightScriptMetadata, filename string
 	case ".ps1":
 		variable = powershellVariable
 	}
+	if interpreterVariable, ok := interpreterVariables[interpreter]; ok {
+		variable = interpreterVariable
+	}
 
 	// Pass 1: We remove any existing metadata comments and record the line at which we
 	// removed them, so that we may re-insert them later.
+	// Metadata in a PowerShell block comment also has the lines opening and closing the block comment removed.
 	inMetadataState := PreMetadata
 	metadataStartLine := 0
+	inBlockComment, blockCommentStartLine, expectBlockCommentEnd := false, "", false
 	scanner := bufio.NewScanner(bytes.NewReader(source))
 	var buffer bytes.Buffer
 	for lineCount := 0; scanner.Scan(); lineCount += 1 {
 		line := scanner.Text()
 
-		if inMetadataState == PreMetadata && metadataStart.MatchString(line) {
+		if inBlockComment {
+			inBlockComment = false
+			if inMetadataState == PreMetadata && blockMetadataStart.MatchString(line) {
+				metadataStartLine = lineCount - 1
+				inMetadataState = InMetadata
+				expectBlockCommentEnd = true
+				continue
+			}
+			buffer.WriteString(blockCommentStartLine + "\n")
+		}
+		if inMetadataState == PostMetadata && expectBlockCommentEnd {
+			expectBlockCommentEnd = false
+			if blockCommentEnd.MatchString(line) {
+				continue
+			}
+		}
+
+		if inMetadataState == PreMetadata && blockCommentStart.MatchString(line) {
+			inBlockComment, blockCommentStartLine = true, line
+		} else if inMetadataState == PreMetadata && metadataStart.MatchString(line) {
 			metadataStartLine = lineCount
 			inMetadataState = InMetadata
-		} else if inMetadataState == InMetadata && metadataEnd.MatchString(line) {
+		} else if inMetadataState == InMetadata && expectBlockCommentEnd && blockMetadataEnd.MatchString(line) {
+			inMetadataState = PostMetadata
+		} else if inMetadataState == InMetadata && !expectBlockCommentEnd && metadataEnd.MatchString(line) {
 			inMetadataState = PostMetadata
 		} else {
 			if inMetadataState != InMetadata {
@@ -118,6 +184,9 @@ func scaffoldBuffer(source []byte, defaults RightScriptMetadata, filename string
 			}
 		}
 	}
+	if inBlockComment {
+		buffer.WriteString(blockCommentStartLine + "\n")
+	}
 	if inMetadataState == PostMetadata {
 		source = buffer.Bytes() // we encountered metadata
 	} else {
@@ -134,6 +203,7 @@ func scaffoldBuffer(source []byte, defaults RightScriptMetadata, filename string
 		if lineCount == 0 {
 			if shebang.MatchString(line) {
 				switch {
+				case interpreter != "":
 				case strings.Contains(line, "ruby"):
 					variable = rubyVariable
 				case strings.Contains(line, "perl"):
@@ -214,6 +284,11 @@ func scaffoldBuffer(source []byte, defaults RightScriptMetadata, filename string
 			}
 		}
 		metadata.Inputs = inputs
+
+		// Sort the inputs and attachments so scaffolding the same script again always writes the same metadata
+		sort.Sort(inputsByName(metadata.Inputs))
+		metadata.Attachments = append([]string{}, metadata.Attachments...)
+		sort.Strings(metadata.Attachments)
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -1,11 +1,11 @@
-package main_test
+package rightscript_test
 
 import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
 
-	. "github.com/rightscale/right_st"
+	. "github.com/rightscale/right_st/rightscript"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -68,7 +68,7 @@ var _ = Describe("RightScript Scaffold", func() {
 		})
 
 		It("should add default metadata", func() {
-			err := ScaffoldRightScript(emptyScript, false, buffer, true)
+			err := ScaffoldRightScript(emptyScript, false, buffer, true, "")
 			Expect(err).To(Succeed())
 			Expect(buffer.Contents()).To(BeEquivalentTo(emptyScript + ": Added metadata\n"))
 
@@ -78,7 +78,7 @@ var _ = Describe("RightScript Scaffold", func() {
 		})
 
 		It("should create a backup file if desired", func() {
-			err := ScaffoldRightScript(emptyScript, true, buffer, true)
+			err := ScaffoldRightScript(emptyScript, true, buffer, true, "")
 			Expect(err).To(Succeed())
 			Expect(buffer.Contents()).To(BeEquivalentTo(emptyScript + ": Added metadata\n"))
 
@@ -110,7 +110,7 @@ echo 'I have metadata already!'
 		})
 
 		It("should not add metadata", func() {
-			err := ScaffoldRightScript(metadataScript, false, buffer, false)
+			err := ScaffoldRightScript(metadataScript, false, buffer, false, "")
 			Expect(err).To(Succeed())
 			Expect(string(buffer.Contents())).Should(ContainSubstring("Script unchanged, already contains metadata"))
 
@@ -156,13 +156,6 @@ echo $BAZ
 # RightScript Name: Metadata Already
 # Description: A script that already has metadata
 # Inputs:
-#   FOO:
-#     Category: (put your input category here)
-#     Description: (put your input description here, it can be multiple lines using
-#       YAML syntax)
-#     Input Type: single
-#     Required: true
-#     Advanced: true
 #   BAZ:
 #     Category: (put your input category here)
 #     Description: (put your input description here, it can be multiple lines using
@@ -171,6 +164,13 @@ echo $BAZ
 #     Required: false
 #     Advanced: false
 #     Default: text:hello
+#   FOO:
+#     Category: (put your input category here)
+#     Description: (put your input description here, it can be multiple lines using
+#       YAML syntax)
+#     Input Type: single
+#     Required: true
+#     Advanced: true
 # Attachments: []
 # ...
 
@@ -185,7 +185,7 @@ echo $BAZ
 		})
 
 		It("should re-scaffold metadata", func() {
-			err := ScaffoldRightScript(metadataScript, false, buffer, true)
+			err := ScaffoldRightScript(metadataScript, false, buffer, true, "")
 			Expect(err).To(Succeed())
 			Expect(buffer.Contents()).To(BeEquivalentTo(metadataScript + ": Added metadata\n"))
 
@@ -208,22 +208,22 @@ echo "$STRING $ARRAY $PATH"
 # RightScript Name: Shell
 # Description: (put your description here, it can be multiple lines using YAML syntax)
 # Inputs:
-#   STRING:
+#   ARRAY:
 #     Category: (put your input category here)
 #     Description: (put your input description here, it can be multiple lines using
 #       YAML syntax)
-#     Input Type: single
+#     Input Type: array
 #     Required: false
 #     Advanced: false
-#     Default: text:hello
-#   ARRAY:
+#     Default: array:["text:hello","text:world"]
+#   STRING:
 #     Category: (put your input category here)
 #     Description: (put your input description here, it can be multiple lines using
 #       YAML syntax)
-#     Input Type: array
+#     Input Type: single
 #     Required: false
 #     Advanced: false
-#     Default: array:["text:hello","text:world"]
+#     Default: text:hello
 # Attachments: []
 # ...
 ` + shellScriptContents
@@ -234,7 +234,7 @@ echo "$STRING $ARRAY $PATH"
 		})
 
 		It("should add metadata with variables and their default values", func() {
-			err := ScaffoldRightScript(shellScript, false, buffer, true)
+			err := ScaffoldRightScript(shellScript, false, buffer, true, "")
 			Expect(err).To(Succeed())
 			Expect(buffer.Contents()).To(BeEquivalentTo(shellScript + ": Added metadata\n"))
 
@@ -271,7 +271,7 @@ puts "#{ENV['INPUT']} #{ENV["PATH"]}"
 		})
 
 		It("should add metadata with variables", func() {
-			err := ScaffoldRightScript(rubyScript, false, buffer, true)
+			err := ScaffoldRightScript(rubyScript, false, buffer, true, "")
 			Expect(err).To(Succeed())
 			Expect(buffer.Contents()).To(BeEquivalentTo(rubyScript + ": Added metadata\n"))
 
@@ -308,7 +308,7 @@ print "$ENV{INPUT} $ENV{PATH}\n";
 		})
 
 		It("should add metadata with variables", func() {
-			err := ScaffoldRightScript(perlScript, false, buffer, true)
+			err := ScaffoldRightScript(perlScript, false, buffer, true, "")
 			Expect(err).To(Succeed())
 			Expect(buffer.Contents()).To(BeEquivalentTo(perlScript + ": Added metadata\n"))
 
@@ -343,7 +343,7 @@ Write-Output "${env:INPUT} $env:PATH"
 		})
 
 		It("should add metadata with variables", func() {
-			err := ScaffoldRightScript(powershellScript, false, buffer, true)
+			err := ScaffoldRightScript(powershellScript, false, buffer, true, "")
 			Expect(err).To(Succeed())
 			Expect(buffer.Contents()).To(BeEquivalentTo(powershellScript + ": Added metadata\n"))
 
@@ -352,4 +352,103 @@ Write-Output "${env:INPUT} $env:PATH"
 			Expect(script).To(BeEquivalentTo(powershellScriptMetadata))
 		})
 	})
+
+	Context("With rescaffolding a PowerShell script with metadata in a block comment", func() {
+		var blockCommentScriptAfter string
+		BeforeEach(func() {
+			powershellScriptContents = `<#
+---
+RightScript Name: Block Comment
+Description: A PowerShell script with metadata in a block comment
+Inputs: {}
+Attachments: []
+...
+#>
+
+Write-Output $env:INPUT
+`
+			blockCommentScriptAfter = `<#
+---
+RightScript Name: Block Comment
+Description: A PowerShell script with metadata in a block comment
+Inputs:
+  INPUT:
+    Category: (put your input category here)
+    Description: (put your input description here, it can be multiple lines using
+      YAML syntax)
+    Input Type: single
+    Required: false
+    Advanced: false
+Attachments: []
+...
+#>
+
+Write-Output $env:INPUT
+`
+			if err := ioutil.WriteFile(powershellScript, []byte(powershellScriptContents), 0600); err != nil {
+				panic(err)
+			}
+		})
+
+		It("should re-scaffold metadata in the block comment", func() {
+			err := ScaffoldRightScript(powershellScript, false, buffer, true, "")
+			Expect(err).To(Succeed())
+			Expect(buffer.Contents()).To(BeEquivalentTo(powershellScript + ": Added metadata\n"))
+
+			script, err := ioutil.ReadFile(powershellScript)
+			Expect(err).To(Succeed())
+			Expect(script).To(BeEquivalentTo(blockCommentScriptAfter))
+		})
+	})
+
+	Context("With a PowerShell script without an extension or shebang", func() {
+		var noExtensionScript string
+
+		BeforeEach(func() {
+			noExtensionScript = filepath.Join(tempDir, "no_extension")
+			if err := ioutil.WriteFile(noExtensionScript, []byte("Write-Output $env:INPUT\n"), 0600); err != nil {
+				panic(err)
+			}
+		})
+
+		It("should detect PowerShell variables with the powershell interpreter", func() {
+			err := ScaffoldRightScript(noExtensionScript, false, buffer, true, "powershell")
+			Expect(err).To(Succeed())
+
+			script, err := ioutil.ReadFile(noExtensionScript)
+			Expect(err).To(Succeed())
+			Expect(string(script)).To(ContainSubstring("# Inputs:\n#   INPUT:\n"))
+		})
+
+		It("should reject an unknown interpreter", func() {
+			err := ScaffoldRightScript(noExtensionScript, false, buffer, true, "cobol")
+			Expect(err).To(MatchError("Unknown interpreter cobol, must be one of: bash, perl, powershell, ruby"))
+		})
+	})
+
+	Context("With a script whose metadata is out of order", func() {
+		var goldenScript string
+
+		BeforeEach(func() {
+			goldenScript = filepath.Join(tempDir, "golden.sh")
+			source, err := ioutil.ReadFile(filepath.Join("testdata", "scaffold.sh"))
+			if err != nil {
+				panic(err)
+			}
+			if err := ioutil.WriteFile(goldenScript, source, 0600); err != nil {
+				panic(err)
+			}
+		})
+
+		It("should write the keys in a fixed order with sorted inputs and attachments every time", func() {
+			golden, err := ioutil.ReadFile(filepath.Join("testdata", "scaffold.sh.golden"))
+			Expect(err).To(Succeed())
+			for scaffold := 0; scaffold < 2; scaffold++ {
+				Expect(ScaffoldRightScript(goldenScript, false, buffer, true, "")).To(Succeed())
+				script, err := ioutil.ReadFile(goldenScript)
+				Expect(err).To(Succeed())
+				Expect(string(script)).To(Equal(string(golden)))
+			}
+		})
+	})
 })
@@ -0,0 +1,153 @@
+package rightscript
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/go-yaml/yaml"
+)
+
+// ValidationSchema holds the rules of a team for the metadata of its RightScripts, loaded from the file given to
+// rightscript validate --schema. Rules which are left out are not checked.
+type ValidationSchema struct {
+	NamePattern      string   `yaml:"name_pattern"`       // Regular expression the whole RightScript name must match
+	InputNamePattern string   `yaml:"input_name_pattern"` // Regular expression the whole name of every input must match
+	RequiredFields   []string `yaml:"required_fields"`    // Metadata fields which must not be empty, see schemaFields
+	RequiredInputs   []string `yaml:"required_inputs"`    // Inputs every RightScript must have
+	// Every input must have a category other than the placeholder scaffold writes
+	RequireCategories bool     `yaml:"require_categories"`
+	Categories        []string `yaml:"categories"`   // Categories inputs may use
+	Interpreters      []string `yaml:"interpreters"` // Interpreters named by the shebang, or powershell for PowerShell scripts
+
+	namePattern, inputNamePattern *regexp.Regexp
+}
+
+// The metadata fields required_fields may name and whether a RightScript has them
+var schemaFields = map[string]func(metadata *RightScriptMetadata) bool{
+	"description": func(metadata *RightScriptMetadata) bool { return strings.TrimSpace(metadata.Description) != "" },
+	"packages":    func(metadata *RightScriptMetadata) bool { return strings.TrimSpace(metadata.Packages) != "" },
+	"inputs":      func(metadata *RightScriptMetadata) bool { return len(metadata.Inputs) > 0 },
+	"attachments": func(metadata *RightScriptMetadata) bool { return len(metadata.Attachments) > 0 },
+}
+
+// The category scaffold gives new inputs, which does not count as a category for require_categories
+const placeholderCategory = "(put your input category here)"
+
+// LoadValidationSchema reads a schema from a YAML (or JSON) file and checks that its rules make sense.
+func LoadValidationSchema(file string) (*ValidationSchema, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var schema ValidationSchema
+	if err := yaml.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err.Error())
+	}
+	if schema.namePattern, err = compileSchemaPattern(schema.NamePattern); err != nil {
+		return nil, fmt.Errorf("%s: invalid name_pattern: %s", file, err.Error())
+	}
+	if schema.inputNamePattern, err = compileSchemaPattern(schema.InputNamePattern); err != nil {
+		return nil, fmt.Errorf("%s: invalid input_name_pattern: %s", file, err.Error())
+	}
+	for _, field := range schema.RequiredFields {
+		if _, ok := schemaFields[field]; !ok {
+			return nil, fmt.Errorf("%s: unknown required field %s, must be one of: attachments, description, inputs, packages",
+				file, field)
+		}
+	}
+	return &schema, nil
+}
+
+// compileSchemaPattern compiles a pattern which has to match a whole name, nil when there is no pattern.
+func compileSchemaPattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile(`^(?:` + pattern + `)$`)
+}
+
+// Validate returns an error listing every rule of the schema the RightScript breaks.
+func (schema *ValidationSchema) Validate(script *RightScript) error {
+	metadata := &script.Metadata
+	violations := []string{}
+	if schema.namePattern != nil && !schema.namePattern.MatchString(metadata.Name) {
+		violations = append(violations, fmt.Sprintf("Name %q does not match %s", metadata.Name, schema.NamePattern))
+	}
+	for _, field := range schema.RequiredFields {
+		if !schemaFields[field](metadata) {
+			violations = append(violations, fmt.Sprintf("Metadata has no %s", field))
+		}
+	}
+	for _, name := range schema.RequiredInputs {
+		found := false
+		for _, input := range metadata.Inputs {
+			found = found || input.Name == name
+		}
+		if !found {
+			violations = append(violations, fmt.Sprintf("Required input %s is missing", name))
+		}
+	}
+	for _, input := range metadata.Inputs {
+		if schema.inputNamePattern != nil && !schema.inputNamePattern.MatchString(input.Name) {
+			violations = append(violations, fmt.Sprintf("Input name %s does not match %s", input.Name, schema.InputNamePattern))
+		}
+		if schema.RequireCategories && (input.Category == "" || input.Category == placeholderCategory) {
+			violations = append(violations, fmt.Sprintf("Input %s has no category", input.Name))
+		}
+	}
+	if len(schema.Categories) > 0 {
+		if err := metadata.Inputs.ValidateCategories(schema.Categories); err != nil {
+			violations = append(violations, err.Error())
+		}
+	}
+	if len(schema.Interpreters) > 0 {
+		source, err := ioutil.ReadFile(script.Path)
+		if err != nil {
+			return err
+		}
+		interpreter := scriptInterpreter(script.Path, source)
+		allowed := false
+		for _, name := range schema.Interpreters {
+			allowed = allowed || name == interpreter
+		}
+		switch {
+		case interpreter == "":
+			violations = append(violations, fmt.Sprintf("Script has no shebang, the interpreter must be one of: %s",
+				strings.Join(schema.Interpreters, ", ")))
+		case !allowed:
+			violations = append(violations, fmt.Sprintf("Interpreter %s is not one of: %s", interpreter,
+				strings.Join(schema.Interpreters, ", ")))
+		}
+	}
+	if len(violations) > 0 {
+		return fmt.Errorf("%s", strings.Join(violations, "\n  "))
+	}
+	return nil
+}
+
+// scriptInterpreter returns the name of the program the shebang of a script runs, looking past env, e.g. bash for
+// "#!/usr/bin/env bash", or powershell for PowerShell scripts. It is empty when there is no shebang.
+func scriptInterpreter(file string, source []byte) string {
+	line, _ := bufio.NewReader(bytes.NewReader(source)).ReadString('\n')
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "#!") {
+		if isPowerShell(file, source) {
+			return "powershell"
+		}
+		return ""
+	}
+	fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+	for index, field := range fields {
+		program := path.Base(field)
+		if index == 0 && program == "env" || strings.HasPrefix(field, "-") || strings.Contains(field, "=") {
+			continue
+		}
+		return program
+	}
+	return ""
+}
@@ -0,0 +1,89 @@
+package rightscript_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/rightscale/right_st/rightscript"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Validation schema", func() {
+	var tempDir string
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = ioutil.TempDir("", "schema")
+		if err != nil {
+			panic(err)
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tempDir)
+	})
+
+	write := func(name, content string) string {
+		file := filepath.Join(tempDir, name)
+		Expect(ioutil.WriteFile(file, []byte(content), 0644)).To(Succeed())
+		return file
+	}
+
+	It("Reports every rule a RightScript breaks", func() {
+		schema, err := LoadValidationSchema(write("schema.yml", `name_pattern: "ACME [A-Z].*"
+input_name_pattern: "ACME_[A-Z_]+"
+required_fields: [description, packages]
+required_inputs: [ACME_ENV]
+require_categories: true
+interpreters: [bash, sh]
+`))
+		Expect(err).NotTo(HaveOccurred())
+		script, err := ValidateRightScript(write("script.py", `#!/usr/bin/env python3
+# ---
+# RightScript Name: Install thing
+# Description: Installs the thing
+# Inputs:
+#   PORT:
+#     Category: (put your input category here)
+#     Input Type: single
+# Attachments: []
+# ...
+`), false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(schema.Validate(script)).To(MatchError(`Name "Install thing" does not match ACME [A-Z].*
+  Metadata has no packages
+  Required input ACME_ENV is missing
+  Input name PORT does not match ACME_[A-Z_]+
+  Input PORT has no category
+  Interpreter python3 is not one of: bash, sh`))
+	})
+
+	It("Accepts a RightScript which follows the rules", func() {
+		schema, err := LoadValidationSchema(write("schema.yml", `name_pattern: "ACME .*"
+required_inputs: [ACME_ENV]
+categories: [Application]
+interpreters: [bash]
+`))
+		Expect(err).NotTo(HaveOccurred())
+		script, err := ValidateRightScript(write("script.sh", `#!/bin/bash -e
+# ---
+# RightScript Name: ACME Install
+# Inputs:
+#   ACME_ENV:
+#     Category: Application
+#     Input Type: single
+# Attachments: []
+# ...
+`), false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(schema.Validate(script)).To(Succeed())
+	})
+
+	It("Rejects a schema with an unknown required field", func() {
+		_, err := LoadValidationSchema(write("schema.yml", "required_fields: [owner]\n"))
+		Expect(err).To(MatchError(ContainSubstring("unknown required field owner")))
+	})
+})
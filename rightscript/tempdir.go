@@ -0,0 +1,46 @@
+package rightscript
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// Temporary directories made by MakeTempDir which are still around, for RemoveTempDirs since deferred functions do not
+// run when a program exits early
+var (
+	tempDirs      = make(map[string]bool)
+	tempDirsMutex sync.Mutex
+)
+
+// MakeTempDir creates a new temporary directory like ioutil.TempDir which is removed by RemoveTempDirs unless
+// RemoveTempDir removed it before.
+func MakeTempDir(prefix string) (string, error) {
+	dir, err := ioutil.TempDir("", prefix)
+	if err != nil {
+		return "", err
+	}
+	tempDirsMutex.Lock()
+	defer tempDirsMutex.Unlock()
+	tempDirs[dir] = true
+	return dir, nil
+}
+
+// RemoveTempDir removes a temporary directory made by MakeTempDir along with everything in it.
+func RemoveTempDir(dir string) {
+	tempDirsMutex.Lock()
+	defer tempDirsMutex.Unlock()
+	os.RemoveAll(dir)
+	delete(tempDirs, dir)
+}
+
+// RemoveTempDirs removes all temporary directories made by MakeTempDir which were not removed yet. Programs should call
+// it before they exit.
+func RemoveTempDirs() {
+	tempDirsMutex.Lock()
+	defer tempDirsMutex.Unlock()
+	for dir := range tempDirs {
+		os.RemoveAll(dir)
+		delete(tempDirs, dir)
+	}
+}
@@ -0,0 +1,18 @@
+#!/bin/bash
+# ---
+# RightScript Name: Golden
+# Packages: curl git
+# Attachments:
+# - zeta.tar.gz
+# - alpha.conf
+# Description: A script whose metadata is scaffolded into a stable order
+# Inputs:
+#   ZULU:
+#     Category: Application
+#     Input Type: single
+#     Required: true
+#     Advanced: false
+# ...
+
+tar -xzf "$ATTACH_DIR/zeta.tar.gz"
+echo "$ZULU ${ALPHA:=one,two} $MIKE"
@@ -0,0 +1,380 @@
+package main_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	. "github.com/rightscale/right_st"
+	"github.com/rightscale/right_st/rightscript"
+	"github.com/rightscale/rsc/cm15"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RightScript", func() {
+	Describe("Print RightScript list", func() {
+		items := []RightScriptListItem{
+			{Id: "1234", Href: "/api/right_scripts/1234", Revision: 0, Name: "Script One"},
+			{Id: "5678", Href: "/api/right_scripts/5678", Revision: 3, Name: "Script Two"},
+		}
+
+		It("Prints the default format", func() {
+			buffer := new(bytes.Buffer)
+			Expect(PrintRightScriptList(buffer, items, nil)).To(Succeed())
+			Expect(buffer.String()).To(Equal("/api/right_scripts/1234  HEAD Script One\n" +
+				"/api/right_scripts/5678     3 Script Two\n"))
+		})
+
+		It("Prints an output template for each item", func() {
+			buffer := new(bytes.Buffer)
+			tmpl := template.Must(template.New("output").Parse("{{.Id}}\t{{.Revision}}\t{{.Name}}"))
+			Expect(PrintRightScriptList(buffer, items, tmpl)).To(Succeed())
+			Expect(buffer.String()).To(Equal("1234\t0\tScript One\n5678\t3\tScript Two\n"))
+		})
+	})
+
+	Describe("Print RightScript CSV", func() {
+		It("Prints a header row and quotes names with commas", func() {
+			buffer := new(bytes.Buffer)
+			items := []RightScriptListItem{
+				{Id: "123", Href: "/api/right_scripts/123", Revision: 0, Name: "Install, Configure"},
+				{Id: "456", Href: "/api/right_scripts/456", Revision: 3, Name: `Say "Hello"`},
+			}
+			Expect(PrintRightScriptCSV(buffer, items)).To(Succeed())
+			Expect(buffer.String()).To(Equal("id,href,revision,name\n" +
+				"123,/api/right_scripts/123,0,\"Install, Configure\"\n" +
+				"456,/api/right_scripts/456,3,\"Say \"\"Hello\"\"\"\n"))
+		})
+	})
+
+	Describe("Print RightScript items", func() {
+		items := []RightScriptListItem{{Id: "1234", Href: "/api/right_scripts/1234", Revision: 3, Name: "Script One"}}
+
+		It("Prints each format", func() {
+			buffer := new(bytes.Buffer)
+			Expect(PrintRightScriptItems(buffer, items, "json", nil)).To(Succeed())
+			Expect(buffer.String()).To(MatchJSON(`[{"id": "1234", "href": "/api/right_scripts/1234", "revision": 3, "name": "Script One"}]`))
+
+			buffer.Reset()
+			Expect(PrintRightScriptItems(buffer, items, "csv", nil)).To(Succeed())
+			Expect(buffer.String()).To(Equal("id,href,revision,name\n1234,/api/right_scripts/1234,3,Script One\n"))
+
+			buffer.Reset()
+			Expect(PrintRightScriptItems(buffer, items, "text", nil)).To(Succeed())
+			Expect(buffer.String()).To(Equal("/api/right_scripts/1234     3 Script One\n"))
+		})
+	})
+
+	Describe("Tagged HREFs", func() {
+		It("Returns the HREFs of the resources", func() {
+			response := []map[string]interface{}{
+				{
+					"tags": []interface{}{map[string]interface{}{"name": "team:payments"}},
+					"links": []interface{}{
+						map[string]interface{}{"rel": "resource", "href": "/api/right_scripts/1"},
+						map[string]interface{}{"rel": "resource", "href": "/api/right_scripts/2"},
+						map[string]interface{}{"rel": "self", "href": "/api/tags/by_tag"},
+					},
+				},
+			}
+			Expect(TaggedHrefs(response)).To(Equal([]string{"/api/right_scripts/1", "/api/right_scripts/2"}))
+		})
+
+		It("Returns nothing when nothing is tagged", func() {
+			Expect(TaggedHrefs(nil)).To(BeEmpty())
+		})
+	})
+
+	Describe("Read names", func() {
+		It("Reads one name per line skipping blanks, comments, and duplicates", func() {
+			names, err := ReadNames(strings.NewReader("  Install Apache \n\n# web tier\nConfigure PHP\r\nInstall Apache\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(names).To(Equal([]string{"Install Apache", "Configure PHP"}))
+		})
+	})
+
+	Describe("Rank candidates", func() {
+		items := []RightScriptListItem{
+			{Id: "1", Name: "Install Apache"},
+			{Id: "2", Name: "Install Nginx"},
+			{Id: "3", Name: "Configure Apache"},
+			{Id: "4", Name: "Instal Apache"},
+		}
+
+		It("Orders close names from the closest", func() {
+			candidates := RankCandidates("install apache", items)
+			Expect(candidates).To(HaveLen(2))
+			Expect(candidates[0].Id).To(Equal("1"))
+			Expect(candidates[1].Id).To(Equal("4"))
+		})
+
+		It("Includes names containing a partial name", func() {
+			candidates := RankCandidates("apache", items)
+			ids := []string{}
+			for _, c := range candidates {
+				ids = append(ids, c.Id)
+			}
+			Expect(ids).To(ConsistOf("1", "3", "4"))
+		})
+
+		It("Returns nothing for unrelated names", func() {
+			Expect(RankCandidates("database backup", items)).To(BeEmpty())
+		})
+	})
+
+	Describe("RightScript search", func() {
+		head := &cm15.RightScript{Name: "Install", Description: "Installs the Apache web server", Revision: 0}
+		committed := &cm15.RightScript{Name: "Install", Description: "Installs nginx", Revision: 3}
+
+		It("Matches everything without any predicates", func() {
+			Expect(RightScriptSearch{}.Match(head)).To(BeTrue())
+			Expect(RightScriptSearch{}.Match(committed)).To(BeTrue())
+		})
+
+		It("Matches only HEAD revisions", func() {
+			Expect(RightScriptSearch{HeadOnly: true}.Match(head)).To(BeTrue())
+			Expect(RightScriptSearch{HeadOnly: true}.Match(committed)).To(BeFalse())
+		})
+
+		It("Matches descriptions ignoring case", func() {
+			Expect(RightScriptSearch{DescriptionContains: "apache"}.Match(head)).To(BeTrue())
+			Expect(RightScriptSearch{DescriptionContains: "apache"}.Match(committed)).To(BeFalse())
+		})
+	})
+
+	Describe("Print attachment list", func() {
+		items := []AttachmentListItem{
+			{Id: "1", Digest: "d41d8cd98f00b204e9800998ecf8427e", Name: "empty.txt"},
+			{Id: "2", Digest: "5d41402abc4b2a76b9719d911017c592", Name: "hello.txt"},
+		}
+
+		It("Prints the ID, digest, and name of each attachment", func() {
+			buffer := new(bytes.Buffer)
+			Expect(PrintAttachmentList(buffer, items, "text")).To(Succeed())
+			Expect(buffer.String()).To(Equal("1 d41d8cd98f00b204e9800998ecf8427e empty.txt\n" +
+				"2 5d41402abc4b2a76b9719d911017c592 hello.txt\n"))
+		})
+
+		It("Prints JSON", func() {
+			buffer := new(bytes.Buffer)
+			Expect(PrintAttachmentList(buffer, items, "json")).To(Succeed())
+			Expect(buffer.String()).To(MatchJSON(`[
+				{"id": "1", "digest": "d41d8cd98f00b204e9800998ecf8427e", "name": "empty.txt"},
+				{"id": "2", "digest": "5d41402abc4b2a76b9719d911017c592", "name": "hello.txt"}
+			]`))
+		})
+	})
+
+	Describe("Print attachment digests", func() {
+		items := []AttachmentListItem{
+			{Id: "2", Digest: "5d41402abc4b2a76b9719d911017c592", Name: "hello.txt"},
+			{Id: "1", Digest: "d41d8cd98f00b204e9800998ecf8427e", Name: "empty.txt"},
+		}
+
+		It("Prints only the digests sorted by name", func() {
+			buffer := new(bytes.Buffer)
+			Expect(PrintAttachmentDigests(buffer, items, false)).To(Succeed())
+			Expect(buffer.String()).To(Equal("d41d8cd98f00b204e9800998ecf8427e\n" +
+				"5d41402abc4b2a76b9719d911017c592\n"))
+		})
+
+		It("Prints the digests with names like md5sum", func() {
+			buffer := new(bytes.Buffer)
+			Expect(PrintAttachmentDigests(buffer, items, true)).To(Succeed())
+			Expect(buffer.String()).To(Equal("d41d8cd98f00b204e9800998ecf8427e  empty.txt\n" +
+				"5d41402abc4b2a76b9719d911017c592  hello.txt\n"))
+		})
+	})
+
+	Describe("Content range size", func() {
+		It("Takes the complete length of a partial response", func() {
+			size, ok := ContentRangeSize("bytes 0-0/1234")
+			Expect(ok).To(BeTrue())
+			Expect(size).To(Equal(int64(1234)))
+		})
+
+		It("Rejects an unknown length or a missing header", func() {
+			_, ok := ContentRangeSize("bytes 0-0/*")
+			Expect(ok).To(BeFalse())
+			_, ok = ContentRangeSize("")
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Describe("Print links", func() {
+		It("Prints each rel and HREF in order", func() {
+			buffer := new(bytes.Buffer)
+			Expect(PrintLinks(buffer, []map[string]string{
+				{"rel": "self", "href": "/api/right_scripts/1"},
+				{"rel": "attachments", "href": "/api/right_scripts/1/attachments"},
+			})).To(Succeed())
+			Expect(buffer.String()).To(Equal("  self: /api/right_scripts/1\n  attachments: /api/right_scripts/1/attachments\n"))
+		})
+	})
+
+	Describe("Sync differences", func() {
+		source := []byte("#!/bin/bash\necho hello\n")
+		digests := map[string]string{"a.txt": "0cc175b9c0f1b6a831c399e269772661"}
+
+		It("Finds nothing when the source and attachments match", func() {
+			Expect(SyncDifferences(source, source, digests, map[string]string{"a.txt": "0cc175b9c0f1b6a831c399e269772661"})).To(
+				BeEmpty())
+		})
+
+		It("Reports a different source", func() {
+			Expect(SyncDifferences(source, []byte("#!/bin/bash\n"), digests, digests)).To(Equal([]string{"source"}))
+		})
+
+		It("Reports attachments which are changed, missing, or extra", func() {
+			Expect(SyncDifferences(source, source, digests, map[string]string{"a.txt": "92eb5ffee6ae2fec3ad71c777531578f"})).To(
+				Equal([]string{"attachments"}))
+			Expect(SyncDifferences(source, source, digests, map[string]string{})).To(Equal([]string{"attachments"}))
+			Expect(SyncDifferences([]byte{}, source, map[string]string{}, digests)).To(Equal([]string{"source", "attachments"}))
+		})
+	})
+
+	Describe("Name from path", func() {
+		It("Joins the path relative to the root with the separator", func() {
+			root := filepath.Join("scripts")
+			file := filepath.Join("scripts", "category", "subcategory", "name.sh")
+			Expect(NameFromPath(root, file, "/")).To(Equal("category/subcategory/name"))
+			Expect(NameFromPath(root, file, " - ")).To(Equal("category - subcategory - name"))
+		})
+
+		It("Uses the file name for a file given directly", func() {
+			file := filepath.Join("scripts", "name.sh")
+			Expect(NameFromPath(file, file, "/")).To(Equal("name"))
+		})
+	})
+
+	Describe("Ignored path", func() {
+		It("Ignores hidden and VCS directories", func() {
+			Expect(IgnoredPath(".git", true)).To(BeTrue())
+			Expect(IgnoredPath(".svn", true)).To(BeTrue())
+			Expect(IgnoredPath("scripts", true)).To(BeFalse())
+		})
+
+		It("Ignores editor backup files and .DS_Store", func() {
+			Expect(IgnoredPath("script.sh~", false)).To(BeTrue())
+			Expect(IgnoredPath(".script.sh.swp", false)).To(BeTrue())
+			Expect(IgnoredPath("script.sh.swp", false)).To(BeTrue())
+			Expect(IgnoredPath(".DS_Store", false)).To(BeTrue())
+			Expect(IgnoredPath("script.sh", false)).To(BeFalse())
+		})
+	})
+
+	Describe("Walk paths", func() {
+		var tempDir string
+		var stderr bytes.Buffer
+
+		BeforeEach(func() {
+			var err error
+			tempDir, err = ioutil.TempDir("", "walk")
+			if err != nil {
+				panic(err)
+			}
+			// EvalSymlinks resolves the temporary directory itself on some systems, e.g. /tmp on Mac OS X
+			if tempDir, err = filepath.EvalSymlinks(tempDir); err != nil {
+				panic(err)
+			}
+			for _, dir := range []string{"scripts/.git", "scripts/sub", "shared"} {
+				if err := os.MkdirAll(filepath.Join(tempDir, dir), 0755); err != nil {
+					panic(err)
+				}
+			}
+			for _, file := range []string{"scripts/a.sh", "scripts/.git/config", "scripts/sub/b.sh", "shared/c.sh"} {
+				if err := ioutil.WriteFile(filepath.Join(tempDir, file), []byte("#!/bin/bash\n"), 0644); err != nil {
+					panic(err)
+				}
+			}
+			if err := os.Symlink(filepath.Join("..", "shared"), filepath.Join(tempDir, "scripts", "link")); err != nil {
+				panic(err)
+			}
+			stderr.Reset()
+			Stderr = &stderr
+		})
+
+		AfterEach(func() {
+			Stderr = os.Stderr
+			os.RemoveAll(tempDir)
+		})
+
+		paths := func(names ...string) []string {
+			files := make([]string, len(names))
+			for i, name := range names {
+				files[i] = filepath.Join(tempDir, name)
+			}
+			return files
+		}
+
+		It("Does not follow symlinks by default", func() {
+			Expect(WalkPaths(paths("scripts"), false, false)).To(Equal(paths(
+				"scripts", "scripts/a.sh", "scripts/link", "scripts/sub", "scripts/sub/b.sh",
+			)))
+		})
+
+		It("Includes hidden files with includeHidden", func() {
+			Expect(WalkPaths(paths("scripts"), false, true)).To(Equal(paths(
+				"scripts", "scripts/.git", "scripts/.git/config", "scripts/a.sh", "scripts/link", "scripts/sub", "scripts/sub/b.sh",
+			)))
+		})
+
+		It("Walks directories symlinks point to with followSymlinks", func() {
+			Expect(WalkPaths(paths("scripts"), true, false)).To(Equal(paths(
+				"scripts", "scripts/a.sh", "scripts/link", "scripts/link/c.sh", "scripts/sub", "scripts/sub/b.sh",
+			)))
+			Expect(stderr.String()).To(BeEmpty())
+		})
+
+		It("Does not follow a symlink to a directory containing it", func() {
+			Expect(os.Symlink("..", filepath.Join(tempDir, "scripts", "sub", "up"))).To(Succeed())
+			Expect(WalkPaths(paths("scripts"), true, false)).To(Equal(paths(
+				"scripts", "scripts/a.sh", "scripts/link", "scripts/link/c.sh", "scripts/sub", "scripts/sub/b.sh", "scripts/sub/up",
+			)))
+			Expect(stderr.String()).To(ContainSubstring("Not following symlink " + filepath.Join(tempDir, "scripts", "sub", "up")))
+		})
+
+		It("Does not loop over sibling directories with symlinks to each other", func() {
+			Expect(os.Symlink(filepath.Join("..", "scripts", "sub"), filepath.Join(tempDir, "shared", "back"))).To(Succeed())
+			Expect(os.Symlink(filepath.Join("..", "..", "shared"), filepath.Join(tempDir, "scripts", "sub", "other"))).To(Succeed())
+			Expect(WalkPaths(paths("scripts"), true, false)).To(Equal(paths(
+				"scripts", "scripts/a.sh", "scripts/link", "scripts/link/back", "scripts/link/back/b.sh",
+				"scripts/link/back/other", "scripts/link/c.sh",
+			)))
+			Expect(stderr.String()).To(ContainSubstring("Not following symlink " + filepath.Join(tempDir, "scripts", "link", "back", "other")))
+		})
+
+		It("Walks each directory only once", func() {
+			Expect(WalkPaths(paths("scripts", "shared"), true, false)).To(Equal(paths(
+				"scripts", "scripts/a.sh", "scripts/link", "scripts/link/c.sh", "scripts/sub", "scripts/sub/b.sh", "shared",
+			)))
+		})
+	})
+
+	Describe("Write upload report", func() {
+		It("Writes the results as JSON with sorted attachment names", func() {
+			tempFile, err := ioutil.TempFile("", "report")
+			Expect(err).NotTo(HaveOccurred())
+			tempFile.Close()
+			defer os.Remove(tempFile.Name())
+
+			results := []*rightscript.PushResult{
+				{Path: "a.sh", Name: "A", Action: "created", Href: "/api/right_scripts/1", Attachments: &rightscript.AttachmentChanges{
+					Uploaded: []string{"b.txt", "a.txt"}, Deleted: []string{}, Renamed: []string{}, Unchanged: []string{},
+				}},
+				{Path: "b.sh", Action: "skipped"},
+			}
+			Expect(WriteUploadReport(tempFile.Name(), results)).To(Succeed())
+			Expect(ioutil.ReadFile(tempFile.Name())).To(MatchJSON(`[
+				{"path": "a.sh", "name": "A", "action": "created", "href": "/api/right_scripts/1", "revision": 0,
+				 "attachments": {"uploaded": ["a.txt", "b.txt"], "deleted": [], "renamed": [], "unchanged": []}},
+				{"path": "b.sh", "action": "skipped", "revision": 0}
+			]`))
+		})
+	})
+})
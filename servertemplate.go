@@ -11,16 +11,18 @@ import (
 	"github.com/go-yaml/yaml"
 	"github.com/rightscale/rsc/cm15"
 	"github.com/rightscale/rsc/rsapi"
+
+	"github.com/rightscale/right_st/rightscript"
 )
 
 type ServerTemplate struct {
 	href             string
-	Name             string                    `yaml:"Name"`
-	Description      string                    `yaml:"Description"`
-	Inputs           map[string]*InputValue    `yaml:"Inputs"`
-	RightScripts     map[string][]*RightScript `yaml:"RightScripts"`
-	MultiCloudImages []*MultiCloudImage        `yaml:"MultiCloudImages"`
-	Alerts           []*Alert                  `yaml:"Alerts"`
+	Name             string                                `yaml:"Name"`
+	Description      string                                `yaml:"Description"`
+	Inputs           map[string]*rightscript.InputValue    `yaml:"Inputs"`
+	RightScripts     map[string][]*rightscript.RightScript `yaml:"RightScripts"`
+	MultiCloudImages []*MultiCloudImage                    `yaml:"MultiCloudImages"`
+	Alerts           []*Alert                              `yaml:"Alerts"`
 }
 
 var sequenceTypes []string = []string{"Boot", "Operational", "Decommission"}
@@ -28,28 +30,28 @@ var sequenceTypes []string = []string{"Boot", "Operational", "Decommission"}
 func stUpload(files []string, prefix string) {
 
 	for _, file := range files {
-		fmt.Printf("Validating %s\n", file)
+		fmt.Fprintf(Stdout, "Validating %s\n", file)
 		st, errors := validateServerTemplate(file)
 		if len(errors) != 0 {
-			fmt.Println("Encountered the following errors with the ServerTemplate:")
+			fmt.Fprintln(Stderr, "Encountered the following errors with the ServerTemplate:")
 			for _, err := range errors {
-				fmt.Println(err)
+				fmt.Fprintln(Stderr, err)
 			}
-			os.Exit(1)
+			exit(exitValidation)
 		}
 		stName := st.Name
 		if prefix != "" {
 			stName = fmt.Sprintf("%s_%s", prefix, stName)
 		}
-		fmt.Printf("Validation successful, uploading as '%s'\n", stName)
+		fmt.Fprintf(Stdout, "Validation successful, uploading as '%s'\n", stName)
 
 		if *debug {
-			fmt.Printf("ST: %#v\n", *st)
+			fmt.Fprintf(Stdout, "ST: %#v\n", *st)
 		}
 		err := doServerTemplateUpload(st, prefix)
 
 		if err != nil {
-			fatalError("Failed to upload ServerTemplate '%s': %s", file, err.Error())
+			fatalError(ErrorExitCode(err), "Failed to upload ServerTemplate '%s': %s", file, err.Error())
 		}
 	}
 }
@@ -71,7 +73,7 @@ func doServerTemplateUpload(stDef *ServerTemplate, prefix string) error {
 	st, err := getServerTemplateByName(stName)
 
 	if err != nil {
-		fatalError("Failed to query for ServerTemplate '%s': %s", stName, err.Error())
+		fatalError(ErrorExitCode(err), "Failed to query for ServerTemplate '%s': %s", stName, err.Error())
 	}
 
 	// -----------------
@@ -86,33 +88,33 @@ func doServerTemplateUpload(stDef *ServerTemplate, prefix string) error {
 		}
 		stLoc, err := client.ServerTemplateLocator("/api/server_templates").Create(&params)
 		if err != nil {
-			fatalError("Failed to create ServerTemplate '%s': %s", stName, err.Error())
+			fatalError(ErrorExitCode(err), "Failed to create ServerTemplate '%s': %s", stName, err.Error())
 		}
 		st, err = stLoc.Show(rsapi.APIParams{})
 		if err != nil {
-			fatalError("Failed to refetch ServerTemplate '%s': %s", stLoc.Href, err.Error())
+			fatalError(ErrorExitCode(err), "Failed to refetch ServerTemplate '%s': %s", stLoc.Href, err.Error())
 		}
 		stVerb = "Creating"
 	} else {
 		if st.Description != stDef.Description {
 			err := st.Locator(client).Update(&cm15.ServerTemplateParam{Description: stDef.Description})
 			if err != nil {
-				fatalError("Failed to update ServerTemplate '%s' description: %s", stName, err.Error())
+				fatalError(ErrorExitCode(err), "Failed to update ServerTemplate '%s' description: %s", stName, err.Error())
 			}
 		}
 	}
-	stDef.href = getLink(st.Links, "self")
-	fmt.Printf("%s ServerTemplate with HREF %s\n", stVerb, stDef.href)
+	stDef.href = rightscript.Link(st.Links, "self")
+	fmt.Fprintf(Stdout, "%s ServerTemplate with HREF %s\n", stVerb, stDef.href)
 
 	// -----------------
 	// Synchronize MCIs
 	// -----------------
 	// Get a list of MCIs on the existing ST.
-	fmt.Println("Updating MCIs:")
+	fmt.Fprintln(Stdout, "Updating MCIs:")
 	if err := uploadMultiCloudImages(stDef, prefix); err != nil {
-		fatalError("  Synchronize MultiCloudImages failed: %s", err.Error())
+		fatalError(ErrorExitCode(err), "  Synchronize MultiCloudImages failed: %s", err.Error())
 	}
-	fmt.Println("  MCIs synced")
+	fmt.Fprintln(Stdout, "  MCIs synced")
 
 	// -----------------
 	// Synchronize RightScripts
@@ -123,7 +125,7 @@ func doServerTemplateUpload(stDef *ServerTemplate, prefix string) error {
 	// Get RightScript object in RightScale. RightScript.Push() handles both cases below:
 	//		1. Doesn't exist: create
 	//		2. Exists: Update contents
-	fmt.Println("Updating or Creating RightScripts:")
+	fmt.Fprintln(Stdout, "Updating or Creating RightScripts:")
 	hrefByName := make(map[string]string)
 	for _, sequenceType := range sequenceTypes {
 		for _, script := range stDef.RightScripts[sequenceType] {
@@ -132,18 +134,18 @@ func doServerTemplateUpload(stDef *ServerTemplate, prefix string) error {
 			}
 			// Push() has the side effort of always populating script.Href which we use below -- probably
 			// rework this to be a bit more upfront in the future.
-			err := script.Push(prefix)
+			err := rightScriptClient(client).Push(script, rightscript.PushOptions{Prefix: prefix})
 			hrefByName[script.Metadata.Name] = script.Href
 			if err != nil {
-				fatalError("  %s", err.Error())
+				fatalError(ErrorExitCode(err), "  %s", err.Error())
 			}
 		}
 	}
-	fmt.Println("  RightScripts synced")
+	fmt.Fprintln(Stdout, "  RightScripts synced")
 
 	// Add new RightScripts to the sequence list. Don't worry about order for now, that'll be fixed up below
-	fmt.Println("Setting order of RightScripts:")
-	rbLoc := client.RunnableBindingLocator(getLink(st.Links, "runnable_bindings"))
+	fmt.Fprintln(Stdout, "Setting order of RightScripts:")
+	rbLoc := client.RunnableBindingLocator(rightscript.Link(st.Links, "runnable_bindings"))
 	existingRbs, _ := rbLoc.Index(rsapi.APIParams{})
 	seenExistingRbs := make([]bool, len(existingRbs), len(existingRbs))
 	for _, sequenceType := range sequenceTypes {
@@ -151,7 +153,7 @@ func doServerTemplateUpload(stDef *ServerTemplate, prefix string) error {
 			seenScript := false
 			scriptHref := hrefByName[script.Metadata.Name]
 			for i, rb := range existingRbs {
-				rbHref := getLink(rb.Links, "right_script")
+				rbHref := rightscript.Link(rb.Links, "right_script")
 				if rb.Sequence == strings.ToLower(sequenceType) && rbHref == scriptHref {
 					seenScript = true
 					seenExistingRbs[i] = true
@@ -162,10 +164,10 @@ func doServerTemplateUpload(stDef *ServerTemplate, prefix string) error {
 					RightScriptHref: scriptHref,
 					Sequence:        strings.ToLower(sequenceType),
 				}
-				fmt.Printf("  Adding %s to ServerTemplate %s bundle\n", scriptHref, sequenceType)
+				fmt.Fprintf(Stdout, "  Adding %s to ServerTemplate %s bundle\n", scriptHref, sequenceType)
 				_, err := rbLoc.Create(&params)
 				if err != nil {
-					fatalError("  Could not create %s RunnableBinding for HREF %s: %s", sequenceType, scriptHref, err.Error())
+					fatalError(ErrorExitCode(err), "  Could not create %s RunnableBinding for HREF %s: %s", sequenceType, scriptHref, err.Error())
 				}
 			}
 		}
@@ -173,10 +175,10 @@ func doServerTemplateUpload(stDef *ServerTemplate, prefix string) error {
 	// Remove RightScripts that don't belong from the sequence list
 	for i, rb := range existingRbs {
 		if !seenExistingRbs[i] {
-			fmt.Printf("  Removing %s from ServerTemplate\n", getLink(rb.Links, "right_script"))
+			fmt.Fprintf(Stdout, "  Removing %s from ServerTemplate\n", rightscript.Link(rb.Links, "right_script"))
 			err := rb.Locator(client).Destroy()
 			if err != nil {
-				fatalError("  Could not destroy RunnableBinding %s: %s", getLink(rb.Links, "right_script"), err.Error())
+				fatalError(ErrorExitCode(err), "  Could not destroy RunnableBinding %s: %s", rightscript.Link(rb.Links, "right_script"), err.Error())
 			}
 		}
 	}
@@ -185,7 +187,7 @@ func doServerTemplateUpload(stDef *ServerTemplate, prefix string) error {
 	existingRbs, _ = rbLoc.Index(rsapi.APIParams{})
 	rbLookup := make(map[string]*cm15.RunnableBinding)
 	for _, rb := range existingRbs {
-		key := rb.Sequence + "_" + getLink(rb.Links, "right_script")
+		key := rb.Sequence + "_" + rightscript.Link(rb.Links, "right_script")
 		rbLookup[key] = rb
 	}
 
@@ -195,7 +197,7 @@ func doServerTemplateUpload(stDef *ServerTemplate, prefix string) error {
 			key := strings.ToLower(sequenceType) + "_" + hrefByName[script.Metadata.Name]
 			rb, ok := rbLookup[key]
 			if !ok {
-				fatalError("  Could not lookup RunnableBinding %s", key)
+				fatalError(exitGeneral, "  Could not lookup RunnableBinding %s", key)
 			}
 			b := cm15.RunnableBindings{
 				Id:       rb.Id,
@@ -208,21 +210,21 @@ func doServerTemplateUpload(stDef *ServerTemplate, prefix string) error {
 	if len(bindings) > 0 {
 		err = rbLoc.MultiUpdate(bindings)
 		if err != nil {
-			fatalError("  MultiUpdate to set RunnableBinding order failed: %s", err.Error())
+			fatalError(ErrorExitCode(err), "  MultiUpdate to set RunnableBinding order failed: %s", err.Error())
 		}
-		fmt.Println("  RightScript order set")
+		fmt.Fprintln(Stdout, "  RightScript order set")
 	} else {
-		fmt.Println("  No RightScripts to order")
+		fmt.Fprintln(Stdout, "  No RightScripts to order")
 	}
 
 	// -----------------
 	// Set Inputs
 	// -----------------
-	fmt.Println("Setting Inputs")
+	fmt.Fprintln(Stdout, "Setting Inputs")
 	inputsLoc := client.InputLocator(stDef.href + "/inputs")
 	oldInputs, err := inputsLoc.Index(rsapi.APIParams{"view": "inputs_2_0"})
 	if err != nil {
-		fatalError("  Failed to Index inputs: %s", err.Error())
+		fatalError(ErrorExitCode(err), "  Failed to Index inputs: %s", err.Error())
 	}
 	inputParams := make(map[string]interface{})
 	for _, input := range oldInputs {
@@ -234,22 +236,22 @@ func doServerTemplateUpload(stDef *ServerTemplate, prefix string) error {
 	if len(inputParams) > 0 {
 		err = inputsLoc.MultiUpdate(inputParams)
 		if err != nil {
-			fatalError("  Failed to MultiUpdate inputs: %s", err.Error())
+			fatalError(ErrorExitCode(err), "  Failed to MultiUpdate inputs: %s", err.Error())
 		}
-		fmt.Println("  Inputs set")
+		fmt.Fprintln(Stdout, "  Inputs set")
 	} else {
-		fmt.Println("  No inputs to set")
+		fmt.Fprintln(Stdout, "  No inputs to set")
 	}
 
 	// -----------------
 	// Synchronize Alerts
 	// -----------------
-	fmt.Println("Synchronizing Alerts")
+	fmt.Fprintln(Stdout, "Synchronizing Alerts")
 	if err := uploadAlerts(stDef); err != nil {
-		fatalError("  Synchronize alerts failed: %s", err.Error())
+		fatalError(ErrorExitCode(err), "  Synchronize alerts failed: %s", err.Error())
 	}
 
-	fmt.Printf("Successfully uploaded ServerTemplate %s with HREF %s\n", st.Name, stDef.href)
+	fmt.Fprintf(Stdout, "Successfully uploaded ServerTemplate %s with HREF %s\n", st.Name, stDef.href)
 
 	// If the user requested a commit on changes, commit the ST. This will commit all RightScripts as well.
 	return nil
@@ -262,135 +264,135 @@ func doServerTemplateUpload(stDef *ServerTemplate, prefix string) error {
 func stShow(href string) {
 	client, err := Config.Account.Client15()
 	if err != nil {
-		fatalError("Could not find ServerTemplate with href %s: %s", href, err.Error())
+		fatalError(exitConfig, "Could not find ServerTemplate with href %s: %s", href, err.Error())
 	}
 
 	stLocator := client.ServerTemplateLocator(href)
 	st, err := stLocator.Show(rsapi.APIParams{"view": "inputs_2_0"})
 	if err != nil {
-		fatalError("Could not find ServerTemplate with href %s: %s", href, err.Error())
+		fatalError(ErrorExitCode(err), "Could not find ServerTemplate with href %s: %s", href, err.Error())
 	}
 
-	mciLocator := client.MultiCloudImageLocator(getLink(st.Links, "multi_cloud_images"))
+	mciLocator := client.MultiCloudImageLocator(rightscript.Link(st.Links, "multi_cloud_images"))
 	mcis, err := mciLocator.Index(rsapi.APIParams{})
 	if err != nil {
-		fatalError("Could not find MCIs with href %s: %s", mciLocator.Href, err.Error())
+		fatalError(ErrorExitCode(err), "Could not find MCIs with href %s: %s", mciLocator.Href, err.Error())
 	}
 
-	rbLocator := client.RunnableBindingLocator(getLink(st.Links, "runnable_bindings"))
+	rbLocator := client.RunnableBindingLocator(rightscript.Link(st.Links, "runnable_bindings"))
 	rbs, err := rbLocator.Index(rsapi.APIParams{})
 	if err != nil {
-		fatalError("Could not find attached RightScripts with href %s: %s", rbLocator.Href, err.Error())
+		fatalError(ErrorExitCode(err), "Could not find attached RightScripts with href %s: %s", rbLocator.Href, err.Error())
 	}
 
-	alertsLocator := client.AlertSpecLocator(getLink(st.Links, "alert_specs"))
+	alertsLocator := client.AlertSpecLocator(rightscript.Link(st.Links, "alert_specs"))
 	alerts, err := alertsLocator.Index(rsapi.APIParams{})
 	if err != nil {
-		fatalError("Could not find AlertSpecs with href %s: %s", alertsLocator.Href, err.Error())
+		fatalError(ErrorExitCode(err), "Could not find AlertSpecs with href %s: %s", alertsLocator.Href, err.Error())
 	}
 
 	rev := "HEAD"
 	if st.Revision != 0 {
 		rev = fmt.Sprintf("%d", st.Revision)
 	}
-	stHref := getLink(st.Links, "self")
+	stHref := rightscript.Link(st.Links, "self")
 
-	fmt.Printf("Name: %s\n", st.Name)
-	fmt.Printf("HREF: %s\n", stHref)
-	fmt.Printf("Revision: %s\n", rev)
-	fmt.Printf("Description: \n%s\n", st.Description)
-	fmt.Printf("MultiCloudImages: (href, rev, name) \n")
+	fmt.Fprintf(Stdout, "Name: %s\n", st.Name)
+	fmt.Fprintf(Stdout, "HREF: %s\n", stHref)
+	fmt.Fprintf(Stdout, "Revision: %s\n", rev)
+	fmt.Fprintf(Stdout, "Description: \n%s\n", st.Description)
+	fmt.Fprintf(Stdout, "MultiCloudImages: (href, rev, name) \n")
 	for _, item := range mcis {
-		mciHref := getLink(item.Links, "self")
+		mciHref := rightscript.Link(item.Links, "self")
 		rev := "HEAD"
 		if item.Revision != 0 {
 			rev = fmt.Sprintf("%d", item.Revision)
 		}
-		fmt.Printf("  %s %5s %s\n", mciHref, rev, item.Name)
+		fmt.Fprintf(Stdout, "  %s %5s %s\n", mciHref, rev, item.Name)
 	}
-	fmt.Printf("RightScripts:\n")
+	fmt.Fprintf(Stdout, "RightScripts:\n")
 	seenSequence := make(map[string]bool)
 	for _, sequenceType := range sequenceTypes {
 		for _, item := range rbs {
-			rsHref := getLink(item.Links, "right_script")
+			rsHref := rightscript.Link(item.Links, "right_script")
 			//if item.RightScript != cm15.RightScript(nil) {
 			rs := item.RightScript
 			if item.Sequence != strings.ToLower(sequenceType) {
 				continue
 			}
 			if !seenSequence[item.Sequence] {
-				fmt.Printf("  %s: (href, rev, name)\n", sequenceType)
+				fmt.Fprintf(Stdout, "  %s: (href, rev, name)\n", sequenceType)
 			}
 			seenSequence[item.Sequence] = true
 			rev := "HEAD"
 			if rs.Revision != 0 {
 				rev = fmt.Sprintf("%d", rs.Revision)
 			}
-			fmt.Printf("    %s %5s %s\n", rsHref, rev, rs.Name)
+			fmt.Fprintf(Stdout, "    %s %5s %s\n", rsHref, rev, rs.Name)
 			// } else {
-			//  fmt.Printf(" RECIPE - NOT HANDLED YET")
+			//  fmt.Fprintf(Stdout, " RECIPE - NOT HANDLED YET")
 			// }
 		}
 	}
-	fmt.Printf("Alerts:\n")
+	fmt.Fprintf(Stdout, "Alerts:\n")
 	for _, alert := range alerts {
-		fmt.Printf("  - Name: %s\n", alert.Name)
-		fmt.Printf("    Description: %s\n", alert.Description)
-		fmt.Printf("    Value: %s\n", printAlertClause(*alert))
+		fmt.Fprintf(Stdout, "  - Name: %s\n", alert.Name)
+		fmt.Fprintf(Stdout, "    Description: %s\n", alert.Description)
+		fmt.Fprintf(Stdout, "    Value: %s\n", printAlertClause(*alert))
 	}
 }
 
 func stDownload(href, downloadTo string, usePublished bool, downloadMciSettings bool, scriptPath string) {
 	client, err := Config.Account.Client15()
 	if err != nil {
-		fatalError("Could not find ServerTemplate with href %s: %s", href, err.Error())
+		fatalError(exitConfig, "Could not find ServerTemplate with href %s: %s", href, err.Error())
 	}
 
 	stLocator := client.ServerTemplateLocator(href)
 	st, err := stLocator.Show(rsapi.APIParams{"view": "inputs_2_0"})
 
 	if err != nil {
-		fatalError("Could not find ServerTemplate with href %s: %s", href, err.Error())
+		fatalError(ErrorExitCode(err), "Could not find ServerTemplate with href %s: %s", href, err.Error())
 	}
 
 	if downloadTo == "" {
-		downloadTo = cleanFileName(st.Name) + ".yml"
-	} else if isDirectory(downloadTo) {
-		downloadTo = filepath.Join(downloadTo, cleanFileName(st.Name)+".yml")
+		downloadTo = rightscript.CleanFileName(st.Name) + ".yml"
+	} else if rightscript.IsDirectory(downloadTo) {
+		downloadTo = filepath.Join(downloadTo, rightscript.CleanFileName(st.Name)+".yml")
 	}
-	fmt.Printf("Downloading '%s' to '%s'\n", st.Name, downloadTo)
+	fmt.Fprintf(Stdout, "Downloading '%s' to '%s'\n", st.Name, downloadTo)
 
 	//-------------------------------------
 	// MultiCloudImages
 	//-------------------------------------
 	mcis, err := downloadMultiCloudImages(st, downloadMciSettings)
 	if err != nil {
-		fatalError("Could not get MCIs from API: %s", err.Error())
+		fatalError(ErrorExitCode(err), "Could not get MCIs from API: %s", err.Error())
 	}
 
 	//-------------------------------------
 	// RightScripts
 	//-------------------------------------
-	rbLocator := client.RunnableBindingLocator(getLink(st.Links, "runnable_bindings"))
+	rbLocator := client.RunnableBindingLocator(rightscript.Link(st.Links, "runnable_bindings"))
 	rbs, err := rbLocator.Index(rsapi.APIParams{})
 	if err != nil {
-		fatalError("Could not find attached RightScripts with href %s: %s", rbLocator.Href, err.Error())
+		fatalError(ErrorExitCode(err), "Could not find attached RightScripts with href %s: %s", rbLocator.Href, err.Error())
 	}
-	rightScripts := make(map[string][]*RightScript)
+	rightScripts := make(map[string][]*rightscript.RightScript)
 	countBySequence := make(map[string]int)
-	seenRightscript := make(map[string]*RightScript)
+	seenRightscript := make(map[string]*rightscript.RightScript)
 	for _, rb := range rbs {
 		countBySequence[strings.Title(rb.Sequence)] += 1
-		seenRightscript[getLink(rb.Links, "right_script")] = nil
+		seenRightscript[rightscript.Link(rb.Links, "right_script")] = nil
 	}
 	for sequenceType, count := range countBySequence {
-		rightScripts[sequenceType] = make([]*RightScript, count)
+		rightScripts[sequenceType] = make([]*rightscript.RightScript, count)
 	}
-	fmt.Printf("Downloading %d attached RightScripts:\n", len(seenRightscript))
+	fmt.Fprintf(Stdout, "Downloading %d attached RightScripts:\n", len(seenRightscript))
 	for _, rb := range rbs {
-		rsHref := getLink(rb.Links, "right_script")
+		rsHref := rightscript.Link(rb.Links, "right_script")
 		if rsHref == "" {
-			fatalError("Could not download ServerTemplate, it has attached cookbook recipes, which are not supported by this tool.\n")
+			fatalError(exitGeneral, "Could not download ServerTemplate, it has attached cookbook recipes, which are not supported by this tool.\n")
 		}
 
 		if scr, ok := seenRightscript[rsHref]; ok && scr != nil {
@@ -398,9 +400,9 @@ func stDownload(href, downloadTo string, usePublished bool, downloadMciSettings
 			continue
 		}
 
-		newScript := RightScript{
-			Type: LocalRightScript,
-			Path: cleanFileName(rb.RightScript.Name),
+		newScript := rightscript.RightScript{
+			Type: rightscript.LocalRightScript,
+			Path: rightscript.CleanFileName(rb.RightScript.Name),
 		}
 		if usePublished {
 			// We repull the rightscript here to get the description field, which we need to break ties between
@@ -408,17 +410,17 @@ func stDownload(href, downloadTo string, usePublished bool, downloadMciSettings
 			rsLoc := client.RightScriptLocator(rsHref)
 			rs, err := rsLoc.Show(rsapi.APIParams{})
 			if err != nil {
-				fatalError("Could not get RightScript %s: %s\n", rsHref, err.Error())
+				fatalError(ErrorExitCode(err), "Could not get RightScript %s: %s\n", rsHref, err.Error())
 			}
-			pub, err := findPublication("RightScript", rs.Name, rs.Revision, map[string]string{`Description`: rs.Description})
+			pub, err := rightScriptClient(client).FindPublication("RightScript", rs.Name, rs.Revision, map[string]string{`Description`: rs.Description})
 			if err != nil {
-				fatalError("Error finding publication: %s\n", err.Error())
+				fatalError(ErrorExitCode(err), "Error finding publication: %s\n", err.Error())
 			}
 			if pub != nil {
-				fmt.Printf("Not downloading '%s' to disk, using Revision %d, Publisher '%s' from the MultiCloud Marketplace\n",
+				fmt.Fprintf(Stdout, "Not downloading '%s' to disk, using Revision %d, Publisher '%s' from the MultiCloud Marketplace\n",
 					rs.Name, rs.Revision, pub.Publisher)
-				newScript = RightScript{
-					Type:      PublishedRightScript,
+				newScript = rightscript.RightScript{
+					Type:      rightscript.PublishedRightScript,
 					Name:      pub.Name,
 					Revision:  pub.Revision,
 					Publisher: pub.Publisher,
@@ -428,17 +430,17 @@ func stDownload(href, downloadTo string, usePublished bool, downloadMciSettings
 
 		rightScripts[strings.Title(rb.Sequence)][rb.Position-1] = &newScript
 
-		if newScript.Type == LocalRightScript {
+		if newScript.Type == rightscript.LocalRightScript {
 			if scriptPath == "" {
-				downloadedTo := rightScriptDownload(rsHref, filepath.Dir(downloadTo))
+				downloadedTo := rightScriptDownload(rsHref, filepath.Dir(downloadTo), false, 0755, false)
 				newScript.Path = strings.TrimPrefix(downloadedTo, filepath.Dir(downloadTo)+string(filepath.Separator))
 			} else {
 				// Create scripts directory
 				err := os.MkdirAll(filepath.Join(filepath.Dir(downloadTo), scriptPath), 0755)
 				if err != nil {
-					fatalError("Error creating directory: %s", err.Error())
+					fatalError(exitGeneral, "Error creating directory: %s", err.Error())
 				}
-				downloadedTo := rightScriptDownload(rsHref, filepath.Join(filepath.Dir(downloadTo), scriptPath))
+				downloadedTo := rightScriptDownload(rsHref, filepath.Join(filepath.Dir(downloadTo), scriptPath), false, 0755, false)
 				newScript.Path = strings.TrimPrefix(downloadedTo, filepath.Dir(downloadTo)+string(filepath.Separator))
 			}
 		}
@@ -450,18 +452,18 @@ func stDownload(href, downloadTo string, usePublished bool, downloadMciSettings
 	//-------------------------------------
 	alerts, err := downloadAlerts(st)
 	if err != nil {
-		fatalError("Could not get Alerts from API: %s", err.Error())
+		fatalError(ErrorExitCode(err), "Could not get Alerts from API: %s", err.Error())
 	}
 
 	//-------------------------------------
 	// Inputs
 	//-------------------------------------
-	stInputs := make(map[string]*InputValue)
+	stInputs := make(map[string]*rightscript.InputValue)
 	for _, inputHash := range st.Inputs {
-		iv, err := parseInputValue(inputHash["value"])
+		iv, err := rightscript.ParseInputValue(inputHash["value"])
 
 		if err != nil {
-			fatalError("Error parsing input value from API:", err.Error())
+			fatalError(ErrorExitCode(err), "Error parsing input value from API:", err.Error())
 		}
 		// The API returns "inherit" values as "blank" values. Blank really means an
 		// empty text string, which is usually not what was meant -- usually people
@@ -482,7 +484,7 @@ func stDownload(href, downloadTo string, usePublished bool, downloadMciSettings
 	//-------------------------------------
 	stDef := ServerTemplate{
 		Name:             st.Name,
-		Description:      removeCarriageReturns(st.Description),
+		Description:      rightscript.RemoveCarriageReturns(st.Description),
 		Inputs:           stInputs,
 		MultiCloudImages: mcis,
 		RightScripts:     rightScripts,
@@ -490,13 +492,13 @@ func stDownload(href, downloadTo string, usePublished bool, downloadMciSettings
 	}
 	bytes, err := yaml.Marshal(&stDef)
 	if err != nil {
-		fatalError("Creating yaml failed: %s", err.Error())
+		fatalError(exitGeneral, "Creating yaml failed: %s", err.Error())
 	}
 	err = ioutil.WriteFile(downloadTo, bytes, 0644)
 	if err != nil {
-		fatalError("Could not create file: %s", err.Error())
+		fatalError(exitGeneral, "Could not create file: %s", err.Error())
 	}
-	fmt.Printf("Finished downloading '%s' to '%s'\n", st.Name, downloadTo)
+	fmt.Fprintf(Stdout, "Finished downloading '%s' to '%s'\n", st.Name, downloadTo)
 
 }
 
@@ -505,17 +507,17 @@ func stValidate(files []string) {
 	for _, file := range files {
 		_, errors := validateServerTemplate(file)
 		if len(errors) != 0 {
-			fmt.Println("Encountered the following errors with the ServerTemplate:")
+			fmt.Fprintln(Stdout, "Encountered the following errors with the ServerTemplate:")
 			err_encountered = true
 			for _, err := range errors {
-				fmt.Fprintf(os.Stderr, "%s: %s\n", file, err.Error())
+				fmt.Fprintf(Stderr, "%s: %s\n", file, err.Error())
 			}
 		} else {
-			fmt.Printf("%s: Valid ServerTemplate\n", file)
+			fmt.Fprintf(Stdout, "%s: Valid ServerTemplate\n", file)
 		}
 	}
 	if err_encountered {
-		os.Exit(1)
+		exit(exitValidation)
 	}
 }
 
@@ -533,7 +535,7 @@ func validateServerTemplate(file string) (*ServerTemplate, []error) {
 		return nil, []error{err}
 	}
 
-	_, err = Config.Account.Client15()
+	client, err := Config.Account.Client15()
 	if err != nil {
 		return nil, []error{err}
 	}
@@ -552,13 +554,13 @@ func validateServerTemplate(file string) (*ServerTemplate, []error) {
 	//-------------------------------------
 	for sequence, scripts := range st.RightScripts {
 		for i, rs := range scripts {
-			if rs.Type == PublishedRightScript {
+			if rs.Type == rightscript.PublishedRightScript {
 				matchers := map[string]string{}
 				if rs.Publisher != "" {
 					matchers[`Publisher`] = rs.Publisher
 				}
 
-				pub, err := findPublication("RightScript", rs.Name, rs.Revision, matchers)
+				pub, err := rightScriptClient(client).FindPublication("RightScript", rs.Name, rs.Revision, matchers)
 				if err != nil {
 					errors = append(errors, fmt.Errorf("Error finding publication for RightScript: %s\n", err.Error()))
 				}
@@ -569,7 +571,7 @@ func validateServerTemplate(file string) (*ServerTemplate, []error) {
 					rs.Metadata.Description = pub.Description
 				}
 				rs.Metadata.Name = rs.Name
-			} else if rs.Type == LocalRightScript {
+			} else if rs.Type == rightscript.LocalRightScript {
 				rsNew, err := validateRightScript(filepath.Join(filepath.Dir(file), rs.Path), false)
 				if err != nil {
 					rsName := rs.Path
@@ -623,7 +625,11 @@ func getServerTemplateByName(name string) (*cm15.ServerTemplate, error) {
 	}
 
 	stLocator := client.ServerTemplateLocator("/api/server_templates")
-	apiParams := rsapi.APIParams{"filter": []string{"name==" + name}}
+	filter, err := rightscript.NameFilter(name)
+	if err != nil {
+		return nil, err
+	}
+	apiParams := rsapi.APIParams{"filter": []string{filter}}
 	fuzzySts, err := stLocator.Index(apiParams)
 	if err != nil {
 		return nil, err
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	. "github.com/rightscale/right_st"
+	"github.com/rightscale/right_st/rightscript"
 
 	"github.com/go-yaml/yaml"
 	. "github.com/onsi/ginkgo"
@@ -52,12 +53,12 @@ MultiCloudImages:
       User Data: Foo
 `)
 			It("should parse correctly", func() {
-				dummy1List := []*RightScript{
-					{Type: PublishedRightScript, Name: `RL10 Foo`, Revision: 10, Publisher: `RightScale`},
-					{Type: LocalRightScript, Path: "Dummy.sh"},
+				dummy1List := []*rightscript.RightScript{
+					{Type: rightscript.PublishedRightScript, Name: `RL10 Foo`, Revision: 10, Publisher: `RightScale`},
+					{Type: rightscript.LocalRightScript, Path: "Dummy.sh"},
 				}
-				dummy2List := []*RightScript{{Type: LocalRightScript, Path: "Dummy2.sh"}}
-				dummy3List := []*RightScript{{Type: LocalRightScript, Path: "Dummy3.sh"}}
+				dummy2List := []*rightscript.RightScript{{Type: rightscript.LocalRightScript, Path: "Dummy2.sh"}}
+				dummy3List := []*rightscript.RightScript{{Type: rightscript.LocalRightScript, Path: "Dummy3.sh"}}
 
 				st, err := ParseServerTemplate(script)
 				Expect(err).To(Succeed())
@@ -71,7 +72,7 @@ MultiCloudImages:
 					&Alert{Name: "Low memory warning",
 						Clause: "If memory/memory-free.value < 100000000 for 5 minutes Then escalate warning"},
 				}))
-				Expect(st.Inputs).To(Equal(map[string]*InputValue{"SERVER_HOSTNAME": &InputValue{Type: "text", Value: "test.local"}}))
+				Expect(st.Inputs).To(Equal(map[string]*rightscript.InputValue{"SERVER_HOSTNAME": &rightscript.InputValue{Type: "text", Value: "test.local"}}))
 				Expect(st.RightScripts["Boot"]).To(Equal(dummy1List))
 				Expect(st.RightScripts["Operational"]).To(Equal(dummy2List))
 				Expect(st.RightScripts["Decommission"]).To(Equal(dummy3List))
@@ -102,7 +103,7 @@ Inputs:
 				Expect(err).To(HaveOccurred())
 				Expect(err).To(MatchError(&yaml.TypeError{
 					Errors: []string{
-						"line 8: cannot unmarshal !!seq into map[string]*main.InputValue",
+						"line 8: cannot unmarshal !!seq into map[string]*rightscript.InputValue",
 					},
 				}))
 			})
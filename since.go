@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rightscale/right_st/rightscript"
+)
+
+// ParseSince parses the value of a --since flag which may either be a duration such as "24h" (meaning that long before
+// now) or an RFC 3339 timestamp such as "2016-07-04T12:00:00Z".
+func ParseSince(value string, now time.Time) (time.Time, error) {
+	if duration, err := time.ParseDuration(value); err == nil {
+		if duration < 0 {
+			return time.Time{}, fmt.Errorf("Invalid since value '%s': duration must not be negative", value)
+		}
+		return now.Add(-duration), nil
+	}
+	if timestamp, err := time.Parse(time.RFC3339, value); err == nil {
+		return timestamp, nil
+	}
+	return time.Time{}, fmt.Errorf("Invalid since value '%s': must be a duration (e.g. 24h) or an RFC 3339 timestamp (e.g. 2006-01-02T15:04:05Z)", value)
+}
+
+// FilterModifiedSince returns only the regular files from files which have been modified after since, or whose
+// sidecar metadata file has been. Directories are dropped since they are never uploaded themselves.
+func FilterModifiedSince(files []string, since time.Time) ([]string, error) {
+	filtered := []string{}
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			continue
+		}
+		if info.ModTime().After(since) {
+			filtered = append(filtered, file)
+		} else if sidecarInfo, err := os.Stat(file + rightscript.SidecarSuffix); err == nil && sidecarInfo.ModTime().After(since) {
+			filtered = append(filtered, file)
+		}
+	}
+	return filtered, nil
+}
+
+// ReadUploadState reads the time of the last successful upload from a state file. A state file that does not exist yet
+// is not an error, the zero time is returned instead so that all files are considered modified.
+func ReadUploadState(stateFile string) (time.Time, error) {
+	data, err := ioutil.ReadFile(stateFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+	timestamp, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(string(data)))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Invalid upload state file %s: %s", stateFile, err.Error())
+	}
+	return timestamp, nil
+}
+
+// WriteUploadState records the time of a successful upload in a state file.
+func WriteUploadState(stateFile string, timestamp time.Time) error {
+	return ioutil.WriteFile(stateFile, []byte(timestamp.UTC().Format(time.RFC3339Nano)+"\n"), 0644)
+}
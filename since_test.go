@@ -0,0 +1,88 @@
+package main_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/rightscale/right_st"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Since", func() {
+	now := time.Date(2016, 7, 4, 12, 0, 0, 0, time.UTC)
+
+	Describe("Parse since", func() {
+		It("Parses a duration relative to now", func() {
+			since, err := ParseSince("24h", now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(since).To(Equal(now.Add(-24 * time.Hour)))
+		})
+
+		It("Parses an RFC 3339 timestamp", func() {
+			since, err := ParseSince("2016-07-01T08:30:00Z", now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(since).To(Equal(time.Date(2016, 7, 1, 8, 30, 0, 0, time.UTC)))
+		})
+
+		It("Returns an error for a negative duration", func() {
+			_, err := ParseSince("-1h", now)
+			Expect(err).To(MatchError("Invalid since value '-1h': duration must not be negative"))
+		})
+
+		It("Returns an error for garbage", func() {
+			_, err := ParseSince("yesterday", now)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("With a temporary directory", func() {
+		var tempDir string
+
+		BeforeEach(func() {
+			var err error
+			tempDir, err = ioutil.TempDir("", "since")
+			if err != nil {
+				panic(err)
+			}
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(tempDir)
+		})
+
+		Describe("Filter modified since", func() {
+			It("Keeps only files modified after the given time", func() {
+				oldFile := filepath.Join(tempDir, "old.sh")
+				newFile := filepath.Join(tempDir, "new.sh")
+				Expect(ioutil.WriteFile(oldFile, []byte("#!/bin/bash\n"), 0644)).To(Succeed())
+				Expect(ioutil.WriteFile(newFile, []byte("#!/bin/bash\n"), 0644)).To(Succeed())
+				Expect(os.Chtimes(oldFile, now.Add(-time.Hour), now.Add(-time.Hour))).To(Succeed())
+				Expect(os.Chtimes(newFile, now.Add(time.Hour), now.Add(time.Hour))).To(Succeed())
+
+				files, err := FilterModifiedSince([]string{tempDir, oldFile, newFile}, now)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(files).To(Equal([]string{newFile}))
+			})
+		})
+
+		Describe("Upload state", func() {
+			It("Returns the zero time for a missing state file", func() {
+				timestamp, err := ReadUploadState(filepath.Join(tempDir, "missing"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(timestamp.IsZero()).To(BeTrue())
+			})
+
+			It("Reads back a written state file", func() {
+				stateFile := filepath.Join(tempDir, "state")
+				Expect(WriteUploadState(stateFile, now)).To(Succeed())
+				timestamp, err := ReadUploadState(stateFile)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(timestamp.Equal(now)).To(BeTrue())
+			})
+		})
+	})
+})
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rightscale/rsc/httpclient"
+	"github.com/rightscale/rsc/rsapi"
+)
+
+// Connection pool settings for the API clients, set by --max-idle-conns-per-host and --keep-alive. Go's stock
+// transport keeps only 2 idle connections per host which is too few for parallel uploads and downloads, so each
+// extra request has to do a new TLS handshake.
+var (
+	MaxIdleConnsPerHost = 16
+	KeepAlive           = 30 * time.Second // a keep-alive of 0 disables keep-alives altogether
+)
+
+// RootCAs set by --ca-cert are the certificate authorities trusted for TLS connections, nil for the system roots.
+var RootCAs *x509.CertPool
+
+// LoadCACert returns the system roots with the PEM encoded certificates in file added, so an internal certificate
+// authority can be trusted without disabling verification.
+func LoadCACert(file string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := systemCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("No PEM encoded certificates found in %s", file)
+	}
+	return pool, nil
+}
+
+// NewTransport returns an HTTP transport which keeps up to maxIdleConnsPerHost idle connections to each host for
+// reuse, or none when keepAlive is 0.
+func NewTransport(maxIdleConnsPerHost int, keepAlive time.Duration) *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		Dial: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: keepAlive,
+		}).Dial,
+		TLSHandshakeTimeout:   10 * time.Second,
+		TLSClientConfig:       &tls.Config{InsecureSkipVerify: httpclient.NoCertCheck, RootCAs: RootCAs},
+		ResponseHeaderTimeout: httpclient.ResponseHeaderTimeout,
+		MaxIdleConnsPerHost:   maxIdleConnsPerHost,
+		DisableKeepAlives:     keepAlive == 0,
+	}
+}
+
+// CheckDumpFlags returns an error when any of the flags in set, which configure the shared pooled transport, are given
+// while HTTP requests are being dumped, since API requests are then sent over rsc's own transport which would silently
+// ignore them.
+func CheckDumpFlags(dumping bool, set []string) error {
+	if !dumping || len(set) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s cannot be used with --debug or --http-dump-file since dumped API requests are not sent over the "+
+		"transport they configure", strings.Join(set, ", "))
+}
+
+// pooledClient sends API requests over a shared transport tuned by the connection pool settings.
+type pooledClient struct {
+	client *http.Client
+}
+
+func (c *pooledClient) Do(req *http.Request) (*http.Response, error) {
+	return c.client.Do(req)
+}
+
+func (c *pooledClient) DoHidden(req *http.Request) (*http.Response, error) {
+	return c.client.Do(req)
+}
+
+var sharedClient *pooledClient
+
+// usePooledClient makes an API client send its requests with RequestContext over the shared pooled transport,
+// retrying rate limited requests, and to BaseURL when there is one. Clients are left with their own transport while HTTP requests are being
+// dumped since only that one knows how to dump them, and without compression so the dumps stay readable; CheckDumpFlags
+// rejects the flags for the pooled transport then.
+func usePooledClient(api *rsapi.API) {
+	if httpclient.DumpFormat == httpclient.NoDump {
+		if sharedClient == nil {
+			sharedClient = &pooledClient{&http.Client{Transport: NewTransport(MaxIdleConnsPerHost, KeepAlive)}}
+		}
+		api.Client = sharedClient
+	}
+	api.Client = &contextClient{api.Client}
+	api.Client = &rateLimitedClient{api.Client}
+	// compressing outside of the rate limit retries means a retried request is sent with the same compressed body
+	if Compression && httpclient.DumpFormat == httpclient.NoDump {
+		api.Client = &gzipClient{api.Client}
+	}
+	// the base URL is applied outside of the retries so it is only applied once
+	if BaseURL != nil {
+		api.Client = &baseURLClient{api.Client}
+	}
+}
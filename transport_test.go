@@ -0,0 +1,108 @@
+package main_test
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"time"
+
+	. "github.com/rightscale/right_st"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Transport", func() {
+	It("Keeps idle connections for reuse", func() {
+		transport := NewTransport(16, 30*time.Second)
+		Expect(transport.MaxIdleConnsPerHost).To(Equal(16))
+		Expect(transport.DisableKeepAlives).To(BeFalse())
+		Expect(transport.Proxy).NotTo(BeNil())
+	})
+
+	It("Disables keep-alives with a keep-alive of 0", func() {
+		transport := NewTransport(16, 0)
+		Expect(transport.DisableKeepAlives).To(BeTrue())
+	})
+
+	Describe("Dump flags", func() {
+		It("Allows the transport flags when requests are not dumped", func() {
+			Expect(CheckDumpFlags(false, []string{"--ca-cert"})).To(Succeed())
+		})
+
+		It("Allows dumping requests without the transport flags", func() {
+			Expect(CheckDumpFlags(true, nil)).To(Succeed())
+		})
+
+		It("Rejects the transport flags when requests are dumped", func() {
+			Expect(CheckDumpFlags(true, []string{"--keep-alive", "--ca-cert"})).To(MatchError(HavePrefix(
+				"--keep-alive, --ca-cert cannot be used with --debug or --http-dump-file")))
+		})
+	})
+
+	Describe("Base URL", func() {
+		It("Sends requests to the base URL with its path prefix", func() {
+			base, err := ParseBaseURL("http://rightscale.example.com:8080/prefix/")
+			Expect(err).NotTo(HaveOccurred())
+			u, err := url.Parse("https://us-3.rightscale.com/api/right_scripts?filter[]=name==foo")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ApplyBaseURL(base, u).String()).To(Equal("http://rightscale.example.com:8080/prefix/api/right_scripts?filter[]=name==foo"))
+		})
+
+		It("Returns an error for a URL which is not absolute", func() {
+			_, err := ParseBaseURL("rightscale.example.com/prefix")
+			Expect(err).To(MatchError(HavePrefix("Invalid base URL 'rightscale.example.com/prefix'")))
+		})
+	})
+
+	Describe("CA certificate", func() {
+		var (
+			server *httptest.Server
+			caFile *os.File
+		)
+
+		BeforeEach(func() {
+			server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			var err error
+			caFile, err = ioutil.TempFile("", "right_st_ca")
+			if err != nil {
+				panic(err)
+			}
+		})
+
+		AfterEach(func() {
+			server.Close()
+			os.Remove(caFile.Name())
+			RootCAs = nil
+		})
+
+		It("Trusts the added certificate authority", func() {
+			certificate := server.TLS.Certificates[0].Certificate[0]
+			if err := pem.Encode(caFile, &pem.Block{Type: "CERTIFICATE", Bytes: certificate}); err != nil {
+				panic(err)
+			}
+			caFile.Close()
+
+			client := &http.Client{Transport: NewTransport(16, 0)}
+			_, err := client.Get(server.URL)
+			Expect(err).To(HaveOccurred())
+
+			RootCAs, err = LoadCACert(caFile.Name())
+			Expect(err).NotTo(HaveOccurred())
+			client = &http.Client{Transport: NewTransport(16, 0)}
+			resp, err := client.Get(server.URL)
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+		})
+
+		It("Returns an error for a file without certificates", func() {
+			caFile.WriteString("not a certificate\n")
+			caFile.Close()
+			_, err := LoadCACert(caFile.Name())
+			Expect(err).To(MatchError(HavePrefix("No PEM encoded certificates found")))
+		})
+	})
+})
@@ -41,8 +41,21 @@ var (
 
 	vvString      = regexp.MustCompile(`^` + regexp.QuoteMeta(app.Name) + ` (v[0-9]+\.[0-9]+\.[0-9]+) -`)
 	versionString = regexp.MustCompile(`^v([0-9]+)\.([0-9]+)\.([0-9]+)$`)
+	vvFields      = regexp.MustCompile(`^\S+ (.*) - (.*) - (.*)$`)
 )
 
+// VersionInfo formats the version, build date, and commit from the version string defined in
+// version.go/version_default.go along with the Go version and platform the executable was built for, so bug reports can
+// identify the exact build in use.
+func VersionInfo(vv string) string {
+	version, date, commit := vv, "unknown", "unknown"
+	if submatches := vvFields.FindStringSubmatch(vv); submatches != nil {
+		version, date, commit = submatches[1], submatches[2], submatches[3]
+	}
+	return fmt.Sprintf("%s %s\nCommit: %s\nBuilt: %s\nGo: %s %s/%s", app.Name, version, commit, date,
+		runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
+
 // UpdateGetCurrentVersion gets the current version struct from the version string defined in
 // version.go/version_default.go.
 func UpdateGetCurrentVersion(vv string) *Version {
@@ -33,6 +33,18 @@ var _ = Describe("Update", func() {
 		})
 	})
 
+	Describe("Version info", func() {
+		It("Includes the version, commit, build date, and Go version", func() {
+			info := VersionInfo("right_st v98.76.54 - 2016-07-04 12:00:00 - 0123456789abcdef")
+			Expect(info).To(Equal("right_st v98.76.54\nCommit: 0123456789abcdef\nBuilt: 2016-07-04 12:00:00\nGo: " +
+				runtime.Version() + " " + runtime.GOOS + "/" + runtime.GOARCH))
+		})
+
+		It("Handles the dev version", func() {
+			Expect(VersionInfo(VV)).To(HavePrefix("right_st dev\nCommit: commit\nBuilt: unknown\n"))
+		})
+	})
+
 	Context("With a update versions URL", func() {
 		var (
 			buffer           *gbytes.Buffer
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/fsnotify.v1"
+
+	"github.com/rightscale/right_st/rightscript"
+)
+
+// Changes are only uploaded once no other change came in for this long, since saving a file often writes it several
+// times and editors may rename and recreate it
+const watchDebounce = 500 * time.Millisecond
+
+// ScriptsForChanges returns the scripts, out of the set of watched scripts, which have to be uploaded again after the
+// files at paths changed: a script itself, its sidecar file, or any file in the attachments directory next to it.
+func ScriptsForChanges(paths []string, scripts map[string]bool) []string {
+	changed := map[string]bool{}
+	for _, path := range paths {
+		script := strings.TrimSuffix(path, rightscript.SidecarSuffix)
+		switch {
+		case scripts[script]:
+			changed[script] = true
+		case filepath.Base(filepath.Dir(path)) == "attachments":
+			dir := filepath.Dir(filepath.Dir(path))
+			for script := range scripts {
+				if filepath.Dir(script) == dir {
+					changed[script] = true
+				}
+			}
+		}
+	}
+	sorted := make([]string, 0, len(changed))
+	for script := range changed {
+		sorted = append(sorted, script)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// isWatchedScript returns whether a file found in a watched directory is a script rather than a sidecar file, an
+// attachment, or something which is ignored when walking directories.
+func isWatchedScript(file string) bool {
+	info, err := os.Stat(file)
+	if err != nil || info.IsDir() || strings.HasSuffix(file, rightscript.SidecarSuffix) ||
+		filepath.Base(filepath.Dir(file)) == "attachments" {
+		return false
+	}
+	return *includeHidden || !IgnoredPath(filepath.Base(file), false)
+}
+
+// rightScriptWatch uploads the scripts in paths each time they, their sidecar files, or their attachments change until
+// it is interrupted. Scripts added to watched directories are picked up as well.
+func rightScriptWatch(paths []string, force bool, nameSeparator string, options rightscript.PushOptions) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		fatalError(exitGeneral, "Could not watch for changes: %s", err.Error())
+	}
+	defer watcher.Close()
+
+	scripts := make(map[string]bool)
+	roots := make(map[string]string)    // path argument each script was found under, for --name-from-path
+	dirRoots := make(map[string]string) // path argument each watched directory was found under
+	dirs := make(map[string]bool)
+	for _, root := range paths {
+		found, err := walkPaths([]string{root})
+		if err != nil {
+			fatalError(exitGeneral, "%s\n", err.Error())
+		}
+		for _, file := range found {
+			if rightscript.IsDirectory(file) {
+				dirs[file] = true
+				dirRoots[file] = root
+				continue
+			}
+			if !isWatchedScript(file) {
+				continue
+			}
+			scripts[file], roots[file] = true, root
+			dirs[filepath.Dir(file)] = true
+			if attachments := filepath.Join(filepath.Dir(file), "attachments"); rightscript.IsDirectory(attachments) {
+				dirs[attachments] = true
+			}
+		}
+	}
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			fatalError(exitGeneral, "Could not watch %s: %s", dir, err.Error())
+		}
+	}
+	fmt.Fprintf(Stdout, "Watching %d RightScripts for changes, press Ctrl-C to stop\n", len(scripts))
+
+	pending := make(map[string]bool)
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-RequestContext.Done():
+			fmt.Fprintln(Stdout, "Stopped watching")
+			return
+		case err := <-watcher.Errors:
+			fmt.Fprintf(Stderr, "WARNING: %s\n", err.Error())
+		case event := <-watcher.Events:
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+				continue
+			}
+			if root, ok := dirRoots[filepath.Dir(event.Name)]; ok && !scripts[event.Name] && isWatchedScript(event.Name) {
+				scripts[event.Name], roots[event.Name] = true, root
+			}
+			pending[event.Name] = true
+			debounce = time.After(watchDebounce)
+		case <-debounce:
+			changed := make([]string, 0, len(pending))
+			for path := range pending {
+				changed = append(changed, path)
+			}
+			pending, debounce = make(map[string]bool), nil
+			for _, file := range ScriptsForChanges(changed, scripts) {
+				// an editor may have moved the file away for good
+				if isWatchedScript(file) {
+					watchUpload(file, roots[file], force, nameSeparator, options)
+				}
+			}
+		}
+	}
+}
+
+// watchUpload uploads a single changed script for --watch and prints the outcome in one line. Failures are only
+// printed so watching carries on.
+func watchUpload(file, root string, force bool, nameSeparator string, options rightscript.PushOptions) {
+	fmt.Fprintf(Stdout, "Uploading %s\n", file)
+	script, err := validateRightScript(file, force)
+	if err == nil {
+		err = rightscript.CheckFileSize(file, options.MaxFileSize)
+	}
+	if err == nil {
+		err = script.CheckAttachmentSizes(options.MaxFileSize)
+	}
+	if err == nil {
+		if nameSeparator != "" && script.Metadata.Comment == "" && script.Metadata.Sidecar == "" {
+			script.Metadata.Name = NameFromPath(root, file, nameSeparator)
+			script.Name = script.Metadata.Name
+		}
+		if options.Strict {
+			var warnings []string
+			if warnings, err = script.Warnings(options.NormalizeEOL, options.SourceEncoding); err == nil && len(warnings) > 0 {
+				err = fmt.Errorf("warnings are errors with --strict: %s", strings.Join(warnings, "; "))
+			}
+		}
+	}
+	if err == nil {
+		err = pushRightScript(script, options)
+	}
+	now := time.Now().Format("15:04:05")
+	if err != nil {
+		fmt.Fprintf(Stderr, "%s %s: ERROR: %s\n", now, file, err.Error())
+		return
+	}
+	if result := script.Result; result != nil {
+		fmt.Fprintf(Stdout, "%s %s: %s %s %s\n", now, file, result.Action, result.Name, result.Href)
+	} else {
+		fmt.Fprintf(Stdout, "%s %s: uploaded\n", now, file)
+	}
+}
@@ -0,0 +1,35 @@
+package main_test
+
+import (
+	"path/filepath"
+
+	. "github.com/rightscale/right_st"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Watching for changes", func() {
+	scripts := map[string]bool{
+		filepath.Join("scripts", "a.sh"):          true,
+		filepath.Join("scripts", "b.sh"):          true,
+		filepath.Join("scripts", "other", "c.sh"): true,
+	}
+
+	It("Uploads a script when it or its sidecar file changes", func() {
+		Expect(ScriptsForChanges([]string{filepath.Join("scripts", "b.sh")}, scripts)).To(Equal(
+			[]string{filepath.Join("scripts", "b.sh")}))
+		Expect(ScriptsForChanges([]string{filepath.Join("scripts", "a.sh.meta.yml"), filepath.Join("scripts", "a.sh")},
+			scripts)).To(Equal([]string{filepath.Join("scripts", "a.sh")}))
+	})
+
+	It("Uploads every script next to an attachments directory when an attachment changes", func() {
+		Expect(ScriptsForChanges([]string{filepath.Join("scripts", "attachments", "file.txt")}, scripts)).To(Equal(
+			[]string{filepath.Join("scripts", "a.sh"), filepath.Join("scripts", "b.sh")}))
+	})
+
+	It("Ignores changes to other files", func() {
+		Expect(ScriptsForChanges([]string{filepath.Join("scripts", "notes.txt"), filepath.Join("elsewhere", "a.sh")},
+			scripts)).To(BeEmpty())
+	})
+})